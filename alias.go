@@ -0,0 +1,27 @@
+package fsm
+
+// AddAlias adds a former name of the state, so that states persisted before a rename
+// are still found by StateByName and FromStateName. Current state names take precedence over aliases.
+func (s *State) AddAlias(name string) *State {
+	s.machine.mustBeMutable()
+	s.aliases = append(s.aliases, name)
+	if s.machine.byAlias == nil {
+		s.machine.byAlias = map[string]*State{}
+	}
+	s.machine.byAlias[s.machine.nameKey(name)] = s
+	return s
+}
+
+// Aliases returns the former names of the state
+func (s *State) Aliases() []string {
+	return append([]string(nil), s.aliases...)
+}
+
+// stateByAlias gets the state with the specified former name, ignoring states that were overridden
+func (s *StateMachine) stateByAlias(name string) *State {
+	st, ok := s.byAlias[s.nameKey(name)]
+	if !ok || s.byName[s.nameKey(st.name)] != st {
+		return nil
+	}
+	return st
+}
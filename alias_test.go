@@ -0,0 +1,35 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlias(t *testing.T) {
+	sm := fsm.New()
+	pending := sm.AddState("PENDING").AddAlias("NEW").AddAlias("CREATED")
+	sm.AddState("CREATED")
+
+	smi, err := sm.FromStateName("NEW")
+	require.NoError(t, err)
+	require.Equal(t, pending, smi.State())
+	require.Equal(t, []string{"NEW", "CREATED"}, pending.Aliases())
+	// names win over aliases
+	require.Equal(t, "CREATED", sm.StateByName("CREATED").Name())
+
+	_, err = sm.FromStateName("OLD")
+	require.ErrorIs(t, err, fsm.ErrUnknownState)
+}
+
+func TestAliasFromDefinition(t *testing.T) {
+	data := []byte(`{"states":[{"name":"PENDING","aliases":["NEW"]}]}`)
+	sm, err := fsm.LoadDefinition(data, fsm.HandlerRegistry{}, fsm.NamingPolicy(fsm.Prefix("t1/")))
+	require.NoError(t, err)
+	require.Equal(t, "t1/PENDING", sm.StateByName("t1/NEW").Name())
+
+	def, err := sm.Definition()
+	require.NoError(t, err)
+	require.Equal(t, []string{"t1/NEW"}, def.States[0].Aliases)
+}
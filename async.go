@@ -0,0 +1,95 @@
+package fsm
+
+import "context"
+
+// AsyncFailure is the event delivered when an asynchronous handler fails.
+// Its Kind is the failure event key and Err the error returned by the handler.
+type AsyncFailure struct {
+	Key interface{}
+	Err error
+}
+
+func (f AsyncFailure) Kind() interface{} {
+	return f.Key
+}
+
+type asyncHandler struct {
+	fn     OnHandler
+	done   interface{}
+	failed interface{}
+}
+
+// asyncWork tracks the asynchronous handler running for the current state of an instance
+type asyncWork struct {
+	// gen is incremented every time the work is replaced, invalidating the pending completions
+	gen    uint64
+	cancel context.CancelFunc
+}
+
+// OnEventAsync option sets a handler run in its own goroutine when an instance enters the state.
+// The transition completes immediately and, when the handler returns, the done event is fired into the instance,
+// or, if it fails, the failed event as an AsyncFailure.
+// The context of the handler, c.Context(), is cancelled when the instance leaves the state or is stopped,
+// and then the completion is discarded.
+// Errors firing the completion are passed to the onError of Start, if any.
+// Async handlers only run in instances, when an event moves the instance to the state,
+// and are restarted by Start, for example after a Restore, if not running.
+func OnEventAsync(fn OnHandler, done, failed interface{}) func(*State) {
+	return func(s *State) {
+		s.async = &asyncHandler{
+			fn:     fn,
+			done:   done,
+			failed: failed,
+		}
+	}
+}
+
+// startAsync cancels the running asynchronous handler, if any, and starts the one of the current state.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) startAsync(c *Context) {
+	m.stopAsync()
+	h := m.currentState.async
+	if h == nil {
+		return
+	}
+	goCtx, cancel := context.WithCancel(m.withValues(context.Background()))
+	m.async.cancel = cancel
+	gen := m.async.gen
+	onError := m.scheduler.onError
+	ac := &Context{
+		machine:  m.StateMachine,
+		instance: m,
+		context:  goCtx,
+		event:    toEventer(nil),
+		to:       m.currentState,
+	}
+	if c != nil {
+		ac.event = c.eventer()
+		ac.from = c.from
+	}
+	go func() {
+		var event interface{} = h.done
+		if err := h.fn(ac); err != nil {
+			event = AsyncFailure{Key: h.failed, Err: err}
+		}
+		if goCtx.Err() != nil {
+			return
+		}
+		err := m.fireWhen(nil, func() bool {
+			return m.async.gen == gen
+		}, event)
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}()
+}
+
+// stopAsync cancels the running asynchronous handler, if any.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) stopAsync() {
+	m.async.gen++
+	if m.async.cancel != nil {
+		m.async.cancel()
+		m.async.cancel = nil
+	}
+}
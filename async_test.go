@@ -0,0 +1,90 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func paymentFSM(work func(c *fsm.Context) error) (*fsm.StateMachine, map[string]*fsm.State) {
+	sm := fsm.New()
+	states := map[string]*fsm.State{}
+	states["CART"] = sm.AddState("CART")
+	states["PAYING"] = sm.AddState("PAYING", fsm.OnEventAsync(work, "PAID", "DECLINED"))
+	states["PAID"] = sm.AddState("PAID")
+	states["FAILED"] = sm.AddState("FAILED", fsm.OnEnter(func(c *fsm.Context) error {
+		failure := c.Data().(fsm.AsyncFailure)
+		if failure.Err.Error() != "card declined" {
+			return errors.New("unexpected failure")
+		}
+		return nil
+	}))
+	states["CART"].AddTransition(SUBMIT, states["PAYING"])
+	states["PAYING"].AddTransition("PAID", states["PAID"])
+	states["PAYING"].AddTransition("DECLINED", states["FAILED"])
+	states["PAYING"].AddTransition(CANCEL, states["CART"])
+	return sm, states
+}
+
+func TestAsyncHandler(t *testing.T) {
+	release := make(chan struct{})
+	sm, states := paymentFSM(func(c *fsm.Context) error {
+		<-release
+		return nil
+	})
+	smi := sm.FromState(states["CART"])
+	require.NoError(t, smi.Fire(SUBMIT))
+	require.Equal(t, states["PAYING"], smi.State())
+
+	close(release)
+	require.Eventually(t, func() bool {
+		return smi.State() == states["PAID"]
+	}, time.Second, time.Millisecond)
+}
+
+func TestAsyncHandlerFailure(t *testing.T) {
+	sm, states := paymentFSM(func(c *fsm.Context) error {
+		return errors.New("card declined")
+	})
+	smi := sm.FromState(states["CART"])
+	require.NoError(t, smi.Fire(SUBMIT))
+	require.Eventually(t, func() bool {
+		return smi.State() == states["FAILED"]
+	}, time.Second, time.Millisecond)
+}
+
+func TestAsyncHandlerCancelled(t *testing.T) {
+	cancelled := make(chan struct{})
+	sm, states := paymentFSM(func(c *fsm.Context) error {
+		<-c.Context().Done()
+		close(cancelled)
+		return nil
+	})
+	smi := sm.FromState(states["CART"])
+	require.NoError(t, smi.Fire(SUBMIT))
+	require.NoError(t, smi.Fire(CANCEL))
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("async handler was not cancelled")
+	}
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, states["CART"], smi.State())
+}
+
+func TestAsyncHandlerRestartedByStart(t *testing.T) {
+	sm, states := paymentFSM(func(c *fsm.Context) error {
+		return nil
+	})
+	smi, err := sm.Restore(fsm.Snapshot{State: "PAYING"})
+	require.NoError(t, err)
+	smi.Start(nil)
+	defer smi.Stop()
+	require.Eventually(t, func() bool {
+		return smi.State() == states["PAID"]
+	}, time.Second, time.Millisecond)
+}
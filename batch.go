@@ -0,0 +1,194 @@
+package fsm
+
+import (
+	"context"
+	"time"
+)
+
+// Result describes the outcome of an event of a batch
+type Result struct {
+	Key  interface{}
+	From *State
+	To   *State
+}
+
+// FireBatch fires the events in order, holding the instance lock once for the whole batch
+// and rescheduling the timeout transitions only at the end.
+// It stops at the first failure, returning the results of the events applied so far and the error.
+// With WithTransaction, the batch runs in one transaction, committed with the events applied before a failure.
+// If the instance cannot be saved, it is restored as it was before the batch.
+func (m *StateMachineInstance) FireBatch(events []interface{}) ([]Result, error) {
+	return m.fireBatch(events, false)
+}
+
+// FireAll fires the events in order, like FireBatch, stopping at the first failure.
+// It returns the number of applied events.
+func (m *StateMachineInstance) FireAll(events ...interface{}) (int, error) {
+	results, err := m.FireBatch(events)
+	return len(results), err
+}
+
+// FireAllAtomic is like FireAll, but either applies all the events or none.
+// When an event fails, the instance is restored as it was before the batch, with its sub-machine,
+// deferred events, deadlines, idempotency keys, history, statistics, quota slots and occupancy,
+// nothing is persisted or appended to the event log, and it returns zero and the error.
+// With WithTransaction, the batch runs in one transaction, rolled back on failure.
+// Other side effects of the handlers of the applied events are not undone.
+func (m *StateMachineInstance) FireAllAtomic(events ...interface{}) (int, error) {
+	results, err := m.fireBatch(events, true)
+	return len(results), err
+}
+
+func (m *StateMachineInstance) fireBatch(events []interface{}, atomic bool) ([]Result, error) {
+	results, exceeded, err := m.fireBatchLocked(events, atomic)
+	if exceeded {
+		m.onStepBudgetExceeded(m)
+	}
+	return results, err
+}
+
+// fireBatchLocked fires the events holding the lock, also reporting if the step budget was crossed.
+// The lock is released even if a handler panics.
+func (m *StateMachineInstance) fireBatchLocked(events []interface{}, atomic bool) ([]Result, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	before := m.steps
+	start := m.currentState
+	var sp *savepoint
+	if atomic || m.transactor != nil || m.store != nil {
+		sp = m.savepoint()
+	}
+	results := make([]Result, 0, len(events))
+	var err error
+	saved := false
+	terr := m.transact(nil, func(goCtx context.Context) error {
+		saved = false
+		for _, e := range events {
+			from := m.currentState
+			if err = m.advance(goCtx, e); err != nil {
+				break
+			}
+			results = append(results, Result{
+				Key:  toEventer(e).Kind(),
+				From: from,
+				To:   m.currentState,
+			})
+		}
+		if err != nil && atomic {
+			return err
+		}
+		if m.steps != before {
+			if perr := m.persist(goCtx); perr != nil {
+				return perr
+			}
+		}
+		saved = true
+		if serr := m.syncTimers(goCtx); serr != nil {
+			return serr
+		}
+		return m.flushLog(goCtx)
+	})
+	if err == nil {
+		err = terr
+	}
+	// a batch that could not be saved is undone, so that the instance does not diverge from the store
+	if err != nil && atomic || terr != nil && (m.transactor != nil || !saved) {
+		m.restoreSavepoint(sp)
+		results = results[:0]
+	}
+	m.logs.entries = nil
+	if serr := m.commitSlots(nil); serr != nil && err == nil {
+		err = serr
+	}
+	if m.currentState != start {
+		m.schedule()
+	}
+	return results, m.budgetCrossed(before), err
+}
+
+// savepoint is the in memory state of an instance, restored when an atomic batch fails or a Fire cannot be saved
+type savepoint struct {
+	state      *State
+	steps      int
+	history    history
+	deferred   []interface{}
+	lastActive map[*State]*State
+	deadlines  map[string]time.Time
+	seen       seenKeys
+	sagaTrail  []sagaStep
+	timerOps   []timerOp
+	slots      int
+	logs       int
+	sub        *StateMachineInstance
+	subPoint   *savepoint
+	stats      dwellStats
+}
+
+// savepoint must be called while holding the lock
+func (m *StateMachineInstance) savepoint() *savepoint {
+	sp := &savepoint{
+		state:     m.currentState,
+		steps:     m.steps,
+		history:   m.history.clone(),
+		deferred:  append([]interface{}(nil), m.deferred...),
+		seen:      seenKeys{order: m.seen.order.clone()},
+		sagaTrail: append([]sagaStep(nil), m.sagaTrail...),
+		timerOps:  m.timerOps[:len(m.timerOps):len(m.timerOps)],
+		slots:     len(m.slots.ops),
+		logs:      len(m.logs.entries),
+		sub:       m.sub,
+		stats:     m.stats.clone(),
+	}
+	if m.lastActive != nil {
+		sp.lastActive = make(map[*State]*State, len(m.lastActive))
+		for k, v := range m.lastActive {
+			sp.lastActive[k] = v
+		}
+	}
+	if m.deadlines != nil {
+		sp.deadlines = make(map[string]time.Time, len(m.deadlines))
+		for k, v := range m.deadlines {
+			sp.deadlines[k] = v
+		}
+	}
+	if m.seen.set != nil {
+		sp.seen.set = make(map[string]struct{}, len(m.seen.set))
+		for k := range m.seen.set {
+			sp.seen.set[k] = struct{}{}
+		}
+	}
+	if m.sub != nil {
+		sp.subPoint = m.sub.savepoint()
+	}
+	return sp
+}
+
+// restoreSavepoint restores the instance, rearming the timers of its deadlines and its asynchronous handler,
+// releasing the quota slots taken since the savepoint and discarding the events accepted since it.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) restoreSavepoint(sp *savepoint) {
+	m.monitor.track(m.id, sp.state)
+	m.moveOccupancy(m.currentState, sp.state)
+	m.slots.rollback(context.Background(), sp.slots)
+	m.logs.entries = m.logs.entries[:sp.logs]
+	moved := m.currentState != sp.state
+	m.currentState = sp.state
+	m.steps = sp.steps
+	m.history = sp.history
+	m.monitor.deferredChanged(len(sp.deferred) - len(m.deferred))
+	m.deferred = sp.deferred
+	m.lastActive = sp.lastActive
+	m.deadlines = sp.deadlines
+	m.seen = sp.seen
+	m.sagaTrail = sp.sagaTrail
+	m.stats = sp.stats
+	m.timerOps = sp.timerOps
+	m.sub = sp.sub
+	if m.sub != nil {
+		m.sub.restoreSavepoint(sp.subPoint)
+	}
+	m.scheduleSLAs()
+	if moved {
+		m.startAsync(nil)
+	}
+}
@@ -0,0 +1,149 @@
+package fsm_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFireBatch(t *testing.T) {
+	smi, states, _, err := createFSM()
+	require.NoError(t, err)
+
+	results, err := smi.FireBatch([]interface{}{TICK, TICK, LOOP})
+	require.NoError(t, err)
+	require.Equal(t, []fsm.Result{
+		{Key: TICK, From: states.green, To: states.yellow},
+		{Key: TICK, From: states.yellow, To: states.red},
+		{Key: LOOP, From: states.red, To: states.red},
+	}, results)
+	require.Equal(t, states.red, smi.State())
+}
+
+func TestFireBatchStopsOnError(t *testing.T) {
+	smi, states, _, err := createFSM()
+	require.NoError(t, err)
+
+	results, err := smi.FireBatch([]interface{}{TICK, TICK, "UNKNOWN", TICK})
+	require.Error(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, states.red, smi.State())
+}
+
+func TestFireAll(t *testing.T) {
+	smi, states, _, err := createFSM()
+	require.NoError(t, err)
+
+	n, err := smi.FireAll(TICK, TICK, "UNKNOWN", TICK)
+	require.ErrorIs(t, err, fsm.ErrUnknownTransition)
+	require.Equal(t, 2, n)
+	require.Equal(t, states.red, smi.State())
+}
+
+func TestFireAllAtomic(t *testing.T) {
+	smi, states, _, err := createFSM()
+	require.NoError(t, err)
+	smi.AutoPersist(fsm.NewMemoryStore(), 0)
+
+	n, err := smi.FireAllAtomic(fsm.WithIdempotencyKey(TICK, "k1"), TICK, "UNKNOWN")
+	require.ErrorIs(t, err, fsm.ErrUnknownTransition)
+	require.Zero(t, n)
+	require.Equal(t, states.green, smi.State())
+	require.Empty(t, smi.History())
+	require.EqualValues(t, 0, smi.Version(), "a failed batch is not persisted")
+	// the idempotency key of the rolled back event is forgotten
+	require.NoError(t, smi.Fire(fsm.WithIdempotencyKey(TICK, "k1")))
+	require.Equal(t, states.yellow, smi.State())
+
+	n, err = smi.FireAllAtomic(TICK, LOOP)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, states.red, smi.State())
+	require.EqualValues(t, 2, smi.Version())
+}
+
+func TestFireAllAtomicReleasesQuota(t *testing.T) {
+	quota := fsm.NewMemoryQuota(map[string]int{"PICKING": 1})
+	sm, pending, picking, _ := quotaFSM(quota)
+
+	smi := sm.FromState(pending)
+	_, err := smi.FireAllAtomic(TICK, "UNKNOWN")
+	require.ErrorIs(t, err, fsm.ErrUnknownTransition)
+	require.Equal(t, pending, smi.State())
+	require.Equal(t, 0, quota.Count("PICKING"))
+
+	other := sm.FromState(pending)
+	require.NoError(t, other.Fire(TICK))
+	require.Equal(t, picking, other.State())
+}
+
+type occupancy struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (o *occupancy) Transitioned(from, to, event string)                    {}
+func (o *occupancy) Handled(kind, state string, d time.Duration, err error) {}
+func (o *occupancy) Failed(state, event string, err error)                  {}
+
+func (o *occupancy) Occupancy(state string, delta int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.counts[state] += delta
+}
+
+func TestFireAllAtomicRestoresOccupancy(t *testing.T) {
+	metrics := &occupancy{counts: map[string]int{}}
+	sm := fsm.New(fsm.WithMetrics(metrics))
+	green := sm.AddState("GREEN")
+	yellow := sm.AddState("YELLOW")
+	red := sm.AddState("RED")
+	green.AddTransition(TICK, yellow)
+	yellow.AddTransition(TICK, red)
+
+	smi := sm.FromState(green)
+	_, err := smi.FireAllAtomic(TICK, TICK, "UNKNOWN")
+	require.ErrorIs(t, err, fsm.ErrUnknownTransition)
+	require.Equal(t, map[string]int{"GREEN": 1, "YELLOW": 0, "RED": 0}, metrics.counts)
+}
+
+func TestFireAllAtomicDiscardsEvents(t *testing.T) {
+	log := fsm.NewMemoryEventLog()
+	sm := fsm.New(fsm.WithEventLog(log))
+	green := sm.AddState("GREEN")
+	yellow := sm.AddState("YELLOW")
+	green.AddTransition(TICK, yellow)
+
+	smi := sm.FromState(green)
+	smi.SetID("light-1")
+	_, err := smi.FireAllAtomic(TICK, "UNKNOWN")
+	require.ErrorIs(t, err, fsm.ErrUnknownTransition)
+	require.Empty(t, log.Events("light-1"))
+
+	_, err = smi.FireAll(TICK, "UNKNOWN")
+	require.ErrorIs(t, err, fsm.ErrUnknownTransition)
+	// the events applied before the failure are recorded
+	require.Len(t, log.Events("light-1"), 1)
+}
+
+func TestPanicReleasesLock(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.OnEnter(func(*fsm.Context) error {
+		panic("boom")
+	}))
+	a.AddTransition("go", b)
+
+	smi := sm.FromState(a)
+	require.Panics(t, func() {
+		_ = smi.Fire("go")
+	})
+	require.Panics(t, func() {
+		_, _ = smi.FireAll("go")
+	})
+	// the instance is still usable
+	require.Equal(t, a, smi.State())
+}
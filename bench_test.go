@@ -0,0 +1,85 @@
+package fsm_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func pingPong() (*fsm.StateMachine, *fsm.State) {
+	sm := fsm.New()
+	ping := sm.AddState("PING")
+	pong := sm.AddState("PONG")
+	ping.AddTransition(TICK, pong)
+	pong.AddTransition(TICK, ping)
+	return sm.Freeze(), ping
+}
+
+func TestFireDoesNotAllocate(t *testing.T) {
+	sm, ping := pingPong()
+	smi := sm.FromState(ping)
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = smi.Fire(TICK)
+	})
+	require.Zero(t, allocs)
+}
+
+func TestSharedDefinition(t *testing.T) {
+	sm, ping := pingPong()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			smi := sm.FromState(ping)
+			for j := 0; j < 101; j++ {
+				if err := smi.Fire(TICK); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+			if smi.State().Name() != "PONG" {
+				t.Errorf("expected PONG, got %s", smi.State())
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkFire(b *testing.B) {
+	sm, ping := pingPong()
+	smi := sm.FromState(ping)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = smi.Fire(TICK)
+	}
+}
+
+func BenchmarkFireSharedDefinition(b *testing.B) {
+	sm, ping := pingPong()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		smi := sm.FromState(ping)
+		for pb.Next() {
+			_ = smi.Fire(TICK)
+		}
+	})
+}
+
+func BenchmarkStateByName(b *testing.B) {
+	sm := fsm.New()
+	for i := 0; i < 1000; i++ {
+		sm.AddState(fmt.Sprintf("S%d", i))
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sinkState = sm.StateByName("S999")
+	}
+}
+
+var sinkState *fsm.State
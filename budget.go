@@ -0,0 +1,125 @@
+package fsm
+
+import (
+	"fmt"
+	"time"
+)
+
+type ErrBudgetExceeded struct {
+	handlers int
+	elapsed  time.Duration
+	path     []*State
+}
+
+func (e *ErrBudgetExceeded) Error() string {
+	return fmt.Sprintf("fire budget exceeded after %d handlers in %s, path: %v", e.handlers, e.elapsed, e.path)
+}
+
+// Handlers returns the number of handlers executed before aborting
+func (e *ErrBudgetExceeded) Handlers() int {
+	return e.handlers
+}
+
+// Elapsed returns the time spent before aborting
+func (e *ErrBudgetExceeded) Elapsed() time.Duration {
+	return e.elapsed
+}
+
+// Path returns the states visited before aborting, starting with the state where the Fire started
+func (e *ErrBudgetExceeded) Path() []*State {
+	return e.path
+}
+
+// FireBudget option limits the work done by a single Fire, including chained transitions.
+// maxHandlers limits the number of handlers executed and maxDuration the wall time.
+// Zero means no limit. Exceeding the budget aborts the Fire with ErrBudgetExceeded.
+func FireBudget(maxHandlers int, maxDuration time.Duration) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.maxHandlers = maxHandlers
+		s.maxFireDuration = maxDuration
+	}
+}
+
+// fireRun tracks the work done by a Fire
+type fireRun struct {
+	start    time.Time
+	handlers int
+	path     []*State
+	// depth is the number of nested chained Fires
+	depth int
+	// accepted are the events of the transitions, for the event log
+	accepted []EventRecord
+}
+
+func newFireRun(state *State) *fireRun {
+	return &fireRun{
+		start: time.Now(),
+		path:  []*State{state},
+	}
+}
+
+func (r *fireRun) visit(state *State) {
+	r.path = append(r.path, state)
+}
+
+// call executes the handler if the fire budget allows it, retrying the OnEnter and OnEvent handlers
+// of states with a retry policy.
+// kind is the kind of handler, like OnEnter, used for tracing.
+// On Strict machines, a handler misusing the Context fails with the misuse error.
+func (s *StateMachine) call(kind string, handler OnHandler, ctx *Context) error {
+	var err error
+	if policy := ctx.ToState().retry; policy != nil && (kind == "OnEnter" || kind == "OnEvent") {
+		err = s.retrying(kind, handler, ctx, policy)
+	} else {
+		err = s.callOnce(kind, handler, ctx)
+	}
+	if err == nil && ctx.misuse != nil {
+		err = ctx.misuse
+		ctx.misuse = nil
+	}
+	return err
+}
+
+// callOnce executes the handler if the fire budget allows it
+func (s *StateMachine) callOnce(kind string, handler OnHandler, ctx *Context) error {
+	r := ctx.run
+	elapsed := time.Since(r.start)
+	if (s.maxHandlers > 0 && r.handlers >= s.maxHandlers) || (s.maxFireDuration > 0 && elapsed > s.maxFireDuration) {
+		path := make([]*State, len(r.path))
+		copy(path, r.path)
+		return &ErrBudgetExceeded{
+			handlers: r.handlers,
+			elapsed:  elapsed,
+			path:     path,
+		}
+	}
+	r.handlers++
+	if err := s.chaos.inject(s.rnd); err != nil {
+		return err
+	}
+	state := ctx.ToState()
+	if kind == "OnExit" {
+		state = ctx.FromState()
+	}
+	if s.tracer == nil && s.metrics == nil && s.logger == nil {
+		return s.invoke(kind, state, handler, ctx)
+	}
+	start := time.Now()
+	err := s.invoke(kind, state, handler, ctx)
+	d := time.Since(start)
+	if s.tracer != nil {
+		s.tracer.record(Span{
+			Kind:     kind,
+			State:    state.Name(),
+			Start:    start,
+			Duration: d,
+		})
+	}
+	if s.metrics != nil {
+		s.metrics.Handled(kind, state.name, d, err)
+	}
+	if err != nil && s.logger != nil {
+		s.logger.Error("fsm: handler failed", "handler", kind, "state", state.name, "event", ctx.Key(), "error", err)
+	}
+	return err
+}
@@ -0,0 +1,46 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFireBudgetHandlers(t *testing.T) {
+	sm := fsm.New(fsm.FireBudget(5, 0))
+	idle := sm.AddState("IDLE")
+	bounce := sm.AddState("BOUNCE", fsm.OnEvent(func(c *fsm.Context) error {
+		return c.Fire(LOOP)
+	}))
+	idle.AddTransition(TICK, bounce)
+	bounce.AddTransition(LOOP, bounce)
+
+	smi := sm.FromState(idle)
+	err := smi.Fire(TICK)
+	var budget *fsm.ErrBudgetExceeded
+	require.ErrorAs(t, err, &budget)
+	require.Equal(t, 5, budget.Handlers())
+	require.Equal(t, []*fsm.State{idle, bounce, bounce, bounce, bounce, bounce, bounce}, budget.Path())
+	require.Equal(t, idle, smi.State())
+}
+
+func TestFireBudgetDuration(t *testing.T) {
+	sm := fsm.New(fsm.FireBudget(0, 5*time.Millisecond))
+	idle := sm.AddState("IDLE")
+	slow := sm.AddState("SLOW",
+		fsm.OnEnter(func(c *fsm.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		}),
+		fsm.OnEvent(func(c *fsm.Context) error {
+			return nil
+		}),
+	)
+	idle.AddTransition(TICK, slow)
+
+	var budget *fsm.ErrBudgetExceeded
+	require.ErrorAs(t, sm.FromState(idle).Fire(TICK), &budget)
+	require.Equal(t, 1, budget.Handlers())
+}
@@ -0,0 +1,10 @@
+package fsm
+
+// Candidate returns the transition whose condition is being evaluated, so that guards can decide,
+// or log, based on the target. Outside of a condition it returns false.
+func (c *Context) Candidate() (TransitionInfo, bool) {
+	if c.candidate == nil {
+		return TransitionInfo{}, false
+	}
+	return c.candidate.info(), true
+}
@@ -0,0 +1,37 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCandidate(t *testing.T) {
+	var seen []string
+	sm := fsm.New()
+	pending := sm.AddState("PENDING")
+	sm.AddState("FLAGGED")
+	approved := sm.AddState("APPROVED", fsm.OnEnter(func(c *fsm.Context) error {
+		_, ok := c.Candidate()
+		require.False(t, ok)
+		return nil
+	}))
+	// only transitions to APPROVED require a low amount
+	guard := func(c *fsm.Context) bool {
+		tr, ok := c.Candidate()
+		require.True(t, ok)
+		seen = append(seen, tr.Name+"->"+tr.To.Name())
+		return tr.To != approved || c.Data().(*fsm.Event).Data.(int) < 100
+	}
+	pending.AddConditionalTransition("auto", approved, guard)
+	pending.AddConditionalTransition("manual", sm.StateByName("FLAGGED"), guard)
+
+	next, err := sm.Fire(pending, 500)
+	require.NoError(t, err)
+	require.Equal(t, "FLAGGED", next.Name())
+	next, err = sm.Fire(pending, 50)
+	require.NoError(t, err)
+	require.Equal(t, approved, next)
+	require.Equal(t, []string{"auto->APPROVED", "manual->FLAGGED", "auto->APPROVED"}, seen)
+}
@@ -0,0 +1,42 @@
+package fsm
+
+import "fmt"
+
+type ErrMaxChainDepth struct {
+	depth int
+	path  []*State
+}
+
+func (e *ErrMaxChainDepth) Error() string {
+	return fmt.Sprintf("maximum chain depth of %d exceeded, path: %v", e.depth, e.path)
+}
+
+// Depth returns the configured maximum depth
+func (e *ErrMaxChainDepth) Depth() int {
+	return e.depth
+}
+
+// Path returns the states visited before aborting, starting with the state where the Fire started
+func (e *ErrMaxChainDepth) Path() []*State {
+	return e.path
+}
+
+// WithMaxChainDepth option limits the number of nested transitions fired from handlers, with Context.Fire,
+// in a single Fire. Exceeding it aborts the Fire with ErrMaxChainDepth.
+// Zero means no limit.
+func WithMaxChainDepth(n int) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.maxChainDepth = n
+	}
+}
+
+// enterChain accounts for a chained Fire, returning a function to leave it
+func (s *StateMachine) enterChain(r *fireRun) (func(), error) {
+	if s.maxChainDepth > 0 && r.depth >= s.maxChainDepth {
+		path := make([]*State, len(r.path))
+		copy(path, r.path)
+		return nil, &ErrMaxChainDepth{depth: s.maxChainDepth, path: path}
+	}
+	r.depth++
+	return func() { r.depth-- }, nil
+}
@@ -0,0 +1,41 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxChainDepth(t *testing.T) {
+	sm := fsm.New(fsm.WithMaxChainDepth(3))
+	idle := sm.AddState("IDLE")
+	bounce := sm.AddState("BOUNCE", fsm.OnEvent(func(c *fsm.Context) error {
+		return c.Fire(LOOP)
+	}))
+	idle.AddTransition(TICK, bounce)
+	bounce.AddTransition(LOOP, bounce)
+
+	smi := sm.FromState(idle)
+	err := smi.Fire(TICK)
+	var chain *fsm.ErrMaxChainDepth
+	require.ErrorAs(t, err, &chain)
+	require.Equal(t, 3, chain.Depth())
+	require.Equal(t, []*fsm.State{idle, bounce, bounce, bounce, bounce}, chain.Path())
+	require.Equal(t, idle, smi.State())
+}
+
+func TestMaxChainDepthNotExceeded(t *testing.T) {
+	sm := fsm.New(fsm.WithMaxChainDepth(1))
+	idle := sm.AddState("IDLE")
+	bounce := sm.AddState("BOUNCE", fsm.OnEvent(func(c *fsm.Context) error {
+		return c.Fire(LOOP)
+	}))
+	done := sm.AddState("DONE")
+	idle.AddTransition(TICK, bounce)
+	bounce.AddTransition(LOOP, done)
+
+	smi := sm.FromState(idle)
+	require.NoError(t, smi.Fire(TICK))
+	require.Equal(t, done, smi.State())
+}
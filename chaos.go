@@ -0,0 +1,66 @@
+package fsm
+
+import (
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// ErrChaos is the error injected by the chaos layer
+var ErrChaos = errors.New("chaos: injected handler failure")
+
+// Chaos injects faults, to verify that retries, compensations and watchdogs recover a workflow.
+// Decisions use the machine random source, so runs are reproducible with RandSeed.
+// It starts disabled.
+type Chaos struct {
+	// ErrorRate is the probability, from 0 to 1, of a handler failing with ErrChaos instead of running
+	ErrorRate float64
+	// DelayRate is the probability of a handler being delayed by Delay
+	DelayRate float64
+	Delay     time.Duration
+	// DropTimerRate is the probability of a timeout transition firing being dropped
+	DropTimerRate float64
+
+	enabled int32
+}
+
+// WithChaos option sets the fault injection layer
+func WithChaos(c *Chaos) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.chaos = c
+	}
+}
+
+// Enable starts injecting faults
+func (c *Chaos) Enable() {
+	atomic.StoreInt32(&c.enabled, 1)
+}
+
+// Disable stops injecting faults
+func (c *Chaos) Disable() {
+	atomic.StoreInt32(&c.enabled, 0)
+}
+
+func (c *Chaos) active() bool {
+	return c != nil && atomic.LoadInt32(&c.enabled) == 1
+}
+
+// inject delays and/or fails a handler execution
+func (c *Chaos) inject(rnd *rand.Rand) error {
+	if !c.active() {
+		return nil
+	}
+	if c.DelayRate > 0 && rnd.Float64() < c.DelayRate {
+		time.Sleep(c.Delay)
+	}
+	if c.ErrorRate > 0 && rnd.Float64() < c.ErrorRate {
+		return ErrChaos
+	}
+	return nil
+}
+
+// dropTimer decides if a timer firing is dropped
+func (c *Chaos) dropTimer(rnd *rand.Rand) bool {
+	return c.active() && c.DropTimerRate > 0 && rnd.Float64() < c.DropTimerRate
+}
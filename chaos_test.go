@@ -0,0 +1,59 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaosErrors(t *testing.T) {
+	chaos := &fsm.Chaos{ErrorRate: 1}
+	sm := fsm.New(fsm.WithChaos(chaos))
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.OnEnter(func(c *fsm.Context) error {
+		return nil
+	}))
+	a.AddTransition(TICK, b)
+
+	// disabled by default
+	require.NoError(t, sm.FromState(a).Fire(TICK))
+
+	chaos.Enable()
+	smi := sm.FromState(a)
+	require.ErrorIs(t, smi.Fire(TICK), fsm.ErrChaos)
+	require.Equal(t, a, smi.State())
+
+	chaos.Disable()
+	require.NoError(t, smi.Fire(TICK))
+}
+
+func TestChaosDelay(t *testing.T) {
+	chaos := &fsm.Chaos{DelayRate: 1, Delay: 10 * time.Millisecond}
+	chaos.Enable()
+	sm := fsm.New(fsm.WithChaos(chaos))
+	a := sm.AddState("A", fsm.OnEvent(func(c *fsm.Context) error {
+		return nil
+	}))
+	a.AddTransition(TICK, a)
+
+	start := time.Now()
+	require.NoError(t, sm.FromState(a).Fire(TICK))
+	require.True(t, time.Since(start) >= 10*time.Millisecond)
+}
+
+func TestChaosDropTimer(t *testing.T) {
+	chaos := &fsm.Chaos{DropTimerRate: 1}
+	chaos.Enable()
+	sm := fsm.New(fsm.WithChaos(chaos))
+	waiting := sm.AddState("WAITING")
+	expired := sm.AddState("EXPIRED")
+	waiting.AddTimeoutTransition(5*time.Millisecond, expired)
+
+	smi := sm.FromState(waiting)
+	smi.Start(nil)
+	defer smi.Stop()
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, waiting, smi.State())
+}
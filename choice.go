@@ -0,0 +1,75 @@
+package fsm
+
+import "fmt"
+
+// Branch is an outgoing transition of a choice pseudo-state
+type Branch struct {
+	name      string
+	condition func(*Context) bool
+	to        *State
+}
+
+// Case returns a branch of a choice taken when the condition is true
+func Case(name string, condition func(*Context) bool, to *State) Branch {
+	return Branch{name: name, condition: condition, to: to}
+}
+
+// Otherwise returns the branch of a choice taken when no other branch is
+func Otherwise(to *State) Branch {
+	return Branch{to: to}
+}
+
+// AddChoice adds a choice pseudo-state. A transition targeting it continues, in the same Fire,
+// to the target of the first branch, in order, whose condition is true, or else to the Otherwise branch.
+// The choice is never the current state and has no handlers: the listeners see a single transition,
+// from the source to the chosen state, and Context.Via reports the choices traversed.
+// If no branch is taken, the Fire fails with ErrTransitionNotFound for the choice.
+func (s *StateMachine) AddChoice(name string, branches ...Branch) *State {
+	st := s.AddState(name)
+	st.choice = true
+	var otherwise *State
+	for _, b := range branches {
+		if b.condition != nil {
+			st.AddConditionalTransition(b.name, b.to, b.condition)
+			continue
+		}
+		if otherwise != nil {
+			panic("fsm: choice " + name + " has more than one Otherwise branch")
+		}
+		otherwise = b.to
+	}
+	// the else branch must be evaluated last
+	if otherwise != nil {
+		st.AddFallbackTransition(otherwise)
+	}
+	return st
+}
+
+// IsChoice checks if the state is a choice pseudo-state
+func (s *State) IsChoice() bool {
+	return s.choice
+}
+
+// Via returns the choice pseudo-states traversed by the transition, in order
+func (c *Context) Via() []*State {
+	return append([]*State(nil), c.via...)
+}
+
+// resolveChoice follows the choice pseudo-states to the state to be entered
+func (s *StateMachine) resolveChoice(target *State, ctx *Context) (*State, error) {
+	for target.choice {
+		if len(ctx.via) > len(s.states) {
+			return nil, fmt.Errorf("choice cycle through %v", ctx.via)
+		}
+		t, err := s.match(target, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			return nil, &ErrTransitionNotFound{state: target.name, key: ctx.Key()}
+		}
+		ctx.via = append(ctx.via, target)
+		target = s.resolveTarget(t.state, ctx)
+	}
+	return target, nil
+}
@@ -0,0 +1,114 @@
+package fsm_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+type submission int
+
+func (submission) Kind() interface{} {
+	return "submit"
+}
+
+func ExampleStateMachine_AddChoice() {
+	sm := fsm.New()
+	yellow := sm.AddState("YELLOW")
+	red := sm.AddState("RED", fsm.OnEnter(func(c *fsm.Context) error {
+		fmt.Println("enter RED via", c.Via())
+		return nil
+	}))
+	green := sm.AddState("GREEN")
+	busy := func(c *fsm.Context) bool {
+		return c.Key() == "TRAFFIC"
+	}
+	check := sm.AddChoice("CHECK", fsm.Case("busy", busy, green), fsm.Otherwise(red))
+	yellow.AddTransition("TICK", check)
+	red.AddTransition("TICK", yellow)
+
+	smi := sm.FromState(yellow)
+	smi.AddOnTransition(func(c *fsm.Context) error {
+		fmt.Printf("%s --%s--> %s\n", c.FromState(), c.Key(), c.ToState())
+		return nil
+	})
+	smi.Fire("TICK")
+	smi.Fire("TICK")
+	// Output:
+	// enter RED via [CHECK]
+	// YELLOW --TICK--> RED
+	// RED --TICK--> YELLOW
+}
+
+func TestChoice(t *testing.T) {
+	sm := fsm.New()
+	review := sm.AddState("REVIEW")
+	large := sm.AddState("LARGE")
+	small := sm.AddState("SMALL")
+	manual := sm.AddState("MANUAL")
+	amount := func(c *fsm.Context) int {
+		n, _ := fsm.DataAs[submission](c)
+		return int(n)
+	}
+	size := sm.AddChoice("SIZE",
+		fsm.Case("large", func(c *fsm.Context) bool { return amount(c) > 100 }, large),
+		fsm.Case("small", func(c *fsm.Context) bool { return amount(c) > 0 }, small),
+	)
+	route := sm.AddChoice("ROUTE",
+		fsm.Otherwise(manual),
+		fsm.Case("automatic", func(c *fsm.Context) bool { return amount(c) > 0 }, size),
+	)
+	review.AddTransition("submit", route)
+	require.True(t, route.IsChoice())
+	require.False(t, review.IsChoice())
+
+	var via []*fsm.State
+	fire := func(n int) (*fsm.State, error) {
+		smi := sm.FromState(review)
+		smi.AddOnTransition(func(c *fsm.Context) error {
+			via = c.Via()
+			return nil
+		})
+		err := smi.Fire(submission(n))
+		return smi.State(), err
+	}
+
+	st, err := fire(500)
+	require.NoError(t, err)
+	require.Equal(t, large, st)
+	require.Equal(t, []*fsm.State{route, size}, via)
+
+	st, err = fire(5)
+	require.NoError(t, err)
+	require.Equal(t, small, st)
+
+	// the otherwise branch is evaluated last
+	st, err = fire(0)
+	require.NoError(t, err)
+	require.Equal(t, manual, st)
+	require.Equal(t, []*fsm.State{route}, via)
+
+	peeked, err := sm.FromState(review).PeekTransition(submission(500))
+	require.NoError(t, err)
+	require.Equal(t, large, peeked)
+}
+
+func TestChoiceWithoutBranch(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	c := sm.AddChoice("C", fsm.Case("never", func(*fsm.Context) bool { return false }, b))
+	a.AddTransition("go", c)
+
+	smi := sm.FromState(a)
+	var notFound *fsm.ErrTransitionNotFound
+	require.ErrorAs(t, smi.Fire("go"), &notFound)
+	require.Equal(t, a, smi.State())
+
+	require.Contains(t, sm.Dot(nil), "C [shape=diamond];")
+	require.Panics(t, func() {
+		sm.AddChoice("D", fsm.Otherwise(a), fsm.Otherwise(b))
+	})
+}
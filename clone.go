@@ -0,0 +1,189 @@
+package fsm
+
+import "math/rand"
+
+// Clone returns a deep copy of the machine, with its own states and transitions, so that the definition
+// can be built once and cloned, for example per aggregate, rebinding the handlers of the copy
+// with the State setters or with Rebind. The clone is not frozen, even if the machine is.
+// The random source is reseeded with the same seed.
+// Observability sinks, like the monitor, metrics, logger, tracer and event log, are shared with the machine.
+func (s *StateMachine) Clone() *StateMachine {
+	c := new(StateMachine)
+	*c = *s
+	c.frozen = false
+	c.rnd = rand.New(&lockedSource{src: rand.NewSource(s.seed)})
+	c.onTransitionListeners = append([]OnHandler{}, s.onTransitionListeners...)
+	c.beforeListeners = append([]OnHandler(nil), s.beforeListeners...)
+	c.observers = append([]Observer(nil), s.observers...)
+	c.declared = append([]interface{}(nil), s.declared...)
+	if s.tokens != nil {
+		c.tokens = make(map[interface{}]EventToken, len(s.tokens))
+		for k, v := range s.tokens {
+			c.tokens[k] = v
+		}
+	}
+
+	// first pass copies the states, so that references between them can be remapped
+	mapping := map[*State]*State{}
+	copyState := func(st *State) *State {
+		n := new(State)
+		*n = *st
+		n.machine = c
+		mapping[st] = n
+		for _, h := range st.histories {
+			nh := new(State)
+			*nh = *h
+			nh.machine = c
+			nh.pseudo = &historyState{kind: h.pseudo.kind, composite: n}
+			mapping[h] = nh
+		}
+		return n
+	}
+	c.states = make([]*State, len(s.states))
+	for i, st := range s.states {
+		c.states[i] = copyState(st)
+	}
+	if s.global != nil {
+		c.global = copyState(s.global)
+	}
+	remap := func(st *State) *State {
+		if n, ok := mapping[st]; ok {
+			return n
+		}
+		return st
+	}
+
+	for old, n := range mapping {
+		if n.pseudo != nil {
+			continue
+		}
+		n.parent = remap(old.parent)
+		n.children = make([]*State, len(old.children))
+		for i, ch := range old.children {
+			n.children[i] = remap(ch)
+		}
+		n.histories = make([]*State, len(old.histories))
+		for i, h := range old.histories {
+			n.histories[i] = remap(h)
+		}
+		n.transitions = make([]*transition, len(old.transitions))
+		for i, t := range old.transitions {
+			nt := new(transition)
+			*nt = *t
+			nt.state = remap(t.state)
+			nt.listeners = append([]OnHandler(nil), t.listeners...)
+			nt.meta = copyMeta(t.meta)
+			nt.labels = copyMeta(t.labels)
+			nt.roles = append([]string(nil), t.roles...)
+			n.transitions[i] = nt
+		}
+		n.onEventKinds = append([]eventKindHandler(nil), old.onEventKinds...)
+		n.meta = copyMeta(old.meta)
+		n.deferred = append([]interface{}(nil), old.deferred...)
+		n.aliases = append([]string(nil), old.aliases...)
+		n.periodic = append([]periodicEvent(nil), old.periodic...)
+		if old.quotaPolicy.overflow != nil {
+			n.quotaPolicy.overflow = remap(old.quotaPolicy.overflow)
+		}
+		if old.quotaPolicy.overflow != nil {
+			n.quotaPolicy.overflow = remap(old.quotaPolicy.overflow)
+		}
+		if old.sub != nil {
+			exits := make(map[string]interface{}, len(old.sub.exits))
+			for k, v := range old.sub.exits {
+				exits[k] = v
+			}
+			n.sub = &subMachine{machine: old.sub.machine.Clone(), exits: exits}
+		}
+	}
+
+	c.byName = make(map[string]*State, len(s.byName))
+	for k, st := range s.byName {
+		c.byName[k] = remap(st)
+	}
+	if s.byAlias != nil {
+		c.byAlias = make(map[string]*State, len(s.byAlias))
+		for k, st := range s.byAlias {
+			c.byAlias[k] = remap(st)
+		}
+	}
+	c.slas = make([]*sla, len(s.slas))
+	for i, v := range s.slas {
+		ns := *v
+		ns.target = remap(v.target)
+		c.slas[i] = &ns
+	}
+	if s.sagaAborted != nil {
+		c.sagaAborted = remap(s.sagaAborted)
+	}
+	// fallback handlers resolve to states of the machine, that must be translated to the ones of the clone
+	c.fallbackResolvers = make([]func(*Context) *State, len(s.fallbackResolvers))
+	for i, r := range s.fallbackResolvers {
+		r := r
+		c.fallbackResolvers[i] = func(ctx *Context) *State {
+			if st := r(ctx); st != nil {
+				return remap(st)
+			}
+			return nil
+		}
+	}
+	return c
+}
+
+// SetOnEnter replaces the handlers called when entering the state, for example to rebind a cloned machine.
+// A nil handler removes them.
+func (s *State) SetOnEnter(fn OnHandler) *State {
+	s.machine.mustBeMutable()
+	s.onEnter = fn
+	return s
+}
+
+// SetOnExit replaces the handlers called when exiting the state.
+// A nil handler removes them.
+func (s *State) SetOnExit(fn OnHandler) *State {
+	s.machine.mustBeMutable()
+	s.onExit = fn
+	return s
+}
+
+// SetOnEvent replaces the handlers called when an event occurs in the state.
+// A nil handler removes them.
+func (s *State) SetOnEvent(fn OnHandler) *State {
+	s.machine.mustBeMutable()
+	s.onEvent = fn
+	return s
+}
+
+// Rebind replaces the handlers, actions and conditions bound by name from a HandlerRegistry,
+// as by FromDefinition, with the ones of the registry of the same name.
+// Names missing from the registry keep their current binding.
+func (s *StateMachine) Rebind(handlers HandlerRegistry) *StateMachine {
+	s.mustBeMutable()
+	states := s.states
+	if s.global != nil {
+		states = append(append([]*State(nil), states...), s.global)
+	}
+	for _, st := range states {
+		for _, h := range []struct {
+			name string
+			fn   *OnHandler
+		}{
+			{st.handlerNames.enter, &st.onEnter},
+			{st.handlerNames.exit, &st.onExit},
+			{st.handlerNames.event, &st.onEvent},
+		} {
+			if fn, ok := handlers.Handlers[h.name]; ok && h.name != "" {
+				*h.fn = st.params.bind(fn)
+			}
+		}
+		for _, t := range st.transitions {
+			if fn, ok := handlers.Handlers[t.actionName]; ok && t.actionName != "" {
+				t.action = t.params.bind(fn)
+			}
+			if cond, ok := handlers.Conditions[t.conditionName]; ok && t.conditionName != "" && t.key == nil && t.timeout == 0 && !t.fallback {
+				t.condition = cond
+			}
+		}
+	}
+	return s
+}
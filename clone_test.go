@@ -0,0 +1,196 @@
+package fsm_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+type counter struct {
+	entered []string
+}
+
+func (c *counter) enter(ctx *fsm.Context) error {
+	c.entered = append(c.entered, ctx.ToState().Name())
+	return nil
+}
+
+func TestClone(t *testing.T) {
+	// the definition is built once, with no handlers
+	def := fsm.New(fsm.RandSeed(7))
+	created := def.AddState("created")
+	booked := def.AddState("booked")
+	paid := def.AddState("paid")
+	created.AddTransition("book", booked)
+	booked.AddTransition("pay", paid)
+	def.Freeze()
+
+	newInstance := func(c *counter) *fsm.StateMachineInstance {
+		sm := def.Clone()
+		sm.StateByName("booked").SetOnEnter(c.enter)
+		sm.StateByName("paid").SetOnEnter(c.enter)
+		return sm.FromState(sm.StateByName("created"))
+	}
+
+	c1, c2 := &counter{}, &counter{}
+	smi1, smi2 := newInstance(c1), newInstance(c2)
+	require.NoError(t, smi1.Fire("book"))
+	require.NoError(t, smi1.Fire("pay"))
+	require.NoError(t, smi2.Fire("book"))
+	require.Equal(t, []string{"booked", "paid"}, c1.entered)
+	require.Equal(t, []string{"booked"}, c2.entered)
+	require.Equal(t, "paid", smi1.State().Name())
+	require.Equal(t, "booked", smi2.State().Name())
+
+	// the original is untouched
+	require.True(t, def.Frozen())
+	require.Equal(t, int64(7), smi1.Seed())
+	clone := def.Clone()
+	clone.StateByName("paid").AddTransition("refund", clone.StateByName("created"))
+	require.Len(t, paid.Transitions(), 0)
+	require.NotSame(t, paid, clone.StateByName("paid"))
+}
+
+func TestCloneComposite(t *testing.T) {
+	sm := fsm.New()
+	idle := sm.AddState("idle")
+	active := sm.AddState("active")
+	running := sm.AddState("running", fsm.ChildOf(active))
+	paused := sm.AddState("paused", fsm.ChildOf(active))
+	running.AddTransition("pause", paused)
+	active.AddTransition("stop", idle)
+	idle.AddTransition("resume", fsm.ShallowHistory(active))
+	idle.AddTransition("start", active)
+
+	clone := sm.Clone()
+	smi := clone.FromState(clone.StateByName("idle"))
+	require.NoError(t, smi.Fire("start"))
+	require.NoError(t, smi.Fire("pause"))
+	require.NoError(t, smi.Fire("stop"))
+	require.NoError(t, smi.Fire("resume"))
+	require.Same(t, clone.StateByName("paused"), smi.State())
+}
+
+func TestCloneOverflow(t *testing.T) {
+	quota := fsm.NewMemoryQuota(map[string]int{"B": 0})
+	sm := fsm.New(fsm.WithQuota(quota))
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	o := sm.AddState("O")
+	a.AddTransition("go", b)
+	b.OnQuotaFull(fsm.OverflowTo(o))
+
+	clone := sm.Clone()
+	smi := clone.FromState(clone.StateByName("A"))
+	require.NoError(t, smi.Fire("go"))
+	require.Same(t, clone.StateByName("O"), smi.State())
+}
+
+// TestCloneStates checks that no state reachable from the clone belongs to the machine
+func TestCloneStates(t *testing.T) {
+	sm := fsm.New(fsm.WithQuota(fsm.NewMemoryQuota(nil)))
+	idle := sm.AddState("idle").AddAlias("waiting")
+	active := sm.AddState("active")
+	running := sm.AddState("running", fsm.ChildOf(active))
+	sm.AddState("paused", fsm.ChildOf(active))
+	overflow := sm.AddState("overflow")
+	aborted := sm.AddState("aborted")
+	choice := sm.AddChoice("pick", fsm.Case("busy", func(*fsm.Context) bool { return true }, running), fsm.Otherwise(idle))
+	idle.AddTransition("resume", fsm.ShallowHistory(active))
+	idle.AddTransition("pick", choice)
+	running.OnQuotaFull(fsm.OverflowTo(overflow))
+	sm.AddGlobalTransition("abort", aborted)
+	sm.AddSLA("fast", "resume", running, time.Minute, "late")
+	sm.EnableSaga(aborted)
+
+	originals := map[uintptr]bool{reflect.ValueOf(sm).Pointer(): true}
+	for _, st := range sm.States() {
+		originals[reflect.ValueOf(st).Pointer()] = true
+	}
+	walkPointers(reflect.ValueOf(sm.Clone()), map[uintptr]bool{}, func(p uintptr) {
+		require.False(t, originals[p], "the clone references the machine or one of its states")
+	})
+}
+
+var (
+	stateType   = reflect.TypeOf(&fsm.State{})
+	machineType = reflect.TypeOf(&fsm.StateMachine{})
+)
+
+// walkPointers calls visit with every state and machine reachable from the value
+func walkPointers(v reflect.Value, seen map[uintptr]bool, visit func(uintptr)) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		if v.Type() == stateType || v.Type() == machineType {
+			visit(v.Pointer())
+		}
+		if seen[v.Pointer()] {
+			return
+		}
+		seen[v.Pointer()] = true
+		walkPointers(v.Elem(), seen, visit)
+	case reflect.Interface:
+		walkPointers(v.Elem(), seen, visit)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			walkPointers(v.Field(i), seen, visit)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkPointers(v.Index(i), seen, visit)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			walkPointers(iter.Key(), seen, visit)
+			walkPointers(iter.Value(), seen, visit)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	var calls []string
+	registry := func(tag string) fsm.HandlerRegistry {
+		return fsm.HandlerRegistry{
+			Handlers: map[string]fsm.OnHandler{
+				"log": func(c *fsm.Context) error {
+					calls = append(calls, tag+" log "+c.ToState().Name())
+					return nil
+				},
+				"ping": func(c *fsm.Context) error {
+					calls = append(calls, tag+" ping")
+					return nil
+				},
+			},
+			Conditions: map[string]func(*fsm.Context) bool{
+				"isEmergency": func(c *fsm.Context) bool {
+					return tag == "clone" && c.Key() == "EMERGENCY"
+				},
+			},
+		}
+	}
+	sm, err := fsm.LoadDefinition([]byte(definitionJSON), registry("original"))
+	require.NoError(t, err)
+
+	clone := sm.Clone().Rebind(registry("clone"))
+	smi, err := clone.FromStateName("YELLOW")
+	require.NoError(t, err)
+	require.NoError(t, smi.Fire("EMERGENCY"))
+	require.Equal(t, "RED", smi.State().Name())
+	require.NoError(t, smi.Fire(fsm.Timeout{After: 30 * time.Second}))
+	require.NoError(t, smi.Fire("PING"))
+	require.Equal(t, []string{"clone log GREEN", "clone ping"}, calls)
+
+	calls = nil
+	smi, err = sm.FromStateName("YELLOW")
+	require.NoError(t, err)
+	require.NoError(t, smi.Fire("EMERGENCY"))
+	require.Equal(t, "EXIT", smi.State().Name())
+	require.Empty(t, calls)
+}
@@ -0,0 +1,72 @@
+// Command fsmgen generates typed event constructors, or with -machine the wiring of the machine,
+// from a JSON machine definition or from a machine written in the DSL, in a file with the .fsm extension.
+//
+// Usage:
+//
+//	//go:generate fsmgen -in order.json -out order_events.go -pkg order
+//	//go:generate fsmgen -machine -in light.fsm -out light_fsm.go -pkg light
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/fsmgen"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintln(os.Stderr, "fsmgen:", err)
+		os.Exit(1)
+	}
+}
+
+// run generates the code for the arguments, writing it to stdout unless -out is set,
+// and the usage of the flags to stderr
+func run(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("fsmgen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	in := flags.String("in", "", "JSON machine definition, or DSL if the extension is .fsm")
+	out := flags.String("out", "", "generated Go file. Defaults to the standard output")
+	pkg := flags.String("pkg", "main", "package of the generated file")
+	machine := flags.Bool("machine", false, "generate the wiring of the machine instead of the event constructors")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *in == "" {
+		return fmt.Errorf("missing -in")
+	}
+	data, err := os.ReadFile(*in)
+	if err != nil {
+		return err
+	}
+	def := fsm.Definition{}
+	if filepath.Ext(*in) == ".fsm" {
+		def, err = fsmgen.ParseDSL(bytes.NewReader(data))
+	} else {
+		err = json.Unmarshal(data, &def)
+	}
+	if err != nil {
+		return err
+	}
+	generate := fsmgen.Generate
+	if *machine {
+		generate = fsmgen.GenerateMachine
+	}
+	src, err := generate(def, *pkg)
+	if err != nil {
+		return err
+	}
+	if *out == "" {
+		_, err = stdout.Write(src)
+		return err
+	}
+	return os.WriteFile(*out, src, 0o644)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"order.json": `{"states":[{"name":"A","transitions":[{"to":"A","event":"tick"}]}]}`,
+		"light.fsm":  "machine \"light\"\nGREEN -> RED on tick\nRED -> GREEN on tick\n",
+		"bad.fsm":    "A -> B tick\n",
+	}
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+	}
+
+	tests := []struct {
+		name string
+		args string
+		want string
+		err  string
+	}{
+		{
+			name: "events",
+			args: "-in order.json -pkg order",
+			want: "package order\n",
+		},
+		{
+			name: "event constructor",
+			args: "-in order.json",
+			want: "func NewTick() fsm.Eventer {\n\treturn Tick{}\n}\n",
+		},
+		{
+			name: "machine",
+			args: "-machine -in light.fsm -pkg light",
+			want: "\tStateGREEN = \"GREEN\"\n\tStateRED   = \"RED\"\n",
+		},
+		{
+			name: "missing input",
+			args: "-pkg order",
+			err:  "missing -in",
+		},
+		{
+			name: "invalid DSL",
+			args: "-in bad.fsm",
+			err:  "line 1: expected state NAME [final] or FROM -> TO on EVENT",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := strings.Fields(tt.args)
+			for i, a := range args {
+				if _, ok := files[a]; ok {
+					args[i] = filepath.Join(dir, a)
+				}
+			}
+			var stdout, stderr bytes.Buffer
+			err := run(args, &stdout, &stderr)
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Contains(t, stdout.String(), tt.want)
+		})
+	}
+}
+
+func TestRunOut(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "light.fsm")
+	out := filepath.Join(dir, "light_fsm.go")
+	require.NoError(t, os.WriteFile(in, []byte("GREEN -> RED on tick\n"), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{"-machine", "-in", in, "-out", out, "-pkg", "light"}, &stdout, &stderr))
+	require.Empty(t, stdout.String())
+	src, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(src), "// Code generated by fsmgen. DO NOT EDIT.\n\npackage light\n"))
+
+	// flag errors print the usage
+	require.Error(t, run([]string{"-input", in}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), "generated Go file. Defaults to the standard output")
+}
@@ -0,0 +1,295 @@
+// Command fsmviz inspects machine definitions, in JSON or in the DSL in files with the .fsm extension,
+// without the handlers of the application. It is meant to be run in CI pipelines of workflow repositories.
+//
+// Usage:
+//
+//	fsmviz render [-format dot|mermaid|plantuml|text] [-out file] order.json
+//	fsmviz validate [-initial NAME] order.json
+//	fsmviz diff old.json new.json
+//
+// validate reports the states unreachable from the initial state, by default the first one,
+// and the states that are not final but cannot be left. validate and diff exit with status 1
+// if there are problems or differences.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/fsmgen"
+	"github.com/quintans/fsm/fsmhttp"
+)
+
+// errFound is returned when problems or differences were found, and reported
+var errFound = errors.New("found")
+
+func main() {
+	err := run(os.Args[1:], os.Stdout, os.Stderr)
+	if errors.Is(err, errFound) {
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "fsmviz:", err)
+		os.Exit(2)
+	}
+}
+
+// run runs the command of the arguments, writing its output to stdout and the usage of its flags to stderr
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("missing command: render, validate or diff")
+	}
+	switch args[0] {
+	case "render":
+		return render(args[1:], stdout, stderr)
+	case "validate":
+		return validate(args[1:], stdout, stderr)
+	case "diff":
+		return diff(args[1:], stdout)
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func render(args []string, w, stderr io.Writer) error {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "dot", "output format: dot, mermaid, plantuml or text")
+	out := flags.String("out", "", "output file. Defaults to the standard output")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("render expects one definition")
+	}
+	sm, err := load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	var doc string
+	switch *format {
+	case "dot":
+		doc = sm.Dot(nil) + "\n"
+	case "mermaid":
+		doc = fsmhttp.Mermaid(sm, nil)
+	case "plantuml":
+		doc = plantUML(sm)
+	case "text":
+		doc = sm.Text()
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	if *out == "" {
+		_, err = io.WriteString(w, doc)
+		return err
+	}
+	return os.WriteFile(*out, []byte(doc), 0o644)
+}
+
+func validate(args []string, w, stderr io.Writer) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	initial := flags.String("initial", "", "initial state. Defaults to the first state")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("validate expects one definition")
+	}
+	sm, err := load(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	states := sm.States()
+	if len(states) == 0 {
+		return errors.New("the machine has no states")
+	}
+	start := states[0]
+	if *initial != "" {
+		if start = sm.StateByName(*initial); start == nil {
+			return fmt.Errorf("unknown initial state %q", *initial)
+		}
+	}
+
+	problems := lint(sm, start.Initial())
+	for _, p := range problems {
+		fmt.Fprintln(w, p)
+	}
+	if len(problems) > 0 {
+		return errFound
+	}
+	return nil
+}
+
+// lint returns the reachability problems of the machine, started at the state
+func lint(sm *fsm.StateMachine, start *fsm.State) []string {
+	reached := map[*fsm.State]bool{}
+	for _, st := range append(sm.ReachableFrom(start), start) {
+		// a composite is reached with its sub-states
+		for ; st != nil; st = st.Parent() {
+			reached[st] = true
+		}
+	}
+	var problems []string
+	for _, st := range sm.States() {
+		if !reached[st] {
+			problems = append(problems, fmt.Sprintf("unreachable: %s", st.Name()))
+		}
+	}
+	for _, st := range sm.States() {
+		if !st.IsFinal() && !st.IsComposite() && len(sm.ReachableFrom(st)) == 0 {
+			problems = append(problems, fmt.Sprintf("dead end: %s is not final and cannot be left", st.Name()))
+		}
+	}
+	return problems
+}
+
+func diff(args []string, w io.Writer) error {
+	if len(args) != 2 {
+		return errors.New("diff expects two definitions")
+	}
+	a, err := load(args[0])
+	if err != nil {
+		return err
+	}
+	b, err := load(args[1])
+	if err != nil {
+		return err
+	}
+	changes := fsm.Diff(a, b)
+	for _, c := range changes {
+		fmt.Fprintln(w, c)
+	}
+	if len(changes) > 0 {
+		return errFound
+	}
+	return nil
+}
+
+// load builds the machine of the definition in the file, binding stubs to its handlers and conditions
+func load(file string) (*fsm.StateMachine, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	def := fsm.Definition{}
+	if filepath.Ext(file) == ".fsm" {
+		def, err = fsmgen.ParseDSL(bytes.NewReader(data))
+	} else {
+		err = json.Unmarshal(data, &def)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	sm, err := fsm.FromDefinition(def, stubs(def))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return sm, nil
+}
+
+// stubs returns a registry with a no-op handler, or a false condition, for every name of the definition
+func stubs(def fsm.Definition) fsm.HandlerRegistry {
+	registry := fsm.HandlerRegistry{
+		Handlers:   map[string]fsm.OnHandler{},
+		Conditions: map[string]func(*fsm.Context) bool{},
+	}
+	handler := func(name string) {
+		if name != "" {
+			registry.Handlers[name] = func(*fsm.Context) error { return nil }
+		}
+	}
+	transitions := func(list []fsm.TransitionDefinition) {
+		for _, td := range list {
+			handler(td.Action)
+			if td.Condition != "" {
+				registry.Conditions[td.Condition] = func(*fsm.Context) bool { return false }
+			}
+		}
+	}
+	for _, sd := range def.States {
+		handler(sd.OnEnter)
+		handler(sd.OnExit)
+		handler(sd.OnEvent)
+		transitions(sd.Transitions)
+	}
+	transitions(def.Global)
+	return registry
+}
+
+// plantUML renders the machine as a PlantUML state diagram, with the sub-states nested in their composites.
+// Global transitions are drawn from a state named "any".
+func plantUML(sm *fsm.StateMachine) string {
+	var b strings.Builder
+	b.WriteString("@startuml\n")
+	if sm.Name() != "" {
+		fmt.Fprintf(&b, "title %s\n", sm.Name())
+	}
+	ids := map[*fsm.State]string{}
+	for i, st := range sm.States() {
+		ids[st] = fmt.Sprintf("s%d", i)
+	}
+	var declare func(st *fsm.State, indent string)
+	declare = func(st *fsm.State, indent string) {
+		var children []*fsm.State
+		for _, c := range sm.States() {
+			if c.Parent() == st {
+				children = append(children, c)
+			}
+		}
+		if len(children) == 0 {
+			fmt.Fprintf(&b, "%sstate %q as %s\n", indent, st.Name(), ids[st])
+			return
+		}
+		fmt.Fprintf(&b, "%sstate %q as %s {\n", indent, st.Name(), ids[st])
+		for _, c := range children {
+			declare(c, indent+"  ")
+		}
+		fmt.Fprintf(&b, "%s}\n", indent)
+	}
+	for _, st := range sm.States() {
+		if st.Parent() == nil {
+			declare(st, "")
+		}
+	}
+	if states := sm.States(); len(states) > 0 {
+		fmt.Fprintf(&b, "[*] --> %s\n", ids[states[0]])
+	}
+	edge := func(from string, t fsm.TransitionInfo) {
+		to, ok := ids[t.To]
+		if !ok {
+			// history pseudo-states are named after their composite
+			name := t.To.Name()
+			suffix := name[strings.LastIndex(name, "["):]
+			to = ids[sm.StateByName(strings.TrimSuffix(name, suffix))] + suffix
+		}
+		label := strings.NewReplacer("\n", " ").Replace(t.Name)
+		if t.Kind == fsm.ConditionalTransition {
+			label = "[" + label + "]"
+		}
+		fmt.Fprintf(&b, "%s --> %s : %s\n", from, to, label)
+	}
+	for _, st := range sm.States() {
+		for _, t := range st.Transitions() {
+			edge(ids[st], t)
+		}
+		if st.IsFinal() {
+			fmt.Fprintf(&b, "%s --> [*]\n", ids[st])
+		}
+	}
+	if global := sm.GlobalTransitions(); len(global) > 0 {
+		b.WriteString("state \"*\" as any\n")
+		for _, t := range global {
+			edge("any", t)
+		}
+	}
+	b.WriteString("@enduml\n")
+	return b.String()
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var definitions = map[string]string{
+	"light.json": `{"name":"light","states":[` +
+		`{"name":"GREEN","transitions":[{"to":"YELLOW","event":"TICK"}]},` +
+		`{"name":"YELLOW","transitions":[{"to":"RED","event":"TICK"}]},` +
+		`{"name":"RED","transitions":[{"to":"GREEN","event":"TICK"}]}]}`,
+	"light.fsm": `machine "light"
+GREEN -> YELLOW on TICK
+YELLOW -> RED on TICK
+RED -> OFF on TICK
+state OFF final
+`,
+	"broken.fsm": `state A
+A -> B on go
+state C
+C -> A on back
+`,
+	"invalid.json": `{"states":`,
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range definitions {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+	}
+
+	tests := []struct {
+		name  string
+		args  string
+		want  string
+		found bool
+		err   string
+	}{
+		{
+			name: "render text",
+			args: "render -format text light.json",
+			want: "# light\nGREEN\n`-- TICK -> YELLOW\nYELLOW\n`-- TICK -> RED\nRED\n`-- TICK -> GREEN\n",
+		},
+		{
+			name: "render dot",
+			args: "render light.fsm",
+			want: "\tRED -> OFF [label = \"TICK\"];\n",
+		},
+		{
+			name: "render mermaid",
+			args: "render -format mermaid light.json",
+			want: "    s2 --> s0 : TICK\n",
+		},
+		{
+			name: "render plantuml",
+			args: "render -format plantuml light.fsm",
+			want: "title light\nstate \"GREEN\" as s0\nstate \"YELLOW\" as s1\nstate \"RED\" as s2\nstate \"OFF\" as s3\n[*] --> s0\n",
+		},
+		{
+			name: "render unknown format",
+			args: "render -format svg light.json",
+			err:  `unknown format "svg"`,
+		},
+		{
+			name: "validate",
+			args: "validate light.fsm",
+		},
+		{
+			name:  "validate problems",
+			args:  "validate broken.fsm",
+			want:  "unreachable: C\ndead end: B is not final and cannot be left\n",
+			found: true,
+		},
+		{
+			name:  "validate from the initial state",
+			args:  "validate -initial C broken.fsm",
+			want:  "dead end: B is not final and cannot be left\n",
+			found: true,
+		},
+		{
+			name: "validate unknown initial state",
+			args: "validate -initial X broken.fsm",
+			err:  `unknown initial state "X"`,
+		},
+		{
+			name: "diff",
+			args: "diff light.json light.json",
+		},
+		{
+			name:  "diff changes",
+			args:  "diff light.json light.fsm",
+			want:  "transition removed: RED -> GREEN [event TICK]\nstate added: OFF\ntransition added: RED -> OFF [event TICK]\n",
+			found: true,
+		},
+		{
+			name: "diff expects two definitions",
+			args: "diff light.json",
+			err:  "diff expects two definitions",
+		},
+		{
+			name: "invalid definition",
+			args: "validate invalid.json",
+			err:  filepath.Join(dir, "invalid.json") + ": unexpected end of JSON input",
+		},
+		{
+			name: "unknown command",
+			args: "lint light.json",
+			err:  `unknown command "lint"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := strings.Fields(tt.args)
+			for i, a := range args {
+				if _, ok := definitions[a]; ok {
+					args[i] = filepath.Join(dir, a)
+				}
+			}
+			var stdout, stderr bytes.Buffer
+			err := run(args, &stdout, &stderr)
+			switch {
+			case tt.err != "":
+				require.EqualError(t, err, tt.err)
+				return
+			case tt.found:
+				require.ErrorIs(t, err, errFound)
+			default:
+				require.NoError(t, err)
+			}
+			if tt.want == "" {
+				require.Empty(t, stdout.String())
+				return
+			}
+			require.Contains(t, stdout.String(), tt.want)
+		})
+	}
+}
+
+func TestRenderOut(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "light.json")
+	out := filepath.Join(dir, "light.txt")
+	require.NoError(t, os.WriteFile(in, []byte(definitions["light.json"]), 0o644))
+
+	var stdout, stderr bytes.Buffer
+	require.NoError(t, run([]string{"render", "-format", "text", "-out", out, in}, &stdout, &stderr))
+	require.Empty(t, stdout.String())
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(string(data), "# light\n"))
+
+	// flag errors print the usage
+	require.Error(t, run([]string{"render", "-colour", in}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), "output format: dot, mermaid, plantuml or text")
+}
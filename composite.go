@@ -0,0 +1,126 @@
+package fsm
+
+import "strings"
+
+// ChildOf option makes the state a sub-state of the composite parent.
+// The first child added to a composite is its initial sub-state, entered when a transition targets the composite.
+// Events not handled by a sub-state are handled by the transitions of its ancestors.
+// Only the handlers of the leaf states are called.
+func ChildOf(parent *State) func(*State) {
+	return func(s *State) {
+		s.parent = parent
+		parent.children = append(parent.children, s)
+	}
+}
+
+// Parent returns the composite state containing this state, or nil
+func (s *State) Parent() *State {
+	return s.parent
+}
+
+// IsComposite checks if the state has sub-states
+func (s *State) IsComposite() bool {
+	return len(s.children) > 0
+}
+
+type historyKind int
+
+const (
+	shallowHistory historyKind = iota + 1
+	deepHistory
+)
+
+// pseudo-state
+type historyState struct {
+	kind      historyKind
+	composite *State
+}
+
+// ShallowHistory returns a pseudo-state that, as a transition target, resumes the composite
+// at the sub-state that was last active, entering its initial sub-state if it is also a composite.
+// Without history, or outside an instance, the composite is entered at its initial sub-state.
+func ShallowHistory(composite *State) *State {
+	return composite.historyPseudoState(shallowHistory)
+}
+
+// DeepHistory returns a pseudo-state that, as a transition target, resumes the composite
+// at the leaf state that was last active, at any depth.
+// Without history, or outside an instance, the composite is entered at its initial sub-state.
+func DeepHistory(composite *State) *State {
+	return composite.historyPseudoState(deepHistory)
+}
+
+func (s *State) historyPseudoState(kind historyKind) *State {
+	for _, h := range s.histories {
+		if h.pseudo.kind == kind {
+			return h
+		}
+	}
+	s.machine.mustBeMutable()
+	suffix := "[H]"
+	if kind == deepHistory {
+		suffix = "[H*]"
+	}
+	h := &State{
+		name:    s.name + suffix,
+		machine: s.machine,
+		pseudo:  &historyState{kind: kind, composite: s},
+	}
+	s.histories = append(s.histories, h)
+	return h
+}
+
+// matchHierarchy matches the event in the state, then in its ancestors and finally in the global transitions
+func (s *StateMachine) matchHierarchy(state *State, ctx *Context) (*transition, error) {
+	for st := state; st != nil; st = st.parent {
+		t, err := s.match(st, ctx)
+		if t != nil || err != nil {
+			return t, err
+		}
+	}
+	if s.global == nil {
+		return nil, nil
+	}
+	return s.match(s.global, ctx)
+}
+
+// resolveTarget resolves history pseudo-states and composites to the leaf state to be entered
+func (s *StateMachine) resolveTarget(target *State, ctx *Context) *State {
+	if h := target.pseudo; h != nil {
+		target = h.composite
+		if ctx.instance != nil {
+			for last := ctx.instance.lastActive[target]; last != nil; last = ctx.instance.lastActive[target] {
+				target = last
+				if h.kind == shallowHistory {
+					break
+				}
+			}
+		}
+	}
+	for len(target.children) > 0 {
+		target = target.children[0]
+	}
+	return target
+}
+
+// recordActive remembers the current state as the last active sub-state of its ancestors
+func (m *StateMachineInstance) recordActive() {
+	for st := m.currentState; st.parent != nil; st = st.parent {
+		if m.lastActive == nil {
+			m.lastActive = map[*State]*State{}
+		}
+		m.lastActive[st.parent] = st
+	}
+}
+
+// targetByName finds a state, or a history pseudo-state, by the name before the naming policy
+func (s *StateMachine) targetByName(name string) *State {
+	for suffix, kind := range map[string]historyKind{"[H]": shallowHistory, "[H*]": deepHistory} {
+		if strings.HasSuffix(name, suffix) {
+			if composite := s.StateByName(s.stateName(strings.TrimSuffix(name, suffix))); composite != nil {
+				return composite.historyPseudoState(kind)
+			}
+		}
+	}
+	return s.StateByName(s.stateName(name))
+}
@@ -0,0 +1,136 @@
+package fsm_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	PAUSE  = "pause"
+	RESUME = "resume"
+	NEXT   = "next"
+)
+
+// WORKING { DOWNLOAD, PROCESS { PARSE, INDEX } } <-> PAUSED
+func pausableFSM(resume func(*fsm.State) *fsm.State) *fsm.StateMachine {
+	sm := fsm.New()
+	working := sm.AddState("WORKING")
+	download := sm.AddState("DOWNLOAD", fsm.ChildOf(working))
+	process := sm.AddState("PROCESS", fsm.ChildOf(working))
+	parse := sm.AddState("PARSE", fsm.ChildOf(process))
+	index := sm.AddState("INDEX", fsm.ChildOf(process))
+	paused := sm.AddState("PAUSED")
+
+	download.AddTransition(NEXT, process)
+	parse.AddTransition(NEXT, index)
+	working.AddTransition(PAUSE, paused)
+	paused.AddTransition(RESUME, resume(working))
+	return sm
+}
+
+func TestComposite(t *testing.T) {
+	sm := pausableFSM(func(s *fsm.State) *fsm.State { return s })
+	smi := sm.FromState(sm.StateByName("DOWNLOAD"))
+
+	// entering a composite enters its initial sub-state
+	require.NoError(t, smi.Fire(NEXT))
+	require.Equal(t, "PARSE", smi.State().Name())
+	require.Equal(t, "PROCESS", smi.State().Parent().Name())
+	require.True(t, smi.State().Parent().IsComposite())
+
+	// the event bubbles to the ancestors
+	require.NoError(t, smi.Fire(PAUSE))
+	require.Equal(t, "PAUSED", smi.State().Name())
+
+	// without history, the composite restarts
+	require.NoError(t, smi.Fire(RESUME))
+	require.Equal(t, "DOWNLOAD", smi.State().Name())
+}
+
+func TestShallowHistory(t *testing.T) {
+	sm := pausableFSM(fsm.ShallowHistory)
+	smi := sm.FromState(sm.StateByName("DOWNLOAD"))
+
+	require.NoError(t, smi.Fire(NEXT))
+	require.NoError(t, smi.Fire(NEXT))
+	require.Equal(t, "INDEX", smi.State().Name())
+
+	require.NoError(t, smi.Fire(PAUSE))
+	require.NoError(t, smi.Fire(RESUME))
+	require.Equal(t, "PARSE", smi.State().Name())
+}
+
+func TestDeepHistory(t *testing.T) {
+	sm := pausableFSM(fsm.DeepHistory)
+	smi := sm.FromState(sm.StateByName("INDEX"))
+
+	require.NoError(t, smi.Fire(PAUSE))
+	require.NoError(t, smi.Fire(RESUME))
+	require.Equal(t, "INDEX", smi.State().Name())
+
+	// there is no history outside an instance
+	next, err := sm.Fire(sm.StateByName("PAUSED"), RESUME)
+	require.NoError(t, err)
+	require.Equal(t, "DOWNLOAD", next.Name())
+}
+
+func TestHistoryDefinition(t *testing.T) {
+	sm := pausableFSM(fsm.DeepHistory)
+	data, err := sm.MarshalDefinition()
+	require.NoError(t, err)
+
+	loaded, err := fsm.LoadDefinition(data, fsm.HandlerRegistry{})
+	require.NoError(t, err)
+	require.Equal(t, sm.Fingerprint(), loaded.Fingerprint())
+
+	smi := loaded.FromState(loaded.StateByName("INDEX"))
+	require.NoError(t, smi.Fire(PAUSE))
+	require.NoError(t, smi.Fire(RESUME))
+	require.Equal(t, "INDEX", smi.State().Name())
+}
+
+func ExampleStateMachine_Dot_composite() {
+	sm := fsm.New()
+	idle := sm.AddState("IDLE")
+	active := sm.AddState("ACTIVE")
+	running := sm.AddState("RUNNING", fsm.ChildOf(active))
+	paused := sm.AddState("PAUSED", fsm.ChildOf(active))
+	done := sm.AddState("DONE")
+	idle.AddTransition("start", active)
+	idle.AddTransition("resume", fsm.ShallowHistory(active))
+	running.AddTransition("pause", paused)
+	paused.AddConditionalTransition("idle", running, func(c *fsm.Context) bool {
+		return true
+	})
+	active.AddTransition("stop", idle)
+	active.AddFallbackTransition(done)
+
+	fmt.Println(sm.DotWithOptions(nil, fsm.DotOptions{DashFallbacks: true}))
+	// Output:
+	// digraph finite_state_machine {
+	// 	rankdir=LR;
+	// 	compound=true;
+	// 	node [shape = circle];
+	// 	# nodes
+	// 	IDLE;
+	// 	subgraph cluster_ACTIVE {
+	// 		label="ACTIVE";
+	// 		RUNNING;
+	// 		PAUSED;
+	// 		"ACTIVE[H]" [label="H", shape=circle];
+	// 	}
+	// 	DONE [style=filled, shape=doublecircle];
+	// 	# transitions
+	// 	IDLE -> "ACTIVE[H]" [label = "resume"];
+	// 	IDLE -> RUNNING [label = "start", lhead = cluster_ACTIVE];
+	// 	PAUSED -> RUNNING [label = "[idle]"];
+	// 	RUNNING -> DONE [label = "fallback", style = dashed, ltail = cluster_ACTIVE];
+	// 	RUNNING -> IDLE [label = "stop", ltail = cluster_ACTIVE];
+	// 	RUNNING -> PAUSED [label = "pause"];
+	// 	# title
+	// 	labelloc="t";
+	// }
+}
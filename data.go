@@ -0,0 +1,50 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrUnexpectedData is returned when the event data is not of the requested type
+var ErrUnexpectedData = errors.New("unexpected event data")
+
+// payload returns the original fired value, unwrapping it from Event, or from an idempotency key, if needed
+func (c *Context) payload() interface{} {
+	if c.event == nil {
+		return c.raw
+	}
+	event := c.event
+	if ie, ok := event.(idempotentEvent); ok {
+		event = toEventer(ie.event)
+	}
+	if e, ok := event.(*Event); ok {
+		return e.Data
+	}
+	return event
+}
+
+// DataAs returns the fired event value as T, even when it was wrapped in an Event.
+func DataAs[T any](c *Context) (T, error) {
+	v, ok := c.payload().(T)
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("%w: expected %T, got %T", ErrUnexpectedData, zero, c.payload())
+	}
+	return v, nil
+}
+
+// Bind stores the fired event value in the value pointed by target, even when it was wrapped in an Event.
+func (c *Context) Bind(target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("bind target must be a non nil pointer, got %T", target)
+	}
+	data := c.payload()
+	dv := reflect.ValueOf(data)
+	if !dv.IsValid() || !dv.Type().AssignableTo(rv.Elem().Type()) {
+		return fmt.Errorf("%w: expected %s, got %T", ErrUnexpectedData, rv.Elem().Type(), data)
+	}
+	rv.Elem().Set(dv)
+	return nil
+}
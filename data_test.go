@@ -0,0 +1,63 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+type payment struct {
+	amount int
+}
+
+func (payment) Kind() interface{} {
+	return "pay"
+}
+
+func TestDataAs(t *testing.T) {
+	var (
+		p      payment
+		key    string
+		bound  payment
+		errStr error
+	)
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.OnEvent(func(c *fsm.Context) error {
+		var err error
+		if c.Key() == "pay" {
+			p, err = fsm.DataAs[payment](c)
+			if err != nil {
+				return err
+			}
+			_, errStr = fsm.DataAs[string](c)
+			return c.Bind(&bound)
+		}
+		key, err = fsm.DataAs[string](c)
+		return err
+	}))
+	a.AddTransition("pay", b)
+	b.AddTransition(TICK, b)
+
+	smi := sm.FromState(a)
+	require.NoError(t, smi.Fire(payment{amount: 10}))
+	require.Equal(t, 10, p.amount)
+	require.Equal(t, 10, bound.amount)
+	require.True(t, errors.Is(errStr, fsm.ErrUnexpectedData))
+
+	// wrapped in fsm.Event
+	require.NoError(t, smi.Fire(TICK))
+	require.Equal(t, TICK, key)
+}
+
+func TestBindInvalidTarget(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A", fsm.OnEvent(func(c *fsm.Context) error {
+		var s string
+		return c.Bind(s)
+	}))
+	a.AddTransition(TICK, a)
+	require.Error(t, sm.FromState(a).Fire(TICK))
+}
@@ -0,0 +1,260 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// stateDeadline is the time limit to leave a state
+type stateDeadline struct {
+	within     time.Duration
+	escalation interface{}
+}
+
+// Deadline declares that instances must leave the state within the duration after entering it,
+// otherwise the escalation event is fired into them. Like SLAs, the deadline is tracked per instance,
+// survives snapshots, is armed once the instance is started and is listed by Deadlines, named by DeadlineName.
+// Re-entering the state restarts it.
+func (s *State) Deadline(within time.Duration, escalation interface{}) *State {
+	s.machine.mustBeMutable()
+	s.deadline = &stateDeadline{within: within, escalation: escalation}
+	return s
+}
+
+// DeadlineName returns the name of the deadline of the state, as listed by Deadlines
+func (s *State) DeadlineName() string {
+	return "state:" + s.name
+}
+
+// Timer is a pending deadline of an instance, kept by a TimerStore
+type Timer struct {
+	InstanceID string
+	Name       string
+	At         time.Time
+}
+
+// TimerStore keeps the pending deadlines of the instances, so that they can be fired after a restart,
+// by any process, with Manager.FireDue
+type TimerStore interface {
+	// Schedule adds the timer, replacing the one of the instance with the same name
+	Schedule(ctx context.Context, t Timer) error
+	// Cancel removes the timer of the instance with the name, if any
+	Cancel(ctx context.Context, id, name string) error
+	// Due returns the timers due at the time
+	Due(ctx context.Context, now time.Time) ([]Timer, error)
+}
+
+// WithTimerStore option keeps the deadlines of the instances, of SLAs and states, in the store.
+// The store is updated by every Fire, once the instance is persisted, and by Create, once the instance is saved.
+// If persisting fails, the store is left unchanged, and updated by the next successful Fire.
+// Errors updating the store are returned by the Fire, after the transition happened in memory.
+func WithTimerStore(store TimerStore) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.timerStore = store
+	}
+}
+
+// timerOp is a pending change of the timer store. A zero time cancels the timer.
+type timerOp struct {
+	name string
+	at   time.Time
+}
+
+// setDeadline sets the pending deadline.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) setDeadline(name string, at time.Time) {
+	if m.deadlines == nil {
+		m.deadlines = map[string]time.Time{}
+	}
+	m.deadlines[name] = at
+	if m.timerStore != nil {
+		m.timerOps = append(m.timerOps, timerOp{name: name, at: at})
+	}
+}
+
+// enterDeadline restarts the deadline of the current state, after leaving the previous one.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) enterDeadline(prev *State) {
+	if prev != nil && prev.deadline != nil {
+		m.clearDeadline(prev.DeadlineName())
+	}
+	d := m.currentState.deadline
+	if d == nil {
+		return
+	}
+	name := m.currentState.DeadlineName()
+	at := time.Now().Add(d.within)
+	m.setDeadline(name, at)
+	m.armDeadline(name, d.escalation, at)
+}
+
+// escalation returns the escalation event of the pending deadline with the name.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) escalation(name string) (interface{}, bool) {
+	if d := m.currentState.deadline; d != nil && m.currentState.DeadlineName() == name {
+		return d.escalation, true
+	}
+	for _, s := range m.slas {
+		if s.name == name {
+			return s.escalation, true
+		}
+	}
+	return nil, false
+}
+
+// syncTimers applies the pending changes to the timer store.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) syncTimers(goCtx context.Context) error {
+	if len(m.timerOps) == 0 {
+		return nil
+	}
+	if goCtx == nil {
+		goCtx = context.Background()
+	}
+	ops := m.timerOps
+	m.timerOps = nil
+	for _, op := range ops {
+		var err error
+		if op.at.IsZero() {
+			err = m.timerStore.Cancel(goCtx, m.id, op.name)
+		} else {
+			err = m.timerStore.Schedule(goCtx, Timer{InstanceID: m.id, Name: op.name, At: op.at})
+		}
+		if err != nil {
+			return fmt.Errorf("unable to update deadline %s of instance %s: %w", op.name, m.id, err)
+		}
+	}
+	return nil
+}
+
+// fireDeadline fires the escalation event of the deadline with the name, if it is pending and due at the time
+func (m *StateMachineInstance) fireDeadline(goCtx context.Context, name string, now time.Time) (bool, error) {
+	m.mu.RLock()
+	escalation, ok := m.escalation(name)
+	m.mu.RUnlock()
+	if !ok {
+		return false, nil
+	}
+	fired := false
+	err := m.fireWhen(goCtx, func() bool {
+		at, ok := m.deadlines[name]
+		if !ok || at.After(now) {
+			return false
+		}
+		m.clearDeadline(name)
+		fired = true
+		return true
+	}, escalation)
+	return fired, err
+}
+
+// FireDue fires the escalation events of the deadlines due at the time, kept in the timer store of the machine,
+// into the instances of the manager, so that deadlines are honoured across restarts without starting the instances.
+// Timers of deadlines that are no longer pending are cancelled.
+// It returns the number of fired escalations and the first error, after trying all the due timers.
+func (m *Manager) FireDue(ctx context.Context, now time.Time) (int, error) {
+	store := m.machine.timerStore
+	if store == nil {
+		return 0, errors.New("unable to fire due deadlines: the machine has no timer store")
+	}
+	timers, err := store.Due(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	fired := 0
+	var first error
+	for _, t := range timers {
+		ok, err := m.fireDue(ctx, store, t, now)
+		if err != nil && first == nil {
+			first = fmt.Errorf("unable to fire deadline %s of instance %s: %w", t.Name, t.InstanceID, err)
+		}
+		if ok && err == nil {
+			fired++
+		}
+	}
+	return fired, first
+}
+
+func (m *Manager) fireDue(ctx context.Context, store TimerStore, t Timer, now time.Time) (bool, error) {
+	fired := false
+	err := m.machine.transact(ctx, func(ctx context.Context) error {
+		smi, version, err := m.load(ctx, t.InstanceID)
+		if errors.Is(err, ErrInstanceNotFound) {
+			return store.Cancel(ctx, t.InstanceID, t.Name)
+		}
+		if err != nil {
+			return err
+		}
+		fired, err = smi.fireDeadline(ctx, t.Name, now)
+		if err != nil {
+			return err
+		}
+		if !fired {
+			return store.Cancel(ctx, t.InstanceID, t.Name)
+		}
+		return m.save(ctx, t.InstanceID, smi.Snapshot(), version)
+	})
+	if err != nil {
+		m.cache.remove(t.InstanceID)
+	}
+	return fired, err
+}
+
+// MemoryTimerStore is an in memory TimerStore, for tests and single process usage
+type MemoryTimerStore struct {
+	mu     sync.Mutex
+	timers map[[2]string]time.Time
+}
+
+func NewMemoryTimerStore() *MemoryTimerStore {
+	return &MemoryTimerStore{timers: map[[2]string]time.Time{}}
+}
+
+func (s *MemoryTimerStore) Schedule(_ context.Context, t Timer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.timers[[2]string{t.InstanceID, t.Name}] = t.At
+	return nil
+}
+
+func (s *MemoryTimerStore) Cancel(_ context.Context, id, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.timers, [2]string{id, name})
+	return nil
+}
+
+func (s *MemoryTimerStore) Due(_ context.Context, now time.Time) ([]Timer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due []Timer
+	for k, at := range s.timers {
+		if !at.After(now) {
+			due = append(due, Timer{InstanceID: k[0], Name: k[1], At: at})
+		}
+	}
+	sortTimers(due)
+	return due, nil
+}
+
+// Timers returns the pending timers, ordered by time
+func (s *MemoryTimerStore) Timers() []Timer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	timers := make([]Timer, 0, len(s.timers))
+	for k, at := range s.timers {
+		timers = append(timers, Timer{InstanceID: k[0], Name: k[1], At: at})
+	}
+	sortTimers(timers)
+	return timers
+}
+
+func sortTimers(timers []Timer) {
+	sort.Slice(timers, func(i, j int) bool {
+		return timers[i].At.Before(timers[j].At)
+	})
+}
@@ -0,0 +1,148 @@
+package fsm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+// PENDING -approve-> APPROVED, with PENDING escalating to ESCALATED after the deadline
+func deadlineFSM(within time.Duration, opts ...func(*fsm.StateMachine)) *fsm.StateMachine {
+	sm := fsm.New(opts...)
+	pending := sm.AddState("PENDING").Deadline(within, ESCALATE)
+	approved := sm.AddState("APPROVED")
+	escalated := sm.AddState("ESCALATED")
+	pending.AddTransition(APPROVE, approved)
+	pending.AddTransition(TICK, pending)
+	pending.AddTransition(ESCALATE, escalated)
+	approved.AddTransition(SUBMIT, pending)
+	return sm
+}
+
+func TestStateDeadlineEscalates(t *testing.T) {
+	sm := deadlineFSM(20 * time.Millisecond)
+	pending := sm.StateByName("PENDING")
+	smi := sm.FromState(pending)
+	require.Len(t, smi.Deadlines(), 1)
+	require.Equal(t, pending.DeadlineName(), smi.Deadlines()[0].Name)
+
+	smi.Start(nil)
+	defer smi.Stop()
+	require.Eventually(t, func() bool {
+		return smi.State().Name() == "ESCALATED"
+	}, time.Second, time.Millisecond)
+	require.Empty(t, smi.Deadlines())
+}
+
+func TestStateDeadlineMet(t *testing.T) {
+	sm := deadlineFSM(20 * time.Millisecond)
+	smi := sm.FromState(sm.StateByName("PENDING"))
+	smi.Start(nil)
+	defer smi.Stop()
+
+	require.NoError(t, smi.Fire(APPROVE))
+	require.Empty(t, smi.Deadlines())
+	time.Sleep(40 * time.Millisecond)
+	require.Equal(t, "APPROVED", smi.State().Name())
+
+	// entering the state again restarts the deadline
+	require.NoError(t, smi.Fire(SUBMIT))
+	require.Len(t, smi.Deadlines(), 1)
+}
+
+func TestStateDeadlineSnapshot(t *testing.T) {
+	sm := deadlineFSM(time.Hour)
+	smi := sm.FromState(sm.StateByName("PENDING"))
+
+	time.Sleep(time.Millisecond)
+	restored, err := sm.Restore(smi.Snapshot())
+	require.NoError(t, err)
+	require.Equal(t, smi.Deadlines()[0].At.UnixNano(), restored.Deadlines()[0].At.UnixNano())
+
+	// a self-transition without re-entry keeps the deadline
+	require.NoError(t, restored.Fire(TICK))
+	require.Equal(t, smi.Deadlines()[0].At.UnixNano(), restored.Deadlines()[0].At.UnixNano())
+}
+
+func TestFireDue(t *testing.T) {
+	timers := fsm.NewMemoryTimerStore()
+	sm := deadlineFSM(time.Hour, fsm.WithTimerStore(timers))
+	pending := sm.StateByName("PENDING")
+	m := fsm.NewManager(sm, fsm.NewMemoryStore())
+	ctx := context.Background()
+
+	require.NoError(t, m.Create(ctx, "late", pending))
+	require.NoError(t, m.Create(ctx, "approved", pending))
+	require.Len(t, timers.Timers(), 2)
+	require.Equal(t, pending.DeadlineName(), timers.Timers()[0].Name)
+
+	_, err := m.Fire(ctx, "approved", APPROVE)
+	require.NoError(t, err)
+	require.Len(t, timers.Timers(), 1)
+	require.Equal(t, "late", timers.Timers()[0].InstanceID)
+
+	fired, err := m.FireDue(ctx, time.Now())
+	require.NoError(t, err)
+	require.Zero(t, fired)
+
+	// a restarted process, with a new manager, fires the due deadlines
+	m = fsm.NewManager(sm, fsm.NewMemoryStore())
+	_, err = m.FireDue(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, timers.Timers(), "the timers of missing instances are cancelled")
+}
+
+func TestFireDueEscalates(t *testing.T) {
+	timers := fsm.NewMemoryTimerStore()
+	sm := deadlineFSM(time.Hour, fsm.WithTimerStore(timers))
+	store := fsm.NewMemoryStore()
+	ctx := context.Background()
+	require.NoError(t, fsm.NewManager(sm, store).Create(ctx, "late", sm.StateByName("PENDING")))
+
+	m := fsm.NewManager(sm, store)
+	fired, err := m.FireDue(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Equal(t, 1, fired)
+	st, err := m.State(ctx, "late")
+	require.NoError(t, err)
+	require.Equal(t, "ESCALATED", st.Name())
+	require.Empty(t, timers.Timers())
+
+	// a stale timer is cancelled without firing
+	require.NoError(t, timers.Schedule(ctx, fsm.Timer{InstanceID: "late", Name: "state:PENDING", At: time.Now()}))
+	fired, err = m.FireDue(ctx, time.Now().Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, fired)
+	require.Empty(t, timers.Timers())
+}
+
+// failingStore fails to save instances
+type failingStore struct {
+	*fsm.MemoryStore
+}
+
+func (s failingStore) Save(context.Context, string, fsm.Snapshot, int64) error {
+	return errors.New("store is down")
+}
+
+func TestTimersKeptWhenPersistFails(t *testing.T) {
+	timers := fsm.NewMemoryTimerStore()
+	sm := deadlineFSM(time.Hour, fsm.WithTimerStore(timers))
+	store := fsm.NewMemoryStore()
+	ctx := context.Background()
+	smi, err := sm.Create(ctx, store, "1", sm.StateByName("PENDING"))
+	require.NoError(t, err)
+	require.Len(t, timers.Timers(), 1)
+
+	smi.AutoPersist(failingStore{store}, smi.Version())
+	require.Error(t, smi.Fire(APPROVE))
+	require.Len(t, timers.Timers(), 1, "the timers of an instance that was not saved are unchanged")
+
+	_, err = sm.Create(ctx, failingStore{store}, "2", sm.StateByName("PENDING"))
+	require.Error(t, err)
+	require.Len(t, timers.Timers(), 1)
+}
@@ -0,0 +1,47 @@
+package fsm
+
+// Decision builds a pair of conditional transitions, one taken when the condition is true
+// and an else one taken when it is false, so that they are mutually exclusive by construction.
+type Decision struct {
+	state     *State
+	name      string
+	condition func(*Context) bool
+	opts      []TransitionOption
+	to        *State
+}
+
+// When starts a decision on the named condition. The options are applied to both transitions.
+//
+//	review.When("approved", isApproved).To(publish).Else(draft)
+func (s *State) When(name string, condition func(*Context) bool, opts ...TransitionOption) *Decision {
+	return &Decision{
+		state:     s,
+		name:      name,
+		condition: condition,
+		opts:      opts,
+	}
+}
+
+// To adds the transition taken when the condition is true
+func (d *Decision) To(to *State) *Decision {
+	d.to = to
+	d.state.AddConditionalTransition(d.name, to, d.eval, d.opts...)
+	return d
+}
+
+// Else adds the transition, named "!<name>", taken when the condition is false.
+// The condition is only evaluated once per event.
+func (d *Decision) Else(to *State) *State {
+	if d.to == nil {
+		panic("fsm: Else of decision " + d.name + " without To")
+	}
+	return d.state.AddConditionalTransition("!"+d.name, to, func(c *Context) bool {
+		return !d.eval(c)
+	}, d.opts...)
+}
+
+func (d *Decision) eval(c *Context) bool {
+	return c.Memo(d, func() interface{} {
+		return d.condition(c)
+	}).(bool)
+}
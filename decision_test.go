@@ -0,0 +1,41 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecision(t *testing.T) {
+	evaluations := 0
+	approved := false
+	sm := fsm.New()
+	review := sm.AddState("REVIEW")
+	publish := sm.AddState("PUBLISH")
+	draft := sm.AddState("DRAFT")
+	review.When("approved", func(c *fsm.Context) bool {
+		evaluations++
+		return approved
+	}).To(publish).Else(draft)
+
+	next, err := sm.Fire(review, SUBMIT)
+	require.NoError(t, err)
+	require.Equal(t, draft, next)
+	require.Equal(t, 1, evaluations)
+
+	approved = true
+	next, err = sm.Fire(review, SUBMIT)
+	require.NoError(t, err)
+	require.Equal(t, publish, next)
+
+	var names []string
+	for _, tr := range review.Transitions() {
+		names = append(names, tr.Name)
+	}
+	require.Equal(t, []string{"approved", "!approved"}, names)
+
+	require.Panics(t, func() {
+		review.When("other", func(c *fsm.Context) bool { return true }).Else(draft)
+	})
+}
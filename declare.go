@@ -0,0 +1,87 @@
+package fsm
+
+import "fmt"
+
+// EventToken is the small integer identifying a declared event
+type EventToken int
+
+type ErrUndeclaredEvent struct {
+	key interface{}
+}
+
+func (e *ErrUndeclaredEvent) Error() string {
+	return fmt.Sprintf("event %+v was not declared", e.key)
+}
+
+// Key returns the undeclared event key
+func (e *ErrUndeclaredEvent) Key() interface{} {
+	return e.key
+}
+
+// DeclareEvent declares an event key and returns its token, the same one if it was already declared.
+// Once an event is declared, adding a transition for, or firing, an undeclared event fails with ErrUndeclaredEvent,
+// catching misspelled keys, and key transitions are matched by token.
+// Events should be declared before adding the transitions. Timeout events don't need to be declared.
+func (s *StateMachine) DeclareEvent(key interface{}) EventToken {
+	s.mustBeMutable()
+	key = s.normalizeKey(toEventer(key).Kind())
+	s.mustBeComparable(key)
+	if tok := s.eventToken(key); tok > 0 {
+		return tok
+	}
+	s.declared = append(s.declared, key)
+	tok := EventToken(len(s.declared))
+	if s.keyComparator == nil {
+		if s.tokens == nil {
+			s.tokens = map[interface{}]EventToken{}
+		}
+		s.tokens[key] = tok
+	}
+	return tok
+}
+
+// DeclaredEvents returns the declared event keys, normalized, in declaration order
+func (s *StateMachine) DeclaredEvents() []interface{} {
+	return append([]interface{}(nil), s.declared...)
+}
+
+// eventToken returns the token of the normalized key, or zero if it was not declared
+func (s *StateMachine) eventToken(key interface{}) EventToken {
+	if s.keyComparator == nil {
+		return s.tokens[key]
+	}
+	for i, k := range s.declared {
+		if s.keyComparator(k, key) {
+			return EventToken(i + 1)
+		}
+	}
+	return 0
+}
+
+// checkDeclared fails with ErrUndeclaredEvent if events were declared and the event of the context was not
+func (s *StateMachine) checkDeclared(ctx *Context) error {
+	if len(s.declared) == 0 {
+		return nil
+	}
+	if _, ok := ctx.kind().(Timeout); ok {
+		return nil
+	}
+	if ctx.token() == 0 {
+		return &ErrUndeclaredEvent{key: ctx.Key()}
+	}
+	return nil
+}
+
+// token returns the token of the event, computed once per context
+func (c *Context) token() EventToken {
+	if c.tok == 0 {
+		c.tok = -1
+		if tok := c.machine.eventToken(c.Key()); tok > 0 {
+			c.tok = tok
+		}
+	}
+	if c.tok < 0 {
+		return 0
+	}
+	return c.tok
+}
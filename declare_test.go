@@ -0,0 +1,34 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeclareEvent(t *testing.T) {
+	sm := fsm.New(fsm.NormalizeStrings())
+	tick := sm.DeclareEvent(TICK)
+	require.Equal(t, tick, sm.DeclareEvent("tick"))
+	require.NotEqual(t, tick, sm.DeclareEvent(LOOP))
+	require.Equal(t, []interface{}{"tick", "loop"}, sm.DeclaredEvents())
+
+	green := sm.AddState("GREEN")
+	yellow := sm.AddState("YELLOW")
+	green.AddTransition(TICK, yellow)
+	green.AddTimeoutTransition(time.Second, yellow)
+	require.PanicsWithError(t, "event TIKC was not declared", func() {
+		yellow.AddTransition("TIKC", green)
+	})
+
+	smi := sm.FromState(green)
+	var undeclared *fsm.ErrUndeclaredEvent
+	require.ErrorAs(t, smi.Fire("TOCK"), &undeclared)
+	require.Equal(t, "tock", undeclared.Key())
+	require.Equal(t, green, smi.State())
+
+	require.NoError(t, smi.Fire(" Tick"))
+	require.Equal(t, yellow, smi.State())
+}
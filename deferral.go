@@ -0,0 +1,67 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+)
+
+// Defer defers the events while in this state.
+// A deferred event is queued by the instance and replayed, in arrival order,
+// as soon as the instance enters a state that does not defer it.
+// A key transition of the state for the same event takes precedence over the deferral.
+// A replayed event without a transition is discarded. If a replayed event fails,
+// its error is returned by the Fire that caused the replay, and the event is discarded.
+func (s *State) Defer(eventKeys ...interface{}) *State {
+	s.machine.mustBeMutable()
+	for _, k := range eventKeys {
+		key := s.machine.normalizeKey(toEventer(k).Kind())
+		s.machine.mustBeComparable(key)
+		s.deferred = append(s.deferred, key)
+	}
+	return s
+}
+
+// defers checks if the event is deferred by the state
+func (s *State) defers(event interface{}) bool {
+	if len(s.deferred) == 0 {
+		return false
+	}
+	key := s.machine.normalizeKey(toEventer(event).Kind())
+	for _, t := range s.transitions {
+		if t.key != nil && s.machine.keysEqual(s.machine.normalizeKey(t.key), key) {
+			return false
+		}
+	}
+	for _, k := range s.deferred {
+		if s.machine.keysEqual(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// replayDeferred fires the queued events that are no longer deferred by the current state
+func (m *StateMachineInstance) replayDeferred(goCtx context.Context) error {
+	for i := 0; i < len(m.deferred); {
+		key := m.deferred[i]
+		if m.currentState.defers(key) {
+			i++
+			continue
+		}
+		m.deferred = append(m.deferred[:i:i], m.deferred[i+1:]...)
+		m.monitor.deferredChanged(-1)
+		if err := m.step(goCtx, key); err != nil && err != errIgnored && !errors.Is(err, ErrUnknownTransition) {
+			return err
+		}
+		// the state may have changed, releasing earlier events
+		i = 0
+	}
+	return nil
+}
+
+// Deferred returns the events waiting to be replayed
+func (m *StateMachineInstance) Deferred() []interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]interface{}(nil), m.deferred...)
+}
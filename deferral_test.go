@@ -0,0 +1,89 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	CONNECT = "connect"
+	DATA    = "data"
+	CLOSE   = "close"
+)
+
+func TestDefer(t *testing.T) {
+	var received []string
+	sm := fsm.New()
+	connecting := sm.AddState("CONNECTING")
+	open := sm.AddState("OPEN", fsm.OnEvent(func(c *fsm.Context) error {
+		received = append(received, c.Key().(string))
+		return nil
+	}))
+	closed := sm.AddState("CLOSED")
+
+	connecting.AddTransition(CONNECT, open).Defer(DATA, CLOSE)
+	open.AddTransition(DATA, open)
+	open.AddTransition(CLOSE, closed)
+
+	smi := sm.FromState(connecting)
+	require.NoError(t, smi.Fire(DATA))
+	require.NoError(t, smi.Fire(CLOSE))
+	require.Equal(t, connecting, smi.State())
+	require.Equal(t, []interface{}{DATA, CLOSE}, smi.Deferred())
+
+	// entering OPEN replays the events in arrival order
+	require.NoError(t, smi.Fire(CONNECT))
+	require.Equal(t, closed, smi.State())
+	require.Empty(t, smi.Deferred())
+	require.Equal(t, []string{CONNECT, DATA}, received)
+}
+
+func TestDeferKeepsEventsStillDeferred(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	c := sm.AddState("C")
+	a.AddTransition(TICK, b).Defer(LOOP, CONTINUE)
+	b.AddTransition(CONTINUE, c).Defer(LOOP)
+	c.AddTransition(LOOP, a)
+
+	smi := sm.FromState(a)
+	require.NoError(t, smi.Fire(LOOP))
+	require.NoError(t, smi.Fire(CONTINUE))
+	// B releases CONTINUE, which moves to C, which then releases LOOP
+	require.NoError(t, smi.Fire(TICK))
+	require.Equal(t, a, smi.State())
+	require.Empty(t, smi.Deferred())
+}
+
+func TestDeferTransitionTakesPrecedence(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b).Defer(TICK)
+
+	smi := sm.FromState(a)
+	require.NoError(t, smi.Fire(TICK))
+	require.Equal(t, b, smi.State())
+	require.Empty(t, smi.Deferred())
+}
+
+func TestDeferDefinition(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b).Defer(LOOP)
+	b.AddTransition(LOOP, a)
+
+	data, err := sm.MarshalDefinition()
+	require.NoError(t, err)
+	loaded, err := fsm.LoadDefinition(data, fsm.HandlerRegistry{})
+	require.NoError(t, err)
+	require.Equal(t, sm.Fingerprint(), loaded.Fingerprint())
+
+	smi := loaded.FromState(loaded.StateByName("A"))
+	require.NoError(t, smi.Fire(LOOP))
+	require.Equal(t, []interface{}{LOOP}, smi.Deferred())
+}
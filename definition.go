@@ -0,0 +1,453 @@
+package fsm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// HandlerRegistry binds, by name, the handlers and conditions referenced in a definition
+type HandlerRegistry struct {
+	Handlers   map[string]OnHandler
+	Conditions map[string]func(*Context) bool
+}
+
+// Definition is the serializable form of a state machine
+type Definition struct {
+	Name   string            `json:"name,omitempty"`
+	States []StateDefinition `json:"states"`
+	// Global are the event transitions that apply from any state
+	Global []TransitionDefinition `json:"global,omitempty"`
+	SLAs   []SLADefinition        `json:"slas,omitempty"`
+}
+
+// SLADefinition describes an SLA. Within is a duration, like "1h"
+type SLADefinition struct {
+	Name       string `json:"name"`
+	Event      string `json:"event"`
+	To         string `json:"to"`
+	Within     string `json:"within"`
+	Escalation string `json:"escalation"`
+}
+
+type StateDefinition struct {
+	Name     string               `json:"name"`
+	Aliases  []string             `json:"aliases,omitempty"`
+	Parent   string               `json:"parent,omitempty"`
+	Final    bool                 `json:"final,omitempty"`
+	OnEnter  string               `json:"onEnter,omitempty"`
+	OnExit   string               `json:"onExit,omitempty"`
+	OnEvent  string               `json:"onEvent,omitempty"`
+	Meta     map[string]string    `json:"meta,omitempty"`
+	Defer    []string             `json:"defer,omitempty"`
+	Deadline *DeadlineDefinition  `json:"deadline,omitempty"`
+	Every    []PeriodicDefinition `json:"every,omitempty"`
+	// Params are the parameters of the handlers, as text/template templates, rendered by Context.Param
+	Params      map[string]string      `json:"params,omitempty"`
+	Transitions []TransitionDefinition `json:"transitions,omitempty"`
+}
+
+// PeriodicDefinition describes an event fired regularly. Interval is a duration, like "5m"
+type PeriodicDefinition struct {
+	Interval string `json:"interval"`
+	Event    string `json:"event"`
+}
+
+// DeadlineDefinition describes the deadline of a state. Within is a duration, like "24h"
+type DeadlineDefinition struct {
+	Within     string `json:"within"`
+	Escalation string `json:"escalation"`
+}
+
+// TransitionDefinition describes a transition. Only one of Event, Fallback, Condition or Timeout is expected.
+type TransitionDefinition struct {
+	To string `json:"to,omitempty"`
+	// Event is the key of a key transition
+	Event    string `json:"event,omitempty"`
+	Fallback bool   `json:"fallback,omitempty"`
+	// Name and Condition describe a conditional transition
+	Name      string `json:"name,omitempty"`
+	Condition string `json:"condition,omitempty"`
+	// Guard is an expression, as parsed by ParseGuard, that must also hold for the transition to be taken.
+	// Alone, it describes a conditional transition named after Name or else the expression.
+	Guard string `json:"guard,omitempty"`
+	// Timeout is a duration, like "30s"
+	Timeout string `json:"timeout,omitempty"`
+	// Action makes an Event transition internal, executing the named handler
+	Action string `json:"action,omitempty"`
+	// Params are the parameters of the action, as text/template templates, rendered by Context.Param
+	Params   map[string]string `json:"params,omitempty"`
+	Priority int               `json:"priority,omitempty"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	// Labels, Schema and Roles describe the event to end users
+	Labels map[string]string `json:"labels,omitempty"`
+	Schema string            `json:"schema,omitempty"`
+	Roles  []string          `json:"roles,omitempty"`
+}
+
+type handlerNames struct {
+	enter string
+	exit  string
+	event string
+}
+
+// Definition returns the serializable form of the machine.
+// Handlers and conditions are referenced by the name they were bound with, when loaded from a definition,
+// and conditional transitions created in code are referenced by the transition name.
+// Only string event keys are supported.
+func (s *StateMachine) Definition() (Definition, error) {
+	def := Definition{Name: s.name}
+	for _, st := range s.states {
+		if st.sub != nil {
+			return Definition{}, fmt.Errorf("unable to marshal sub-machine state %s: sub-machines are not supported", st.name)
+		}
+		sd := StateDefinition{
+			Name:    st.name,
+			Final:   st.final,
+			OnEnter: st.handlerNames.enter,
+			OnExit:  st.handlerNames.exit,
+			OnEvent: st.handlerNames.event,
+			Meta:    st.Meta(),
+			Aliases: st.Aliases(),
+		}
+		if st.params != nil {
+			sd.Params = copyMeta(st.params.src)
+		}
+		if st.parent != nil {
+			sd.Parent = st.parent.name
+		}
+		for _, k := range st.deferred {
+			e, ok := k.(string)
+			if !ok {
+				return Definition{}, fmt.Errorf("unable to marshal deferred event key %+v of type %T on state %s: only string keys are supported", k, k, st.name)
+			}
+			sd.Defer = append(sd.Defer, e)
+		}
+		if d := st.deadline; d != nil {
+			escalation, ok := toEventer(d.escalation).Kind().(string)
+			if !ok {
+				return Definition{}, fmt.Errorf("unable to marshal deadline of state %s: only string event keys are supported", st.name)
+			}
+			sd.Deadline = &DeadlineDefinition{Within: d.within.String(), Escalation: escalation}
+		}
+		for _, p := range st.periodic {
+			e, ok := toEventer(p.event).Kind().(string)
+			if !ok {
+				return Definition{}, fmt.Errorf("unable to marshal periodic event %+v of state %s: only string event keys are supported", p.event, st.name)
+			}
+			sd.Every = append(sd.Every, PeriodicDefinition{Interval: p.interval.String(), Event: e})
+		}
+		for _, t := range st.transitions {
+			td, err := transitionDefinition(st, t)
+			if err != nil {
+				return Definition{}, err
+			}
+			sd.Transitions = append(sd.Transitions, td)
+		}
+		def.States = append(def.States, sd)
+	}
+	for _, t := range s.globalTransitions() {
+		td, err := transitionDefinition(s.global, t)
+		if err != nil {
+			return Definition{}, err
+		}
+		def.Global = append(def.Global, td)
+	}
+	for _, sl := range s.slas {
+		event, ok1 := sl.event.(string)
+		escalation, ok2 := toEventer(sl.escalation).Kind().(string)
+		if !ok1 || !ok2 {
+			return Definition{}, fmt.Errorf("unable to marshal SLA %s: only string event keys are supported", sl.name)
+		}
+		def.SLAs = append(def.SLAs, SLADefinition{
+			Name:       sl.name,
+			Event:      event,
+			To:         sl.target.name,
+			Within:     sl.within.String(),
+			Escalation: escalation,
+		})
+	}
+	return def, nil
+}
+
+func transitionDefinition(st *State, t *transition) (TransitionDefinition, error) {
+	td := TransitionDefinition{
+		Priority: t.priority,
+		Meta:     copyMeta(t.meta),
+		Labels:   copyMeta(t.labels),
+		Schema:   t.schema,
+		Roles:    t.roles,
+	}
+	switch {
+	case t.timeout > 0:
+		td.Timeout = t.timeout.String()
+	case t.fallback:
+		td.Fallback = true
+	case t.key != nil:
+		k, ok := t.key.(string)
+		if !ok {
+			return td, fmt.Errorf("unable to marshal event key %+v of type %T on state %s: only string keys are supported", t.key, t.key, st.name)
+		}
+		td.Event = k
+	case t.guardExpr != "" && t.conditionName == "":
+		if t.name != t.guardExpr {
+			td.Name = t.name
+		}
+	default:
+		td.Name = t.name
+		td.Condition = t.conditionName
+		if td.Condition == "" {
+			td.Condition = t.name
+		}
+	}
+	td.Guard = t.guardExpr
+	if t.action != nil {
+		td.Action = t.actionName
+		if t.params != nil {
+			td.Params = copyMeta(t.params.src)
+		}
+		return td, nil
+	}
+	td.To = t.state.name
+	return td, nil
+}
+
+// MarshalDefinition marshals the machine definition as JSON
+func (s *StateMachine) MarshalDefinition() ([]byte, error) {
+	def, err := s.Definition()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(def)
+}
+
+// LoadDefinition creates a state machine from a JSON definition, binding the handlers by name.
+// Errors are reported as a *DefinitionError, with the line and column of the offending element.
+func LoadDefinition(data []byte, handlers HandlerRegistry, opts ...func(*StateMachine)) (*StateMachine, error) {
+	return loadDefinition("", data, handlers, opts)
+}
+
+// LoadDefinitionFile creates a state machine from a JSON definition file, binding the handlers by name.
+// Errors are reported as a *DefinitionError, with the file, line and column of the offending element.
+func LoadDefinitionFile(file string, handlers HandlerRegistry, opts ...func(*StateMachine)) (*StateMachine, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return loadDefinition(file, data, handlers, opts)
+}
+
+func loadDefinition(file string, data []byte, handlers HandlerRegistry, opts []func(*StateMachine)) (*StateMachine, error) {
+	def := Definition{}
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, syntaxError(file, data, err)
+	}
+	sm, err := FromDefinition(def, handlers, opts...)
+	if err != nil {
+		return nil, locate(file, data, err)
+	}
+	return sm, nil
+}
+
+// FromDefinition creates a state machine from a definition, binding the handlers by name.
+// Errors are reported as a *DefinitionError, with the path of the offending element, like states[1].transitions[0].
+func FromDefinition(def Definition, handlers HandlerRegistry, opts ...func(*StateMachine)) (*StateMachine, error) {
+	// the name of the definition can be overridden by the options
+	sm := New(append([]func(*StateMachine){WithName(def.Name)}, opts...)...)
+
+	// states first, so that transitions can reference any of them
+	states := make([]*State, len(def.States))
+	for i, sd := range def.States {
+		path := fmt.Sprintf("states[%d]", i)
+		if sm.targetByName(sd.Name) != nil {
+			return nil, atPath(path+".name", &ErrDuplicateState{name: sd.Name})
+		}
+		var stateOpts []func(*State)
+		names := handlerNames{
+			enter: sd.OnEnter,
+			exit:  sd.OnExit,
+			event: sd.OnEvent,
+		}
+		params, err := parseParams(sd.Params)
+		if err != nil {
+			return nil, atPath(path+".params", fmt.Errorf("%w on state %s", err, sd.Name))
+		}
+		for _, h := range []struct {
+			field string
+			name  string
+			opt   func(OnHandler) func(*State)
+		}{
+			{"onEnter", sd.OnEnter, OnEnter},
+			{"onExit", sd.OnExit, OnExit},
+			{"onEvent", sd.OnEvent, OnEvent},
+		} {
+			if h.name == "" {
+				continue
+			}
+			fn, err := handlers.handler(h.name, sd.Name)
+			if err != nil {
+				return nil, atPath(path+"."+h.field, err)
+			}
+			stateOpts = append(stateOpts, h.opt(params.bind(fn)))
+		}
+		if sd.Parent != "" {
+			parent := sm.targetByName(sd.Parent)
+			if parent == nil {
+				return nil, atPath(path+".parent", &ErrStateNotFound{state: sd.Parent})
+			}
+			stateOpts = append(stateOpts, ChildOf(parent))
+		}
+		st := sm.AddState(sd.Name, stateOpts...)
+		states[i] = st
+		st.handlerNames = names
+		st.params = params
+		st.final = sd.Final
+		st.meta = copyMeta(sd.Meta)
+		for _, e := range sd.Defer {
+			st.Defer(e)
+		}
+		for _, a := range sd.Aliases {
+			st.AddAlias(sm.stateName(a))
+		}
+		if sd.Deadline != nil {
+			within, err := time.ParseDuration(sd.Deadline.Within)
+			if err != nil {
+				return nil, atPath(path+".deadline.within", fmt.Errorf("invalid deadline of state %s: %w", sd.Name, err))
+			}
+			st.Deadline(within, sd.Deadline.Escalation)
+		}
+		for j, p := range sd.Every {
+			interval, err := time.ParseDuration(p.Interval)
+			if err == nil && interval <= 0 {
+				err = errors.New("must be positive")
+			}
+			if err != nil {
+				return nil, atPath(fmt.Sprintf("%s.every[%d].interval", path, j), fmt.Errorf("invalid periodic event %s of state %s: %w", p.Event, sd.Name, err))
+			}
+			st.Every(interval, p.Event)
+		}
+	}
+
+	for i, sd := range def.States {
+		st := states[i]
+		for j, td := range sd.Transitions {
+			if err := addTransitionDefinition(sm, st, td, handlers); err != nil {
+				return nil, atPath(fmt.Sprintf("states[%d].transitions[%d]", i, j), err)
+			}
+		}
+	}
+	for i, td := range def.Global {
+		path := fmt.Sprintf("global[%d]", i)
+		if td.Event == "" {
+			return nil, atPath(path, fmt.Errorf("global transition to %s must have an event", td.To))
+		}
+		if err := addTransitionDefinition(sm, sm.globalState(), td, handlers); err != nil {
+			return nil, atPath(path, err)
+		}
+	}
+	for i, sd := range def.SLAs {
+		path := fmt.Sprintf("slas[%d]", i)
+		to := sm.targetByName(sd.To)
+		if to == nil {
+			return nil, atPath(path+".to", &ErrStateNotFound{state: sd.To})
+		}
+		within, err := time.ParseDuration(sd.Within)
+		if err != nil {
+			return nil, atPath(path+".within", fmt.Errorf("invalid SLA %s: %w", sd.Name, err))
+		}
+		sm.AddSLA(sd.Name, sd.Event, to, within, sd.Escalation)
+	}
+	return sm, nil
+}
+
+func addTransitionDefinition(sm *StateMachine, st *State, td TransitionDefinition, handlers HandlerRegistry) error {
+	if len(td.Params) > 0 && td.Action == "" {
+		return fmt.Errorf("parameters without an action on state %s", st.name)
+	}
+	params, err := parseParams(td.Params)
+	if err != nil {
+		return fmt.Errorf("%w on state %s", err, st.name)
+	}
+	var guard func(*Context) bool
+	if td.Guard != "" {
+		var err error
+		if guard, err = ParseGuard(td.Guard); err != nil {
+			return fmt.Errorf("%w on state %s", err, st.name)
+		}
+	}
+	opts := []TransitionOption{
+		WithPriority(td.Priority),
+		WithRoles(td.Roles...),
+		WithPayloadSchema(td.Schema),
+		func(t *transition) {
+			t.meta = copyMeta(td.Meta)
+			t.labels = copyMeta(td.Labels)
+			t.actionName = td.Action
+			t.conditionName = td.Condition
+			t.guardExpr = td.Guard
+			t.params = params
+		},
+	}
+	// alone, the guard is the condition of the transition
+	alone := td.Event == "" && td.Timeout == "" && !td.Fallback && td.Condition == ""
+	if guard != nil && !alone {
+		opts = append(opts, WithGuard(guard))
+	}
+	if td.Action != "" || (td.Timeout == "" && !td.Fallback && td.Condition == "" && td.Guard == "") {
+		if err := st.checkDuplicate(&transition{key: td.Event}); err != nil {
+			return err
+		}
+	}
+	if td.Action != "" {
+		fn, err := handlers.handler(td.Action, st.name)
+		if err != nil {
+			return err
+		}
+		st.AddInternalTransition(td.Event, params.bind(fn), opts...)
+		return nil
+	}
+
+	to := sm.targetByName(td.To)
+	if to == nil {
+		return &ErrStateNotFound{state: td.To}
+	}
+	switch {
+	case td.Timeout != "":
+		d, err := time.ParseDuration(td.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout on state %s: %w", st.name, err)
+		}
+		st.AddTimeoutTransition(d, to, opts...)
+	case td.Fallback:
+		st.AddFallbackTransition(to, opts...)
+	case guard != nil && alone:
+		name := td.Name
+		if name == "" {
+			name = td.Guard
+		}
+		st.AddConditionalTransition(name, to, guard, opts...)
+	case td.Condition != "":
+		cond, ok := handlers.Conditions[td.Condition]
+		if !ok {
+			return fmt.Errorf("unknown condition %q on state %s", td.Condition, st.name)
+		}
+		name := td.Name
+		if name == "" {
+			name = td.Condition
+		}
+		st.AddConditionalTransition(name, to, cond, opts...)
+	default:
+		st.AddTransition(td.Event, to, opts...)
+	}
+	return nil
+}
+
+func (r HandlerRegistry) handler(name, state string) (OnHandler, error) {
+	fn, ok := r.Handlers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown handler %q on state %s", name, state)
+	}
+	return fn, nil
+}
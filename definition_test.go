@@ -0,0 +1,68 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+const definitionJSON = `{"states":[` +
+	`{"name":"GREEN","onEnter":"log","transitions":[{"to":"YELLOW","event":"TICK"},{"action":"ping","event":"PING"}]},` +
+	`{"name":"YELLOW","transitions":[{"to":"RED","event":"TICK"},{"to":"RED","name":"emergency","condition":"isEmergency"},{"to":"EXIT","fallback":true}]},` +
+	`{"name":"RED","meta":{"sla":"1m"},"transitions":[{"to":"GREEN","timeout":"30s","meta":{"owner":"ops"}}]},` +
+	`{"name":"EXIT","deadline":{"within":"24h0m0s","escalation":"TICK"},"every":[{"interval":"5m0s","event":"POLL"}]}]}`
+
+func TestLoadDefinition(t *testing.T) {
+	var calls []string
+	registry := fsm.HandlerRegistry{
+		Handlers: map[string]fsm.OnHandler{
+			"log": func(c *fsm.Context) error {
+				calls = append(calls, "log "+c.ToState().Name())
+				return nil
+			},
+			"ping": func(c *fsm.Context) error {
+				calls = append(calls, "ping")
+				return nil
+			},
+		},
+		Conditions: map[string]func(*fsm.Context) bool{
+			"isEmergency": func(c *fsm.Context) bool {
+				return c.Key() == "EMERGENCY"
+			},
+		},
+	}
+	sm, err := fsm.LoadDefinition([]byte(definitionJSON), registry)
+	require.NoError(t, err)
+
+	smi, err := sm.FromStateName("YELLOW")
+	require.NoError(t, err)
+	require.NoError(t, smi.Fire("EMERGENCY"))
+	require.Equal(t, "RED", smi.State().Name())
+	require.NoError(t, smi.Fire(fsm.Timeout{After: 30 * time.Second}))
+	require.NoError(t, smi.Fire("PING"))
+	require.Equal(t, "GREEN", smi.State().Name())
+	require.Equal(t, []string{"log GREEN", "ping"}, calls)
+	require.NoError(t, smi.Fire("TICK"))
+	require.NoError(t, smi.Fire("LEAVE"))
+	require.Equal(t, "EXIT", smi.State().Name())
+	require.Equal(t, "state:EXIT", smi.Deadlines()[0].Name)
+
+	data, err := sm.MarshalDefinition()
+	require.NoError(t, err)
+	require.JSONEq(t, definitionJSON, string(data))
+}
+
+func TestLoadDefinitionUnknownHandler(t *testing.T) {
+	_, err := fsm.LoadDefinition([]byte(definitionJSON), fsm.HandlerRegistry{})
+	require.EqualError(t, err, `1:38:states[0].onEnter: unknown handler "log" on state GREEN`)
+}
+
+func TestMarshalDefinitionNonStringKey(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	a.AddTransition(1, a)
+	_, err := sm.MarshalDefinition()
+	require.Error(t, err)
+}
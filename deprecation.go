@@ -0,0 +1,62 @@
+package fsm
+
+import "context"
+
+type correlationKey struct{}
+
+// WithCorrelationID returns a context carrying the correlation ID of the caller.
+// Use it with FireContext so that reports, like deprecated transitions, can be traced back to the caller.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID returns the correlation ID carried by the context, if any
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// DeprecatedTransition describes the traversal of a deprecated transition
+type DeprecatedTransition struct {
+	From          *State
+	To            *State
+	Name          string
+	Reason        string
+	CorrelationID string
+}
+
+// OnDeprecatedTransition option sets the handler called every time a deprecated transition is traversed.
+// By default, the traversal is logged at debug level with the logger set by WithLogger, if any.
+func OnDeprecatedTransition(handler func(DeprecatedTransition)) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.onDeprecated = handler
+	}
+}
+
+// DeprecateTransition marks the transitions with the given name as deprecated.
+// They keep working, but every traversal is reported, so that it is possible to know if a legacy path is still used.
+func (s *State) DeprecateTransition(name string, reason string) *State {
+	s.machine.mustBeMutable()
+	for _, t := range s.transitions {
+		if t.name == name {
+			t.deprecated = true
+			t.deprecationReason = reason
+		}
+	}
+	return s
+}
+
+func (s *StateMachine) reportDeprecated(from *State, t *transition, ctx *Context) {
+	d := DeprecatedTransition{
+		From:          from,
+		To:            t.state,
+		Name:          t.name,
+		Reason:        t.deprecationReason,
+		CorrelationID: CorrelationID(ctx.Context()),
+	}
+	if s.onDeprecated != nil {
+		s.onDeprecated(d)
+		return
+	}
+	s.debug("fsm: deprecated transition", "state", d.From.name, "transition", d.Name, "to", d.To.name, "correlation_id", d.CorrelationID, "reason", d.Reason)
+}
@@ -0,0 +1,46 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeprecatedTransition(t *testing.T) {
+	var reports []fsm.DeprecatedTransition
+	sm := fsm.New(fsm.OnDeprecatedTransition(func(d fsm.DeprecatedTransition) {
+		reports = append(reports, d)
+	}))
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b).
+		AddTransition(LOOP, a).
+		DeprecateTransition(LOOP, "use TICK")
+
+	smi := sm.FromState(a)
+	ctx := fsm.WithCorrelationID(context.Background(), "req-1")
+	require.NoError(t, smi.FireContext(ctx, LOOP))
+	require.NoError(t, smi.FireContext(ctx, TICK))
+	require.Equal(t, b, smi.State())
+
+	require.Equal(t, []fsm.DeprecatedTransition{{
+		From:          a,
+		To:            a,
+		Name:          LOOP,
+		Reason:        "use TICK",
+		CorrelationID: "req-1",
+	}}, reports)
+}
+
+func TestDeprecatedTransitionLogged(t *testing.T) {
+	logger := &recordingLogger{}
+	sm := fsm.New(fsm.WithLogger(logger))
+	a := sm.AddState("A")
+	a.AddTransition(LOOP, a).DeprecateTransition(LOOP, "use TICK")
+
+	smi := sm.FromState(a)
+	require.NoError(t, smi.FireContext(fsm.WithCorrelationID(context.Background(), "req-1"), LOOP))
+	require.Contains(t, logger.lines, "DEBUG fsm: deprecated transition state=A transition=LOOP to=A correlation_id=req-1 reason=use TICK")
+}
@@ -0,0 +1,110 @@
+package fsm
+
+import "fmt"
+
+// ChangeKind is the kind of a change between two machines
+type ChangeKind int
+
+const (
+	StateAdded ChangeKind = iota + 1
+	StateRemoved
+	TransitionAdded
+	TransitionRemoved
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case StateAdded:
+		return "state added"
+	case StateRemoved:
+		return "state removed"
+	case TransitionAdded:
+		return "transition added"
+	case TransitionRemoved:
+		return "transition removed"
+	}
+	return "unknown"
+}
+
+// Change is a difference between two machines
+type Change struct {
+	Kind ChangeKind
+	// State is the name of the state, or the source state of the transition
+	State string
+	// Transition is the name of the transition, and TransitionKind its kind
+	Transition     string
+	TransitionKind TransitionKind
+	// To is the name of the target state of the transition
+	To string
+}
+
+func (c Change) String() string {
+	if c.Kind == StateAdded || c.Kind == StateRemoved {
+		return fmt.Sprintf("%s: %s", c.Kind, c.State)
+	}
+	return fmt.Sprintf("%s: %s -> %s [%s %s]", c.Kind, c.State, c.To, c.TransitionKind, c.Transition)
+}
+
+// Diff returns the states and transitions removed from the machine a and the ones added by the machine b.
+// States are matched by name and transitions by source, kind, name and target,
+// so a transition with a new target is reported as removed and added.
+// Removals come first, in the order of a, followed by the additions, in the order of b.
+// Global transitions are reported on the state named "*".
+func Diff(a, b *StateMachine) []Change {
+	var changes []Change
+	changes = append(changes, diffStates(a, b, StateRemoved)...)
+	changes = append(changes, diffTransitions(a, b, TransitionRemoved)...)
+	changes = append(changes, diffStates(b, a, StateAdded)...)
+	changes = append(changes, diffTransitions(b, a, TransitionAdded)...)
+	return changes
+}
+
+// diffStates returns the states of from missing in to
+func diffStates(from, to *StateMachine, kind ChangeKind) []Change {
+	var changes []Change
+	for _, st := range from.states {
+		if to.StateByName(st.name) == nil {
+			changes = append(changes, Change{Kind: kind, State: st.name})
+		}
+	}
+	return changes
+}
+
+// diffTransitions returns the transitions of from missing in to
+func diffTransitions(from, to *StateMachine, kind ChangeKind) []Change {
+	others := map[Change]bool{}
+	for _, c := range transitionChanges(to) {
+		others[c] = true
+	}
+	var changes []Change
+	for _, c := range transitionChanges(from) {
+		if !others[c] {
+			c.Kind = kind
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+// transitionChanges describes the transitions of the machine as changes without a kind
+func transitionChanges(sm *StateMachine) []Change {
+	var changes []Change
+	add := func(st *State) {
+		for _, t := range st.transitions {
+			info := t.info()
+			changes = append(changes, Change{
+				State:          st.name,
+				Transition:     info.Name,
+				TransitionKind: info.Kind,
+				To:             info.To.name,
+			})
+		}
+	}
+	for _, st := range sm.states {
+		add(st)
+	}
+	if sm.global != nil {
+		add(sm.global)
+	}
+	return changes
+}
@@ -0,0 +1,41 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	v1 := fsm.New().
+		AddSimpleTransition("DRAFT", "submit", "REVIEW").
+		AddSimpleTransition("REVIEW", "approve", "PUBLISHED").
+		AddSimpleTransition("REVIEW", "reject", "DRAFT")
+	v2 := fsm.New().
+		AddSimpleTransition("DRAFT", "submit", "REVIEW").
+		AddSimpleTransition("REVIEW", "approve", "SCHEDULED").
+		AddSimpleTransition("SCHEDULED", "publish", "LIVE")
+	v2.AddGlobalTransition("archive", v2.AddState("ARCHIVED"))
+
+	changes := fsm.Diff(v1, v2)
+	var report []string
+	for _, c := range changes {
+		report = append(report, c.String())
+	}
+	require.Equal(t, []string{
+		"state removed: PUBLISHED",
+		"transition removed: REVIEW -> PUBLISHED [event approve]",
+		"transition removed: REVIEW -> DRAFT [event reject]",
+		"state added: SCHEDULED",
+		"state added: LIVE",
+		"state added: ARCHIVED",
+		"transition added: REVIEW -> SCHEDULED [event approve]",
+		"transition added: SCHEDULED -> LIVE [event publish]",
+		"transition added: * -> ARCHIVED [event archive]",
+	}, report)
+	require.Equal(t, fsm.TransitionAdded, changes[6].Kind)
+	require.Equal(t, "approve", changes[6].Transition)
+
+	require.Empty(t, fsm.Diff(v1, v1))
+}
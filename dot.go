@@ -4,42 +4,106 @@ import (
 	"bytes"
 	"fmt"
 	"sort"
+	"strconv"
+	"strings"
 )
 
 type node struct {
 	name string
 	edge bool
+	meta map[string]string
+	// choice nodes are drawn as diamonds
+	choice bool
+	// children of a composite are drawn inside its cluster, with its history pseudo-states
+	children  []node
+	histories []*State
 }
 
+// DotOptions customizes the Graphviz output
+type DotOptions struct {
+	// Title is rendered at the top of the graph
+	Title string
+	// RankDir is the direction of the graph layout. Defaults to LR
+	RankDir string
+	// HighlightColor is the fill color of the current state. Defaults to gold
+	HighlightColor string
+	// HideFallbacks omits the fallback transitions
+	HideFallbacks bool
+	// DashFallbacks renders the fallback transitions as dashed edges
+	DashFallbacks bool
+	// NodeAttrs and EdgeAttrs are default attributes for all nodes and edges
+	NodeAttrs map[string]string
+	EdgeAttrs map[string]string
+}
+
+// Dot renders the machine in the Graphviz dot language, highlighting the current state, if not nil.
+// Composite states are rendered as clusters and the guards of conditional transitions between brackets.
 func (m *StateMachine) Dot(currentState *State) string {
+	return m.DotWithOptions(currentState, DotOptions{})
+}
+
+// DotWithOptions renders the machine in the Graphviz dot language, highlighting the current state, if not nil.
+func (m *StateMachine) DotWithOptions(currentState *State, opts DotOptions) string {
+	if opts.RankDir == "" {
+		opts.RankDir = "LR"
+	}
+	if opts.HighlightColor == "" {
+		opts.HighlightColor = "gold"
+	}
+	nodeAttrs := map[string]string{"shape": "circle"}
+	for k, v := range opts.NodeAttrs {
+		nodeAttrs[k] = v
+	}
+
 	var buf bytes.Buffer
-	buf.WriteString("digraph finite_state_machine {\n\trankdir=LR;")
+	buf.WriteString("digraph finite_state_machine {\n\trankdir=" + opts.RankDir + ";")
+	if m.name != "" {
+		buf.WriteString(fmt.Sprintf("\n\tlabel=%q;", m.name))
+	}
 
-	buf.WriteString("\n\tnode [shape = circle];\n")
+	nodes := m.nodes()
+	for _, n := range nodes {
+		if len(n.children) > 0 {
+			// allows edges to be clipped at the cluster boundaries
+			buf.WriteString("\n\tcompound=true;")
+			break
+		}
+	}
+	buf.WriteString("\n\tnode [" + formatAttrs(nodeAttrs) + "];\n")
+	if len(opts.EdgeAttrs) > 0 {
+		buf.WriteString("\tedge [" + formatAttrs(opts.EdgeAttrs) + "];\n")
+	}
 
 	buf.WriteString("\t# nodes\n")
-	for _, n := range m.nodes() {
-		active := n.name == currentState.name
-		buf.WriteString("\t")
-		buf.WriteString(n.name)
-		if active || n.edge {
-			buf.WriteString(" [style=filled")
-			if active {
-				buf.WriteString(", fillcolor=gold")
-			}
-			if n.edge {
-				buf.WriteString(", shape=doublecircle")
-			}
-			buf.WriteString("]")
-		}
-		buf.WriteString(";\n")
+	for _, n := range nodes {
+		writeNode(&buf, n, "\t", currentState, opts)
 	}
 
 	buf.WriteString("\t# transitions\n")
 	var transitions []string
 	for _, s := range m.states {
 		for _, t := range s.transitions {
-			transitions = append(transitions, fmt.Sprintf("\t%s -> %s [label = \"%+v\"];\n", s.name, t.state.name, t.name))
+			if opts.HideFallbacks && t.fallback {
+				continue
+			}
+			var attrs string
+			if len(t.meta) > 0 {
+				attrs += fmt.Sprintf(", tooltip = %q", formatMeta(t.meta))
+			}
+			if opts.DashFallbacks && t.fallback {
+				attrs += ", style = dashed"
+			}
+			// edges of composites start and end at their initial leaf, clipped at the cluster
+			from, to := s, t.state
+			if len(from.children) > 0 {
+				attrs += ", ltail = " + dotID("cluster_"+from.name)
+				from = from.Initial()
+			}
+			if to.pseudo == nil && len(to.children) > 0 {
+				attrs += ", lhead = " + dotID("cluster_"+to.name)
+				to = to.Initial()
+			}
+			transitions = append(transitions, fmt.Sprintf("\t%s -> %s [label = %q%s];\n", dotID(from.name), dotID(to.name), t.label(), attrs))
 		}
 	}
 	sort.Strings(transitions)
@@ -48,24 +112,132 @@ func (m *StateMachine) Dot(currentState *State) string {
 	}
 
 	buf.WriteString("\t# title")
-	buf.WriteString(fmt.Sprintf("\n\tlabelloc=\"t\";\n"))
+	buf.WriteString("\n\tlabelloc=\"t\";\n")
+	if opts.Title != "" {
+		buf.WriteString(fmt.Sprintf("\tlabel=%q;\n", opts.Title))
+	}
 	buf.WriteString("}")
 	return buf.String()
 }
 
+// formatAttrs renders the attributes sorted by name
+func formatAttrs(attrs map[string]string) string {
+	list := make([]string, 0, len(attrs))
+	for k, v := range attrs {
+		list = append(list, k+" = "+dotID(v))
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
+
+// dotID quotes the value unless it is a plain identifier or number
+func dotID(v string) string {
+	for _, r := range v {
+		if !(r == '_' || r == '.' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')) {
+			return strconv.Quote(v)
+		}
+	}
+	if v == "" {
+		return `""`
+	}
+	return v
+}
+
+// writeNode renders the node of a state, or the cluster of a composite
+func writeNode(buf *bytes.Buffer, n node, indent string, currentState *State, opts DotOptions) {
+	if len(n.children) > 0 {
+		buf.WriteString(indent + "subgraph " + dotID("cluster_"+n.name) + " {\n")
+		buf.WriteString(indent + "\tlabel=" + strconv.Quote(n.name) + ";\n")
+		if len(n.meta) > 0 {
+			buf.WriteString(fmt.Sprintf("%s\ttooltip=%q;\n", indent, formatMeta(n.meta)))
+		}
+		for _, c := range n.children {
+			writeNode(buf, c, indent+"\t", currentState, opts)
+		}
+		for _, h := range n.histories {
+			label := "H"
+			if h.pseudo.kind == deepHistory {
+				label = "H*"
+			}
+			buf.WriteString(fmt.Sprintf("%s\t%s [label=%q, shape=circle];\n", indent, dotID(h.name), label))
+		}
+		buf.WriteString(indent + "}\n")
+		return
+	}
+
+	active := currentState != nil && n.name == currentState.name
+	buf.WriteString(indent)
+	buf.WriteString(dotID(n.name))
+	var attrs []string
+	if active || n.edge {
+		attrs = append(attrs, "style=filled")
+		if active {
+			attrs = append(attrs, "fillcolor="+opts.HighlightColor)
+		}
+		if n.edge && !n.choice {
+			attrs = append(attrs, "shape=doublecircle")
+		}
+	}
+	if n.choice {
+		attrs = append(attrs, "shape=diamond")
+	}
+	if len(n.meta) > 0 {
+		attrs = append(attrs, fmt.Sprintf("tooltip=%q", formatMeta(n.meta)))
+	}
+	if len(attrs) > 0 {
+		buf.WriteString(" [")
+		buf.WriteString(strings.Join(attrs, ", "))
+		buf.WriteString("]")
+	}
+	buf.WriteString(";\n")
+}
+
+// label renders the transition name, with the guard as a suffix
+func (t *transition) label() string {
+	name := fmt.Sprintf("%+v", t.name)
+	guard := t.conditionName
+	if t.guardExpr != "" {
+		guard = t.guardExpr
+	}
+	if t.key != nil || t.timeout > 0 || t.fallback {
+		if t.guardExpr != "" {
+			return name + " [" + t.guardExpr + "]"
+		}
+		return name
+	}
+	if guard == "" || guard == t.name {
+		return "[" + name + "]"
+	}
+	return name + " [" + guard + "]"
+}
+
+// nodes returns the top level states, with the sub-states nested in their composites
 func (m *StateMachine) nodes() []node {
 	var nodes []node
 	for _, state := range m.states {
-		nodes = append(nodes, node{
-			name: state.name,
-			edge: isEnd(state) || m.isStart(state),
-		})
+		if state.parent == nil {
+			nodes = append(nodes, m.node(state))
+		}
 	}
 	return nodes
 }
 
+func (m *StateMachine) node(state *State) node {
+	n := node{
+		name:      state.name,
+		edge:      isEnd(state) || m.isStart(state),
+		meta:      state.meta,
+		choice:    state.choice,
+		histories: state.histories,
+	}
+	for _, c := range state.children {
+		n.children = append(n.children, m.node(c))
+	}
+	return n
+}
+
 func isEnd(state *State) bool {
-	return len(state.transitions) == 0
+	return state.final || len(state.transitions) == 0
 }
 
 func (m *StateMachine) isStart(state *State) bool {
@@ -84,6 +256,16 @@ func (m *StateMachine) isStart(state *State) bool {
 	return true
 }
 
+// Dot renders the machine highlighting the current state.
+// It is safe to call while other goroutines fire events.
 func (m *StateMachineInstance) Dot() string {
-	return m.StateMachine.Dot(m.currentState)
+	return m.DotWithOptions(DotOptions{})
+}
+
+// DotWithOptions renders the machine highlighting the current state.
+// It is safe to call while other goroutines fire events.
+func (m *StateMachineInstance) DotWithOptions(opts DotOptions) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.StateMachine.DotWithOptions(m.currentState, opts)
 }
@@ -0,0 +1,46 @@
+package fsm
+
+import "fmt"
+
+type ErrDuplicateTransition struct {
+	state string
+	key   interface{}
+}
+
+func (e *ErrDuplicateTransition) Error() string {
+	return fmt.Sprintf("state %s already has a transition for event %+v", e.state, e.key)
+}
+
+// State returns the name of the state with the duplicated transition
+func (e *ErrDuplicateTransition) State() string {
+	return e.state
+}
+
+// Key returns the duplicated event key
+func (e *ErrDuplicateTransition) Key() interface{} {
+	return e.key
+}
+
+// RejectDuplicateTransitions option makes AddTransition, and the other methods adding event transitions,
+// panic with ErrDuplicateTransition when the state already has a transition for an equal event key,
+// since the transition added last could never be taken. Transitions with a guard, added with WithGuard, are not duplicates.
+// Definitions with duplicated transitions are always rejected.
+func RejectDuplicateTransitions() func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.rejectDuplicateTransitions = true
+	}
+}
+
+// checkDuplicate returns ErrDuplicateTransition if the state already has a transition for the event key of the transition
+func (s *State) checkDuplicate(t *transition) error {
+	if t.key == nil || t.guarded {
+		return nil
+	}
+	key := s.machine.normalizeKey(t.key)
+	for _, other := range s.transitions {
+		if other.key != nil && !other.guarded && s.machine.keysEqual(s.machine.normalizeKey(other.key), key) {
+			return &ErrDuplicateTransition{state: s.name, key: t.key}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRejectDuplicateTransitions(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b)
+	a.AddTransition(TICK, a)
+
+	sm = fsm.New(fsm.RejectDuplicateTransitions())
+	a = sm.AddState("A")
+	b = sm.AddState("B")
+	a.AddTransition(TICK, b)
+	a.AddTimeoutTransition(time.Second, b)
+	a.AddFallbackTransition(b)
+	require.PanicsWithError(t, "state A already has a transition for event TICK", func() {
+		a.AddTransition(TICK, a)
+	})
+	require.PanicsWithError(t, "state A already has a transition for event TICK", func() {
+		a.AddInternalTransition(TICK, func(c *fsm.Context) error { return nil })
+	})
+}
+
+func TestDuplicateTransitionsInDefinition(t *testing.T) {
+	data := []byte(`{"states":[{"name":"A","transitions":[{"event":"TICK","to":"B"},{"event":"TICK","to":"A"}]},{"name":"B"}]}`)
+	_, err := fsm.LoadDefinition(data, fsm.HandlerRegistry{})
+	var dup *fsm.ErrDuplicateTransition
+	require.True(t, errors.As(err, &dup))
+	require.Equal(t, "A", dup.State())
+	require.Equal(t, "TICK", dup.Key())
+}
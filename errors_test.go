@@ -0,0 +1,42 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSentinelErrors(t *testing.T) {
+	smi, _, _, err := createFSM()
+	require.NoError(t, err)
+
+	_, err = smi.FromStateName("NOPE")
+	require.True(t, errors.Is(err, fsm.ErrUnknownState))
+
+	err = smi.Fire("UNKNOWN")
+	require.True(t, errors.Is(err, fsm.ErrUnknownTransition))
+	var notFound *fsm.ErrTransitionNotFound
+	require.True(t, errors.As(err, &notFound))
+	require.Equal(t, stateGreen, notFound.State())
+}
+
+func TestTransitionError(t *testing.T) {
+	boom := errors.New("boom")
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.OnEnter(func(c *fsm.Context) error {
+		return boom
+	}))
+	a.AddTransition(TICK, b)
+
+	err := sm.FromState(a).Fire(TICK)
+	require.True(t, errors.Is(err, boom))
+	var te *fsm.TransitionError
+	require.True(t, errors.As(err, &te))
+	require.Equal(t, a, te.From)
+	require.Equal(t, b, te.To)
+	require.Equal(t, TICK, te.Key)
+	require.Equal(t, "OnEnter", te.Handler)
+}
@@ -0,0 +1,157 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventRecord is an event accepted by an instance, with the transition it caused
+type EventRecord struct {
+	// Event is the event as fired
+	Event interface{}
+	Key   interface{}
+	From  string
+	To    string
+	Time  time.Time
+}
+
+// EventLog is an append-only log of the events accepted by instances
+type EventLog interface {
+	// Append appends the records, in order, to the log of the instance
+	Append(ctx context.Context, id string, records []EventRecord) error
+}
+
+// WithEventLog option records, for every Fire of an instance, the accepted events, including the ones fired by handlers,
+// in the order their transitions started. The events are appended once the Fire succeeds, after the instance is persisted,
+// and events of failed Fires, or of atomic batches that failed, are not recorded.
+// Errors appending to the log are returned by the Fire, after the transition happened in memory.
+func WithEventLog(log EventLog) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.eventLog = log
+	}
+}
+
+// accept records the event of a transition starting, returning its position, if there is an event log
+func (s *StateMachine) accept(from, to *State, ctx *Context) int {
+	r := ctx.run
+	if s.eventLog == nil || ctx.instance == nil {
+		return len(r.accepted)
+	}
+	r.accepted = append(r.accepted, EventRecord{
+		Event: ctx.eventer(),
+		Key:   ctx.Key(),
+		From:  from.name,
+		To:    to.name,
+		Time:  time.Now(),
+	})
+	return len(r.accepted) - 1
+}
+
+// reject discards the event recorded at the position, and the ones after it, when its transition fails
+func (r *fireRun) reject(idx int) {
+	if idx < len(r.accepted) {
+		r.accepted = r.accepted[:idx]
+	}
+}
+
+// appendLog records the accepted events of the step, appended to the log once the Fire succeeds
+func (m *StateMachineInstance) appendLog(ctx *Context) {
+	if m.eventLog == nil || len(ctx.run.accepted) == 0 {
+		return
+	}
+	m.logs.entries = append(m.logs.entries, logEntry{
+		instance: m,
+		records:  append([]EventRecord(nil), ctx.run.accepted...),
+	})
+}
+
+// flushLog appends the recorded events to the logs of the instances, in order.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) flushLog(goCtx context.Context) error {
+	if len(m.logs.entries) == 0 {
+		return nil
+	}
+	if goCtx == nil {
+		goCtx = context.Background()
+	}
+	entries := m.logs.entries
+	m.logs.entries = nil
+	for _, e := range entries {
+		if err := e.instance.eventLog.Append(goCtx, e.instance.id, e.records); err != nil {
+			return fmt.Errorf("unable to append events of instance %s: %w", e.instance.id, err)
+		}
+	}
+	return nil
+}
+
+// logJournal holds the events accepted by an instance and its sub-machines until the Fire succeeds
+type logJournal struct {
+	entries []logEntry
+}
+
+type logEntry struct {
+	instance *StateMachineInstance
+	records  []EventRecord
+}
+
+// Rehydrate rebuilds an instance from its recorded events, without calling any handler.
+// The instance starts in the From state of the first record and follows the recorded transitions,
+// failing if a record does not start in the state reached by the previous one.
+func (s *StateMachine) Rehydrate(events []EventRecord) (*StateMachineInstance, error) {
+	if len(events) == 0 {
+		return nil, errors.New("unable to rehydrate an instance without events")
+	}
+	m, err := s.FromStateName(events[0].From)
+	if err != nil {
+		return nil, err
+	}
+	for i, e := range events {
+		if s.StateByName(e.From) != m.currentState {
+			return nil, fmt.Errorf("unable to rehydrate event #%d %+v: it starts in %s but the instance is in %s", i, e.Key, e.From, m.currentState.name)
+		}
+		to := s.StateByName(e.To)
+		if to == nil {
+			return nil, fmt.Errorf("unable to rehydrate event #%d %+v: %w", i, e.Key, &ErrStateNotFound{state: e.To})
+		}
+		m.steps++
+		m.history.add(TransitionRecord{
+			From: m.currentState,
+			To:   to,
+			Key:  e.Key,
+			Time: e.Time,
+		})
+		if to != m.currentState {
+			m.monitor.track(m.id, to)
+			m.currentState = to
+			m.startSub()
+		}
+	}
+	return m, nil
+}
+
+// MemoryEventLog is an in memory EventLog, for tests and single process usage
+type MemoryEventLog struct {
+	mu     sync.Mutex
+	events map[string][]EventRecord
+}
+
+func NewMemoryEventLog() *MemoryEventLog {
+	return &MemoryEventLog{events: map[string][]EventRecord{}}
+}
+
+func (l *MemoryEventLog) Append(_ context.Context, id string, records []EventRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events[id] = append(l.events[id], records...)
+	return nil
+}
+
+// Events returns the events recorded for the instance
+func (l *MemoryEventLog) Events(id string) []EventRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]EventRecord(nil), l.events[id]...)
+}
@@ -0,0 +1,60 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventLog(t *testing.T) {
+	log := fsm.NewMemoryEventLog()
+	handled := 0
+	sm := fsm.New(fsm.WithEventLog(log))
+	idle := sm.AddState("IDLE")
+	bounce := sm.AddState("BOUNCE", fsm.OnEvent(func(c *fsm.Context) error {
+		handled++
+		return c.Fire(TICK)
+	}))
+	done := sm.AddState("DONE", fsm.OnEnter(func(c *fsm.Context) error {
+		handled++
+		return nil
+	}))
+	broken := sm.AddState("BROKEN", fsm.OnEnter(func(c *fsm.Context) error {
+		return errors.New("boom")
+	}))
+	idle.AddTransition(LOOP, bounce)
+	bounce.AddTransition(TICK, done)
+	done.AddTransition(LOOP, idle)
+	idle.AddTransition(CANCEL, broken)
+
+	smi := sm.FromState(idle)
+	smi.SetID("order-1")
+	require.NoError(t, smi.Fire(LOOP))
+	require.Error(t, smi.Fire(CANCEL))
+	require.NoError(t, smi.Fire(LOOP))
+
+	events := log.Events("order-1")
+	var transitions []string
+	for _, e := range events {
+		transitions = append(transitions, e.From+" -"+e.Key.(string)+"-> "+e.To)
+	}
+	// chained events are recorded in the order the transitions started
+	require.Equal(t, []string{
+		"IDLE -LOOP-> BOUNCE",
+		"BOUNCE -TICK-> DONE",
+		"DONE -LOOP-> IDLE",
+	}, transitions)
+
+	before := handled
+	rebuilt, err := sm.Rehydrate(events)
+	require.NoError(t, err)
+	require.Equal(t, idle, rebuilt.State())
+	require.Equal(t, before, handled)
+
+	_, err = sm.Rehydrate(events[1:2:2])
+	require.NoError(t, err)
+	_, err = sm.Rehydrate([]fsm.EventRecord{events[0], events[2]})
+	require.Error(t, err)
+}
@@ -0,0 +1,42 @@
+package fsm
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// EventTypes maps the names of events to their payload types, like the ones generated by fsmgen,
+// to build the events received from outside, like by fsmhttp, fsmbroker and fsmgrpc.
+// The types must be registered before decoding, since it is not safe for concurrent registration.
+type EventTypes struct {
+	types map[string]reflect.Type
+}
+
+// NewEventTypes creates an empty set of payload types
+func NewEventTypes() *EventTypes {
+	return &EventTypes{
+		types: map[string]reflect.Type{},
+	}
+}
+
+// Register registers the type of the prototype as the payload type of the event
+func (t *EventTypes) Register(event string, prototype Eventer) {
+	t.types[event] = reflect.TypeOf(prototype)
+}
+
+// Decode returns the event to fire: a new value of the payload type of the event, with the JSON payload decoded into it,
+// or the zero value if the payload is empty. If the event has no registered type, the payload is ignored
+// and the event name is returned.
+func (t *EventTypes) Decode(event string, payload []byte) (interface{}, error) {
+	typ, ok := t.types[event]
+	if !ok {
+		return event, nil
+	}
+	v := reflect.New(typ)
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, v.Interface()); err != nil {
+			return nil, err
+		}
+	}
+	return v.Elem().Interface(), nil
+}
@@ -0,0 +1,37 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+type refund struct {
+	Amount int `json:"amount"`
+}
+
+func (refund) Kind() interface{} {
+	return "refund"
+}
+
+func TestEventTypes(t *testing.T) {
+	types := fsm.NewEventTypes()
+	types.Register("refund", refund{})
+
+	event, err := types.Decode("refund", []byte(`{"amount": 10}`))
+	require.NoError(t, err)
+	require.Equal(t, refund{Amount: 10}, event)
+
+	event, err = types.Decode("refund", nil)
+	require.NoError(t, err)
+	require.Equal(t, refund{}, event)
+
+	_, err = types.Decode("refund", []byte(`{`))
+	require.Error(t, err)
+
+	// the payloads of unregistered events are ignored
+	event, err = types.Decode("cancel", []byte(`{"reason": "late"}`))
+	require.NoError(t, err)
+	require.Equal(t, "cancel", event)
+}
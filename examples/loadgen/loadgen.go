@@ -0,0 +1,94 @@
+// Package loadgen provides realistic example machines and a load generator
+// driving many instances of them, used as documentation and by the benchmarks.
+package loadgen
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Config configures a load generation run
+type Config struct {
+	// Instances is the number of instances to create
+	Instances int
+	// MaxEvents is the maximum number of events fired per instance
+	MaxEvents int
+	// Workers is the number of goroutines driving the instances
+	Workers int
+	// Seed makes runs reproducible. Each instance uses its own source derived from it.
+	Seed int64
+}
+
+// Report summarizes a load generation run
+type Report struct {
+	// Seed is the seed used by the run, so that it can be reproduced
+	Seed      int64
+	Instances int
+	Events    int
+	Errors    int
+	// Completed counts the instances that reached a state with nothing left to do
+	Completed int
+	Duration  time.Duration
+}
+
+// Run drives cfg.Instances instances of the machine, firing random events until
+// each instance completes or cfg.MaxEvents is reached.
+func Run(m Machine, cfg Config) Report {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+
+	start := time.Now()
+	ids := make(chan int)
+	reports := make(chan Report, cfg.Workers)
+	var wg sync.WaitGroup
+	for w := 0; w < cfg.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var r Report
+			for id := range ids {
+				drive(m, cfg, id, &r)
+			}
+			reports <- r
+		}()
+	}
+	for i := 0; i < cfg.Instances; i++ {
+		ids <- i
+	}
+	close(ids)
+	wg.Wait()
+	close(reports)
+
+	total := Report{Seed: cfg.Seed}
+	for r := range reports {
+		total.Instances += r.Instances
+		total.Events += r.Events
+		total.Errors += r.Errors
+		total.Completed += r.Completed
+	}
+	total.Duration = time.Since(start)
+	return total
+}
+
+func drive(m Machine, cfg Config, id int, r *Report) {
+	rnd := rand.New(rand.NewSource(cfg.Seed + int64(id)))
+	smi := m.Definition.FromState(m.Initial)
+	r.Instances++
+	for i := 0; i < cfg.MaxEvents; i++ {
+		evt := m.Next(smi.State(), rnd)
+		if evt == nil {
+			r.Completed++
+			return
+		}
+		r.Events++
+		if err := smi.Fire(evt); err != nil {
+			r.Errors++
+			return
+		}
+	}
+	if m.Next(smi.State(), rnd) == nil {
+		r.Completed++
+	}
+}
@@ -0,0 +1,45 @@
+package loadgen_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm/examples/loadgen"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	for _, m := range loadgen.Machines() {
+		t.Run(m.Name, func(t *testing.T) {
+			cfg := loadgen.Config{
+				Instances: 1000,
+				MaxEvents: 20,
+				Workers:   4,
+				Seed:      42,
+			}
+			r := loadgen.Run(m, cfg)
+			require.Equal(t, 1000, r.Instances)
+			require.Zero(t, r.Errors)
+			require.Equal(t, r, withDuration(loadgen.Run(m, cfg), r))
+		})
+	}
+}
+
+func withDuration(r, other loadgen.Report) loadgen.Report {
+	r.Duration = other.Duration
+	return r
+}
+
+func BenchmarkRun(b *testing.B) {
+	for _, m := range loadgen.Machines() {
+		b.Run(m.Name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				loadgen.Run(m, loadgen.Config{
+					Instances: 1000,
+					MaxEvents: 20,
+					Workers:   4,
+					Seed:      int64(i),
+				})
+			}
+		})
+	}
+}
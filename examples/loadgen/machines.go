@@ -0,0 +1,121 @@
+package loadgen
+
+import (
+	"math/rand"
+
+	"github.com/quintans/fsm"
+)
+
+// Machine is an example machine definition together with the events that drive it
+type Machine struct {
+	Name       string
+	Definition *fsm.StateMachine
+	Initial    *fsm.State
+	// events lists, per state name, the events that can be fired from that state.
+	// A state without events ends the run of an instance.
+	events map[string][]interface{}
+}
+
+// Next picks a random event to fire from the state, or nil if there is nothing left to do
+func (m Machine) Next(state *fsm.State, rnd *rand.Rand) interface{} {
+	evts := m.events[state.Name()]
+	if len(evts) == 0 {
+		return nil
+	}
+	return evts[rnd.Intn(len(evts))]
+}
+
+// TrafficLight is a cyclic machine that never ends
+func TrafficLight() Machine {
+	sm := fsm.New()
+	green := sm.AddState("GREEN")
+	yellow := sm.AddState("YELLOW")
+	red := sm.AddState("RED")
+
+	green.AddTransition("TICK", yellow)
+	yellow.AddTransition("TICK", red)
+	red.AddTransition("TICK", green)
+
+	return Machine{
+		Name:       "traffic-light",
+		Definition: sm,
+		Initial:    green,
+		events: map[string][]interface{}{
+			"GREEN":  {"TICK"},
+			"YELLOW": {"TICK"},
+			"RED":    {"TICK"},
+		},
+	}
+}
+
+// OrderFulfillment is a linear workflow that can be cancelled until the order is picked
+func OrderFulfillment() Machine {
+	sm := fsm.New()
+	created := sm.AddState("CREATED")
+	paid := sm.AddState("PAID")
+	picking := sm.AddState("PICKING")
+	packed := sm.AddState("PACKED")
+	shipped := sm.AddState("SHIPPED")
+	delivered := sm.AddState("DELIVERED")
+	cancelled := sm.AddState("CANCELLED")
+
+	created.AddTransition("pay", paid)
+	created.AddTransition("cancel", cancelled)
+	paid.AddTransition("pick", picking)
+	paid.AddTransition("cancel", cancelled)
+	picking.AddTransition("pack", packed)
+	packed.AddTransition("ship", shipped)
+	shipped.AddTransition("deliver", delivered)
+
+	return Machine{
+		Name:       "order-fulfillment",
+		Definition: sm,
+		Initial:    created,
+		events: map[string][]interface{}{
+			"CREATED": {"pay", "pay", "pay", "cancel"},
+			"PAID":    {"pick", "pick", "pick", "cancel"},
+			"PICKING": {"pack"},
+			"PACKED":  {"ship"},
+			"SHIPPED": {"deliver"},
+		},
+	}
+}
+
+// Saga books a trip, reserving a hotel and a flight.
+// When the flight reservation fails, the hotel reservation is compensated
+// by a transitional state that chains into the aborted state.
+func Saga() Machine {
+	sm := fsm.New()
+	started := sm.AddState("STARTED")
+	hotelReserved := sm.AddState("HOTEL_RESERVED")
+	confirmed := sm.AddState("CONFIRMED")
+	compensating := sm.AddState("COMPENSATING", fsm.OnEvent(func(c *fsm.Context) error {
+		return c.Fire("hotel_cancelled")
+	}))
+	aborted := sm.AddState("ABORTED")
+
+	started.AddTransition("hotel_reserved", hotelReserved)
+	started.AddTransition("hotel_failed", aborted)
+	hotelReserved.AddTransition("flight_reserved", confirmed)
+	hotelReserved.AddTransition("flight_failed", compensating)
+	compensating.AddTransition("hotel_cancelled", aborted)
+
+	return Machine{
+		Name:       "saga",
+		Definition: sm,
+		Initial:    started,
+		events: map[string][]interface{}{
+			"STARTED":        {"hotel_reserved", "hotel_reserved", "hotel_reserved", "hotel_failed"},
+			"HOTEL_RESERVED": {"flight_reserved", "flight_reserved", "flight_reserved", "flight_failed"},
+		},
+	}
+}
+
+// Machines returns all the example machines
+func Machines() []Machine {
+	return []Machine{
+		TrafficLight(),
+		OrderFulfillment(),
+		Saga(),
+	}
+}
@@ -0,0 +1,33 @@
+package fsm
+
+import "errors"
+
+// ErrMachineCompleted is returned when firing an event from a final state
+var ErrMachineCompleted = errors.New("machine completed: no events are accepted on a final state")
+
+// Final option marks a state as terminal. Firing an event from a final state returns ErrMachineCompleted.
+func Final() func(*State) {
+	return func(s *State) {
+		s.final = true
+	}
+}
+
+// IsFinal reports if the state is terminal
+func (s *State) IsFinal() bool {
+	return s.final
+}
+
+// OnCompleted option sets the handler called when a final state is entered,
+// after the transition listeners. An error fails the transition.
+func OnCompleted(handler OnHandler) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.onCompleted = handler
+	}
+}
+
+// Done reports if the instance reached a final state
+func (m *StateMachineInstance) Done() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.currentState.final
+}
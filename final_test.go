@@ -0,0 +1,30 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFinalState(t *testing.T) {
+	var completed []string
+	sm := fsm.New(fsm.OnCompleted(func(c *fsm.Context) error {
+		completed = append(completed, c.ToState().Name())
+		return nil
+	}))
+	a := sm.AddState("A")
+	done := sm.AddState("DONE", fsm.Final())
+	a.AddTransition(TICK, done)
+	done.AddTransition(TICK, a)
+
+	smi := sm.FromState(a)
+	require.False(t, smi.Done())
+	require.NoError(t, smi.Fire(TICK))
+	require.True(t, smi.Done())
+	require.True(t, done.IsFinal())
+	require.Equal(t, []string{"DONE"}, completed)
+
+	require.ErrorIs(t, smi.Fire(TICK), fsm.ErrMachineCompleted)
+	require.Equal(t, done, smi.State())
+}
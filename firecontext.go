@@ -0,0 +1,39 @@
+package fsm
+
+import (
+	"context"
+	"time"
+)
+
+// fireScratch holds the state of the Fire of an instance, reused by the next Fire to avoid allocations
+type fireScratch struct {
+	ctx  Context
+	run  fireRun
+	path [4]*State
+}
+
+// newContext prepares the context of a Fire of the instance, reusing the one of the previous Fire.
+// Raw keys are only wrapped in an Event if a handler asks for it.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) newContext(goCtx context.Context, key interface{}) *Context {
+	if m.scratch == nil {
+		m.scratch = &fireScratch{}
+	}
+	sc := m.scratch
+	sc.run = fireRun{
+		start: time.Now(),
+		path:  append(sc.path[:0], m.currentState),
+	}
+	sc.ctx = Context{
+		machine:  m.StateMachine,
+		instance: m,
+		context:  m.withValues(goCtx),
+		run:      &sc.run,
+	}
+	if e, ok := key.(Eventer); ok {
+		sc.ctx.event = e
+	} else {
+		sc.ctx.raw = key
+	}
+	return &sc.ctx
+}
@@ -0,0 +1,20 @@
+package fsm
+
+// Freeze makes the machine an immutable definition, safe to be shared by any number of instances.
+// Afterwards, any method changing the machine, its states or transitions panics.
+// Per instance behaviour, like listeners and fallback handlers, is set through the instance methods.
+func (s *StateMachine) Freeze() *StateMachine {
+	s.frozen = true
+	return s
+}
+
+// Frozen checks if the machine was frozen
+func (s *StateMachine) Frozen() bool {
+	return s.frozen
+}
+
+func (s *StateMachine) mustBeMutable() {
+	if s.frozen {
+		panic("fsm: the machine is frozen and cannot be changed")
+	}
+}
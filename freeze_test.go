@@ -0,0 +1,58 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreeze(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b)
+	require.False(t, sm.Frozen())
+	require.True(t, sm.Freeze().Frozen())
+
+	require.Panics(t, func() { sm.AddState("C") })
+	require.Panics(t, func() { a.AddTransition(LOOP, a) })
+	require.Panics(t, func() { b.AddTimeoutTransition(1, a) })
+	require.Panics(t, func() { a.AddOnEnter(func(c *fsm.Context) error { return nil }) })
+	require.Panics(t, func() { sm.AddOnTransition(func(c *fsm.Context) error { return nil }) })
+	require.Panics(t, func() { sm.AddGlobalTransition(LOOP, a) })
+
+	// instances keep working
+	smi := sm.FromState(a)
+	require.NoError(t, smi.Fire(TICK))
+	require.Equal(t, b, smi.State())
+}
+
+func TestInstanceListeners(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b)
+	b.AddTransition(TICK, a)
+	var calls []string
+	sm.AddOnTransition(func(c *fsm.Context) error {
+		calls = append(calls, "machine")
+		return nil
+	})
+	sm.Freeze()
+
+	first := sm.FromState(a)
+	first.AddBeforeTransition(func(c *fsm.Context) error {
+		calls = append(calls, "before")
+		return nil
+	})
+	first.AddOnTransition(func(c *fsm.Context) error {
+		calls = append(calls, "first")
+		return nil
+	})
+	second := sm.FromState(a)
+
+	require.NoError(t, first.Fire(TICK))
+	require.NoError(t, second.Fire(TICK))
+	require.Equal(t, []string{"before", "machine", "first", "machine"}, calls)
+}
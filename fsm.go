@@ -2,7 +2,20 @@ package fsm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrUnknownState is matched by errors.Is for any ErrStateNotFound
+	ErrUnknownState = errors.New("state not found")
+	// ErrUnknownTransition is matched by errors.Is for any ErrTransitionNotFound
+	ErrUnknownTransition = errors.New("transition not found")
+	// ErrFireNotAllowed is returned when Context.Fire is called outside of an OnEvent handler
+	ErrFireNotAllowed = errors.New("fire is only allowed on event")
 )
 
 type ErrStateNotFound struct {
@@ -17,6 +30,10 @@ func (e *ErrStateNotFound) State() string {
 	return e.state
 }
 
+func (e *ErrStateNotFound) Is(target error) bool {
+	return target == ErrUnknownState
+}
+
 type ErrTransitionNotFound struct {
 	state string
 	key   interface{}
@@ -34,6 +51,46 @@ func (e *ErrTransitionNotFound) State() string {
 	return e.state
 }
 
+func (e *ErrTransitionNotFound) Is(target error) bool {
+	return target == ErrUnknownTransition
+}
+
+// TransitionError wraps an error returned by a handler with the transition where it happened
+type TransitionError struct {
+	From *State
+	To   *State
+	Key  interface{}
+	// Handler is the handler that failed: OnExit, OnEnter, OnEvent, Action,
+	// BeforeTransition, Observer.Exit, Observer.Enter, OnTransition or OnCompleted
+	Handler string
+	Err     error
+	// Rollback is the error returned by the compensation, when rollback is enabled
+	Rollback error
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%s handler failed on transition %s -> %s for %+v: %s", e.Handler, e.From, e.To, e.Key, e.Err)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return e.Err
+}
+
+// handlerError wraps the error of a handler, unless it already comes from a chained transition
+func handlerError(handler string, ctx *Context, err error) error {
+	var te *TransitionError
+	if errors.As(err, &te) {
+		return err
+	}
+	return &TransitionError{
+		From:    ctx.FromState(),
+		To:      ctx.ToState(),
+		Key:     ctx.Key(),
+		Handler: handler,
+		Err:     err,
+	}
+}
+
 type Eventer interface {
 	Kind() interface{}
 }
@@ -56,80 +113,243 @@ func toEventer(e interface{}) Eventer {
 
 // StateMachine represents a Finite State Machine (FSM)
 type StateMachine struct {
-	states                []*State
+	name   string
+	states []*State
+	// byName indexes the states by their name key
+	byName map[string]*State
+	// byAlias indexes the states by their alias key
+	byAlias map[string]*State
+	// declared are the declared event keys, indexed by token in tokens
+	declared              []interface{}
+	tokens                map[interface{}]EventToken
 	onTransitionListeners []OnHandler
-	fallbackHandler       func(*Context) *State
+	fallbackResolvers     []func(*Context) *State
+	stepBudget            int
+	onStepBudgetExceeded  func(*StateMachineInstance)
+	keyNormalizer         func(interface{}) interface{}
+	keyComparator         func(a, b interface{}) bool
+	foldStrings           bool
+	seed                  int64
+	rnd                   *rand.Rand
+	onDrift               func(Snapshot) (Snapshot, error)
+	rollback              bool
+	rollbackHandler       OnHandler
+	onDeprecated          func(DeprecatedTransition)
+	maxHandlers           int
+	maxFireDuration       time.Duration
+	beforeListeners       []OnHandler
+	observers             []Observer
+	ignoreListenerErrors  bool
+	strictMatching        bool
+	tracer                *Tracer
+	onUnknownState        func(*StateMachine, string) (*State, error)
+	onCompleted           OnHandler
+	chaos                 *Chaos
+	quota                 Quota
+	// global holds the transitions that apply from any state
+	global  *State
+	slas    []*sla
+	frozen  bool
+	monitor *Monitor
+	metrics Metrics
+	logger  Logger
+	// namingPolicy maps the names given to AddState
+	namingPolicy     func(string) string
+	rejectDuplicates bool
+	// rejectDuplicateTransitions makes adding a transition for an event key already handled by the state panic
+	rejectDuplicateTransitions bool
+	handlerTimeout             time.Duration
+	maxChainDepth              int
+	reenterOnSelf              bool
+	onRetry                    func(*Context, string, int, error)
+	sagaMode                   bool
+	sagaAborted                *State
+	eventLog                   EventLog
+	// idempotencyWindow is the number of idempotency keys remembered by each instance
+	idempotencyWindow int
+	transactor        Transactor
+	timerStore        TimerStore
+	ignoreUnhandled   bool
+	strict            bool
 }
 
 // New creates a new FSM
-func New() *StateMachine {
-	return &StateMachine{
+func New(opts ...func(*StateMachine)) *StateMachine {
+	sm := &StateMachine{
 		onTransitionListeners: []OnHandler{},
 	}
+	RandSeed(time.Now().UnixNano())(sm)
+	for _, o := range opts {
+		o(sm)
+	}
+	return sm
 }
 
-// StateByName gets a registered state with the specified name
+// StateByName gets a registered state with the specified name, or else with the specified alias
 func (s *StateMachine) StateByName(name string) *State {
-	for _, s := range s.states {
-		if s.name == name {
-			return s
-		}
+	if st, ok := s.byName[s.nameKey(name)]; ok {
+		return st
 	}
-	return nil
+	return s.stateByAlias(name)
+}
+
+// States returns the registered states, in registration order
+func (s *StateMachine) States() []*State {
+	return append([]*State(nil), s.states...)
 }
 
 // FromState sets the current State. No event handlers will be called.
+// The instance shares the machine definition, so changing the machine affects all its instances.
+// Freeze the machine to prevent it, and use the instance methods, like SetFallbackHandler or AddOnTransition,
+// for per instance behaviour.
 func (s *StateMachine) FromState(state *State) *StateMachineInstance {
-	smCopy := *s
-	return &StateMachineInstance{
-		StateMachine: &smCopy,
+	if s.metrics != nil {
+		s.metrics.Occupancy(state.name, 1)
+	}
+	m := s.newInstance(state)
+	s.monitor.track(m.id, state)
+	m.startSub()
+	return m
+}
+
+// newInstance creates an instance in the state, without accounting for it in the monitor and the metrics,
+// like a restored instance, that was accounted for when created
+func (s *StateMachine) newInstance(state *State) *StateMachineInstance {
+	m := &StateMachineInstance{
+		StateMachine: s,
+		id:           newInstanceID(),
 		currentState: state,
+		createdAt:    time.Now(),
+		slots:        &slotJournal{},
+		logs:         &logJournal{},
 	}
+	m.enterDeadline(nil)
+	return m
 }
 
 // FromStateName sets the current State using the name of the state.
 // No event handlers will be called.
+// If the state does not exist, the OnUnknownState hook, if any, is used to recover.
 func (s *StateMachine) FromStateName(name string) (*StateMachineInstance, error) {
+	state, err := s.lookupState(name)
+	if err != nil {
+		return nil, err
+	}
+	return s.FromState(state), nil
+}
+
+// lookupState returns the state with the name, recovering with the OnUnknownState hook, if any
+func (s *StateMachine) lookupState(name string) (*State, error) {
 	state := s.StateByName(name)
+	if state == nil && s.onUnknownState != nil {
+		var err error
+		state, err = s.onUnknownState(s, name)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if state == nil {
 		return nil, &ErrStateNotFound{state: name}
 	}
-	return s.FromState(state), nil
+	return state, nil
+}
+
+// OnUnknownState option sets the hook called when FromStateName does not find the state,
+// like a persisted state that no longer exists in the definition.
+// The hook can map it to another state, like a quarantine state, or reject it by returning an error.
+// Returning a nil state and no error results in ErrStateNotFound.
+func OnUnknownState(hook func(sm *StateMachine, name string) (*State, error)) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.onUnknownState = hook
+	}
 }
 
 // AddOnTransition add a transition listener.
 // Is only used to report transitions that have already happened, fired AFTER a transition has happened.
+// All the listeners are called and the first error fails the Fire, unless IgnoreListenerErrors is set.
+// Listeners of a single transition can be added with the OnFire option.
+// Chained transitions, fired by handlers with Context.Fire, are reported in causal order:
+// the listeners of a transition are called before the ones of the transitions it chains.
+// A listener error then aborts the chained Fire and fails the transition.
 func (s *StateMachine) AddOnTransition(listener OnHandler) {
+	s.mustBeMutable()
 	s.onTransitionListeners = append(s.onTransitionListeners, listener)
 }
 
-func (s *StateMachine) fireOnTransition(ctx *Context) {
-	for _, v := range s.onTransitionListeners {
-		v(ctx)
+// IgnoreListenerErrors option discards the errors returned by the transition listeners
+func IgnoreListenerErrors() func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.ignoreListenerErrors = true
+	}
+}
+
+func (s *StateMachine) fireOnTransition(ctx *Context) error {
+	var first error
+	listeners := s.onTransitionListeners
+	if ctx.matched != nil && len(ctx.matched.listeners) > 0 {
+		listeners = append(ctx.matched.listeners[:len(ctx.matched.listeners):len(ctx.matched.listeners)], listeners...)
+	}
+	if ctx.instance != nil && len(ctx.instance.onTransitionListeners) > 0 {
+		listeners = append(listeners[:len(listeners):len(listeners)], ctx.instance.onTransitionListeners...)
+	}
+	for _, v := range listeners {
+		if err := v(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+	for _, o := range s.observersFor(ctx) {
+		if err := o.AfterTransition(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+	if s.ignoreListenerErrors {
+		return nil
+	}
+	return first
+}
+
+// notifyTransition calls the transition listeners only once.
+// A Context.Fire from a handler notifies the running transition before the chained one,
+// so that listeners observe the transitions in causal order.
+func (s *StateMachine) notifyTransition(ctx *Context) error {
+	if ctx.notified {
+		return nil
 	}
+	ctx.notified = true
+	return s.fireOnTransition(ctx)
 }
 
 // AddState adds or overrides a state to the StateMachine.
+// The name is mapped by the NamingPolicy, if any, and with RejectDuplicateStates overriding a state panics.
 func (s *StateMachine) AddState(name string, opts ...func(*State)) *State {
+	s.mustBeMutable()
+	name = s.stateName(name)
 	state := &State{
-		name: name,
+		name:    name,
+		machine: s,
 	}
 	for _, o := range opts {
 		o(state)
 	}
 
-	idx := -1
-	for k, s := range s.states {
-		if s.name == name {
-			idx = k
-			break
+	key := s.nameKey(name)
+	if old, ok := s.byName[key]; ok {
+		if s.rejectDuplicates {
+			panic(&ErrDuplicateState{name: name})
+		}
+		for k, st := range s.states {
+			if st == old {
+				s.states[k] = state
+				break
+			}
 		}
-	}
-	if idx != -1 {
-		s.states[idx] = state
 	} else {
 		s.states = append(s.states, state)
 	}
+	if s.byName == nil {
+		s.byName = map[string]*State{}
+	}
+	s.byName[key] = state
 	return state
 }
 
@@ -141,6 +361,23 @@ func (s *StateMachine) Fire(currentState *State, key interface{}) (*State, error
 		event:   toEventer(key),
 	}
 
+	if s.transactor == nil {
+		return s.fireContext(currentState, ctx)
+	}
+	var next *State
+	err := s.transact(nil, func(goCtx context.Context) error {
+		ctx.context = goCtx
+		var err error
+		next, err = s.fireContext(currentState, ctx)
+		return err
+	})
+	return next, err
+}
+
+func (s *StateMachine) fireContext(currentState *State, ctx *Context) (*State, error) {
+	if ctx.run == nil {
+		ctx.run = newFireRun(currentState)
+	}
 	err := s.fire(currentState, ctx)
 	if err != nil {
 		return nil, err
@@ -150,115 +387,565 @@ func (s *StateMachine) Fire(currentState *State, key interface{}) (*State, error
 
 func (s *StateMachine) fire(currentState *State, ctx *Context) error {
 	state := currentState
+	if state.final {
+		return ErrMachineCompleted
+	}
+	if err := s.checkDeclared(ctx); err != nil {
+		return err
+	}
 	var nextState *State
-	for _, t := range state.transitions {
-		if t.condition(ctx) {
-			nextState = t.state
-			break
+	t, err := s.matchHierarchy(state, ctx)
+	if err != nil {
+		return err
+	}
+	if t != nil {
+		if ctx.instance != nil && ctx.instance.throttled(t, ctx) {
+			s.debug("fsm: event throttled", "state", state.name, "event", ctx.Key())
+			ctx.ignored = true
+			ctx.deepest = state
+			return nil
+		}
+		ctx.matched = t
+		if t.deprecated {
+			s.reportDeprecated(state, t, ctx)
 		}
+		if t.action != nil {
+			idx := s.accept(state, state, ctx)
+			err := s.internalTransition(state, t.action, ctx)
+			if err != nil {
+				ctx.run.reject(idx)
+			}
+			return err
+		}
+		nextState = t.state
 	}
-	if nextState == nil && s.fallbackHandler != nil {
+	if nextState == nil {
 		// get the dynamic fallback state transition for this machine
-		nextState = s.fallbackHandler(ctx)
+		nextState = s.resolveFallback(ctx)
+		if nextState != nil {
+			s.debug("fsm: fallback resolved", "state", state.name, "event", ctx.Key(), "to", nextState.name)
+		}
 	}
 
 	if nextState == nil {
+		if s.ignoreUnhandled {
+			s.debug("fsm: event ignored", "state", state.name, "event", ctx.Key())
+			ctx.ignored = true
+			ctx.deepest = state
+			return nil
+		}
+		s.debug("fsm: no transition", "state", state.name, "event", ctx.Key())
 		return &ErrTransitionNotFound{state: state.name, key: ctx.Key()}
 	}
+	nextState, err = s.resolveChoice(s.resolveTarget(nextState, ctx), ctx)
+	if err != nil {
+		return err
+	}
 
-	if err := s.transition(state, nextState, ctx); err != nil {
+	mark := s.slotMark(ctx)
+	admitted, err := s.admit(state, nextState, ctx)
+	if err != nil {
+		return err
+	}
+	idx := s.accept(state, admitted, ctx)
+	if err := s.transition(state, admitted, ctx); err != nil {
+		ctx.run.reject(idx)
+		s.rollbackSlots(mark, state, admitted, ctx)
 		return err
 	}
 
-	return nil
+	return s.release(state, admitted, ctx)
 }
 
 // transition transitions the state machine to the specified state
 // calling the appropriate event handlers
 func (s *StateMachine) transition(currentState, nextState *State, ctx *Context) error {
+	start := time.Now()
 	ctx.setFrom(currentState)
 	ctx.setTo(nextState)
+	ctx.run.visit(nextState)
 
-	diffState := nextState != currentState
-	exitHandler := currentState.onExit
-	if diffState && currentState != nil && exitHandler != nil {
-		if err := exitHandler(ctx); err != nil {
-			return err
+	if err := s.fireBeforeTransition(ctx); err != nil {
+		return handlerError("BeforeTransition", ctx, err)
+	}
+
+	ctx.reentry = s.reenterOnSelf && currentState != nil && nextState == currentState
+	diffState := nextState != currentState || ctx.reentry
+	if diffState && currentState != nil {
+		if exitHandler := currentState.onExit; exitHandler != nil {
+			if err := s.call("OnExit", exitHandler, ctx); err != nil {
+				return handlerError("OnExit", ctx, err)
+			}
+		}
+		if err := s.notifyObservers(Observer.Exit, ctx); err != nil {
+			return handlerError("Observer.Exit", ctx, err)
 		}
 	}
 
-	if diffState && nextState.onEnter != nil {
-		if err := nextState.onEnter(ctx); err != nil {
-			return err
+	if diffState {
+		if nextState.onEnter != nil {
+			if err := s.call("OnEnter", nextState.onEnter, ctx); err != nil {
+				return s.compensate(currentState, nextState, ctx, handlerError("OnEnter", ctx, err))
+			}
+		}
+		if err := s.notifyObservers(Observer.Enter, ctx); err != nil {
+			return s.compensate(currentState, nextState, ctx, handlerError("Observer.Enter", ctx, err))
+		}
+		s.recordSaga(ctx)
+	}
+
+	// the entry is recorded before the chained transitions, and undone if the transition fails
+	entered := diffState && ctx.instance != nil
+	var entry statsEntry
+	if entered {
+		entry = ctx.instance.entered(currentState, nextState, time.Now())
+	}
+	fail := func(err error) error {
+		if entered {
+			ctx.instance.undoEntered(entry)
 		}
+		return s.compensate(currentState, nextState, ctx, err)
 	}
 
-	if nextState.onEvent != nil {
+	if onEvent := nextState.eventHandler(ctx); onEvent != nil {
 		ctx.canFire = true
-		err := nextState.onEvent(ctx)
+		err := s.call("OnEvent", onEvent, ctx)
 		ctx.canFire = false
+		if ctx.listenerErr != nil {
+			return fail(handlerError("OnTransition", ctx, ctx.listenerErr))
+		}
 		if err != nil {
-			return err
+			return fail(handlerError("OnEvent", ctx, err))
+		}
+	}
+
+	if err := s.notifyTransition(ctx); err != nil {
+		return fail(handlerError("OnTransition", ctx, err))
+	}
+
+	if diffState && nextState.final && s.onCompleted != nil {
+		if err := s.onCompleted(ctx); err != nil {
+			return fail(handlerError("OnCompleted", ctx, err))
 		}
 	}
 
-	s.fireOnTransition(ctx)
+	if ctx.instance != nil {
+		ctx.instance.transitioned(ctx, start)
+	}
+
+	return nil
+}
+
+// internalTransition executes the action of an internal transition without leaving the current state
+func (s *StateMachine) internalTransition(state *State, action OnHandler, ctx *Context) error {
+	start := time.Now()
+	ctx.setFrom(state)
+	ctx.setTo(state)
+	ctx.run.visit(state)
+
+	if err := s.fireBeforeTransition(ctx); err != nil {
+		return handlerError("BeforeTransition", ctx, err)
+	}
+
+	ctx.canFire = true
+	err := s.call("Action", action, ctx)
+	ctx.canFire = false
+	if ctx.listenerErr != nil {
+		return handlerError("OnTransition", ctx, ctx.listenerErr)
+	}
+	if err != nil {
+		return handlerError("Action", ctx, err)
+	}
+
+	if err := s.notifyTransition(ctx); err != nil {
+		return handlerError("OnTransition", ctx, err)
+	}
+
+	if ctx.instance != nil {
+		ctx.instance.transitioned(ctx, start)
+	}
 
 	return nil
 }
 
 // SetFallbackHandler sets the fallback handler when an Event is not handled by any of the transitions of the current state.
+// It replaces any fallback resolver previously added.
 func (s *StateMachine) SetFallbackHandler(handler func(*Context) *State) {
-	s.fallbackHandler = handler
+	s.mustBeMutable()
+	s.fallbackResolvers = []func(*Context) *State{handler}
 }
 
+// AddFallbackResolver appends a resolver to the fallback chain, used when an Event is not handled
+// by any of the transitions of the current state.
+// Resolvers are called in the order they were added until one returns a state.
+// A resolver declines by returning nil, allowing concerns like metrics or dead letters to compose.
+func (s *StateMachine) AddFallbackResolver(resolver func(*Context) *State) {
+	s.mustBeMutable()
+	s.fallbackResolvers = append(s.fallbackResolvers, resolver)
+}
+
+// resolveFallback calls the resolvers of the instance, if any, and then the ones of the machine
+func (s *StateMachine) resolveFallback(ctx *Context) *State {
+	if ctx.instance != nil {
+		for _, r := range ctx.instance.fallbackResolvers {
+			if state := r(ctx); state != nil {
+				return state
+			}
+		}
+	}
+	for _, r := range s.fallbackResolvers {
+		if state := r(ctx); state != nil {
+			return state
+		}
+	}
+	return nil
+}
+
+// StateMachineInstance is a StateMachine positioned in a state.
+// Fire and the instance getters are safe to be called concurrently.
+// Handlers must not call the instance methods, since they run while the instance is locked.
 type StateMachineInstance struct {
 	*StateMachine
+	mu           sync.RWMutex
+	id           string
+	payload      interface{}
 	currentState *State
+	createdAt    time.Time
+	steps        int
+	scheduler    scheduler
+	history      history
+	deferred     []interface{}
+	// lastActive is the last active sub-state of each composite state
+	lastActive map[*State]*State
+	// fallbackResolvers are tried before the ones of the machine
+	fallbackResolvers []func(*Context) *State
+	// deadlines are the pending SLAs and state deadlines, by name
+	deadlines map[string]time.Time
+	slaTimers map[string]*time.Timer
+	// timerOps are the changes of the deadlines not yet applied to the timer store
+	timerOps []timerOp
+	// listeners and observers of this instance, called after the ones of the machine
+	onTransitionListeners []OnHandler
+	beforeListeners       []OnHandler
+	observers             []Observer
+	// sub is the running instance of the sub-machine of the current state
+	sub   *StateMachineInstance
+	async asyncWork
+	// sagaTrail are the entered states with a compensation, in saga mode
+	sagaTrail []sagaStep
+	// store and version are set to persist the instance after every transition
+	store   Store
+	version int64
+	// seen are the idempotency keys of the processed events
+	seen seenKeys
+	// scratch is reused by every Fire of the instance
+	scratch *fireScratch
+	// outcome is the outcome of the last event
+	outcome FireOutcome
+	// values are attached with WithValue, and available to every handler
+	values context.Context
+	// throttles are the states of the debounced and rate limited transitions
+	throttles map[*transition]*throttleState
+	// queue are the posted events, fired in the background
+	queue eventQueue
+	// stats are the entries and dwell times of the states
+	stats dwellStats
+	// slot is the state whose quota slot the instance holds, if any
+	slot *State
+	// slots are the uncommitted quota changes, shared with the sub-machines
+	slots *slotJournal
+	// logs are the events accepted by the running Fire, shared with the sub-machines
+	logs *logJournal
+}
+
+// SetFallbackHandler sets the fallback handler of this instance, tried before the fallback resolvers of the machine.
+// It replaces any fallback resolver previously added to this instance.
+func (m *StateMachineInstance) SetFallbackHandler(handler func(*Context) *State) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fallbackResolvers = []func(*Context) *State{handler}
+}
+
+// AddFallbackResolver appends a resolver to the fallback chain of this instance,
+// tried before the fallback resolvers of the machine.
+func (m *StateMachineInstance) AddFallbackResolver(resolver func(*Context) *State) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.fallbackResolvers = append(m.fallbackResolvers, resolver)
 }
 
 // Fire is called to submit an event to the FSM
 // triggering the appropriate state transition, if any is registered for the event.
 func (m *StateMachineInstance) Fire(key interface{}) error {
-	cur, err := m.StateMachine.Fire(m.currentState, key)
+	return m.fireWhen(nil, nil, key)
+}
+
+// FireContext is like Fire, making the context available to the handlers through Context.Context()
+func (m *StateMachineInstance) FireContext(ctx context.Context, key interface{}) error {
+	return m.fireWhen(ctx, nil, key)
+}
+
+// fireWhen fires the event only if the guard, evaluated while holding the lock, returns true
+func (m *StateMachineInstance) fireWhen(goCtx context.Context, guard func() bool, key interface{}) error {
+	_, err := m.fireOutcome(goCtx, guard, key)
+	return err
+}
+
+// fireOutcome is like fireWhen, also returning the outcome of the event
+func (m *StateMachineInstance) fireOutcome(goCtx context.Context, guard func() bool, key interface{}) (FireOutcome, error) {
+	outcome, exceeded, err := m.fireLocked(goCtx, guard, key)
+	if exceeded {
+		m.onStepBudgetExceeded(m)
+	}
+	return outcome, err
+}
+
+// fireLocked fires the event holding the lock, also reporting if the step budget was crossed.
+// The lock is released even if a handler panics.
+func (m *StateMachineInstance) fireLocked(goCtx context.Context, guard func() bool, key interface{}) (FireOutcome, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if guard != nil && !guard() {
+		return Ignored, false, nil
+	}
+	before := m.steps
+	var sp *savepoint
+	if m.transactor != nil || m.store != nil {
+		sp = m.savepoint()
+	}
+	var fired, saved bool
+	var err error
+	if m.transactor != nil {
+		fired, saved, err = m.fireInTransaction(goCtx, before, key)
+	} else {
+		fired, saved, err = m.fireAndPersist(goCtx, before, key)
+	}
+	// a Fire that could not be saved is undone, so that the instance does not diverge from the store
+	if err != nil && fired && (m.transactor != nil || !saved) {
+		moved := m.currentState != sp.state
+		m.restoreSavepoint(sp)
+		if moved {
+			m.schedule()
+		}
+	}
+	m.logs.entries = nil
+	if serr := m.commitSlots(goCtx); serr != nil && err == nil {
+		err = serr
+	}
+	return m.outcome, m.budgetCrossed(before), err
+}
+
+// fireAndPersist fires the event and persists the instance, if it transitioned since the given step,
+// reporting if the event was fired and if the instance was saved
+func (m *StateMachineInstance) fireAndPersist(goCtx context.Context, before int, key interface{}) (bool, bool, error) {
+	if err := m.fire(goCtx, key); err != nil {
+		return false, false, err
+	}
+	if m.steps != before {
+		if err := m.persist(goCtx); err != nil {
+			return true, false, err
+		}
+	}
+	// the timers must not change if the instance was not saved
+	if err := m.syncTimers(goCtx); err != nil {
+		return true, true, err
+	}
+	return true, true, m.flushLog(goCtx)
+}
+
+// fireInTransaction is like fireAndPersist, running in a transaction
+func (m *StateMachineInstance) fireInTransaction(goCtx context.Context, before int, key interface{}) (bool, bool, error) {
+	var fired, saved bool
+	err := m.transact(goCtx, func(goCtx context.Context) error {
+		var err error
+		fired, saved, err = m.fireAndPersist(goCtx, before, key)
+		return err
+	})
+	return fired, saved, err
+}
+
+func (m *StateMachineInstance) fire(goCtx context.Context, key interface{}) error {
+	prev := m.currentState
+	if err := m.advance(goCtx, key); err != nil {
+		return err
+	}
+	if m.currentState != prev {
+		m.schedule()
+	}
+	return nil
+}
+
+// advance fires the event and moves to the reached state, without rescheduling timeouts.
+// Events deferred by the current state are queued, and replayed once a state that does not defer them is reached.
+func (m *StateMachineInstance) advance(goCtx context.Context, key interface{}) error {
+	key, idempotencyKey, err := m.unwrapIdempotent(key)
+	if err != nil {
+		return err
+	}
+	if m.currentState.defers(key) {
+		m.deferred = append(m.deferred, key)
+		m.monitor.deferredChanged(1)
+		m.remember(idempotencyKey)
+		m.outcome = Deferred
+		return nil
+	}
+	prev := m.currentState
+	m.outcome = Handled
+	if err := m.step(goCtx, key); err == errIgnored {
+		m.outcome = Ignored
+	} else if err != nil {
+		return err
+	}
+	m.remember(idempotencyKey)
+	if m.currentState != prev {
+		return m.replayDeferred(goCtx)
+	}
+	return nil
+}
+
+// step fires the event into the running sub-machine, if any, or else into this instance
+func (m *StateMachineInstance) step(goCtx context.Context, key interface{}) error {
+	if m.sub != nil {
+		if handled, err := m.stepSub(goCtx, key); handled {
+			return err
+		}
+	}
+	return m.stepSelf(goCtx, key)
+}
+
+// stepSelf fires the event and moves to the reached state
+func (m *StateMachineInstance) stepSelf(goCtx context.Context, key interface{}) error {
+	m.recordActive()
+	ctx := m.newContext(goCtx, key)
+	cur, err := m.StateMachine.fireContext(m.currentState, ctx)
+	if err == nil && ctx.ignored {
+		return errIgnored
+	}
+	m.measureStep(m.currentState, cur, key, err)
 	if err != nil {
+		m.monitor.failed(m.currentState, key, err)
+		var te *TransitionError
+		if m.sagaMode && errors.As(err, &te) {
+			return m.abortSaga(goCtx, err)
+		}
 		return err
 	}
+	if cur.final {
+		m.sagaTrail = nil
+	}
+	m.monitor.track(m.id, cur)
+	prev := m.currentState
 	m.currentState = cur
+	if cur != prev || ctx.reentry {
+		m.startSub()
+		m.startAsync(ctx)
+		m.enterDeadline(prev)
+	}
+	m.trackSLAs(key)
+	m.appendLog(ctx)
 	return nil
 }
 
 // State getter for the current state
 func (m *StateMachineInstance) State() *State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	return m.currentState
 }
 
 type OnHandler func(*Context) error
 
-// OnEnter option
+// OnEnter option.
+// Several OnEnter options add handlers that are called in order, stopping at the first error.
 func OnEnter(fn OnHandler) func(*State) {
 	return func(s *State) {
-		s.onEnter = fn
+		s.AddOnEnter(fn)
 	}
 }
 
-// OnExit option
+// OnExit option.
+// Several OnExit options add handlers that are called in order, stopping at the first error.
 func OnExit(fn OnHandler) func(*State) {
 	return func(s *State) {
-		s.onExit = fn
+		s.AddOnExit(fn)
 	}
 }
 
-// OnEvent option
-func OnEvent(fn func(*Context) error) func(*State) {
+// OnEvent option.
+// Several OnEvent options add handlers that are called in order, stopping at the first error.
+func OnEvent(fn OnHandler) func(*State) {
 	return func(s *State) {
-		s.onEvent = fn
+		s.AddOnEvent(fn)
 	}
 }
 
+// AddOnEnter appends a handler called when entering the state
+func (s *State) AddOnEnter(fn OnHandler) *State {
+	s.machine.mustBeMutable()
+	s.onEnter = chain(s.onEnter, fn)
+	return s
+}
+
+// AddOnExit appends a handler called when exiting the state
+func (s *State) AddOnExit(fn OnHandler) *State {
+	s.machine.mustBeMutable()
+	s.onExit = chain(s.onExit, fn)
+	return s
+}
+
+// AddOnEvent appends a handler called when an event occurs in the state
+func (s *State) AddOnEvent(fn OnHandler) *State {
+	s.machine.mustBeMutable()
+	s.onEvent = chain(s.onEvent, fn)
+	return s
+}
+
+// chain returns a handler calling first and then next, unless first fails
+func chain(first, next OnHandler) OnHandler {
+	if first == nil {
+		return next
+	}
+	return func(c *Context) error {
+		if err := first(c); err != nil {
+			return err
+		}
+		return next(c)
+	}
+}
+
+// OnEventKind option registers a handler called instead of the OnEvent handler
+// when the event key matches the eventKey.
+func OnEventKind(eventKey interface{}, fn OnHandler) func(*State) {
+	return func(s *State) {
+		key := s.machine.normalizeKey(toEventer(eventKey).Kind())
+		s.machine.mustBeComparable(key)
+		s.onEventKinds = append(s.onEventKinds, eventKindHandler{
+			key:     key,
+			handler: fn,
+		})
+	}
+}
+
+type eventKindHandler struct {
+	key     interface{}
+	handler OnHandler
+}
+
+// eventHandler returns the handler for the event kind, falling back to the OnEvent handler
+func (s *State) eventHandler(ctx *Context) OnHandler {
+	key := ctx.Key()
+	for _, h := range s.onEventKinds {
+		if ctx.machine.keysEqual(key, h.key) {
+			return h.handler
+		}
+	}
+	return s.onEvent
+}
+
 // State represents a state of the FSM
 type State struct {
 	name        string
+	machine     *StateMachine
 	transitions []*transition
 	// onEnter is called when entering a state
 	// when there is a transition A -> B where A != B.
@@ -272,34 +959,95 @@ type State struct {
 	// onExit is called when exiting a state
 	// when there is a transition A -> B where A != B
 	onExit OnHandler
+	// onEventKinds are called instead of onEvent for specific event kinds
+	onEventKinds []eventKindHandler
+	// handlerNames are the names of the handlers when bound from a HandlerRegistry
+	handlerNames handlerNames
+	// params are the parameters of the handlers, bound from a definition
+	params *handlerParams
+	meta   map[string]string
+	// final states do not accept events
+	final bool
+	// deferred are the normalized keys of the events deferred by this state
+	deferred    []interface{}
+	quotaPolicy QuotaPolicy
+	parent      *State
+	children    []*State
+	// histories are the history pseudo-states of the composite
+	histories []*State
+	pseudo    *historyState
+	sub       *subMachine
+	async     *asyncHandler
+	retry     *retryPolicy
+	// compensation undoes the work of entering the state, in saga mode
+	compensation OnHandler
+	// aliases are former names of the state
+	aliases []string
+	// deadline is the time limit to leave the state
+	deadline *stateDeadline
+	// periodic are the events fired regularly while the state is the current one
+	periodic []periodicEvent
+	// choice pseudo-states are left, in the same Fire, through the first matching branch
+	choice bool
 }
 
 // AddTransition adds a state transition.
-func (s *State) AddTransition(eventKey interface{}, to *State) *State {
-	key := toEventer(eventKey).Kind()
-	s.AddConditionalTransition(fmt.Sprintf("%+v", key), to, func(c *Context) bool {
-		return c.Key() == key
-	})
-	return s
+func (s *State) AddTransition(eventKey interface{}, to *State, opts ...TransitionOption) *State {
+	return s.addTransition(s.keyTransition(eventKey, to), opts)
+}
+
+// keyTransition creates a transition that occurs when the event key matches
+func (s *State) keyTransition(eventKey interface{}, to *State) *transition {
+	raw := toEventer(eventKey).Kind()
+	key := s.machine.normalizeKey(raw)
+	s.machine.mustBeComparable(key)
+	var tok EventToken
+	if len(s.machine.declared) > 0 {
+		if tok = s.machine.eventToken(key); tok == 0 {
+			panic(&ErrUndeclaredEvent{key: raw})
+		}
+	}
+	return &transition{
+		name:  fmt.Sprintf("%+v", raw),
+		state: to,
+		condition: func(c *Context) bool {
+			if tok > 0 {
+				return c.token() == tok
+			}
+			return c.machine.keysEqual(c.Key(), key)
+		},
+		key: raw,
+	}
 }
 
 // AddFallbackTransition adds a fallback transition.
 // If no transition is identified this one will be used
-func (s *State) AddFallbackTransition(to *State) *State {
-	s.AddConditionalTransition("fallback", to, func(c *Context) bool {
-		return true
-	})
-	return s
+func (s *State) AddFallbackTransition(to *State, opts ...TransitionOption) *State {
+	return s.addTransition(&transition{
+		name:  "fallback",
+		state: to,
+		condition: func(c *Context) bool {
+			return true
+		},
+		fallback: true,
+	}, opts)
+}
+
+// AddInternalTransition adds a transition that executes the action, for the event, without leaving the state.
+// Unlike a self transition, neither the OnExit, OnEnter or OnEvent handlers are called.
+func (s *State) AddInternalTransition(eventKey interface{}, action OnHandler, opts ...TransitionOption) *State {
+	t := s.keyTransition(eventKey, s)
+	t.action = action
+	return s.addTransition(t, opts)
 }
 
 // AddConditionalTransition adds a state transition that will only occur if the condition function return true
-func (s *State) AddConditionalTransition(name string, to *State, condition func(c *Context) bool) *State {
-	s.transitions = append(s.transitions, &transition{
+func (s *State) AddConditionalTransition(name string, to *State, condition func(c *Context) bool, opts ...TransitionOption) *State {
+	return s.addTransition(&transition{
 		name:      name,
 		state:     to,
 		condition: condition,
-	})
-	return s
+	}, opts)
 }
 
 // Name getter for the name
@@ -316,25 +1064,112 @@ type transition struct {
 	name      string
 	state     *State
 	condition func(*Context) bool
+	// timeout is set for transitions fired automatically by the instance scheduler
+	timeout time.Duration
+	// action is set for internal transitions
+	action OnHandler
+	// key is the event key, as registered, of a key transition
+	key      interface{}
+	fallback bool
+	// names of the handlers when bound from a HandlerRegistry
+	conditionName string
+	actionName    string
+	// guardExpr is the source of the guard parsed from a definition
+	guardExpr string
+	// guarded transitions share their event key with other transitions
+	guarded bool
+	// params are the parameters of the action, bound from a definition
+	params *handlerParams
+	// throttle debounces or rate limits the transition
+	throttle *throttle
+	// deprecated transitions still work but are reported when traversed
+	deprecated        bool
+	deprecationReason string
+	meta              map[string]string
+	// priority decides the winner when several conditions match. Higher wins.
+	priority int
+	// labels, schema and roles describe the event to end users, by language
+	labels map[string]string
+	schema string
+	roles  []string
+	// listeners are called when the transition is taken, before the machine listeners
+	listeners []OnHandler
 }
 
-// Context represents the event of the state machine
+// Context represents the event of the state machine.
+// It is only valid during the call of the handler, since instances reuse it in the next Fire.
 type Context struct {
-	machine *StateMachine
-	context context.Context
-	event   Eventer
-	to      *State
-	from    *State
+	machine  *StateMachine
+	instance *StateMachineInstance
+	context  context.Context
+	// event is nil while a raw key, not implementing Eventer, was not wrapped yet
+	event Eventer
+	raw   interface{}
+	// tok caches the token of the declared event, -1 if not declared
+	tok EventToken
+	// candidate is the transition whose condition is being evaluated
+	candidate *transition
+	to        *State
+	from      *State
 	// deepest reached state
 	deepest *State
 	canFire bool
+	// run is shared by all the chained transitions of a Fire
+	run *fireRun
+	// memo caches values computed while dispatching the event
+	memo map[interface{}]interface{}
+	// matched is the matched transition, nil if the target was resolved by a fallback handler
+	matched *transition
+	// reentry is set when a self-transition exits and re-enters the state
+	reentry bool
+	// ignored is set when the event had no transition, with IgnoreUnhandledEvents
+	ignored bool
+	// misuse is the error of a misuse by the running handler, reported by Strict machines
+	misuse error
+	// via are the choice pseudo-states traversed by the transition
+	via []*State
+	// notified is set once the listeners were called for the transition
+	notified bool
+	// listenerErr is the error of the listeners notified before a chained Fire
+	listenerErr error
+	// params are the parameters of the running handler
+	params *handlerParams
+	// expiry is set on the copy of the context given to a handler with a timeout
+	expiry *handlerExpiry
 }
 
 func (c *Context) Fire(event interface{}) error {
 	if !c.canFire {
-		return fmt.Errorf("fire is only allowed on event. Insvalid call on state: %s", c.ToState())
+		err := fmt.Errorf("%w. Invalid call on state: %s", ErrFireNotAllowed, c.ToState())
+		if c.machine.strict {
+			c.misuse = err
+		}
+		return err
+	}
+	if c.expiry != nil {
+		// a timed out handler no longer owns the instance
+		c.expiry.mu.Lock()
+		defer c.expiry.mu.Unlock()
+		if c.expiry.expired {
+			return fmt.Errorf("%w. The handler of state %s timed out", ErrFireNotAllowed, c.ToState())
+		}
 	}
-	state, err := c.machine.Fire(c.ToState(), event)
+	leave, err := c.machine.enterChain(c.run)
+	if err != nil {
+		return err
+	}
+	defer leave()
+	if err := c.machine.notifyTransition(c); err != nil {
+		c.listenerErr = err
+		return err
+	}
+	state, err := c.machine.fireContext(c.ToState(), &Context{
+		machine:  c.machine,
+		instance: c.instance,
+		context:  c.context,
+		event:    toEventer(event),
+		run:      c.run,
+	})
 	if err != nil {
 		return err
 	}
@@ -351,14 +1186,30 @@ func (c *Context) setTo(state *State) {
 	c.deepest = state
 }
 
-// Key gets the key
+// Key gets the key, normalized if a key normalizer was set
 func (c *Context) Key() interface{} {
+	return c.machine.normalizeKey(c.kind())
+}
+
+// kind returns the kind of the event, without wrapping a raw key in an Event
+func (c *Context) kind() interface{} {
+	if c.event == nil {
+		return c.raw
+	}
 	return c.event.Kind()
 }
 
+// eventer returns the event, wrapping a raw key in an Event on first use
+func (c *Context) eventer() Eventer {
+	if c.event == nil {
+		c.event = &Event{Data: c.raw}
+	}
+	return c.event
+}
+
 // Data gets the data
 func (c *Context) Data() interface{} {
-	return c.event
+	return c.eventer()
 }
 
 func (c *Context) FromState() *State {
@@ -369,6 +1220,21 @@ func (c *Context) ToState() *State {
 	return c.to
 }
 
+// Memo returns the value cached under key, calling fn to compute it on the first call.
+// The cache lives while the event is dispatched, so that guards sharing expensive lookups only do them once.
+// Chained transitions, fired from handlers, start with an empty cache.
+func (c *Context) Memo(key interface{}, fn func() interface{}) interface{} {
+	if v, ok := c.memo[key]; ok {
+		return v
+	}
+	if c.memo == nil {
+		c.memo = map[interface{}]interface{}{}
+	}
+	v := fn()
+	c.memo[key] = v
+	return v
+}
+
 func (c *Context) Context() context.Context {
 	if c.context == nil {
 		return context.Background()
@@ -153,7 +153,10 @@ func (t *Trip) book(c *fsm.Context) error {
 	if t.bookID != "" {
 		return nil
 	}
-	b := c.Data().(book)
+	b, err := fsm.DataAs[book](c)
+	if err != nil {
+		return err
+	}
 	t.bookID = b.id
 	return nil
 }
@@ -170,7 +173,10 @@ func (t *Trip) Cancel(payService PayService) error {
 
 func (t *Trip) cancel(c *fsm.Context) error {
 	t.cancelled = true
-	cncl := c.Data().(cancel)
+	cncl, err := fsm.DataAs[cancel](c)
+	if err != nil {
+		return err
+	}
 	return c.Fire(pay{
 		amount:     2,
 		payService: cncl.payService,
@@ -188,8 +194,11 @@ func (t *Trip) paid(c *fsm.Context) error {
 	if t.fare != 0 {
 		return nil
 	}
-	p := c.Data().(pay)
-	err := p.payService.Pay(p.amount)
+	p, err := fsm.DataAs[pay](c)
+	if err != nil {
+		return err
+	}
+	err = p.payService.Pay(p.amount)
 	if err != nil {
 		return err
 	}
@@ -1,7 +1,9 @@
 package fsm_test
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/quintans/fsm"
@@ -234,7 +236,7 @@ func TestDefaultTransition(t *testing.T) {
 	require.Equal(t, stateExit, sm.State().Name())
 }
 
-func ExampleDot() {
+func ExampleStateMachineInstance_Dot() {
 	smi, _, _, err := createFSM()
 	if err != nil {
 		panic(err)
@@ -263,7 +265,37 @@ func ExampleDot() {
 	// }
 }
 
-func ExampleListener() {
+func ExampleStateMachine_DotWithOptions() {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b)
+	a.AddFallbackTransition(a)
+
+	fmt.Println(sm.DotWithOptions(nil, fsm.DotOptions{
+		Title:         "Simple",
+		RankDir:       "TB",
+		HideFallbacks: true,
+		NodeAttrs:     map[string]string{"fontname": "Helvetica Neue"},
+		EdgeAttrs:     map[string]string{"color": "gray"},
+	}))
+	// Output:
+	// digraph finite_state_machine {
+	// 	rankdir=TB;
+	// 	node [fontname = "Helvetica Neue", shape = circle];
+	// 	edge [color = gray];
+	// 	# nodes
+	// 	A [style=filled, shape=doublecircle];
+	// 	B [style=filled, shape=doublecircle];
+	// 	# transitions
+	// 	A -> B [label = "TICK"];
+	// 	# title
+	// 	labelloc="t";
+	// 	label="Simple";
+	// }
+}
+
+func ExampleStateMachine_AddOnTransition() {
 	smi, _, _, err := createFSM()
 	if err != nil {
 		panic(err)
@@ -283,7 +315,264 @@ func ExampleListener() {
 	smi.Fire("UNMAPPED_EVENT")
 	// Output:
 	// GREEN --TICK--> YELLOW
-	// BOUNCE --CONTINUE--> RED
 	// YELLOW --TICK--> BOUNCE
+	// BOUNCE --CONTINUE--> RED
 	// RED --UNMAPPED_EVENT--> FALLBACK
 }
+
+func TestConcurrentDot(t *testing.T) {
+	smi, _, _, err := createFSM()
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			smi.Fire(TICK)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			require.NotEmpty(t, smi.Dot())
+			require.NotNil(t, smi.State())
+		}
+	}()
+	wg.Wait()
+}
+
+func TestInternalTransition(t *testing.T) {
+	smi, states, tracker, err := createFSM()
+	require.NoError(t, err)
+
+	calls := 0
+	states.green.AddInternalTransition(LOOP, func(c *fsm.Context) error {
+		calls++
+		return nil
+	})
+
+	require.NoError(t, smi.Fire(LOOP))
+	require.Equal(t, stateGreen, smi.State().Name())
+	require.Equal(t, 1, calls)
+	require.Empty(t, tracker.Events())
+}
+
+func TestOnEventKind(t *testing.T) {
+	sm := fsm.New()
+	var calls []string
+	idle := sm.AddState("IDLE")
+	busy := sm.AddState("BUSY",
+		fsm.OnEvent(func(c *fsm.Context) error {
+			calls = append(calls, "any")
+			return nil
+		}),
+		fsm.OnEventKind(TICK, func(c *fsm.Context) error {
+			calls = append(calls, "tick")
+			return nil
+		}),
+	)
+	idle.AddTransition(TICK, busy)
+	busy.AddTransition(LOOP, busy)
+
+	smi := sm.FromState(idle)
+	require.NoError(t, smi.Fire(TICK))
+	require.NoError(t, smi.Fire(LOOP))
+	require.Equal(t, []string{"tick", "any"}, calls)
+}
+
+func TestFallbackResolvers(t *testing.T) {
+	smi, _, _, err := createFSM()
+	require.NoError(t, err)
+
+	deadLetter := smi.AddState("DEAD_LETTER")
+	var seen []interface{}
+	smi.AddFallbackResolver(func(c *fsm.Context) *fsm.State {
+		seen = append(seen, c.Key())
+		return nil
+	})
+	smi.AddFallbackResolver(func(c *fsm.Context) *fsm.State {
+		if c.Key() == "POISON" {
+			return deadLetter
+		}
+		return nil
+	})
+
+	err = smi.Fire("UNKNOWN")
+	require.Error(t, err)
+	require.NoError(t, smi.Fire("POISON"))
+	require.Equal(t, deadLetter, smi.State())
+	require.Equal(t, []interface{}{"UNKNOWN", "POISON"}, seen)
+}
+
+func TestOnUnknownState(t *testing.T) {
+	sm := fsm.New(fsm.OnUnknownState(func(sm *fsm.StateMachine, name string) (*fsm.State, error) {
+		switch name {
+		case "LEGACY":
+			return sm.StateByName("QUARANTINE"), nil
+		case "FORBIDDEN":
+			return nil, fmt.Errorf("state %s is not allowed", name)
+		}
+		return nil, nil
+	}))
+	quarantine := sm.AddState("QUARANTINE")
+
+	smi, err := sm.FromStateName("LEGACY")
+	require.NoError(t, err)
+	require.Equal(t, quarantine, smi.State())
+
+	_, err = sm.FromStateName("FORBIDDEN")
+	require.EqualError(t, err, "state FORBIDDEN is not allowed")
+
+	_, err = sm.FromStateName("OTHER")
+	require.ErrorIs(t, err, fsm.ErrUnknownState)
+}
+
+func TestMultipleHandlers(t *testing.T) {
+	var calls []string
+	handler := func(name string, err error) fsm.OnHandler {
+		return func(c *fsm.Context) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B",
+		fsm.OnEnter(handler("enter1", nil)),
+		fsm.OnEnter(handler("enter2", nil)),
+	)
+	b.AddOnEvent(handler("event1", fmt.Errorf("failed"))).
+		AddOnEvent(handler("event2", nil))
+	a.AddTransition(TICK, b)
+
+	require.Error(t, sm.FromState(a).Fire(TICK))
+	require.Equal(t, []string{"enter1", "enter2", "event1"}, calls)
+}
+
+func TestInstanceFallbackHandler(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	machineFallback := sm.AddState("MACHINE")
+	instanceFallback := sm.AddState("INSTANCE")
+	sm.SetFallbackHandler(func(c *fsm.Context) *fsm.State {
+		return machineFallback
+	})
+
+	overridden := sm.FromState(a)
+	overridden.SetFallbackHandler(func(c *fsm.Context) *fsm.State {
+		if c.Key() == "DECLINE" {
+			return nil
+		}
+		return instanceFallback
+	})
+	other := sm.FromState(a)
+
+	require.NoError(t, overridden.Fire("UNKNOWN"))
+	require.Equal(t, instanceFallback, overridden.State())
+	require.NoError(t, other.Fire("UNKNOWN"))
+	require.Equal(t, machineFallback, other.State())
+
+	// declined by the instance, resolved by the machine
+	declined := sm.FromState(a)
+	declined.AddFallbackResolver(func(c *fsm.Context) *fsm.State {
+		return nil
+	})
+	require.NoError(t, declined.Fire("DECLINE"))
+	require.Equal(t, machineFallback, declined.State())
+}
+
+func TestMemo(t *testing.T) {
+	lookups := 0
+	vip := func(c *fsm.Context) bool {
+		return c.Memo("customer", func() interface{} {
+			lookups++
+			return "vip"
+		}) == "vip"
+	}
+
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.OnEvent(func(c *fsm.Context) error {
+		return c.Fire(TICK)
+	}))
+	c := sm.AddState("C")
+	d := sm.AddState("D")
+	a.AddConditionalTransition("vip-not-silver", c, func(c *fsm.Context) bool { return vip(c) && c.Key() == "silver" })
+	a.AddConditionalTransition("vip", b, vip)
+	b.AddConditionalTransition("vip", d, vip)
+
+	smi := sm.FromState(a)
+	require.NoError(t, smi.Fire(TICK))
+	require.Equal(t, d, smi.State())
+	// one lookup per dispatched event
+	require.Equal(t, 2, lookups)
+}
+
+func TestListenersCausalOrder(t *testing.T) {
+	sm := fsm.New()
+	var log []string
+	record := func(prefix string) fsm.OnHandler {
+		return func(c *fsm.Context) error {
+			log = append(log, fmt.Sprintf("%s%s-%v->%s", prefix, c.FromState(), c.Key(), c.ToState()))
+			return nil
+		}
+	}
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.OnEvent(func(c *fsm.Context) error {
+		return c.Fire("next")
+	}))
+	cs := sm.AddState("C", fsm.OnEvent(func(c *fsm.Context) error {
+		return c.Fire("next")
+	}))
+	d := sm.AddState("D")
+	a.AddTransition("go", b, fsm.OnFire(record("fire ")))
+	b.AddTransition("next", cs)
+	cs.AddTransition("next", d)
+	d.AddInternalTransition("ping", func(c *fsm.Context) error {
+		return c.Fire("back")
+	})
+	d.AddTransition("back", a)
+	sm.AddOnTransition(record(""))
+
+	smi := sm.FromState(a)
+	smi.AddOnTransition(record("instance "))
+	require.NoError(t, smi.Fire("go"))
+	require.NoError(t, smi.Fire("ping"))
+	require.Equal(t, []string{
+		"fire A-go->B", "A-go->B", "instance A-go->B",
+		"B-next->C", "instance B-next->C",
+		"C-next->D", "instance C-next->D",
+		"D-ping->D", "instance D-ping->D",
+		"D-back->A", "instance D-back->A",
+	}, log)
+}
+
+func TestListenerErrorAbortsChain(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.OnEvent(func(c *fsm.Context) error {
+		return c.Fire("next")
+	}))
+	cs := sm.AddState("C")
+	a.AddTransition("go", b)
+	b.AddTransition("next", cs)
+	var seen []string
+	boom := errors.New("boom")
+	sm.AddOnTransition(func(c *fsm.Context) error {
+		seen = append(seen, c.ToState().Name())
+		if c.ToState().Name() == "B" {
+			return boom
+		}
+		return nil
+	})
+
+	smi := sm.FromState(a)
+	err := smi.Fire("go")
+	require.ErrorIs(t, err, boom)
+	var te *fsm.TransitionError
+	require.ErrorAs(t, err, &te)
+	require.Equal(t, "OnTransition", te.Handler)
+	require.Equal(t, []string{"B"}, seen)
+	require.Equal(t, a, smi.State())
+}
@@ -0,0 +1,148 @@
+// Package fsmbroker fires the events consumed from a message broker into the instances of an fsm.Manager.
+//
+// Messages are routed to instances by their correlation ID and fired with their message ID as idempotency key,
+// so that redelivered messages are acknowledged without firing twice. Since the idempotency keys are part
+// of the snapshot, this gives at-least-once delivery with effectively-once transitions.
+//
+// The package does not depend on broker clients: NATS and Kafka consumers are built over small interfaces
+// that the usual clients can be adapted to.
+package fsmbroker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/quintans/fsm"
+)
+
+// The headers carrying the message fields, when the broker supports headers
+const (
+	HeaderMessageID     = "Fsm-Message-Id"
+	HeaderCorrelationID = "Fsm-Correlation-Id"
+	HeaderEvent         = "Fsm-Event"
+)
+
+// DefaultConflictRetries is the number of times a message is fired again after a version conflict
+const DefaultConflictRetries = 3
+
+// Message is an event consumed from a broker
+type Message struct {
+	// ID identifies the message across redeliveries. It is used as idempotency key, unless empty.
+	ID string
+	// CorrelationID is the ID of the instance the event is fired into
+	CorrelationID string
+	// Event is the name of the event
+	Event string
+	// Payload is the JSON payload of the event
+	Payload []byte
+}
+
+// Handler processes a message. A message is only acknowledged when its handler succeeds.
+type Handler func(ctx context.Context, msg Message) error
+
+// Consumer consumes the messages of a topic, calling the handler for each one, until the context is cancelled
+type Consumer interface {
+	Consume(ctx context.Context, handler Handler) error
+}
+
+// RouterOption configures a Router
+type RouterOption func(*Router)
+
+// WithEventType option registers the payload type of an event, like the ones generated by fsmgen.
+// The JSON payload is decoded into a new value of the type of the prototype, which is then fired.
+// The payloads of events without a registered type are ignored, and the event name is fired.
+func WithEventType(event string, prototype fsm.Eventer) RouterOption {
+	return func(r *Router) {
+		r.types.Register(event, prototype)
+	}
+}
+
+// WithConflictRetries option sets the number of times a message is fired again after a version conflict.
+// Defaults to DefaultConflictRetries.
+func WithConflictRetries(n int) RouterOption {
+	return func(r *Router) {
+		r.retries = n
+	}
+}
+
+// WithErrorHandler option sets the function deciding what to do with a message that failed.
+// Returning nil acknowledges the message, like when sending it to a dead letter topic,
+// while returning an error leaves it to be redelivered. By default, every error is returned.
+func WithErrorHandler(fn func(ctx context.Context, msg Message, err error) error) RouterOption {
+	return func(r *Router) {
+		r.onError = fn
+	}
+}
+
+// Router fires the consumed messages into the instances of a manager
+type Router struct {
+	manager *fsm.Manager
+	types   *fsm.EventTypes
+	retries int
+	onError func(ctx context.Context, msg Message, err error) error
+}
+
+// NewRouter creates a router firing into the instances of the manager
+func NewRouter(m *fsm.Manager, opts ...RouterOption) *Router {
+	r := &Router{
+		manager: m,
+		types:   fsm.NewEventTypes(),
+		retries: DefaultConflictRetries,
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	return r
+}
+
+// Run consumes the messages of the consumer until the context is cancelled or the consumer fails
+func (r *Router) Run(ctx context.Context, c Consumer) error {
+	return c.Consume(ctx, r.Handle)
+}
+
+// Handle fires the message into its instance.
+// Messages already processed by the instance are ignored.
+func (r *Router) Handle(ctx context.Context, msg Message) error {
+	err := r.fire(ctx, msg)
+	if err != nil && r.onError != nil {
+		return r.onError(ctx, msg, err)
+	}
+	return err
+}
+
+func (r *Router) fire(ctx context.Context, msg Message) error {
+	if msg.CorrelationID == "" {
+		return fmt.Errorf("message %s of event %s has no correlation ID", msg.ID, msg.Event)
+	}
+	event, err := r.event(msg)
+	if err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		_, err = r.manager.Fire(ctx, msg.CorrelationID, event)
+		if !errors.Is(err, fsm.ErrVersionConflict) || i >= r.retries {
+			break
+		}
+	}
+	var duplicate *fsm.ErrDuplicateEvent
+	if errors.As(err, &duplicate) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to fire message %s of event %s into instance %s: %w", msg.ID, msg.Event, msg.CorrelationID, err)
+	}
+	return nil
+}
+
+// event builds the event to fire
+func (r *Router) event(msg Message) (interface{}, error) {
+	event, err := r.types.Decode(msg.Event, msg.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payload of message %s of event %s: %w", msg.ID, msg.Event, err)
+	}
+	if msg.ID != "" {
+		event = fsm.WithIdempotencyKey(event, msg.ID)
+	}
+	return event, nil
+}
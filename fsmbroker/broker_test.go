@@ -0,0 +1,87 @@
+package fsmbroker_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/fsmbroker"
+	"github.com/stretchr/testify/require"
+)
+
+type Deposit struct {
+	Amount int `json:"amount"`
+}
+
+func (Deposit) Kind() interface{} {
+	return "deposit"
+}
+
+// newManager creates a manager of accounts summing the deposits in total
+func newManager(t *testing.T, total *int) *fsm.Manager {
+	sm := fsm.New()
+	open := sm.AddState("OPEN")
+	closed := sm.AddState("CLOSED", fsm.Final())
+	open.AddInternalTransition("deposit", func(c *fsm.Context) error {
+		d, err := fsm.DataAs[Deposit](c)
+		if err != nil {
+			return err
+		}
+		*total += d.Amount
+		return nil
+	})
+	open.AddTransition("close", closed)
+
+	m := fsm.NewManager(sm, fsm.NewMemoryStore())
+	require.NoError(t, m.Create(context.Background(), "acc1", open))
+	return m
+}
+
+func TestRouter(t *testing.T) {
+	total := 0
+	m := newManager(t, &total)
+	r := fsmbroker.NewRouter(m, fsmbroker.WithEventType("deposit", Deposit{}))
+	ctx := context.Background()
+
+	deposit := fsmbroker.Message{ID: "m1", CorrelationID: "acc1", Event: "deposit", Payload: []byte(`{"amount":10}`)}
+	require.NoError(t, r.Handle(ctx, deposit))
+	// redelivered messages are acknowledged without firing again
+	require.NoError(t, r.Handle(ctx, deposit))
+	require.Equal(t, 10, total)
+
+	deposit.ID = "m2"
+	require.NoError(t, r.Handle(ctx, deposit))
+	require.Equal(t, 20, total)
+
+	err := r.Handle(ctx, fsmbroker.Message{ID: "m3", CorrelationID: "acc2", Event: "close"})
+	require.ErrorIs(t, err, fsm.ErrInstanceNotFound)
+
+	err = r.Handle(ctx, fsmbroker.Message{ID: "m4", CorrelationID: "acc1", Event: "deposit", Payload: []byte(`{`)})
+	require.Error(t, err)
+
+	require.NoError(t, r.Handle(ctx, fsmbroker.Message{ID: "m5", CorrelationID: "acc1", Event: "close"}))
+	st, err := m.State(ctx, "acc1")
+	require.NoError(t, err)
+	require.Equal(t, "CLOSED", st.Name())
+}
+
+func TestRouterErrorHandler(t *testing.T) {
+	total := 0
+	m := newManager(t, &total)
+	var dead []string
+	r := fsmbroker.NewRouter(m, fsmbroker.WithErrorHandler(func(ctx context.Context, msg fsmbroker.Message, err error) error {
+		if errors.Is(err, fsm.ErrUnknownTransition) {
+			dead = append(dead, msg.ID)
+			return nil
+		}
+		return err
+	}))
+	ctx := context.Background()
+
+	require.NoError(t, r.Handle(ctx, fsmbroker.Message{ID: "m1", CorrelationID: "acc1", Event: "reopen"}))
+	require.Equal(t, []string{"m1"}, dead)
+
+	err := r.Handle(ctx, fsmbroker.Message{ID: "m2", CorrelationID: "missing", Event: "close"})
+	require.ErrorIs(t, err, fsm.ErrInstanceNotFound)
+}
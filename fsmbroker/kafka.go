@@ -0,0 +1,83 @@
+package fsmbroker
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// KafkaRecord is a record of a Kafka topic partition
+type KafkaRecord struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Headers   map[string][]byte
+	Value     []byte
+}
+
+// KafkaReader reads the records of a consumer group, like the Reader of segmentio/kafka-go
+type KafkaReader interface {
+	// FetchMessage blocks until the next record is available, without committing it
+	FetchMessage(ctx context.Context) (KafkaRecord, error)
+	// CommitMessages commits the offsets of the records
+	CommitMessages(ctx context.Context, records ...KafkaRecord) error
+}
+
+// KafkaConsumer consumes the records of a Kafka consumer group.
+// The correlation ID is the record key, unless HeaderCorrelationID is set, the event is the HeaderEvent header,
+// and the message ID is the HeaderMessageID header, defaulting to the topic, partition and offset of the record.
+//
+// The offset of a record is committed once it is handled. Since Kafka can not redeliver a single record,
+// the consumer stops with the error of the first record that fails, without committing it,
+// so that it is consumed again on restart. Use WithErrorHandler to skip the records that can never succeed.
+type KafkaConsumer struct {
+	reader KafkaReader
+}
+
+// NewKafkaConsumer creates a consumer over the reader
+func NewKafkaConsumer(reader KafkaReader) *KafkaConsumer {
+	return &KafkaConsumer{reader: reader}
+}
+
+func (c *KafkaConsumer) Consume(ctx context.Context, handler Handler) error {
+	for {
+		r, err := c.reader.FetchMessage(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := handler(ctx, kafkaMessage(r)); err != nil {
+			return fmt.Errorf("unable to handle record %s/%d/%d: %w", r.Topic, r.Partition, r.Offset, err)
+		}
+		if err := c.reader.CommitMessages(ctx, r); err != nil {
+			return err
+		}
+	}
+}
+
+func kafkaMessage(r KafkaRecord) Message {
+	header := func(name string) string {
+		for k, v := range r.Headers {
+			if strings.EqualFold(k, name) {
+				return string(v)
+			}
+		}
+		return ""
+	}
+	m := Message{
+		ID:            header(HeaderMessageID),
+		CorrelationID: header(HeaderCorrelationID),
+		Event:         header(HeaderEvent),
+		Payload:       r.Value,
+	}
+	if m.ID == "" {
+		m.ID = fmt.Sprintf("%s/%d/%d", r.Topic, r.Partition, r.Offset)
+	}
+	if m.CorrelationID == "" {
+		m.CorrelationID = string(r.Key)
+	}
+	return m
+}
@@ -0,0 +1,67 @@
+package fsmbroker_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quintans/fsm/fsmbroker"
+	"github.com/stretchr/testify/require"
+)
+
+type kafkaReader struct {
+	records   []fsmbroker.KafkaRecord
+	committed []int64
+	cancel    func()
+}
+
+func (r *kafkaReader) FetchMessage(ctx context.Context) (fsmbroker.KafkaRecord, error) {
+	if len(r.records) == 0 {
+		r.cancel()
+		return fsmbroker.KafkaRecord{}, ctx.Err()
+	}
+	rec := r.records[0]
+	r.records = r.records[1:]
+	return rec, nil
+}
+
+func (r *kafkaReader) CommitMessages(_ context.Context, records ...fsmbroker.KafkaRecord) error {
+	for _, rec := range records {
+		r.committed = append(r.committed, rec.Offset)
+	}
+	return nil
+}
+
+func TestKafkaConsumer(t *testing.T) {
+	record := func(offset int64, event, value string) fsmbroker.KafkaRecord {
+		return fsmbroker.KafkaRecord{
+			Topic:   "accounts",
+			Offset:  offset,
+			Key:     []byte("acc1"),
+			Headers: map[string][]byte{"fsm-event": []byte(event)},
+			Value:   []byte(value),
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	reader := &kafkaReader{
+		records: []fsmbroker.KafkaRecord{
+			record(0, "deposit", `{"amount":5}`),
+			record(1, "deposit", `{"amount":7}`),
+			record(2, "reopen", ""),
+			record(3, "deposit", `{"amount":9}`),
+		},
+		cancel: cancel,
+	}
+
+	total := 0
+	r := fsmbroker.NewRouter(newManager(t, &total), fsmbroker.WithEventType("deposit", Deposit{}))
+	err := r.Run(ctx, fsmbroker.NewKafkaConsumer(reader))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "accounts/0/2")
+	require.Equal(t, 12, total)
+	require.Equal(t, []int64{0, 1}, reader.committed)
+
+	// consuming again from the failed record, after skipping it
+	reader.records = []fsmbroker.KafkaRecord{record(1, "deposit", `{"amount":7}`), record(3, "deposit", `{"amount":9}`)}
+	require.NoError(t, r.Run(ctx, fsmbroker.NewKafkaConsumer(reader)))
+	require.Equal(t, 21, total)
+}
@@ -0,0 +1,90 @@
+package fsmbroker
+
+import (
+	"context"
+	"fmt"
+	"net/textproto"
+)
+
+// NATSMsg is a message of a NATS JetStream pull subscription
+type NATSMsg struct {
+	Subject string
+	Header  map[string][]string
+	Data    []byte
+	// Ack acknowledges the message
+	Ack func() error
+	// Nak asks for the message to be redelivered
+	Nak func() error
+}
+
+// NATSFetcher fetches the next messages of a pull subscription, blocking until at least one is available
+// or the context is done. With nats.go it can be adapted from Subscription.Fetch:
+//
+//	fsmbroker.NATSFetcherFunc(func(ctx context.Context, batch int) ([]fsmbroker.NATSMsg, error) {
+//		msgs, err := sub.Fetch(batch, nats.Context(ctx))
+//		...
+//	})
+type NATSFetcher interface {
+	Fetch(ctx context.Context, batch int) ([]NATSMsg, error)
+}
+
+// NATSFetcherFunc adapts a function to a NATSFetcher
+type NATSFetcherFunc func(ctx context.Context, batch int) ([]NATSMsg, error)
+
+func (f NATSFetcherFunc) Fetch(ctx context.Context, batch int) ([]NATSMsg, error) {
+	return f(ctx, batch)
+}
+
+// NATSConsumer consumes a NATS JetStream pull subscription.
+// The message ID is the Nats-Msg-Id header, used by JetStream for deduplication, unless HeaderMessageID is set,
+// and the event and the correlation ID come from the HeaderEvent and HeaderCorrelationID headers.
+// Messages are acknowledged when handled, and negatively acknowledged otherwise, to be redelivered.
+type NATSConsumer struct {
+	fetcher NATSFetcher
+	batch   int
+}
+
+// NewNATSConsumer creates a consumer fetching up to batch messages at a time
+func NewNATSConsumer(fetcher NATSFetcher, batch int) *NATSConsumer {
+	if batch <= 0 {
+		batch = 1
+	}
+	return &NATSConsumer{fetcher: fetcher, batch: batch}
+}
+
+func (c *NATSConsumer) Consume(ctx context.Context, handler Handler) error {
+	for {
+		msgs, err := c.fetcher.Fetch(ctx, c.batch)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			if err := handler(ctx, natsMessage(m)); err != nil {
+				if nerr := m.Nak(); nerr != nil {
+					return fmt.Errorf("unable to nak message after %v: %w", err, nerr)
+				}
+				continue
+			}
+			if err := m.Ack(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func natsMessage(m NATSMsg) Message {
+	h := textproto.MIMEHeader(m.Header)
+	id := h.Get(HeaderMessageID)
+	if id == "" {
+		id = h.Get("Nats-Msg-Id")
+	}
+	return Message{
+		ID:            id,
+		CorrelationID: h.Get(HeaderCorrelationID),
+		Event:         h.Get(HeaderEvent),
+		Payload:       m.Data,
+	}
+}
@@ -0,0 +1,54 @@
+package fsmbroker_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quintans/fsm/fsmbroker"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNATSConsumer(t *testing.T) {
+	var acked, naked []string
+	msg := func(id, event, data string) fsmbroker.NATSMsg {
+		return fsmbroker.NATSMsg{
+			Subject: "accounts.events",
+			Header: map[string][]string{
+				"Nats-Msg-Id":        {id},
+				"Fsm-Correlation-Id": {"acc1"},
+				"Fsm-Event":          {event},
+			},
+			Data: []byte(data),
+			Ack: func() error {
+				acked = append(acked, id)
+				return nil
+			},
+			Nak: func() error {
+				naked = append(naked, id)
+				return nil
+			},
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	batches := [][]fsmbroker.NATSMsg{
+		{msg("m1", "deposit", `{"amount":5}`), msg("m2", "reopen", "")},
+		{msg("m1", "deposit", `{"amount":5}`)},
+	}
+	fetcher := fsmbroker.NATSFetcherFunc(func(ctx context.Context, batch int) ([]fsmbroker.NATSMsg, error) {
+		require.Equal(t, 10, batch)
+		if len(batches) == 0 {
+			cancel()
+			return nil, ctx.Err()
+		}
+		b := batches[0]
+		batches = batches[1:]
+		return b, nil
+	})
+
+	total := 0
+	r := fsmbroker.NewRouter(newManager(t, &total), fsmbroker.WithEventType("deposit", Deposit{}))
+	require.NoError(t, r.Run(ctx, fsmbroker.NewNATSConsumer(fetcher, 10)))
+	require.Equal(t, 5, total)
+	require.Equal(t, []string{"m1", "m1"}, acked)
+	require.Equal(t, []string{"m2"}, naked)
+}
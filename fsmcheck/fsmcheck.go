@@ -0,0 +1,275 @@
+// Package fsmcheck checks properties of a machine exhaustively over its finite graph of states,
+// reporting a counter-example path for each violated property:
+//
+//	violations := fsmcheck.Check(sm,
+//		fsmcheck.EventuallyReaches("BOOKED", "PAID", "CANCELLED"),
+//		fsmcheck.NeverReenters("CREATED"),
+//	)
+//
+// Every declared transition is assumed to be possible, since conditions are not evaluated,
+// and internal transitions are ignored, since they don't change the state.
+// A state named in a property also matches its sub-states.
+package fsmcheck
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/quintans/fsm"
+)
+
+// Violation is a property that does not hold, with a path of state names showing why
+type Violation struct {
+	Property string
+	Path     []string
+}
+
+func (v Violation) Error() string {
+	if len(v.Path) == 0 {
+		return v.Property
+	}
+	return fmt.Sprintf("%s violated by %s", v.Property, strings.Join(v.Path, " -> "))
+}
+
+// Property is a property of the graph of a machine
+type Property struct {
+	name  string
+	check func(g *graph) ([]*fsm.State, error)
+}
+
+// String returns the description of the property
+func (p Property) String() string {
+	return p.name
+}
+
+// Check checks the properties, returning the violated ones.
+// Properties naming unknown states are reported as violations without a path.
+func Check(sm *fsm.StateMachine, props ...Property) []Violation {
+	g := &graph{machine: sm, edges: map[*fsm.State][]*fsm.State{}}
+	var violations []Violation
+	for _, p := range props {
+		path, err := p.check(g)
+		if err != nil {
+			violations = append(violations, Violation{Property: fmt.Sprintf("%s: %v", p.name, err)})
+			continue
+		}
+		if path != nil {
+			violations = append(violations, Violation{Property: p.name, Path: names(path)})
+		}
+	}
+	return violations
+}
+
+// EventuallyReaches holds when every path starting in the state eventually reaches one of the targets,
+// that is, no path ends, or loops forever, without going through them.
+func EventuallyReaches(from string, targets ...string) Property {
+	return Property{
+		name: fmt.Sprintf("every path from %s eventually reaches %s", from, strings.Join(targets, " or ")),
+		check: func(g *graph) ([]*fsm.State, error) {
+			start, err := g.state(from)
+			if err != nil {
+				return nil, err
+			}
+			goals, err := g.states(targets)
+			if err != nil {
+				return nil, err
+			}
+			return g.avoiding(start, goals), nil
+		},
+	}
+}
+
+// NeverReaches holds when no path starting in the state goes through the other one
+func NeverReaches(from, state string) Property {
+	return Property{
+		name: fmt.Sprintf("no path from %s reaches %s", from, state),
+		check: func(g *graph) ([]*fsm.State, error) {
+			start, err := g.state(from)
+			if err != nil {
+				return nil, err
+			}
+			target, err := g.state(state)
+			if err != nil {
+				return nil, err
+			}
+			return g.pathTo(start.Initial(), func(st *fsm.State) bool {
+				return within(st, target)
+			}), nil
+		},
+	}
+}
+
+// NeverReenters holds when no path leaving the state comes back to it
+func NeverReenters(state string) Property {
+	return Property{
+		name: fmt.Sprintf("no path re-enters %s", state),
+		check: func(g *graph) ([]*fsm.State, error) {
+			target, err := g.state(state)
+			if err != nil {
+				return nil, err
+			}
+			// paths starting in any of the states within the target
+			for _, st := range g.machine.States() {
+				if !within(st, target) {
+					continue
+				}
+				for _, next := range g.successors(st) {
+					if within(next, target) {
+						continue
+					}
+					if path := g.pathTo(next, func(s *fsm.State) bool { return within(s, target) }); path != nil {
+						return append([]*fsm.State{st}, path...), nil
+					}
+				}
+			}
+			return nil, nil
+		},
+	}
+}
+
+// Invariant holds when every state reachable from the start satisfies the predicate
+func Invariant(name, from string, holds func(*fsm.State) bool) Property {
+	return Property{
+		name: fmt.Sprintf("invariant %s from %s", name, from),
+		check: func(g *graph) ([]*fsm.State, error) {
+			start, err := g.state(from)
+			if err != nil {
+				return nil, err
+			}
+			return g.pathTo(start.Initial(), func(st *fsm.State) bool {
+				return !holds(st)
+			}), nil
+		},
+	}
+}
+
+type graph struct {
+	machine *fsm.StateMachine
+	edges   map[*fsm.State][]*fsm.State
+}
+
+func (g *graph) state(name string) (*fsm.State, error) {
+	st := g.machine.StateByName(name)
+	if st == nil {
+		return nil, fmt.Errorf("unknown state %s", name)
+	}
+	return st, nil
+}
+
+func (g *graph) states(names []string) ([]*fsm.State, error) {
+	states := make([]*fsm.State, len(names))
+	for i, n := range names {
+		st, err := g.state(n)
+		if err != nil {
+			return nil, err
+		}
+		states[i] = st
+	}
+	return states, nil
+}
+
+// successors returns the states entered by the transitions applying on the state
+func (g *graph) successors(st *fsm.State) []*fsm.State {
+	if next, ok := g.edges[st]; ok {
+		return next
+	}
+	next := []*fsm.State{}
+	if !st.IsFinal() {
+		seen := map[*fsm.State]bool{}
+		for _, t := range st.AllTransitions() {
+			if t.Kind == fsm.InternalTransition {
+				continue
+			}
+			to := t.To.Initial()
+			if !seen[to] {
+				seen[to] = true
+				next = append(next, to)
+			}
+		}
+	}
+	g.edges[st] = next
+	return next
+}
+
+// pathTo returns the shortest path from the start to a state matching the predicate, or nil
+func (g *graph) pathTo(start *fsm.State, match func(*fsm.State) bool) []*fsm.State {
+	prev := map[*fsm.State]*fsm.State{start: nil}
+	queue := []*fsm.State{start}
+	for len(queue) > 0 {
+		st := queue[0]
+		queue = queue[1:]
+		if match(st) {
+			var path []*fsm.State
+			for ; st != nil; st = prev[st] {
+				path = append([]*fsm.State{st}, path...)
+			}
+			return path
+		}
+		for _, next := range g.successors(st) {
+			if _, ok := prev[next]; !ok {
+				prev[next] = st
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// avoiding returns a path from the start that never goes through the goals,
+// either ending in a state without transitions or looping back to a state of the path, or nil
+func (g *graph) avoiding(start *fsm.State, goals []*fsm.State) []*fsm.State {
+	isGoal := func(st *fsm.State) bool {
+		for _, goal := range goals {
+			if within(st, goal) {
+				return true
+			}
+		}
+		return false
+	}
+	done := map[*fsm.State]bool{}
+	onPath := map[*fsm.State]bool{}
+	var path []*fsm.State
+	var visit func(st *fsm.State) []*fsm.State
+	visit = func(st *fsm.State) []*fsm.State {
+		if isGoal(st) || done[st] {
+			return nil
+		}
+		path = append(path, st)
+		if onPath[st] {
+			return path
+		}
+		next := g.successors(st)
+		if len(next) == 0 {
+			return path
+		}
+		onPath[st] = true
+		for _, n := range next {
+			if found := visit(n); found != nil {
+				return found
+			}
+		}
+		onPath[st] = false
+		done[st] = true
+		path = path[:len(path)-1]
+		return nil
+	}
+	return visit(start.Initial())
+}
+
+// within checks if the state is the other one or one of its sub-states
+func within(st, other *fsm.State) bool {
+	for ; st != nil; st = st.Parent() {
+		if st == other {
+			return true
+		}
+	}
+	return false
+}
+
+func names(path []*fsm.State) []string {
+	list := make([]string, len(path))
+	for i, st := range path {
+		list[i] = st.Name()
+	}
+	return list
+}
@@ -0,0 +1,47 @@
+package fsmcheck_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/fsmcheck"
+	"github.com/stretchr/testify/require"
+)
+
+func booking() *fsm.StateMachine {
+	return fsm.New().
+		AddSimpleTransition("CREATED", "book", "BOOKED").
+		AddSimpleTransition("BOOKED", "pay", "PAID").
+		AddSimpleTransition("BOOKED", "cancel", "CANCELLED").
+		AddSimpleTransition("BOOKED", "hold", "ON_HOLD").
+		AddSimpleTransition("ON_HOLD", "resume", "BOOKED")
+}
+
+func TestCheck(t *testing.T) {
+	sm := booking()
+	sm.StateByName("PAID").AddTransition("refund", sm.StateByName("CANCELLED"))
+
+	violations := fsmcheck.Check(sm,
+		fsmcheck.NeverReenters("CREATED"),
+		fsmcheck.NeverReaches("PAID", "BOOKED"),
+		fsmcheck.Invariant("not on hold", "CREATED", func(st *fsm.State) bool { return st.Name() != "ON_HOLD" }),
+		fsmcheck.EventuallyReaches("BOOKED", "PAID", "CANCELLED"),
+		fsmcheck.EventuallyReaches("PAID", "REFUNDED"),
+	)
+	require.Len(t, violations, 3)
+	require.EqualError(t, violations[0], "invariant not on hold from CREATED violated by CREATED -> BOOKED -> ON_HOLD")
+	// the hold loop can go on forever
+	require.Equal(t, []string{"BOOKED", "ON_HOLD", "BOOKED"}, violations[1].Path)
+	require.Equal(t, "every path from PAID eventually reaches REFUNDED: unknown state REFUNDED", violations[2].Property)
+	require.Empty(t, violations[2].Path)
+	require.EqualError(t, violations[2], violations[2].Property)
+	require.Equal(t, "no path re-enters CREATED", fsmcheck.NeverReenters("CREATED").String())
+}
+
+func TestEventuallyReachesDeadEnd(t *testing.T) {
+	violations := fsmcheck.Check(booking(), fsmcheck.EventuallyReaches("CREATED", "PAID"))
+	require.Len(t, violations, 1)
+	require.Equal(t, []string{"CREATED", "BOOKED", "CANCELLED"}, violations[0].Path)
+
+	require.Empty(t, fsmcheck.Check(booking(), fsmcheck.NeverReenters("CREATED"), fsmcheck.NeverReaches("PAID", "BOOKED")))
+}
@@ -0,0 +1,283 @@
+// Package fsmdebug implements an interactive step debugger for machine instances,
+// driven by JSON messages, usually over a WebSocket.
+//
+// A client sends requests and receives responses with the same id:
+//
+//	{"id": 1, "op": "break", "state": "RED"}   pause before entering RED
+//	{"id": 2, "op": "clear", "state": "RED"}   remove the breakpoint
+//	{"id": 3, "op": "breakpoints"}             list the breakpoints
+//	{"id": 4, "op": "inspect"}                 the context of the paused transition
+//	{"id": 5, "op": "step"}                    resume and pause before the next transition
+//	{"id": 6, "op": "resume"}                  resume until the next breakpoint
+//
+// When a transition pauses, the clients are notified with {"event": "paused", "context": {...}}.
+// A paused transition blocks the Fire that triggered it, holding the instance lock, until it is resumed.
+// Transitions of different instances can be paused at the same time: they are inspected and resumed
+// one at a time, from the oldest.
+//
+// It is a separate module, so that the core package does not depend on golang.org/x/net.
+package fsmdebug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/quintans/fsm"
+)
+
+// Request is a command sent by a client
+type Request struct {
+	ID    int    `json:"id"`
+	Op    string `json:"op"`
+	State string `json:"state,omitempty"`
+}
+
+// Message is a response to a request, when ID is set, or a notification, when Event is set
+type Message struct {
+	ID          int          `json:"id,omitempty"`
+	Event       string       `json:"event,omitempty"`
+	Error       string       `json:"error,omitempty"`
+	Context     *ContextInfo `json:"context,omitempty"`
+	Breakpoints []string     `json:"breakpoints,omitempty"`
+}
+
+// ContextInfo describes a paused transition
+type ContextInfo struct {
+	// Instance is the ID of the instance, if any
+	Instance string      `json:"instance,omitempty"`
+	From     string      `json:"from"`
+	To       string      `json:"to"`
+	Event    string      `json:"event"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// Conn exchanges JSON values with a client
+type Conn interface {
+	Receive(v interface{}) error
+	Send(v interface{}) error
+}
+
+// ErrNotPaused is returned when inspecting or resuming while no transition is paused
+var ErrNotPaused = errors.New("no transition is paused")
+
+type pause struct {
+	info   ContextInfo
+	resume chan struct{}
+}
+
+// Debugger pauses the transitions of the attached instances at breakpoints
+type Debugger struct {
+	mu          sync.Mutex
+	breakpoints map[string]bool
+	stepping    bool
+	paused      []*pause
+	clients     map[Conn]chan Message
+}
+
+func New() *Debugger {
+	return &Debugger{
+		breakpoints: map[string]bool{},
+		clients:     map[Conn]chan Message{},
+	}
+}
+
+// Attach debugs the transitions of the instance
+func (d *Debugger) Attach(smi *fsm.StateMachineInstance) {
+	smi.AddBeforeTransition(d.before)
+}
+
+// AttachMachine debugs the transitions of all the instances of the machine
+func (d *Debugger) AttachMachine(sm *fsm.StateMachine) {
+	sm.AddBeforeTransition(d.before)
+}
+
+// Break pauses before entering the state
+func (d *Debugger) Break(state string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[state] = true
+}
+
+// Clear removes the breakpoint of the state
+func (d *Debugger) Clear(state string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, state)
+}
+
+// Breakpoints returns the states with a breakpoint, sorted
+func (d *Debugger) Breakpoints() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	list := make([]string, 0, len(d.breakpoints))
+	for s := range d.breakpoints {
+		list = append(list, s)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Inspect returns the context of the oldest paused transition
+func (d *Debugger) Inspect() (ContextInfo, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.paused) == 0 {
+		return ContextInfo{}, ErrNotPaused
+	}
+	return d.paused[0].info, nil
+}
+
+// Step resumes the oldest paused transition, if any, and pauses before the next one
+func (d *Debugger) Step() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stepping = true
+	d.release()
+}
+
+// Resume resumes the oldest paused transition until the next breakpoint
+func (d *Debugger) Resume() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stepping = false
+	if len(d.paused) == 0 {
+		return ErrNotPaused
+	}
+	d.release()
+	return nil
+}
+
+// release resumes the oldest paused transition, if any
+func (d *Debugger) release() {
+	if len(d.paused) > 0 {
+		close(d.paused[0].resume)
+		d.paused = d.paused[1:]
+	}
+}
+
+// forget removes the paused transition, if still paused
+func (d *Debugger) forget(p *pause) {
+	for i, q := range d.paused {
+		if q == p {
+			d.paused = append(d.paused[:i:i], d.paused[i+1:]...)
+			return
+		}
+	}
+}
+
+func (d *Debugger) before(c *fsm.Context) error {
+	d.mu.Lock()
+	if !d.stepping && !d.breakpoints[c.ToState().Name()] {
+		d.mu.Unlock()
+		return nil
+	}
+	d.stepping = false
+	data, _ := fsm.DataAs[interface{}](c)
+	p := &pause{
+		info: ContextInfo{
+			Instance: c.InstanceID(),
+			From:     c.FromState().Name(),
+			To:       c.ToState().Name(),
+			Event:    fmt.Sprintf("%+v", c.Key()),
+			Data:     data,
+		},
+		resume: make(chan struct{}),
+	}
+	d.paused = append(d.paused, p)
+	info := p.info
+	for _, out := range d.clients {
+		select {
+		case out <- Message{Event: "paused", Context: &info}:
+		default:
+			// slow client, it can still inspect
+		}
+	}
+	d.mu.Unlock()
+
+	select {
+	case <-p.resume:
+		return nil
+	case <-c.Context().Done():
+		d.mu.Lock()
+		d.forget(p)
+		d.mu.Unlock()
+		return c.Context().Err()
+	}
+}
+
+// Serve handles the requests of a client until the connection fails or the context is done
+func (d *Debugger) Serve(ctx context.Context, conn Conn) error {
+	out := make(chan Message, 16)
+	d.mu.Lock()
+	d.clients[conn] = out
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.clients, conn)
+		d.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	sendErr := make(chan error, 1)
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				sendErr <- nil
+				return
+			case m := <-out:
+				if err := conn.Send(m); err != nil {
+					sendErr <- err
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		req := Request{}
+		if err := conn.Receive(&req); err != nil {
+			cancel()
+			<-sendErr
+			return err
+		}
+		select {
+		case out <- d.handle(req):
+		case err := <-sendErr:
+			return err
+		}
+	}
+}
+
+func (d *Debugger) handle(req Request) Message {
+	res := Message{ID: req.ID}
+	var err error
+	switch req.Op {
+	case "break":
+		d.Break(req.State)
+	case "clear":
+		d.Clear(req.State)
+	case "breakpoints":
+		res.Breakpoints = d.Breakpoints()
+	case "inspect":
+		var info ContextInfo
+		info, err = d.Inspect()
+		if err == nil {
+			res.Context = &info
+		}
+	case "step":
+		d.Step()
+	case "resume":
+		err = d.Resume()
+	default:
+		err = fmt.Errorf("unknown operation %q", req.Op)
+	}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
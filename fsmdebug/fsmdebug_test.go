@@ -0,0 +1,179 @@
+package fsmdebug_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/fsmdebug"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func lightFSM() (*fsm.StateMachine, *fsm.State) {
+	sm := fsm.New()
+	green := sm.AddState("GREEN")
+	yellow := sm.AddState("YELLOW")
+	red := sm.AddState("RED")
+	green.AddTransition("tick", yellow)
+	yellow.AddTransition("tick", red)
+	red.AddTransition("tick", green)
+	return sm, green
+}
+
+func TestBreakpointStepResume(t *testing.T) {
+	sm, green := lightFSM()
+	smi := sm.FromState(green)
+	id := smi.ID()
+	dbg := fsmdebug.New()
+	dbg.Attach(smi)
+	dbg.Break("YELLOW")
+
+	done := make(chan error)
+	go func() {
+		done <- smi.Fire("tick")
+	}()
+
+	require.Eventually(t, func() bool {
+		_, err := dbg.Inspect()
+		return err == nil
+	}, time.Second, time.Millisecond)
+	info, err := dbg.Inspect()
+	require.NoError(t, err)
+	require.Equal(t, fsmdebug.ContextInfo{Instance: id, From: "GREEN", To: "YELLOW", Event: "tick", Data: "tick"}, info)
+
+	dbg.Step()
+	require.NoError(t, <-done)
+	require.Equal(t, "YELLOW", smi.State().Name())
+
+	// stepping pauses the next transition, without breakpoint
+	go func() {
+		done <- smi.Fire("tick")
+	}()
+	require.Eventually(t, func() bool {
+		info, err := dbg.Inspect()
+		return err == nil && info.To == "RED"
+	}, time.Second, time.Millisecond)
+	require.NoError(t, dbg.Resume())
+	require.NoError(t, <-done)
+
+	// no breakpoint on GREEN
+	require.NoError(t, smi.Fire("tick"))
+	require.ErrorIs(t, dbg.Resume(), fsmdebug.ErrNotPaused)
+}
+
+// chanConn is a client connection fed by channels
+type chanConn struct {
+	requests chan fsmdebug.Request
+	messages chan fsmdebug.Message
+}
+
+func (c *chanConn) Receive(v interface{}) error {
+	req, ok := <-c.requests
+	if !ok {
+		return io.EOF
+	}
+	*v.(*fsmdebug.Request) = req
+	return nil
+}
+
+func (c *chanConn) Send(v interface{}) error {
+	c.messages <- v.(fsmdebug.Message)
+	return nil
+}
+
+func TestPausedInstances(t *testing.T) {
+	sm, green := lightFSM()
+	dbg := fsmdebug.New()
+	dbg.AttachMachine(sm)
+	conn := &chanConn{requests: make(chan fsmdebug.Request), messages: make(chan fsmdebug.Message, 4)}
+	defer close(conn.requests)
+	go dbg.Serve(context.Background(), conn)
+	conn.requests <- fsmdebug.Request{ID: 1, Op: "break", State: "YELLOW"}
+	require.Equal(t, fsmdebug.Message{ID: 1}, <-conn.messages)
+
+	first := sm.FromState(green)
+	second := sm.FromState(green)
+	firstID, secondID := first.ID(), second.ID()
+	done := make(chan error)
+	go func() {
+		done <- first.Fire("tick")
+	}()
+	require.Equal(t, firstID, (<-conn.messages).Context.Instance)
+	go func() {
+		done <- second.Fire("tick")
+	}()
+	require.Equal(t, secondID, (<-conn.messages).Context.Instance)
+
+	// both transitions are paused, and resumed from the oldest
+	info, err := dbg.Inspect()
+	require.NoError(t, err)
+	require.Equal(t, firstID, info.Instance)
+	require.NoError(t, dbg.Resume())
+	require.NoError(t, <-done)
+
+	info, err = dbg.Inspect()
+	require.NoError(t, err)
+	require.Equal(t, secondID, info.Instance)
+	require.NoError(t, dbg.Resume())
+	require.NoError(t, <-done)
+
+	require.Equal(t, "YELLOW", first.State().Name())
+	require.Equal(t, "YELLOW", second.State().Name())
+	require.ErrorIs(t, dbg.Resume(), fsmdebug.ErrNotPaused)
+}
+
+func TestPausedFireContextCancel(t *testing.T) {
+	sm, green := lightFSM()
+	dbg := fsmdebug.New()
+	dbg.AttachMachine(sm)
+	dbg.Break("YELLOW")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	smi := sm.FromState(green)
+	require.ErrorIs(t, smi.FireContext(ctx, "tick"), context.DeadlineExceeded)
+	require.Equal(t, "GREEN", smi.State().Name())
+}
+
+func TestWebSocket(t *testing.T) {
+	sm, green := lightFSM()
+	smi := sm.FromState(green)
+	dbg := fsmdebug.New()
+	dbg.Attach(smi)
+
+	server := httptest.NewServer(dbg.Handler())
+	defer server.Close()
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", server.URL)
+	require.NoError(t, err)
+	defer ws.Close()
+
+	send := func(req fsmdebug.Request) fsmdebug.Message {
+		require.NoError(t, websocket.JSON.Send(ws, req))
+		res := fsmdebug.Message{}
+		require.NoError(t, websocket.JSON.Receive(ws, &res))
+		return res
+	}
+
+	require.Equal(t, fsmdebug.Message{ID: 1}, send(fsmdebug.Request{ID: 1, Op: "break", State: "YELLOW"}))
+	require.Equal(t, []string{"YELLOW"}, send(fsmdebug.Request{ID: 2, Op: "breakpoints"}).Breakpoints)
+	require.Equal(t, fsmdebug.ErrNotPaused.Error(), send(fsmdebug.Request{ID: 3, Op: "inspect"}).Error)
+	require.NotEmpty(t, send(fsmdebug.Request{ID: 4, Op: "jump"}).Error)
+
+	done := make(chan error)
+	go func() {
+		done <- smi.Fire("tick")
+	}()
+	paused := fsmdebug.Message{}
+	require.NoError(t, websocket.JSON.Receive(ws, &paused))
+	require.Equal(t, "paused", paused.Event)
+	require.Equal(t, "YELLOW", paused.Context.To)
+
+	require.Equal(t, "GREEN", send(fsmdebug.Request{ID: 5, Op: "inspect"}).Context.From)
+	require.Empty(t, send(fsmdebug.Request{ID: 6, Op: "resume"}).Error)
+	require.NoError(t, <-done)
+}
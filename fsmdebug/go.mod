@@ -0,0 +1,17 @@
+module github.com/quintans/fsm/fsmdebug
+
+go 1.18
+
+require (
+	github.com/quintans/fsm v0.0.0
+	github.com/stretchr/testify v1.7.0
+	golang.org/x/net v0.17.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
+
+replace github.com/quintans/fsm => ../
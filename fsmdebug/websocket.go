@@ -0,0 +1,29 @@
+package fsmdebug
+
+import (
+	"net/http"
+
+	"golang.org/x/net/websocket"
+)
+
+// Handler serves the debugger protocol over WebSocket, one client per connection.
+// The Origin of the clients is not checked, so the handler should only be exposed to trusted networks.
+func (d *Debugger) Handler() http.Handler {
+	return websocket.Server{
+		Handler: func(ws *websocket.Conn) {
+			_ = d.Serve(ws.Request().Context(), wsConn{ws: ws})
+		},
+	}
+}
+
+type wsConn struct {
+	ws *websocket.Conn
+}
+
+func (c wsConn) Receive(v interface{}) error {
+	return websocket.JSON.Receive(c.ws, v)
+}
+
+func (c wsConn) Send(v interface{}) error {
+	return websocket.JSON.Send(c.ws, v)
+}
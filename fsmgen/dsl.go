@@ -0,0 +1,111 @@
+package fsmgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/quintans/fsm"
+)
+
+// ParseDSL parses a machine written in the textual DSL into a definition.
+// Each line is a statement and # starts a comment. Names are identifiers or quoted strings.
+//
+//	machine "traffic-light"
+//	state GREEN
+//	state OFF final
+//	GREEN -> YELLOW on TICK
+//	state "YELLOW" -> "RED" on "TICK"
+//
+// States are declared in order of appearance, explicitly or by being referenced in a transition.
+func ParseDSL(r io.Reader) (fsm.Definition, error) {
+	def := fsm.Definition{}
+	index := map[string]int{}
+	declare := func(name string) int {
+		i, ok := index[name]
+		if !ok {
+			i = len(def.States)
+			index[name] = i
+			def.States = append(def.States, fsm.StateDefinition{Name: name})
+		}
+		return i
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		tokens, err := tokenize(scanner.Text())
+		if err != nil {
+			return fsm.Definition{}, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+		if tokens[0] == "machine" {
+			if len(tokens) != 2 {
+				return fsm.Definition{}, fmt.Errorf("line %d: expected machine NAME", line)
+			}
+			def.Name = tokens[1]
+			continue
+		}
+		if tokens[0] == "state" {
+			tokens = tokens[1:]
+			if len(tokens) == 1 || (len(tokens) == 2 && tokens[1] == "final") {
+				i := declare(tokens[0])
+				def.States[i].Final = len(tokens) == 2
+				continue
+			}
+		}
+		if len(tokens) != 5 || tokens[1] != "->" || tokens[3] != "on" {
+			return fsm.Definition{}, fmt.Errorf("line %d: expected state NAME [final] or FROM -> TO on EVENT", line)
+		}
+		from := declare(tokens[0])
+		declare(tokens[2])
+		def.States[from].Transitions = append(def.States[from].Transitions, fsm.TransitionDefinition{
+			Event: tokens[4],
+			To:    tokens[2],
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return fsm.Definition{}, err
+	}
+	return def, nil
+}
+
+// tokenize splits a line in words, quoted strings and arrows, dropping comments
+func tokenize(line string) ([]string, error) {
+	var tokens []string
+	rest := strings.TrimSpace(line)
+	for rest != "" && rest[0] != '#' {
+		var tok string
+		switch {
+		case rest[0] == '"':
+			quoted, err := strconv.QuotedPrefix(rest)
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted string: %s", rest)
+			}
+			tok, _ = strconv.Unquote(quoted)
+			rest = rest[len(quoted):]
+		case strings.HasPrefix(rest, "->"):
+			tok = "->"
+			rest = rest[2:]
+		default:
+			end := strings.IndexFunc(rest, func(r rune) bool {
+				return unicode.IsSpace(r) || r == '"' || r == '#'
+			})
+			if end == -1 {
+				end = len(rest)
+			}
+			if arrow := strings.Index(rest[:end], "->"); arrow > 0 {
+				end = arrow
+			}
+			tok = rest[:end]
+			rest = rest[end:]
+		}
+		tokens = append(tokens, tok)
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+	}
+	return tokens, nil
+}
@@ -0,0 +1,232 @@
+// Package fsmgen generates typed Go constructors for the events of a machine definition,
+// and the Go wiring of machines described in a small textual DSL (see ParseDSL and GenerateMachine).
+//
+// For each event, it generates a struct type implementing fsm.Eventer, with the fields described by the
+// JSON schema of the transition payload, and a constructor taking every field,
+// so that producers can only fire structurally valid payloads:
+//
+//	// Pay is the payload of the "pay" event
+//	type Pay struct {
+//		Amount int        `json:"amount"`
+//		Svc    PayService `json:"svc"`
+//	}
+//
+//	func NewPay(amount int, svc PayService) fsm.Eventer
+//
+// Handlers read the payload with fsm.DataAs[Pay].
+//
+// The schema properties are mapped to Go types: integer to int, number to float64, string to string,
+// boolean to bool, array to a slice of its items and any other to interface{}.
+// The "x-go-type" extension sets the Go type of a property, and "x-go-import" the package it requires.
+package fsmgen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/token"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/quintans/fsm"
+)
+
+type schema struct {
+	Type       string          `json:"type"`
+	Properties json.RawMessage `json:"properties"`
+	Items      *schema         `json:"items"`
+	GoType     string          `json:"x-go-type"`
+	GoImport   string          `json:"x-go-import"`
+}
+
+type field struct {
+	name   string
+	param  string
+	goType string
+	json   string
+}
+
+type event struct {
+	key    string
+	name   string
+	fields []field
+}
+
+// Generate generates the Go source, for the package, with the event constructors of the definition
+func Generate(def fsm.Definition, pkg string) ([]byte, error) {
+	events, imports, err := collect(def)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by fsmgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	b.WriteString("import (\n")
+	imports = append(imports, "github.com/quintans/fsm")
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(&b, "\t%q\n", imp)
+	}
+	b.WriteString(")\n\n")
+
+	b.WriteString("const (\n")
+	for _, e := range events {
+		fmt.Fprintf(&b, "\tEvent%s = %q\n", e.name, e.key)
+	}
+	b.WriteString(")\n")
+
+	for _, e := range events {
+		fmt.Fprintf(&b, "\n// %s is the payload of the %q event\n", e.name, e.key)
+		fmt.Fprintf(&b, "type %s struct {\n", e.name)
+		for _, f := range e.fields {
+			fmt.Fprintf(&b, "\t%s %s `json:%q`\n", f.name, f.goType, f.json)
+		}
+		b.WriteString("}\n\n")
+		fmt.Fprintf(&b, "func (%s) Kind() interface{} {\n\treturn Event%s\n}\n\n", e.name, e.name)
+
+		var params, values []string
+		for _, f := range e.fields {
+			params = append(params, f.param+" "+f.goType)
+			values = append(values, f.name+": "+f.param)
+		}
+		fmt.Fprintf(&b, "// New%s creates the %q event\n", e.name, e.key)
+		fmt.Fprintf(&b, "func New%s(%s) fsm.Eventer {\n", e.name, strings.Join(params, ", "))
+		fmt.Fprintf(&b, "\treturn %s{%s}\n}\n", e.name, strings.Join(values, ", "))
+	}
+
+	return format.Source(b.Bytes())
+}
+
+// collect gathers the events of the definition, in order of appearance, and the imports they need
+func collect(def fsm.Definition) ([]event, []string, error) {
+	var events []event
+	seen := map[string]bool{}
+	names := map[string]string{}
+	imports := map[string]bool{}
+	for _, sd := range def.States {
+		for _, td := range sd.Transitions {
+			if td.Event == "" || seen[td.Event] {
+				continue
+			}
+			seen[td.Event] = true
+			e := event{key: td.Event, name: exported(td.Event)}
+			if other, ok := names[e.name]; ok {
+				return nil, nil, fmt.Errorf("events %q and %q generate the same name %s", other, e.key, e.name)
+			}
+			names[e.name] = e.key
+			if td.Schema != "" {
+				fields, err := fields(td.Schema, imports)
+				if err != nil {
+					return nil, nil, fmt.Errorf("invalid schema of event %q: %w", td.Event, err)
+				}
+				e.fields = fields
+			}
+			events = append(events, e)
+		}
+	}
+	list := make([]string, 0, len(imports))
+	for imp := range imports {
+		list = append(list, imp)
+	}
+	return events, list, nil
+}
+
+// fields returns the fields of an object schema, in the order of the properties
+func fields(raw string, imports map[string]bool) ([]field, error) {
+	s := schema{}
+	if err := json.Unmarshal([]byte(raw), &s); err != nil {
+		return nil, err
+	}
+	if s.Type != "object" {
+		return nil, fmt.Errorf("expected an object schema, got %q", s.Type)
+	}
+	if len(s.Properties) == 0 {
+		return nil, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(s.Properties))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var list []field
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		name := tok.(string)
+		prop := schema{}
+		if err := dec.Decode(&prop); err != nil {
+			return nil, err
+		}
+		list = append(list, field{
+			name:   exported(name),
+			param:  param(name),
+			goType: goType(prop, imports),
+			json:   name,
+		})
+	}
+	return list, nil
+}
+
+func goType(s schema, imports map[string]bool) string {
+	if s.GoType != "" {
+		if s.GoImport != "" {
+			imports[s.GoImport] = true
+		}
+		return s.GoType
+	}
+	switch s.Type {
+	case "integer":
+		return "int"
+	case "number":
+		return "float64"
+	case "string":
+		return "string"
+	case "boolean":
+		return "bool"
+	case "array":
+		if s.Items != nil {
+			return "[]" + goType(*s.Items, imports)
+		}
+		return "[]interface{}"
+	default:
+		return "interface{}"
+	}
+}
+
+// exported converts a key, like halt_intake, to an exported identifier, like HaltIntake
+func exported(key string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	id := b.String()
+	if id == "" || unicode.IsDigit([]rune(id)[0]) {
+		id = "E" + id
+	}
+	return id
+}
+
+// param converts a property name to an unexported identifier
+func param(name string) string {
+	id := []rune(exported(name))
+	id[0] = unicode.ToLower(id[0])
+	p := string(id)
+	if token.IsKeyword(p) {
+		p += "_"
+	}
+	return p
+}
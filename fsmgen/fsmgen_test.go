@@ -0,0 +1,150 @@
+package fsmgen_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/fsmgen"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	sm := fsm.New()
+	pending := sm.AddState("PENDING")
+	paid := sm.AddState("PAID")
+	cancelled := sm.AddState("CANCELLED")
+	pending.AddTransition("pay", paid, fsm.WithPayloadSchema(`{
+		"type": "object",
+		"properties": {
+			"amount": {"type": "integer"},
+			"svc": {"x-go-type": "PayService"},
+			"paid_at": {"type": "string", "x-go-type": "time.Time", "x-go-import": "time"},
+			"tags": {"type": "array", "items": {"type": "string"}}
+		}
+	}`))
+	pending.AddTransition("cancel", cancelled)
+	paid.AddTransition("cancel", cancelled)
+
+	def, err := sm.Definition()
+	require.NoError(t, err)
+	src, err := fsmgen.Generate(def, "orders")
+	require.NoError(t, err)
+
+	code := string(src)
+	require.Contains(t, code, "package orders")
+	require.Contains(t, code, `"time"`)
+	require.Contains(t, code, `EventPay    = "pay"`)
+	require.Contains(t, code, "func NewPay(amount int, svc PayService, paidAt time.Time, tags []string) fsm.Eventer {")
+	require.Contains(t, code, "PaidAt time.Time  `json:\"paid_at\"`")
+	require.Contains(t, code, "func NewCancel() fsm.Eventer {")
+}
+
+func TestGenerateRejectsInvalidSchema(t *testing.T) {
+	def := fsm.Definition{States: []fsm.StateDefinition{{
+		Name:        "A",
+		Transitions: []fsm.TransitionDefinition{{To: "A", Event: "tick", Schema: `{"type":"string"}`}},
+	}}}
+	_, err := fsmgen.Generate(def, "p")
+	require.Error(t, err)
+}
+
+func TestGenerateRejectsNameCollision(t *testing.T) {
+	def := fsm.Definition{States: []fsm.StateDefinition{{
+		Name: "A",
+		Transitions: []fsm.TransitionDefinition{
+			{To: "A", Event: "halt-intake"},
+			{To: "A", Event: "halt_intake"},
+		},
+	}}}
+	_, err := fsmgen.Generate(def, "p")
+	require.Error(t, err)
+}
+
+func TestParseDSL(t *testing.T) {
+	def, err := fsmgen.ParseDSL(strings.NewReader(`
+# traffic light
+machine "traffic-light"
+state GREEN
+state "OFF" final
+GREEN -> YELLOW on TICK
+state "YELLOW"->"RED" on "TICK" # quoted
+RED -> GREEN on TICK
+GREEN -> OFF on "turn off"
+`))
+	require.NoError(t, err)
+	require.Equal(t, fsm.Definition{
+		Name: "traffic-light",
+		States: []fsm.StateDefinition{
+			{Name: "GREEN", Transitions: []fsm.TransitionDefinition{{Event: "TICK", To: "YELLOW"}, {Event: "turn off", To: "OFF"}}},
+			{Name: "OFF", Final: true},
+			{Name: "YELLOW", Transitions: []fsm.TransitionDefinition{{Event: "TICK", To: "RED"}}},
+			{Name: "RED", Transitions: []fsm.TransitionDefinition{{Event: "TICK", To: "GREEN"}}},
+		},
+	}, def)
+
+	_, err = fsmgen.ParseDSL(strings.NewReader("state A\nA -> B TICK"))
+	require.EqualError(t, err, "line 2: expected state NAME [final] or FROM -> TO on EVENT")
+	_, err = fsmgen.ParseDSL(strings.NewReader(`A -> "B on TICK`))
+	require.Error(t, err)
+}
+
+func TestGenerateMachine(t *testing.T) {
+	def, err := fsmgen.ParseDSL(strings.NewReader(`
+machine "traffic-light"
+GREEN -> YELLOW on tick
+YELLOW -> RED on tick
+RED -> GREEN on tick
+state OFF final
+RED -> OFF on turn_off
+`))
+	require.NoError(t, err)
+	src, err := fsmgen.GenerateMachine(def, "light")
+	require.NoError(t, err)
+	require.Equal(t, `// Code generated by fsmgen. DO NOT EDIT.
+
+package light
+
+import "github.com/quintans/fsm"
+
+const (
+	StateGREEN  = "GREEN"
+	StateYELLOW = "YELLOW"
+	StateRED    = "RED"
+	StateOFF    = "OFF"
+)
+
+const (
+	EventTick    = "tick"
+	EventTurnOff = "turn_off"
+)
+
+// States holds the states of the machine
+type States struct {
+	GREEN  *fsm.State
+	YELLOW *fsm.State
+	RED    *fsm.State
+	OFF    *fsm.State
+}
+
+// NewMachine creates the machine, with its states and transitions
+func NewMachine(opts ...func(*fsm.StateMachine)) (*fsm.StateMachine, States) {
+	opts = append([]func(*fsm.StateMachine){fsm.WithName("traffic-light")}, opts...)
+	sm := fsm.New(opts...)
+	s := States{}
+	s.GREEN = sm.AddState(StateGREEN)
+	s.YELLOW = sm.AddState(StateYELLOW)
+	s.RED = sm.AddState(StateRED)
+	s.OFF = sm.AddState(StateOFF, fsm.Final())
+	s.GREEN.AddTransition(EventTick, s.YELLOW)
+	s.YELLOW.AddTransition(EventTick, s.RED)
+	s.RED.AddTransition(EventTick, s.GREEN)
+	s.RED.AddTransition(EventTurnOff, s.OFF)
+	return sm, s
+}
+`, string(src))
+
+	def.States[0].Transitions = append(def.States[0].Transitions, fsm.TransitionDefinition{Condition: "ready", To: "RED"})
+	_, err = fsmgen.GenerateMachine(def, "light")
+	require.Error(t, err)
+}
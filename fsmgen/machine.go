@@ -0,0 +1,107 @@
+package fsmgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+
+	"github.com/quintans/fsm"
+)
+
+// GenerateMachine generates the Go source, for the package, wiring the states and event transitions of the definition:
+// constants for the names of the states and the events, a States struct with a field per state,
+// and a NewMachine function creating the machine.
+//
+//	const StateGreen = "GREEN"
+//	const EventTick = "TICK"
+//
+//	func NewMachine(opts ...func(*fsm.StateMachine)) (*fsm.StateMachine, States)
+//
+// Only event transitions are supported, since the other kinds need handlers.
+// The event constants have the same names as the ones generated by Generate, so only one of them should be generated in a package.
+func GenerateMachine(def fsm.Definition, pkg string) ([]byte, error) {
+	states := make([]string, len(def.States))
+	fields := map[string]string{}
+	names := map[string]string{}
+	for i, sd := range def.States {
+		name := exported(sd.Name)
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("states %q and %q generate the same name %s", other, sd.Name, name)
+		}
+		names[name] = sd.Name
+		states[i] = name
+		fields[sd.Name] = name
+	}
+	events, _, err := collect(def)
+	if err != nil {
+		return nil, err
+	}
+	eventNames := map[string]string{}
+	for _, e := range events {
+		eventNames[e.key] = e.name
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by fsmgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	b.WriteString("import \"github.com/quintans/fsm\"\n\n")
+
+	b.WriteString("const (\n")
+	for i, sd := range def.States {
+		fmt.Fprintf(&b, "\tState%s = %q\n", states[i], sd.Name)
+	}
+	b.WriteString(")\n\n")
+	if len(events) > 0 {
+		b.WriteString("const (\n")
+		for _, e := range events {
+			fmt.Fprintf(&b, "\tEvent%s = %q\n", e.name, e.key)
+		}
+		b.WriteString(")\n\n")
+	}
+
+	b.WriteString("// States holds the states of the machine\n")
+	b.WriteString("type States struct {\n")
+	for _, name := range states {
+		fmt.Fprintf(&b, "\t%s *fsm.State\n", name)
+	}
+	b.WriteString("}\n\n")
+
+	b.WriteString("// NewMachine creates the machine, with its states and transitions\n")
+	b.WriteString("func NewMachine(opts ...func(*fsm.StateMachine)) (*fsm.StateMachine, States) {\n")
+	if def.Name != "" {
+		fmt.Fprintf(&b, "\topts = append([]func(*fsm.StateMachine){fsm.WithName(%q)}, opts...)\n", def.Name)
+	}
+	b.WriteString("\tsm := fsm.New(opts...)\n")
+	b.WriteString("\ts := States{}\n")
+	declared := map[string]bool{}
+	for i, sd := range def.States {
+		var opts string
+		if sd.Parent != "" {
+			if !declared[sd.Parent] {
+				return nil, fmt.Errorf("unknown parent %q of state %q: parents must be declared first", sd.Parent, sd.Name)
+			}
+			parent := fields[sd.Parent]
+			opts += fmt.Sprintf(", fsm.ChildOf(s.%s)", parent)
+		}
+		if sd.Final {
+			opts += ", fsm.Final()"
+		}
+		fmt.Fprintf(&b, "\ts.%s = sm.AddState(State%s%s)\n", states[i], states[i], opts)
+		declared[sd.Name] = true
+	}
+	for i, sd := range def.States {
+		for _, td := range sd.Transitions {
+			if td.Event == "" || td.Action != "" || td.Timeout != "" || td.Fallback || td.Condition != "" {
+				return nil, fmt.Errorf("unsupported transition on state %q: only event transitions can be generated", sd.Name)
+			}
+			to, ok := fields[td.To]
+			if !ok {
+				return nil, fmt.Errorf("unknown target %q of event %q on state %q", td.To, td.Event, sd.Name)
+			}
+			fmt.Fprintf(&b, "\ts.%s.AddTransition(Event%s, s.%s)\n", states[i], eventNames[td.Event], to)
+		}
+	}
+	b.WriteString("\treturn sm, s\n}\n")
+
+	return format.Source(b.Bytes())
+}
@@ -0,0 +1,9 @@
+// Package fsmgrpc exposes state machine instances as the gRPC service described in fsm.proto,
+// so that they can be driven and inspected remotely.
+//
+// It is a separate module, so that the core package does not depend on grpc-go.
+// The messages and the service stubs are generated from fsm.proto by buf, with the protoc-gen-go
+// and protoc-gen-go-grpc plugins, that must be in the PATH.
+package fsmgrpc
+
+//go:generate buf generate
@@ -0,0 +1,147 @@
+package fsmgrpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/fsmgrpc"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+type Pay struct {
+	Amount int `json:"amount"`
+}
+
+func (Pay) Kind() interface{} {
+	return "pay"
+}
+
+// serve starts the server over an in memory connection and returns a client for it
+func serve(t *testing.T, server *fsmgrpc.Server) fsmgrpc.StateMachineClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	fsmgrpc.RegisterStateMachineServer(srv, server)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return fsmgrpc.NewStateMachineClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	var paid []int
+	sm := fsm.New()
+	booked := sm.AddState("BOOKED")
+	paidState := sm.AddState("PAID", fsm.Final(), fsm.OnEnter(func(c *fsm.Context) error {
+		p, err := fsm.DataAs[Pay](c)
+		if err != nil {
+			return err
+		}
+		paid = append(paid, p.Amount)
+		return nil
+	}))
+	cancelled := sm.AddState("CANCELLED", fsm.Final())
+	booked.AddTransition("pay", paidState)
+	booked.AddTransition("cancel", cancelled)
+
+	smi := sm.FromState(booked)
+	smi.SetID("b1")
+	server := fsmgrpc.NewServer(fsmgrpc.WithEventType("pay", Pay{}))
+	server.Register(smi)
+	client := serve(t, server)
+	ctx := context.Background()
+
+	st, err := client.GetState(ctx, &fsmgrpc.GetStateRequest{InstanceId: "b1"})
+	require.NoError(t, err)
+	requireProto(t, &fsmgrpc.InstanceState{InstanceId: "b1", State: "BOOKED", PermittedEvents: []string{"pay", "cancel"}}, st)
+
+	list, err := client.ListTransitions(ctx, &fsmgrpc.ListTransitionsRequest{InstanceId: "b1"})
+	require.NoError(t, err)
+	requireProto(t, &fsmgrpc.ListTransitionsResponse{Transitions: []*fsmgrpc.Transition{
+		{Name: "pay", Kind: "event", Event: "pay", To: "PAID"},
+		{Name: "cancel", Kind: "event", Event: "cancel", To: "CANCELLED"},
+	}}, list)
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.StreamTransitions(streamCtx, &fsmgrpc.StreamTransitionsRequest{InstanceId: "b1"})
+	require.NoError(t, err)
+	// the stream is only watching once its headers are received
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	st, err = client.Fire(ctx, &fsmgrpc.FireRequest{InstanceId: "b1", Event: "pay", Payload: []byte(`{"amount":30}`)})
+	require.NoError(t, err)
+	require.Equal(t, "PAID", st.State)
+	require.Empty(t, st.PermittedEvents)
+	require.Equal(t, []int{30}, paid)
+
+	e, err := stream.Recv()
+	require.NoError(t, err)
+	requireProto(t, &fsmgrpc.TransitionEvent{InstanceId: "b1", From: "BOOKED", To: "PAID", Event: "pay"}, e)
+
+	_, err = client.Fire(ctx, &fsmgrpc.FireRequest{InstanceId: "b1", Event: "cancel"})
+	require.Equal(t, codes.FailedPrecondition, status.Code(err), err)
+
+	_, err = client.Fire(ctx, &fsmgrpc.FireRequest{InstanceId: "b1", Event: "pay", Payload: []byte(`{`)})
+	require.Equal(t, codes.InvalidArgument, status.Code(err), err)
+
+	_, err = client.GetState(ctx, &fsmgrpc.GetStateRequest{InstanceId: "missing"})
+	require.Equal(t, codes.NotFound, status.Code(err), err)
+
+	missing, err := client.StreamTransitions(ctx, &fsmgrpc.StreamTransitionsRequest{InstanceId: "missing"})
+	require.NoError(t, err)
+	_, err = missing.Recv()
+	require.Equal(t, codes.NotFound, status.Code(err), err)
+}
+
+func TestRegisterReplacesInstance(t *testing.T) {
+	sm := fsm.New()
+	green := sm.AddState("GREEN")
+	red := sm.AddState("RED")
+	green.AddTransition("stop", red)
+	red.AddTransition("go", green)
+
+	smi := sm.FromState(red)
+	smi.SetID("t1")
+	server := fsmgrpc.NewServer()
+	other := sm.FromState(green)
+	other.SetID("t1")
+	server.Register(smi)
+	server.Register(other)
+	client := serve(t, server)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := client.StreamTransitions(ctx, &fsmgrpc.StreamTransitionsRequest{InstanceId: "t1"})
+	require.NoError(t, err)
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	// the replaced instance no longer feeds the stream
+	require.NoError(t, smi.Fire("go"))
+	require.NoError(t, other.Fire("stop"))
+	e, err := stream.Recv()
+	require.NoError(t, err)
+	requireProto(t, &fsmgrpc.TransitionEvent{InstanceId: "t1", From: "GREEN", To: "RED", Event: "stop"}, e)
+}
+
+func requireProto(t *testing.T, expected, actual proto.Message) {
+	t.Helper()
+	require.True(t, proto.Equal(expected, actual), "expected %v, got %v", expected, actual)
+}
@@ -0,0 +1,241 @@
+package fsmgrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/quintans/fsm"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ServerOption configures a Server
+type ServerOption func(*Server)
+
+// WithEventType option registers the payload type of an event, like the ones generated by fsmgen.
+// The JSON payload is decoded into a new value of the type of the prototype, which is then fired.
+// The payloads of events without a registered type are ignored, and the event name is fired.
+func WithEventType(event string, prototype fsm.Eventer) ServerOption {
+	return func(s *Server) {
+		s.types.Register(event, prototype)
+	}
+}
+
+// Server serves the StateMachine service for the registered instances
+type Server struct {
+	UnimplementedStateMachineServer
+
+	mu        sync.Mutex
+	instances map[string]registration
+	watchers  map[string]map[chan *TransitionEvent]struct{}
+	types     *fsm.EventTypes
+}
+
+// registration is a registered instance with the observer that feeds its transition streams
+type registration struct {
+	smi      *fsm.StateMachineInstance
+	observer *observer
+}
+
+// observer broadcasts the transitions of a registered instance
+type observer struct {
+	fsm.BaseObserver
+	server *Server
+	id     string
+}
+
+func (o *observer) AfterTransition(c *fsm.Context) error {
+	o.server.broadcast(o, &TransitionEvent{
+		InstanceId: o.id,
+		From:       c.FromState().Name(),
+		To:         c.ToState().Name(),
+		Event:      fmt.Sprintf("%+v", c.Key()),
+	})
+	return nil
+}
+
+// NewServer creates a server without instances
+func NewServer(opts ...ServerOption) *Server {
+	s := &Server{
+		instances: map[string]registration{},
+		watchers:  map[string]map[chan *TransitionEvent]struct{}{},
+		types:     fsm.NewEventTypes(),
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// Register exposes the instance under its ID, replacing any instance with the same ID
+func (s *Server) Register(smi *fsm.StateMachineInstance) {
+	id := smi.ID()
+	s.mu.Lock()
+	old, ok := s.instances[id]
+	if ok && old.smi == smi {
+		s.mu.Unlock()
+		return
+	}
+	r := registration{smi: smi, observer: &observer{server: s, id: id}}
+	s.instances[id] = r
+	s.mu.Unlock()
+
+	// the observers are changed without holding the server lock, that the transitions take while holding the instance lock
+	if ok {
+		old.smi.RemoveObserver(old.observer)
+	}
+	smi.AddObserver(r.observer)
+}
+
+// Unregister stops exposing the instance with the ID, removing its observer and ending its transition streams
+func (s *Server) Unregister(id string) {
+	s.mu.Lock()
+	r, ok := s.instances[id]
+	delete(s.instances, id)
+	for ch := range s.watchers[id] {
+		close(ch)
+	}
+	delete(s.watchers, id)
+	s.mu.Unlock()
+
+	if ok {
+		r.smi.RemoveObserver(r.observer)
+	}
+}
+
+// broadcast sends the transition to the streams of the instance, dropping it for the ones that are not keeping up.
+// Transitions from an observer that is no longer registered are ignored.
+func (s *Server) broadcast(o *observer, e *TransitionEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.instances[o.id].observer != o {
+		return
+	}
+	for ch := range s.watchers[o.id] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (s *Server) instance(id string) (*fsm.StateMachineInstance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.instances[id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "%v: %s", fsm.ErrInstanceNotFound, id)
+	}
+	return r.smi, nil
+}
+
+// Fire fires the event into the instance and returns its new state
+func (s *Server) Fire(ctx context.Context, req *FireRequest) (*InstanceState, error) {
+	smi, err := s.instance(req.InstanceId)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := s.types.Decode(req.Event, req.Payload)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid payload of event %s: %v", req.Event, err)
+	}
+	if err := smi.FireContext(ctx, payload); err != nil {
+		return nil, statusOf(err)
+	}
+	return instanceState(smi), nil
+}
+
+// GetState returns the current state of the instance and the events it accepts
+func (s *Server) GetState(_ context.Context, req *GetStateRequest) (*InstanceState, error) {
+	smi, err := s.instance(req.InstanceId)
+	if err != nil {
+		return nil, err
+	}
+	return instanceState(smi), nil
+}
+
+func instanceState(smi *fsm.StateMachineInstance) *InstanceState {
+	st := &InstanceState{InstanceId: smi.ID(), State: smi.State().Name()}
+	for _, pe := range smi.PermittedEvents() {
+		st.PermittedEvents = append(st.PermittedEvents, fmt.Sprintf("%+v", pe.Key))
+	}
+	return st
+}
+
+// ListTransitions returns the transitions that apply on the current state of the instance
+func (s *Server) ListTransitions(_ context.Context, req *ListTransitionsRequest) (*ListTransitionsResponse, error) {
+	smi, err := s.instance(req.InstanceId)
+	if err != nil {
+		return nil, err
+	}
+	resp := &ListTransitionsResponse{}
+	for _, t := range smi.State().AllTransitions() {
+		tr := &Transition{Name: t.Name, Kind: t.Kind.String(), To: t.To.Name()}
+		if t.Key != nil {
+			tr.Event = fmt.Sprintf("%+v", t.Key)
+		}
+		resp.Transitions = append(resp.Transitions, tr)
+	}
+	return resp, nil
+}
+
+// StreamTransitions sends the transitions of the instance until the client goes away or the instance is unregistered
+func (s *Server) StreamTransitions(req *StreamTransitionsRequest, stream StateMachine_StreamTransitionsServer) error {
+	ch := make(chan *TransitionEvent, 16)
+	s.mu.Lock()
+	if _, ok := s.instances[req.InstanceId]; !ok {
+		s.mu.Unlock()
+		return status.Errorf(codes.NotFound, "%v: %s", fsm.ErrInstanceNotFound, req.InstanceId)
+	}
+	if s.watchers[req.InstanceId] == nil {
+		s.watchers[req.InstanceId] = map[chan *TransitionEvent]struct{}{}
+	}
+	s.watchers[req.InstanceId][ch] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.watchers[req.InstanceId], ch)
+	}()
+
+	// the headers tell the client that the transitions are being watched
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case e, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(e); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// statusOf maps the error of a fire to a status
+func statusOf(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	var undeclared *fsm.ErrUndeclaredEvent
+	switch {
+	case errors.Is(err, fsm.ErrUnknownTransition), errors.Is(err, fsm.ErrMachineCompleted):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, fsm.ErrVersionConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.As(err, &undeclared):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
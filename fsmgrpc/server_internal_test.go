@@ -0,0 +1,49 @@
+package fsmgrpc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnregisterRemovesObserver(t *testing.T) {
+	sm := fsm.New()
+	green := sm.AddState("GREEN")
+	red := sm.AddState("RED")
+	green.AddTransition("stop", red)
+
+	smi := sm.FromState(green)
+	smi.SetID("t1")
+	server := NewServer()
+	server.Register(smi)
+	server.Unregister("t1")
+	requireDetached(t, server, smi)
+
+	smi = sm.FromState(green)
+	smi.SetID("t2")
+	server.Register(smi)
+	other := sm.FromState(green)
+	other.SetID("t2")
+	server.Register(other)
+	requireDetached(t, server, smi)
+}
+
+// requireDetached requires that the transitions of the instance no longer reach the server,
+// since they would block on its lock
+func requireDetached(t *testing.T, server *Server, smi *fsm.StateMachineInstance) {
+	t.Helper()
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	done := make(chan error, 1)
+	go func() {
+		done <- smi.Fire("stop")
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("the instance still notifies the server")
+	}
+}
@@ -0,0 +1,213 @@
+// Package fsmhttp exposes state machines over HTTP.
+//
+// Instances are addressed under a base path:
+//
+//	GET  {base}/instances/{id}                 current state and permitted events
+//	POST {base}/instances/{id}/events/{event}  fires the event, with the JSON payload as body
+//
+// OpenAPI describes the routes and Handler serves them, driving the instances of a fsm.Manager.
+//
+// The Visualizer serves a live diagram of an instance, for operator dashboards.
+package fsmhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/quintans/fsm"
+)
+
+// Info describes the API in the OpenAPI document
+type Info struct {
+	Title   string
+	Version string
+	// BasePath is the prefix of the instance routes, like /orders
+	BasePath string
+}
+
+// OpenAPI generates an OpenAPI 3 document describing the routes of the machine,
+// with one operation per event, its payload schema and the possible resulting states.
+func OpenAPI(sm *fsm.StateMachine, info Info) ([]byte, error) {
+	return json.MarshalIndent(openAPIDocument(sm, info), "", "  ")
+}
+
+// OpenAPIHandler serves the OpenAPI document of the machine
+func OpenAPIHandler(sm *fsm.StateMachine, info Info) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, err := OpenAPI(sm, info)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(doc)
+	})
+}
+
+// eventInfo aggregates an event over all the states accepting it
+type eventInfo struct {
+	name    string
+	label   string
+	schema  string
+	from    []string
+	targets []string
+	roles   []string
+}
+
+func collectEvents(sm *fsm.StateMachine) []*eventInfo {
+	byName := map[string]*eventInfo{}
+	var events []*eventInfo
+	for _, st := range sm.States() {
+		for _, pe := range st.PermittedEvents() {
+			name := fmt.Sprintf("%+v", pe.Key)
+			e, ok := byName[name]
+			if !ok {
+				e = &eventInfo{name: name, label: pe.Label("")}
+				byName[name] = e
+				events = append(events, e)
+			}
+			if e.schema == "" {
+				e.schema = pe.Schema
+			}
+			e.from = appendUnique(e.from, st.Name())
+			e.targets = appendUnique(e.targets, pe.To.Name())
+			for _, r := range pe.Roles {
+				e.roles = appendUnique(e.roles, r)
+			}
+		}
+	}
+	return events
+}
+
+func appendUnique(list []string, v string) []string {
+	for _, s := range list {
+		if s == v {
+			return list
+		}
+	}
+	return append(list, v)
+}
+
+func openAPIDocument(sm *fsm.StateMachine, info Info) map[string]interface{} {
+	base := strings.TrimSuffix(info.BasePath, "/")
+	var states []string
+	for _, st := range sm.States() {
+		states = append(states, st.Name())
+	}
+	sort.Strings(states)
+
+	paths := map[string]interface{}{
+		base + "/instances/{id}": map[string]interface{}{
+			"get": map[string]interface{}{
+				"operationId": "getInstance",
+				"summary":     "Current state and permitted events",
+				"parameters":  []interface{}{idParameter()},
+				"responses": map[string]interface{}{
+					"200": jsonResponse("The instance", instanceSchema(states)),
+					"404": errorResponse("Unknown instance"),
+				},
+			},
+		},
+	}
+
+	for _, e := range collectEvents(sm) {
+		targets := append([]string(nil), e.targets...)
+		sort.Strings(targets)
+		op := map[string]interface{}{
+			"operationId": "fire_" + e.name,
+			"summary":     e.label,
+			"description": fmt.Sprintf("Accepted on states %s. Resulting states: %s.", strings.Join(e.from, ", "), strings.Join(targets, ", ")),
+			"parameters":  []interface{}{idParameter()},
+			"responses": map[string]interface{}{
+				"200": jsonResponse("The event was applied", instanceSchema(targets)),
+				"400": errorResponse("Invalid payload or undeclared event"),
+				"404": errorResponse("Unknown instance"),
+				"409": errorResponse("The event is not accepted on the current state, or the instance was changed concurrently"),
+			},
+		}
+		if e.schema != "" {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": json.RawMessage(e.schema),
+					},
+				},
+			}
+		}
+		if len(e.roles) > 0 {
+			op["x-roles"] = e.roles
+		}
+		paths[base+"/instances/{id}/events/"+url.PathEscape(e.name)] = map[string]interface{}{
+			"post": op,
+		}
+	}
+
+	title := info.Title
+	if title == "" {
+		title = "State machine"
+	}
+	version := info.Version
+	if version == "" {
+		version = sm.Fingerprint()
+	}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   title,
+			"version": version,
+		},
+		"paths": paths,
+	}
+}
+
+func idParameter() map[string]interface{} {
+	return map[string]interface{}{
+		"name":     "id",
+		"in":       "path",
+		"required": true,
+		"schema":   map[string]interface{}{"type": "string"},
+	}
+}
+
+func instanceSchema(states []string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id": map[string]interface{}{"type": "string"},
+			"state": map[string]interface{}{
+				"type": "string",
+				"enum": states,
+			},
+			"permittedEvents": map[string]interface{}{
+				"type":  "array",
+				"items": map[string]interface{}{"type": "string"},
+			},
+		},
+	}
+}
+
+func jsonResponse(description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{
+				"schema": schema,
+			},
+		},
+	}
+}
+
+func errorResponse(description string) map[string]interface{} {
+	return jsonResponse(description, map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"error": map[string]interface{}{"type": "string"},
+			"code":  map[string]interface{}{"type": "string"},
+		},
+	})
+}
@@ -0,0 +1,71 @@
+package fsmhttp_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/fsmhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func newTrip() *fsm.StateMachine {
+	sm := fsm.New()
+	created := sm.AddState("created")
+	booked := sm.AddState("booked")
+	cancelled := sm.AddState("cancelled")
+	paid := sm.AddState("paid")
+	created.AddTransition("book", booked,
+		fsm.WithLabel("", "Book a trip"),
+		fsm.WithPayloadSchema(`{"type":"object","properties":{"id":{"type":"string"}}}`),
+	)
+	booked.AddTransition("cancel", cancelled, fsm.WithRoles("customer"))
+	booked.AddTransition("pay", paid)
+	cancelled.AddTransition("pay", paid)
+	return sm
+}
+
+func TestOpenAPI(t *testing.T) {
+	data, err := fsmhttp.OpenAPI(newTrip(), fsmhttp.Info{Title: "Trips", Version: "1.0", BasePath: "/trips/"})
+	require.NoError(t, err)
+
+	var doc struct {
+		Info  map[string]string
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Summary     string
+			Description string
+			RequestBody *struct {
+				Content map[string]struct {
+					Schema map[string]interface{}
+				}
+			}
+			Roles []string `json:"x-roles"`
+		}
+	}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	require.Equal(t, "Trips", doc.Info["title"])
+	require.Len(t, doc.Paths, 4)
+
+	book := doc.Paths["/trips/instances/{id}/events/book"]["post"]
+	require.Equal(t, "fire_book", book.OperationID)
+	require.Equal(t, "Book a trip", book.Summary)
+	require.Equal(t, "object", book.RequestBody.Content["application/json"].Schema["type"])
+
+	pay := doc.Paths["/trips/instances/{id}/events/pay"]["post"]
+	require.Equal(t, "Accepted on states booked, cancelled. Resulting states: paid.", pay.Description)
+	require.Nil(t, pay.RequestBody)
+
+	require.Equal(t, []string{"customer"}, doc.Paths["/trips/instances/{id}/events/cancel"]["post"].Roles)
+	require.Contains(t, doc.Paths["/trips/instances/{id}"], "get")
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	fsmhttp.OpenAPIHandler(newTrip(), fsmhttp.Info{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	require.True(t, json.Valid(rec.Body.Bytes()))
+}
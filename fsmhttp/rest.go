@@ -0,0 +1,164 @@
+package fsmhttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/quintans/fsm"
+)
+
+// Error codes of the error responses
+const (
+	CodeInstanceNotFound   = "instance_not_found"
+	CodeTransitionNotFound = "transition_not_found"
+	CodeMachineCompleted   = "machine_completed"
+	CodeVersionConflict    = "version_conflict"
+	CodeInvalidPayload     = "invalid_payload"
+	CodeUndeclaredEvent    = "undeclared_event"
+	CodeInternal           = "internal"
+)
+
+// ErrorResponse is the body of the error responses
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+// InstanceResponse is the body of the instance responses
+type InstanceResponse struct {
+	ID              string   `json:"id"`
+	State           string   `json:"state"`
+	PermittedEvents []string `json:"permittedEvents"`
+}
+
+// Handler serves the instances of a manager on the routes described by OpenAPI
+type Handler struct {
+	manager *fsm.Manager
+	base    string
+	types   *fsm.EventTypes
+}
+
+// HandlerOption configures a Handler
+type HandlerOption func(*Handler)
+
+// WithBasePath option sets the prefix of the routes, like /orders
+func WithBasePath(base string) HandlerOption {
+	return func(h *Handler) {
+		h.base = strings.TrimSuffix(base, "/")
+	}
+}
+
+// WithEventType option registers the payload type of an event, like the ones generated by fsmgen.
+// The JSON body is decoded into a new value of the type of the prototype, which is then fired.
+// The bodies of events without a registered type are ignored, and the event name is fired.
+func WithEventType(event string, prototype fsm.Eventer) HandlerOption {
+	return func(h *Handler) {
+		h.types.Register(event, prototype)
+	}
+}
+
+// NewHandler creates the handler driving the instances of the manager
+func NewHandler(m *fsm.Manager, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		manager: m,
+		types:   fsm.NewEventTypes(),
+	}
+	for _, o := range opts {
+		o(h)
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.EscapedPath(), h.base+"/instances/")
+	if rest == r.URL.EscapedPath() || rest == "" {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.Split(rest, "/")
+	id, err := url.PathUnescape(parts[0])
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		h.get(w, r, id)
+	case len(parts) == 3 && parts[1] == "events" && r.Method == http.MethodPost:
+		event, err := url.PathUnescape(parts[2])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		h.fire(w, r, id, event)
+	case len(parts) == 1 || (len(parts) == 3 && parts[1] == "events"):
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, id string) {
+	st, err := h.manager.State(r.Context(), id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeInstance(w, id, st)
+}
+
+func (h *Handler) fire(w http.ResponseWriter, r *http.Request, id, event string) {
+	var payload interface{}
+	body, err := io.ReadAll(r.Body)
+	if err == nil {
+		payload, err = h.types.Decode(event, body)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: fmt.Sprintf("invalid payload of event %s: %v", event, err), Code: CodeInvalidPayload})
+		return
+	}
+	st, err := h.manager.Fire(r.Context(), id, payload)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeInstance(w, id, st)
+}
+
+func writeInstance(w http.ResponseWriter, id string, st *fsm.State) {
+	events := []string{}
+	for _, pe := range st.PermittedEvents() {
+		events = append(events, fmt.Sprintf("%+v", pe.Key))
+	}
+	writeJSON(w, http.StatusOK, InstanceResponse{ID: id, State: st.Name(), PermittedEvents: events})
+}
+
+// writeError maps the error to a status and a code
+func writeError(w http.ResponseWriter, err error) {
+	status, code := http.StatusInternalServerError, CodeInternal
+	var undeclared *fsm.ErrUndeclaredEvent
+	switch {
+	case errors.Is(err, fsm.ErrInstanceNotFound):
+		status, code = http.StatusNotFound, CodeInstanceNotFound
+	case errors.Is(err, fsm.ErrUnknownTransition):
+		status, code = http.StatusConflict, CodeTransitionNotFound
+	case errors.Is(err, fsm.ErrMachineCompleted):
+		status, code = http.StatusConflict, CodeMachineCompleted
+	case errors.Is(err, fsm.ErrVersionConflict):
+		status, code = http.StatusConflict, CodeVersionConflict
+	case errors.As(err, &undeclared):
+		status, code = http.StatusBadRequest, CodeUndeclaredEvent
+	}
+	writeJSON(w, status, ErrorResponse{Error: err.Error(), Code: code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,94 @@
+package fsmhttp_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/fsmhttp"
+	"github.com/stretchr/testify/require"
+)
+
+type Pay struct {
+	Amount int `json:"amount"`
+}
+
+func (Pay) Kind() interface{} {
+	return "pay"
+}
+
+func TestHandler(t *testing.T) {
+	var paid []int
+	sm := fsm.New()
+	booked := sm.AddState("BOOKED")
+	paidState := sm.AddState("PAID", fsm.Final(), fsm.OnEnter(func(c *fsm.Context) error {
+		p, err := fsm.DataAs[Pay](c)
+		if err != nil {
+			return err
+		}
+		paid = append(paid, p.Amount)
+		return nil
+	}))
+	cancelled := sm.AddState("CANCELLED", fsm.Final())
+	booked.AddTransition("pay", paidState)
+	booked.AddTransition("cancel", cancelled)
+
+	m := fsm.NewManager(sm, fsm.NewMemoryStore())
+	require.NoError(t, m.Create(context.Background(), "b1", booked))
+	require.NoError(t, m.Create(context.Background(), "b2", booked))
+	srv := httptest.NewServer(fsmhttp.NewHandler(m, fsmhttp.WithBasePath("/bookings"), fsmhttp.WithEventType("pay", Pay{})))
+	defer srv.Close()
+
+	status, body := call(t, http.MethodGet, srv.URL+"/bookings/instances/b1", "")
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"id":"b1","state":"BOOKED","permittedEvents":["pay","cancel"]}`, body)
+
+	status, body = call(t, http.MethodPost, srv.URL+"/bookings/instances/b1/events/pay", `{"amount":30}`)
+	require.Equal(t, http.StatusOK, status)
+	require.JSONEq(t, `{"id":"b1","state":"PAID","permittedEvents":[]}`, body)
+	require.Equal(t, []int{30}, paid)
+
+	status, body = call(t, http.MethodPost, srv.URL+"/bookings/instances/b1/events/cancel", "")
+	require.Equal(t, http.StatusConflict, status)
+	require.Equal(t, fsmhttp.CodeMachineCompleted, errorCode(t, body))
+
+	status, body = call(t, http.MethodPost, srv.URL+"/bookings/instances/b2/events/refund", "")
+	require.Equal(t, http.StatusConflict, status)
+	require.Equal(t, fsmhttp.CodeTransitionNotFound, errorCode(t, body))
+
+	status, body = call(t, http.MethodPost, srv.URL+"/bookings/instances/b2/events/pay", `{"amount":"x"}`)
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, fsmhttp.CodeInvalidPayload, errorCode(t, body))
+
+	status, body = call(t, http.MethodGet, srv.URL+"/bookings/instances/b3", "")
+	require.Equal(t, http.StatusNotFound, status)
+	require.Equal(t, fsmhttp.CodeInstanceNotFound, errorCode(t, body))
+
+	status, _ = call(t, http.MethodDelete, srv.URL+"/bookings/instances/b2", "")
+	require.Equal(t, http.StatusMethodNotAllowed, status)
+	status, _ = call(t, http.MethodGet, srv.URL+"/orders/instances/b2", "")
+	require.Equal(t, http.StatusNotFound, status)
+}
+
+func call(t *testing.T, method, url, body string) (int, string) {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res.StatusCode, string(b)
+}
+
+func errorCode(t *testing.T, body string) string {
+	var e fsmhttp.ErrorResponse
+	require.NoError(t, json.Unmarshal([]byte(body), &e))
+	require.NotEmpty(t, e.Error)
+	return e.Code
+}
@@ -0,0 +1,191 @@
+package fsmhttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/quintans/fsm"
+)
+
+// Transition is the notification sent to the visualizer pages when the instance transitions
+type Transition struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Event string `json:"event"`
+}
+
+// Visualizer serves an HTML page rendering the machine of an instance, with Mermaid,
+// highlighting the current state and updating it, over server-sent events, as the instance transitions.
+// It should be mounted with a trailing slash, since the page uses relative URLs:
+//
+//	mux.Handle("/viz/", http.StripPrefix("/viz", fsmhttp.NewVisualizer(smi)))
+//
+// Routes, relative to the mount point:
+//
+//	GET /          the HTML page
+//	GET /diagram   the Mermaid source of the diagram
+//	GET /events    the stream of transitions, as server-sent events
+//
+// The page loads Mermaid from a CDN.
+type Visualizer struct {
+	instance *fsm.StateMachineInstance
+	mu       sync.Mutex
+	clients  map[chan Transition]struct{}
+}
+
+// NewVisualizer creates the visualizer of the instance, listening to its transitions
+func NewVisualizer(smi *fsm.StateMachineInstance) *Visualizer {
+	v := &Visualizer{
+		instance: smi,
+		clients:  map[chan Transition]struct{}{},
+	}
+	smi.AddOnTransition(v.notify)
+	return v
+}
+
+func (v *Visualizer) notify(c *fsm.Context) error {
+	t := Transition{
+		From:  c.FromState().Name(),
+		To:    c.ToState().Name(),
+		Event: fmt.Sprintf("%+v", c.Key()),
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for ch := range v.clients {
+		select {
+		case ch <- t:
+		default:
+			// slow client. It will catch up with the next diagram refresh
+		}
+	}
+	return nil
+}
+
+func (v *Visualizer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/diagram"):
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, Mermaid(v.instance.StateMachine, v.instance.State()))
+	case strings.HasSuffix(r.URL.Path, "/events"):
+		v.stream(w, r)
+	default:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		page.Execute(w, v.instance.StateMachine.Name())
+	}
+}
+
+// stream sends the transitions as server-sent events until the client goes away
+func (v *Visualizer) stream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	ch := make(chan Transition, 16)
+	v.mu.Lock()
+	v.clients[ch] = struct{}{}
+	v.mu.Unlock()
+	defer func() {
+		v.mu.Lock()
+		delete(v.clients, ch)
+		v.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case t := <-ch:
+			data, _ := json.Marshal(t)
+			fmt.Fprintf(w, "event: transition\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
+// Mermaid renders the machine as a Mermaid state diagram, highlighting the current state, if any.
+// Global transitions are drawn from a state named "*".
+func Mermaid(sm *fsm.StateMachine, current *fsm.State) string {
+	ids := map[*fsm.State]string{}
+	var b strings.Builder
+	b.WriteString("stateDiagram-v2\n")
+	b.WriteString("    classDef current fill:#f96,stroke:#333,stroke-width:2px\n")
+	for i, st := range sm.States() {
+		ids[st] = fmt.Sprintf("s%d", i)
+		fmt.Fprintf(&b, "    state %q as %s\n", st.Name(), ids[st])
+	}
+	edge := func(from string, t fsm.TransitionInfo) {
+		to, ok := ids[t.To]
+		if !ok {
+			return
+		}
+		fmt.Fprintf(&b, "    %s --> %s : %s\n", from, to, mermaidLabel(t.Name))
+	}
+	for _, st := range sm.States() {
+		for _, t := range st.Transitions() {
+			edge(ids[st], t)
+		}
+		if st.IsFinal() {
+			fmt.Fprintf(&b, "    %s --> [*]\n", ids[st])
+		}
+	}
+	if global := sm.GlobalTransitions(); len(global) > 0 {
+		b.WriteString("    state \"*\" as any\n")
+		for _, t := range global {
+			edge("any", t)
+		}
+	}
+	if id, ok := ids[current]; ok {
+		fmt.Fprintf(&b, "    class %s current\n", id)
+	}
+	return b.String()
+}
+
+// mermaidLabel removes the characters that end a Mermaid transition label
+func mermaidLabel(s string) string {
+	return strings.NewReplacer("\n", " ", ";", ",", ":", " ").Replace(s)
+}
+
+var page = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{if .}}{{.}}{{else}}State machine{{end}}</title>
+<script src="https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.min.js"></script>
+</head>
+<body>
+<h1>{{if .}}{{.}}{{else}}State machine{{end}}</h1>
+<div id="diagram"></div>
+<pre id="last"></pre>
+<script>
+const base = location.pathname.replace(/\/?$/, '/');
+mermaid.initialize({startOnLoad: false});
+let seq = 0;
+async function refresh() {
+	const src = await (await fetch(base + 'diagram')).text();
+	const {svg} = await mermaid.render('fsm' + (seq++), src);
+	document.getElementById('diagram').innerHTML = svg;
+}
+const events = new EventSource(base + 'events');
+events.addEventListener('transition', e => {
+	const t = JSON.parse(e.data);
+	document.getElementById('last').textContent = t.from + ' -> ' + t.to + ' on ' + t.event;
+	refresh();
+});
+refresh();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,83 @@
+package fsmhttp_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/fsmhttp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMermaid(t *testing.T) {
+	sm := fsm.New()
+	green := sm.AddState("GREEN")
+	yellow := sm.AddState("YELLOW")
+	off := sm.AddState("OFF", fsm.Final())
+	green.AddTransition("TICK", yellow)
+	sm.AddGlobalTransition("halt", off)
+
+	require.Equal(t, `stateDiagram-v2
+    classDef current fill:#f96,stroke:#333,stroke-width:2px
+    state "GREEN" as s0
+    state "YELLOW" as s1
+    state "OFF" as s2
+    s0 --> s1 : TICK
+    s2 --> [*]
+    state "*" as any
+    any --> s2 : halt
+    class s1 current
+`, fsmhttp.Mermaid(sm, yellow))
+}
+
+func TestVisualizer(t *testing.T) {
+	sm := fsm.New(fsm.WithName("traffic"))
+	green := sm.AddState("GREEN")
+	yellow := sm.AddState("YELLOW")
+	green.AddTransition("TICK", yellow)
+	smi := sm.FromState(green)
+
+	mux := http.NewServeMux()
+	mux.Handle("/viz/", http.StripPrefix("/viz", fsmhttp.NewVisualizer(smi)))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	body := get(t, srv.URL+"/viz/")
+	require.Contains(t, body, "<title>traffic</title>")
+	require.Contains(t, body, "mermaid")
+	require.Contains(t, get(t, srv.URL+"/viz/diagram"), "class s0 current")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/viz/events", nil)
+	require.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	require.NoError(t, smi.Fire("TICK"))
+	lines := bufio.NewReader(res.Body)
+	line, err := lines.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "event: transition\n", line)
+	line, err = lines.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, `data: {"from":"GREEN","to":"YELLOW","event":"TICK"}`+"\n", line)
+	require.Contains(t, get(t, srv.URL+"/viz/diagram"), "class s1 current")
+}
+
+func get(t *testing.T, url string) string {
+	res, err := http.Get(url)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	b, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,93 @@
+// Package fsmprom implements fsm.Metrics with Prometheus collectors.
+//
+// It is a separate module, so that the core package does not depend on the Prometheus client.
+package fsmprom
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/quintans/fsm"
+)
+
+// Metrics collects the measurements of the instances of one machine, labelled with the machine name
+type Metrics struct {
+	transitions *prometheus.CounterVec
+	handlers    *prometheus.HistogramVec
+	errors      *prometheus.CounterVec
+	instances   *prometheus.GaugeVec
+}
+
+var _ fsm.Metrics = (*Metrics)(nil)
+
+// Collectors are the collectors shared by the machines registered in the same registry
+type Collectors struct {
+	transitions *prometheus.CounterVec
+	handlers    *prometheus.HistogramVec
+	errors      *prometheus.CounterVec
+	instances   *prometheus.GaugeVec
+}
+
+// NewCollectors creates and registers the collectors:
+//
+//	fsm_transitions_total{machine, from, to, event}
+//	fsm_handler_duration_seconds{machine, kind, state}
+//	fsm_errors_total{machine, state, event, source}
+//	fsm_instances{machine, state}
+func NewCollectors(reg prometheus.Registerer) (*Collectors, error) {
+	c := &Collectors{
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fsm_transitions_total",
+			Help: "Number of transitions.",
+		}, []string{"machine", "from", "to", "event"}),
+		handlers: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "fsm_handler_duration_seconds",
+			Help:    "Duration of the state handlers.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"machine", "kind", "state"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "fsm_errors_total",
+			Help: "Number of failed handlers and fires.",
+		}, []string{"machine", "state", "event", "source"}),
+		instances: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "fsm_instances",
+			Help: "Number of instances in each state.",
+		}, []string{"machine", "state"}),
+	}
+	for _, col := range []prometheus.Collector{c.transitions, c.handlers, c.errors, c.instances} {
+		if err := reg.Register(col); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// Machine returns the metrics for the named machine, to be set with fsm.WithMetrics
+func (c *Collectors) Machine(name string) *Metrics {
+	labels := prometheus.Labels{"machine": name}
+	return &Metrics{
+		transitions: c.transitions.MustCurryWith(labels),
+		handlers:    c.handlers.MustCurryWith(labels).(*prometheus.HistogramVec),
+		errors:      c.errors.MustCurryWith(labels),
+		instances:   c.instances.MustCurryWith(labels),
+	}
+}
+
+func (m *Metrics) Transitioned(from, to, event string) {
+	m.transitions.WithLabelValues(from, to, event).Inc()
+}
+
+func (m *Metrics) Handled(kind, state string, d time.Duration, err error) {
+	m.handlers.WithLabelValues(kind, state).Observe(d.Seconds())
+	if err != nil {
+		m.errors.WithLabelValues(state, "", kind).Inc()
+	}
+}
+
+func (m *Metrics) Failed(state, event string, err error) {
+	m.errors.WithLabelValues(state, event, "fire").Inc()
+}
+
+func (m *Metrics) Occupancy(state string, delta int) {
+	m.instances.WithLabelValues(state).Add(float64(delta))
+}
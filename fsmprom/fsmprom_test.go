@@ -0,0 +1,52 @@
+package fsmprom_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/fsmprom"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	collectors, err := fsmprom.NewCollectors(reg)
+	require.NoError(t, err)
+
+	sm := fsm.New(fsm.WithMetrics(collectors.Machine("light")))
+	green := sm.AddState("GREEN")
+	yellow := sm.AddState("YELLOW", fsm.OnEnter(func(c *fsm.Context) error {
+		return nil
+	}))
+	red := sm.AddState("RED", fsm.OnEnter(func(c *fsm.Context) error {
+		return errors.New("boom")
+	}))
+	green.AddTransition("tick", yellow)
+	yellow.AddTransition("tick", red)
+
+	smi := sm.FromState(green)
+	require.NoError(t, smi.Fire("tick"))
+	require.Error(t, smi.Fire("tick"))
+
+	expected := `
+# HELP fsm_instances Number of instances in each state.
+# TYPE fsm_instances gauge
+fsm_instances{machine="light",state="GREEN"} 0
+fsm_instances{machine="light",state="YELLOW"} 1
+# HELP fsm_transitions_total Number of transitions.
+# TYPE fsm_transitions_total counter
+fsm_transitions_total{event="tick",from="GREEN",machine="light",to="YELLOW"} 1
+# HELP fsm_errors_total Number of failed handlers and fires.
+# TYPE fsm_errors_total counter
+fsm_errors_total{event="",machine="light",source="OnEnter",state="RED"} 1
+fsm_errors_total{event="tick",machine="light",source="fire",state="YELLOW"} 1
+`
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "fsm_instances", "fsm_transitions_total", "fsm_errors_total"))
+	count, err := testutil.GatherAndCount(reg, "fsm_handler_duration_seconds")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
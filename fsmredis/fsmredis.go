@@ -0,0 +1,117 @@
+// Package fsmredis implements an fsm.Store over Redis.
+//
+// Each instance is a hash, at the key prefix+id, with the version and the JSON snapshot,
+// updated atomically with Lua scripts. The package does not depend on a Redis client:
+// any client able to evaluate scripts can be adapted, like go-redis:
+//
+//	store := fsmredis.New(fsmredis.ClientFunc(func(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+//		return rdb.Eval(ctx, script, keys, args...).Result()
+//	}))
+package fsmredis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/quintans/fsm"
+)
+
+// Client evaluates Lua scripts
+type Client interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// ClientFunc adapts a function to a Client
+type ClientFunc func(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+
+func (f ClientFunc) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	return f(ctx, script, keys, args...)
+}
+
+const loadScript = `
+local v = redis.call('HMGET', KEYS[1], 'version', 'snapshot')
+if not v[1] then
+	return {}
+end
+return v
+`
+
+const saveScript = `
+local cur = tonumber(redis.call('HGET', KEYS[1], 'version')) or 0
+if cur ~= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'version', cur + 1, 'snapshot', ARGV[2])
+return 1
+`
+
+// Store is an fsm.Store over Redis
+type Store struct {
+	client Client
+	prefix string
+}
+
+// Option configures a Store
+type Option func(*Store)
+
+// WithPrefix option sets the prefix of the keys. Defaults to "fsm:".
+func WithPrefix(prefix string) Option {
+	return func(s *Store) {
+		s.prefix = prefix
+	}
+}
+
+// New creates a store over the client
+func New(client Client, opts ...Option) *Store {
+	s := &Store{
+		client: client,
+		prefix: "fsm:",
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+func (s *Store) Load(ctx context.Context, id string) (fsm.Snapshot, int64, error) {
+	res, err := s.client.Eval(ctx, loadScript, []string{s.prefix + id})
+	if err != nil {
+		return fsm.Snapshot{}, 0, err
+	}
+	values, ok := res.([]interface{})
+	if !ok {
+		return fsm.Snapshot{}, 0, fmt.Errorf("unexpected reply loading instance %s: %T", id, res)
+	}
+	if len(values) == 0 {
+		return fsm.Snapshot{}, 0, fsm.ErrInstanceNotFound
+	}
+	if len(values) != 2 {
+		return fsm.Snapshot{}, 0, fmt.Errorf("unexpected reply loading instance %s: %v", id, values)
+	}
+	version, err := strconv.ParseInt(fmt.Sprint(values[0]), 10, 64)
+	if err != nil {
+		return fsm.Snapshot{}, 0, fmt.Errorf("invalid version of instance %s: %w", id, err)
+	}
+	snap := fsm.Snapshot{}
+	if err := json.Unmarshal([]byte(fmt.Sprint(values[1])), &snap); err != nil {
+		return fsm.Snapshot{}, 0, fmt.Errorf("invalid snapshot of instance %s: %w", id, err)
+	}
+	return snap, version, nil
+}
+
+func (s *Store) Save(ctx context.Context, id string, snap fsm.Snapshot, expected int64) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	res, err := s.client.Eval(ctx, saveScript, []string{s.prefix + id}, expected, string(data))
+	if err != nil {
+		return err
+	}
+	if n, ok := res.(int64); !ok || n != 1 {
+		return fsm.ErrVersionConflict
+	}
+	return nil
+}
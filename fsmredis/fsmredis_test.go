@@ -0,0 +1,66 @@
+package fsmredis_test
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/fsmredis"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis emulates the scripts of the store over a map of hashes
+type fakeRedis struct {
+	mu     sync.Mutex
+	hashes map[string]map[string]string
+}
+
+func (r *fakeRedis) Eval(_ context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := r.hashes[keys[0]]
+	if strings.Contains(script, "HMGET") {
+		if h == nil {
+			return []interface{}{}, nil
+		}
+		return []interface{}{h["version"], h["snapshot"]}, nil
+	}
+	cur, _ := strconv.ParseInt(h["version"], 10, 64)
+	if cur != args[0].(int64) {
+		return int64(0), nil
+	}
+	r.hashes[keys[0]] = map[string]string{
+		"version":  strconv.FormatInt(cur+1, 10),
+		"snapshot": args[1].(string),
+	}
+	return int64(1), nil
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	redis := &fakeRedis{hashes: map[string]map[string]string{}}
+	store := fsmredis.New(redis, fsmredis.WithPrefix("orders:"))
+
+	_, _, err := store.Load(ctx, "order-1")
+	require.ErrorIs(t, err, fsm.ErrInstanceNotFound)
+
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition("tick", b)
+
+	smi, err := sm.Create(ctx, store, "order-1", a)
+	require.NoError(t, err)
+	require.NoError(t, smi.Fire("tick"))
+	require.Contains(t, redis.hashes, "orders:order-1")
+
+	loaded, err := sm.Load(ctx, store, "order-1")
+	require.NoError(t, err)
+	require.Equal(t, b, loaded.State())
+	require.EqualValues(t, 2, loaded.Version())
+
+	require.ErrorIs(t, store.Save(ctx, "order-1", fsm.Snapshot{State: "A"}, 1), fsm.ErrVersionConflict)
+}
@@ -0,0 +1,153 @@
+// Package fsmsql implements an fsm.Store over database/sql.
+//
+// The snapshots are stored as JSON in a table like:
+//
+//	CREATE TABLE fsm_instances (
+//		id       VARCHAR(255) PRIMARY KEY,
+//		version  BIGINT NOT NULL,
+//		snapshot TEXT NOT NULL
+//	)
+package fsmsql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/quintans/fsm"
+)
+
+// Transaction returns an fsm.Transactor running each Fire in a transaction of the database.
+// The store joins it, so that the instance is saved in the same transaction as the writes of the handlers,
+// that get the *sql.Tx with Context.Tx.
+func Transaction(db *sql.DB) fsm.Transactor {
+	return func(ctx context.Context, fn func(tx interface{}) error) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if err := fn(tx); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		return tx.Commit()
+	}
+}
+
+// conn is implemented by *sql.DB and *sql.Tx
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Store is an fsm.Store over a SQL database
+type Store struct {
+	db     *sql.DB
+	table  string
+	dollar bool
+}
+
+// Option configures a Store
+type Option func(*Store)
+
+// WithTable option sets the table name. Defaults to fsm_instances.
+func WithTable(table string) Option {
+	return func(s *Store) {
+		s.table = table
+	}
+}
+
+// WithDollarPlaceholders option uses $1, $2... placeholders, like PostgreSQL, instead of ?
+func WithDollarPlaceholders() Option {
+	return func(s *Store) {
+		s.dollar = true
+	}
+}
+
+// New creates a store over the database
+func New(db *sql.DB, opts ...Option) *Store {
+	s := &Store{
+		db:    db,
+		table: "fsm_instances",
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	return s
+}
+
+// conn returns the transaction carried by the context, if any, or else the database
+func (s *Store) conn(ctx context.Context) conn {
+	if tx, ok := fsm.TxFromContext(ctx).(*sql.Tx); ok {
+		return tx
+	}
+	return s.db
+}
+
+// query replaces the ? placeholders, if dollar placeholders are used
+func (s *Store) query(format string) string {
+	q := fmt.Sprintf(format, s.table)
+	if !s.dollar {
+		return q
+	}
+	var b []byte
+	n := 0
+	for i := 0; i < len(q); i++ {
+		if q[i] == '?' {
+			n++
+			b = append(b, fmt.Sprintf("$%d", n)...)
+			continue
+		}
+		b = append(b, q[i])
+	}
+	return string(b)
+}
+
+func (s *Store) Load(ctx context.Context, id string) (fsm.Snapshot, int64, error) {
+	var version int64
+	var data string
+	err := s.conn(ctx).QueryRowContext(ctx, s.query("SELECT version, snapshot FROM %s WHERE id = ?"), id).Scan(&version, &data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fsm.Snapshot{}, 0, fsm.ErrInstanceNotFound
+	}
+	if err != nil {
+		return fsm.Snapshot{}, 0, err
+	}
+	snap := fsm.Snapshot{}
+	if err := json.Unmarshal([]byte(data), &snap); err != nil {
+		return fsm.Snapshot{}, 0, fmt.Errorf("invalid snapshot of instance %s: %w", id, err)
+	}
+	return snap, version, nil
+}
+
+func (s *Store) Save(ctx context.Context, id string, snap fsm.Snapshot, expected int64) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if expected == 0 {
+		_, err := s.conn(ctx).ExecContext(ctx, s.query("INSERT INTO %s (id, version, snapshot) VALUES (?, ?, ?)"), id, 1, string(data))
+		if err == nil {
+			return nil
+		}
+		// a duplicate key is reported differently by every driver, so check if the instance exists
+		if _, _, lerr := s.Load(ctx, id); lerr == nil {
+			return fsm.ErrVersionConflict
+		}
+		return err
+	}
+	res, err := s.conn(ctx).ExecContext(ctx, s.query("UPDATE %s SET version = ?, snapshot = ? WHERE id = ? AND version = ?"), expected+1, string(data), id, expected)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fsm.ErrVersionConflict
+	}
+	return nil
+}
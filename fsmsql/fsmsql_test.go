@@ -0,0 +1,214 @@
+package fsmsql_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/fsmsql"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDriver is an in memory database that only understands the statements of the store
+type fakeDriver struct {
+	mu      sync.Mutex
+	queries []string
+	rows    map[string][2]driver.Value
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{d: d}, nil
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	c.d.mu.Lock()
+	c.d.queries = append(c.d.queries, query)
+	c.d.mu.Unlock()
+	return &fakeStmt{d: c.d, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	c.d.queries = append(c.d.queries, "BEGIN")
+	saved := map[string][2]driver.Value{}
+	for k, v := range c.d.rows {
+		saved[k] = v
+	}
+	return &fakeTx{d: c.d, saved: saved}, nil
+}
+
+// fakeTx restores the rows on rollback
+type fakeTx struct {
+	d     *fakeDriver
+	saved map[string][2]driver.Value
+}
+
+func (t *fakeTx) Commit() error {
+	t.d.mu.Lock()
+	defer t.d.mu.Unlock()
+	t.d.queries = append(t.d.queries, "COMMIT")
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.d.mu.Lock()
+	defer t.d.mu.Unlock()
+	t.d.queries = append(t.d.queries, "ROLLBACK")
+	t.d.rows = t.saved
+	return nil
+}
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	switch {
+	case strings.HasPrefix(s.query, "INSERT"):
+		id := args[0].(string)
+		if _, ok := s.d.rows[id]; ok {
+			return nil, errors.New("duplicate key")
+		}
+		s.d.rows[id] = [2]driver.Value{args[1], args[2]}
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "UPDATE"):
+		id := args[2].(string)
+		row, ok := s.d.rows[id]
+		if !ok || row[0] != args[3] {
+			return driver.RowsAffected(0), nil
+		}
+		s.d.rows[id] = [2]driver.Value{args[0], args[1]}
+		return driver.RowsAffected(1), nil
+	}
+	return nil, errors.New("unexpected statement: " + s.query)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.mu.Lock()
+	defer s.d.mu.Unlock()
+	row, ok := s.d.rows[args[0].(string)]
+	if !ok {
+		return &fakeRows{}, nil
+	}
+	return &fakeRows{values: [][]driver.Value{row[:]}}, nil
+}
+
+type fakeRows struct {
+	values [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return []string{"version", "snapshot"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// Connect makes the driver its own connector, so that every test has its own database
+func (d *fakeDriver) Connect(context.Context) (driver.Conn, error) {
+	return d.Open("")
+}
+
+func (d *fakeDriver) Driver() driver.Driver {
+	return d
+}
+
+// openFake opens a new empty database
+func openFake() (*sql.DB, *fakeDriver) {
+	fake := &fakeDriver{rows: map[string][2]driver.Value{}}
+	return sql.OpenDB(fake), fake
+}
+
+func TestStore(t *testing.T) {
+	db, fake := openFake()
+	defer db.Close()
+	ctx := context.Background()
+	store := fsmsql.New(db, fsmsql.WithTable("orders"), fsmsql.WithDollarPlaceholders())
+
+	_, _, err := store.Load(ctx, "order-1")
+	require.ErrorIs(t, err, fsm.ErrInstanceNotFound)
+
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition("tick", b)
+
+	smi, err := sm.Create(ctx, store, "order-1", a)
+	require.NoError(t, err)
+	require.NoError(t, smi.Fire("tick"))
+
+	snap, version, err := store.Load(ctx, "order-1")
+	require.NoError(t, err)
+	require.Equal(t, "B", snap.State)
+	require.Equal(t, "order-1", snap.ID)
+	require.EqualValues(t, 2, version)
+
+	require.ErrorIs(t, store.Save(ctx, "order-1", snap, 1), fsm.ErrVersionConflict)
+	require.ErrorIs(t, store.Save(ctx, "order-1", snap, 0), fsm.ErrVersionConflict)
+
+	require.Contains(t, fake.queries, "UPDATE orders SET version = $1, snapshot = $2 WHERE id = $3 AND version = $4")
+}
+
+func TestTransaction(t *testing.T) {
+	db, fake := openFake()
+	defer db.Close()
+	ctx := context.Background()
+	store := fsmsql.New(db)
+
+	fail := false
+	sm := fsm.New(fsm.WithTransaction(fsmsql.Transaction(db)))
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.OnEnter(func(c *fsm.Context) error {
+		if _, ok := c.Tx().(*sql.Tx); !ok {
+			return errors.New("no transaction")
+		}
+		if fail {
+			return errors.New("boom")
+		}
+		return nil
+	}))
+	a.AddTransition("tick", b)
+	b.AddTransition("tock", a)
+
+	smi, err := sm.Create(ctx, store, "tx-1", a)
+	require.NoError(t, err)
+
+	fake.mu.Lock()
+	fake.queries = nil
+	fake.mu.Unlock()
+	require.NoError(t, smi.Fire("tick"))
+	require.Equal(t, []string{"BEGIN", "UPDATE fsm_instances SET version = ?, snapshot = ? WHERE id = ? AND version = ?", "COMMIT"}, fake.queries)
+
+	require.NoError(t, smi.Fire("tock"))
+	fail = true
+	require.Error(t, smi.Fire("tick"))
+	require.Equal(t, "ROLLBACK", fake.queries[len(fake.queries)-1])
+	snap, version, err := store.Load(ctx, "tx-1")
+	require.NoError(t, err)
+	require.Equal(t, "A", snap.State)
+	require.EqualValues(t, 3, version)
+}
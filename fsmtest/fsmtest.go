@@ -0,0 +1,243 @@
+// Package fsmtest provides helpers to test state machines:
+// recording the transitions, driving instances with sequences of events,
+// asserting reachability and checking that every declared transition was covered.
+package fsmtest
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/quintans/fsm"
+)
+
+// Step is a recorded transition
+type Step struct {
+	From  string
+	To    string
+	Event interface{}
+}
+
+// Recorder records the transitions of a machine or of an instance
+type Recorder struct {
+	mu    sync.Mutex
+	steps []Step
+}
+
+// Record records the transitions of the instance
+func Record(smi *fsm.StateMachineInstance) *Recorder {
+	r := &Recorder{}
+	smi.AddOnTransition(r.record)
+	return r
+}
+
+// RecordMachine records the transitions of all the instances of the machine.
+// The machine must not be frozen.
+func RecordMachine(sm *fsm.StateMachine) *Recorder {
+	r := &Recorder{}
+	sm.AddOnTransition(r.record)
+	return r
+}
+
+func (r *Recorder) record(c *fsm.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, Step{
+		From:  c.FromState().Name(),
+		To:    c.ToState().Name(),
+		Event: c.Key(),
+	})
+	return nil
+}
+
+// Steps returns the recorded transitions, in order
+func (r *Recorder) Steps() []Step {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Step(nil), r.steps...)
+}
+
+// Path returns the names of the visited states, starting with the source of the first transition
+func (r *Recorder) Path() []string {
+	steps := r.Steps()
+	if len(steps) == 0 {
+		return nil
+	}
+	path := []string{steps[0].From}
+	for _, s := range steps {
+		path = append(path, s.To)
+	}
+	return path
+}
+
+// Enters returns the number of times the state was entered from another state
+func (r *Recorder) Enters(state string) int {
+	cnt := 0
+	for _, s := range r.Steps() {
+		if s.To == state && s.From != state {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// Exits returns the number of times the state was left to another state
+func (r *Recorder) Exits(state string) int {
+	cnt := 0
+	for _, s := range r.Steps() {
+		if s.From == state && s.To != state {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+// Reset discards the recorded transitions
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = nil
+}
+
+// Fire fires the events in order, failing the test at the first error
+func Fire(t testing.TB, smi *fsm.StateMachineInstance, events ...interface{}) {
+	t.Helper()
+	for i, e := range events {
+		if err := smi.Fire(e); err != nil {
+			t.Fatalf("firing event #%d %+v on state %s: %v", i, e, smi.State(), err)
+		}
+	}
+}
+
+// RequireState fails the test if the instance is not in the named state
+func RequireState(t testing.TB, smi *fsm.StateMachineInstance, state string) {
+	t.Helper()
+	if cur := smi.State().Name(); cur != state {
+		t.Fatalf("expected state %s, got %s", state, cur)
+	}
+}
+
+// RequireSequence fires the events in order and fails the test if the instance does not end in the named state
+func RequireSequence(t testing.TB, smi *fsm.StateMachineInstance, state string, events ...interface{}) {
+	t.Helper()
+	Fire(t, smi, events...)
+	RequireState(t, smi, state)
+}
+
+// RequireReachable fails the test if there is no path of declared transitions between the named states.
+// Conditions are not evaluated.
+func RequireReachable(t testing.TB, sm *fsm.StateMachine, from, to string) {
+	t.Helper()
+	if !reachable(t, sm, from, to) {
+		t.Fatalf("state %s is not reachable from %s", to, from)
+	}
+}
+
+// RequireUnreachable fails the test if there is a path of declared transitions between the named states
+func RequireUnreachable(t testing.TB, sm *fsm.StateMachine, from, to string) {
+	t.Helper()
+	if reachable(t, sm, from, to) {
+		t.Fatalf("state %s is reachable from %s", to, from)
+	}
+}
+
+func reachable(t testing.TB, sm *fsm.StateMachine, from, to string) bool {
+	t.Helper()
+	target := mustState(t, sm, to)
+	for _, st := range sm.ReachableFrom(mustState(t, sm, from)) {
+		if within(st, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustState(t testing.TB, sm *fsm.StateMachine, name string) *fsm.State {
+	t.Helper()
+	st := sm.StateByName(name)
+	if st == nil {
+		t.Fatalf("unknown state %s", name)
+	}
+	return st
+}
+
+// Edge is a declared transition
+type Edge struct {
+	From       string
+	To         string
+	Transition string
+	Kind       fsm.TransitionKind
+}
+
+func (e Edge) String() string {
+	return fmt.Sprintf("%s -> %s [%s %s]", e.From, e.To, e.Kind, e.Transition)
+}
+
+// Uncovered returns the declared transitions that were not taken in any of the recordings.
+// A recorded transition covers the first declared transition of the source state, or of its ancestors,
+// with the same target and, for event transitions, the same event key.
+func Uncovered(sm *fsm.StateMachine, recorders ...*Recorder) []Edge {
+	covered := map[Edge]bool{}
+	for _, r := range recorders {
+		for _, s := range r.Steps() {
+			if e, ok := coveredEdge(sm, s); ok {
+				covered[e] = true
+			}
+		}
+	}
+
+	var uncovered []Edge
+	for _, st := range sm.States() {
+		for _, tr := range st.Transitions() {
+			e := edge(st, tr)
+			if !covered[e] {
+				uncovered = append(uncovered, e)
+			}
+		}
+	}
+	return uncovered
+}
+
+// RequireCovered fails the test if some declared transition was not taken in any of the recordings
+func RequireCovered(t testing.TB, sm *fsm.StateMachine, recorders ...*Recorder) {
+	t.Helper()
+	uncovered := Uncovered(sm, recorders...)
+	if len(uncovered) > 0 {
+		t.Fatalf("%d transitions not covered: %v", len(uncovered), uncovered)
+	}
+}
+
+func edge(st *fsm.State, tr fsm.TransitionInfo) Edge {
+	return Edge{
+		From:       st.Name(),
+		To:         tr.To.Name(),
+		Transition: tr.Name,
+		Kind:       tr.Kind,
+	}
+}
+
+func coveredEdge(sm *fsm.StateMachine, s Step) (Edge, bool) {
+	to := sm.StateByName(s.To)
+	for st := sm.StateByName(s.From); st != nil; st = st.Parent() {
+		for _, tr := range st.Transitions() {
+			if !within(to, tr.To) {
+				continue
+			}
+			if (tr.Kind == fsm.EventTransition || tr.Kind == fsm.InternalTransition) && tr.Key != s.Event {
+				continue
+			}
+			return edge(st, tr), true
+		}
+	}
+	return Edge{}, false
+}
+
+// within checks if the state is the target or one of its sub-states
+func within(st, target *fsm.State) bool {
+	for ; st != nil; st = st.Parent() {
+		if st == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package fsmtest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/fsmtest"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeT captures the failures instead of failing the test
+type fakeT struct {
+	testing.TB
+	failure string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Fatalf(format string, args ...interface{}) {
+	f.failure = fmt.Sprintf(format, args...)
+}
+
+func doorFSM() (*fsm.StateMachine, *fsm.State) {
+	sm := fsm.New()
+	closed := sm.AddState("CLOSED")
+	open := sm.AddState("OPEN")
+	locked := sm.AddState("LOCKED")
+	broken := sm.AddState("BROKEN")
+	closed.AddTransition("open", open)
+	closed.AddTransition("lock", locked)
+	open.AddTransition("close", closed)
+	locked.AddTransition("unlock", closed)
+	broken.AddTransition("fix", closed)
+	return sm, closed
+}
+
+func TestRecorder(t *testing.T) {
+	sm, closed := doorFSM()
+	smi := sm.FromState(closed)
+	rec := fsmtest.Record(smi)
+
+	fsmtest.RequireSequence(t, smi, "CLOSED", "open", "close", "lock", "unlock")
+	require.Equal(t, []string{"CLOSED", "OPEN", "CLOSED", "LOCKED", "CLOSED"}, rec.Path())
+	require.Equal(t, fsmtest.Step{From: "CLOSED", To: "OPEN", Event: "open"}, rec.Steps()[0])
+	require.Equal(t, 2, rec.Enters("CLOSED"))
+	require.Equal(t, 2, rec.Exits("CLOSED"))
+
+	rec.Reset()
+	require.Empty(t, rec.Steps())
+}
+
+func TestRequireFailures(t *testing.T) {
+	sm, closed := doorFSM()
+	smi := sm.FromState(closed)
+
+	ft := &fakeT{}
+	fsmtest.Fire(ft, smi, "open", "lock")
+	require.Contains(t, ft.failure, "firing event #1 lock on state OPEN")
+
+	ft = &fakeT{}
+	fsmtest.RequireState(ft, smi, "CLOSED")
+	require.Equal(t, "expected state CLOSED, got OPEN", ft.failure)
+}
+
+func TestReachable(t *testing.T) {
+	sm, _ := doorFSM()
+	fsmtest.RequireReachable(t, sm, "OPEN", "LOCKED")
+	fsmtest.RequireUnreachable(t, sm, "OPEN", "BROKEN")
+
+	ft := &fakeT{}
+	fsmtest.RequireReachable(ft, sm, "CLOSED", "BROKEN")
+	require.Equal(t, "state BROKEN is not reachable from CLOSED", ft.failure)
+}
+
+func TestCoverage(t *testing.T) {
+	sm, closed := doorFSM()
+	rec := fsmtest.RecordMachine(sm)
+	smi := sm.FromState(closed)
+	fsmtest.Fire(t, smi, "open", "close", "lock")
+
+	require.Equal(t, []fsmtest.Edge{
+		{From: "LOCKED", To: "CLOSED", Transition: "unlock", Kind: fsm.EventTransition},
+		{From: "BROKEN", To: "CLOSED", Transition: "fix", Kind: fsm.EventTransition},
+	}, fsmtest.Uncovered(sm, rec))
+
+	ft := &fakeT{}
+	fsmtest.RequireCovered(ft, sm, rec)
+	require.Equal(t, "2 transitions not covered: [LOCKED -> CLOSED [event unlock] BROKEN -> CLOSED [event fix]]", ft.failure)
+
+	fsmtest.Fire(t, smi, "unlock")
+	fsmtest.Fire(t, sm.FromState(sm.StateByName("BROKEN")), "fix")
+	fsmtest.RequireCovered(t, sm, rec)
+}
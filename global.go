@@ -0,0 +1,31 @@
+package fsm
+
+// globalStateName is the name of the holder of the global transitions
+const globalStateName = "*"
+
+// AddGlobalTransition adds a transition, for the event, that applies from any state.
+// A state, or an ancestor, with its own transition for the event overrides it. Final states ignore it.
+func (s *StateMachine) AddGlobalTransition(eventKey interface{}, to *State, opts ...TransitionOption) *StateMachine {
+	s.mustBeMutable()
+	s.globalState().AddTransition(eventKey, to, opts...)
+	return s
+}
+
+// globalState returns the pseudo-state holding the global transitions, creating it if needed
+func (s *StateMachine) globalState() *State {
+	if s.global == nil {
+		s.global = &State{
+			name:    globalStateName,
+			machine: s,
+		}
+	}
+	return s.global
+}
+
+// globalTransitions returns the global transitions
+func (s *StateMachine) globalTransitions() []*transition {
+	if s.global == nil {
+		return nil
+	}
+	return s.global.transitions
+}
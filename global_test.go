@@ -0,0 +1,73 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+const CANCEL = "cancel"
+
+func TestGlobalTransition(t *testing.T) {
+	sm := fsm.New()
+	pending := sm.AddState("PENDING")
+	paid := sm.AddState("PAID")
+	shipped := sm.AddState("SHIPPED")
+	refunded := sm.AddState("REFUNDED")
+	delivered := sm.AddState("DELIVERED", fsm.Final())
+	aborted := sm.AddState("ABORTED")
+
+	pending.AddTransition(TICK, paid)
+	paid.AddTransition(TICK, shipped)
+	shipped.AddTransition(TICK, delivered)
+	// per state override
+	paid.AddTransition(CANCEL, refunded)
+	sm.AddGlobalTransition(CANCEL, aborted)
+
+	smi := sm.FromState(pending)
+	require.NoError(t, smi.Fire(CANCEL))
+	require.Equal(t, aborted, smi.State())
+
+	smi = sm.FromState(paid)
+	require.NoError(t, smi.Fire(CANCEL))
+	require.Equal(t, refunded, smi.State())
+
+	smi = sm.FromState(shipped)
+	require.Equal(t, []string{TICK, CANCEL}, permittedKeys(smi.PermittedEvents()))
+	require.NoError(t, smi.Fire(CANCEL))
+	require.Equal(t, aborted, smi.State())
+
+	// final states do not accept events
+	_, err := sm.Fire(delivered, CANCEL)
+	require.ErrorIs(t, err, fsm.ErrMachineCompleted)
+	require.Empty(t, delivered.PermittedEvents())
+}
+
+func TestGlobalTransitionDefinition(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	aborted := sm.AddState("ABORTED")
+	a.AddTransition(TICK, b)
+	sm.AddGlobalTransition(CANCEL, aborted)
+
+	data, err := sm.MarshalDefinition()
+	require.NoError(t, err)
+	loaded, err := fsm.LoadDefinition(data, fsm.HandlerRegistry{})
+	require.NoError(t, err)
+	require.Equal(t, sm.Fingerprint(), loaded.Fingerprint())
+	require.NotEqual(t, fsm.New().Fingerprint(), sm.Fingerprint())
+
+	next, err := loaded.Fire(loaded.StateByName("B"), CANCEL)
+	require.NoError(t, err)
+	require.Equal(t, "ABORTED", next.Name())
+}
+
+func permittedKeys(events []fsm.PermittedEvent) []string {
+	var keys []string
+	for _, e := range events {
+		keys = append(keys, e.Key.(string))
+	}
+	return keys
+}
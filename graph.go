@@ -0,0 +1,186 @@
+package fsm
+
+// TransitionKind is the kind of a transition
+type TransitionKind int
+
+const (
+	// EventTransition occurs when the event key matches
+	EventTransition TransitionKind = iota + 1
+	// ConditionalTransition occurs when its condition returns true
+	ConditionalTransition
+	// FallbackTransition occurs when no other transition matches
+	FallbackTransition
+	// TimeoutTransition is fired by the instance scheduler after some time in the state
+	TimeoutTransition
+	// InternalTransition executes an action without leaving the state
+	InternalTransition
+)
+
+func (k TransitionKind) String() string {
+	switch k {
+	case EventTransition:
+		return "event"
+	case ConditionalTransition:
+		return "conditional"
+	case FallbackTransition:
+		return "fallback"
+	case TimeoutTransition:
+		return "timeout"
+	case InternalTransition:
+		return "internal"
+	}
+	return "unknown"
+}
+
+// TransitionInfo describes a transition of a state
+type TransitionInfo struct {
+	Name string
+	Kind TransitionKind
+	// Key is the event key of event and internal transitions, and the Timeout of timeout transitions
+	Key interface{}
+	// To is the target state, the state itself for internal transitions
+	To *State
+	// Meta is a copy of the metadata of the transition
+	Meta map[string]string
+}
+
+// Transitions returns the transitions declared on the state, in declaration order.
+// The transitions inherited from ancestors and the global transitions are not included.
+func (s *State) Transitions() []TransitionInfo {
+	infos := make([]TransitionInfo, 0, len(s.transitions))
+	for _, t := range s.transitions {
+		infos = append(infos, t.info())
+	}
+	return infos
+}
+
+// AllTransitions returns the transitions that apply on the state: its own, followed by the ones inherited
+// from its ancestors and, unless the state is final, the global transitions.
+func (s *State) AllTransitions() []TransitionInfo {
+	var infos []TransitionInfo
+	for st := s; st != nil; st = st.parent {
+		infos = append(infos, st.Transitions()...)
+	}
+	if !s.final {
+		infos = append(infos, s.machine.GlobalTransitions()...)
+	}
+	return infos
+}
+
+// GlobalTransitions returns the transitions added with AddGlobalTransition, in declaration order
+func (s *StateMachine) GlobalTransitions() []TransitionInfo {
+	if s.global == nil {
+		return nil
+	}
+	return s.global.Transitions()
+}
+
+// Initial returns the state entered when a transition targets this state:
+// the initial leaf sub-state of a composite, or the initial leaf of the composite of a history pseudo-state,
+// since the history of an instance is not known, or else the state itself.
+func (s *State) Initial() *State {
+	return s.machine.resolveTarget(s, &Context{machine: s.machine})
+}
+
+func (t *transition) info() TransitionInfo {
+	info := TransitionInfo{
+		Name: t.name,
+		Key:  t.key,
+		To:   t.state,
+		Meta: copyMeta(t.meta),
+	}
+	switch {
+	case t.action != nil:
+		info.Kind = InternalTransition
+	case t.timeout > 0:
+		info.Kind = TimeoutTransition
+		info.Key = Timeout{After: t.timeout}
+	case t.fallback:
+		info.Kind = FallbackTransition
+	case t.key != nil:
+		info.Kind = EventTransition
+	default:
+		info.Kind = ConditionalTransition
+	}
+	return info
+}
+
+// successors returns the states entered by the transitions available on the state,
+// including the inherited and the global ones, with composite and history targets resolved to their initial leaf
+func (s *StateMachine) successors(state *State) []*State {
+	var next []*State
+	seen := map[*State]bool{}
+	add := func(transitions []*transition) {
+		for _, t := range transitions {
+			if t.action != nil {
+				continue
+			}
+			to := s.resolveTarget(t.state, &Context{machine: s})
+			if !seen[to] {
+				seen[to] = true
+				next = append(next, to)
+			}
+		}
+	}
+	for st := state; st != nil; st = st.parent {
+		add(st.transitions)
+	}
+	if !state.final {
+		add(s.globalTransitions())
+	}
+	return next
+}
+
+// ReachableFrom returns the states that can be reached from the state by following the declared transitions, in breadth first order.
+// The state itself is only included if it can be reached again. Conditions are not evaluated.
+func (s *StateMachine) ReachableFrom(state *State) []*State {
+	var reached []*State
+	visited := map[*State]bool{}
+	queue := []*State{state}
+	for len(queue) > 0 {
+		st := queue[0]
+		queue = queue[1:]
+		for _, next := range s.successors(st) {
+			if !visited[next] {
+				visited[next] = true
+				reached = append(reached, next)
+				queue = append(queue, next)
+			}
+		}
+	}
+	return reached
+}
+
+// PathsBetween returns the paths of declared transitions going from one state to the other, or to any of its sub-states.
+// Each path starts with the source and does not visit a state twice. Conditions are not evaluated.
+func (s *StateMachine) PathsBetween(from, to *State) [][]*State {
+	var paths [][]*State
+	onPath := map[*State]bool{from: true}
+	var walk func(path []*State)
+	walk = func(path []*State) {
+		for _, next := range s.successors(path[len(path)-1]) {
+			if next.isWithin(to) {
+				paths = append(paths, append(append([]*State(nil), path...), next))
+				continue
+			}
+			if onPath[next] {
+				continue
+			}
+			onPath[next] = true
+			walk(append(path, next))
+			onPath[next] = false
+		}
+	}
+	walk([]*State{from})
+	return paths
+}
+
+// isWithin checks if the state is the other one or one of its sub-states
+func (s *State) isWithin(other *State) bool {
+	for st := s; st != nil; st = st.parent {
+		if st == other {
+			return true
+		}
+	}
+	return false
+}
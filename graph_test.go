@@ -0,0 +1,58 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitions(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	noop := func(c *fsm.Context) error { return nil }
+	a.AddTransition(TICK, b)
+	a.AddConditionalTransition("ready", b, func(c *fsm.Context) bool { return true })
+	a.AddTimeoutTransition(time.Second, b)
+	a.AddInternalTransition(LOOP, noop)
+	a.AddFallbackTransition(b)
+
+	require.Equal(t, []fsm.TransitionInfo{
+		{Name: "TICK", Kind: fsm.EventTransition, Key: TICK, To: b},
+		{Name: "ready", Kind: fsm.ConditionalTransition, To: b},
+		{Name: "timeout(1s)", Kind: fsm.TimeoutTransition, Key: fsm.Timeout{After: time.Second}, To: b},
+		{Name: "LOOP", Kind: fsm.InternalTransition, Key: LOOP, To: a},
+		{Name: "fallback", Kind: fsm.FallbackTransition, To: b},
+	}, a.Transitions())
+	require.Empty(t, b.Transitions())
+}
+
+func TestReachability(t *testing.T) {
+	sm := fsm.New()
+	draft := sm.AddState("DRAFT")
+	review := sm.AddState("REVIEW")
+	published := sm.AddState("PUBLISHED", fsm.Final())
+	archived := sm.AddState("ARCHIVED", fsm.Final())
+	orphan := sm.AddState("ORPHAN")
+	draft.AddTransition("submit", review)
+	draft.AddInternalTransition("edit", func(c *fsm.Context) error { return nil })
+	review.AddTransition("reject", draft)
+	review.AddTransition("approve", published)
+	draft.AddTransition("publish", published)
+	sm.AddGlobalTransition("archive", archived)
+
+	require.Equal(t, []*fsm.State{review, published, archived, draft}, sm.ReachableFrom(draft))
+	require.Equal(t, []*fsm.State{archived}, sm.ReachableFrom(orphan))
+	require.Empty(t, sm.ReachableFrom(published))
+
+	require.Equal(t, [][]*fsm.State{
+		{draft, review, published},
+		{draft, published},
+	}, sm.PathsBetween(draft, published))
+	require.Equal(t, [][]*fsm.State{
+		{review, draft},
+	}, sm.PathsBetween(review, draft))
+	require.Empty(t, sm.PathsBetween(draft, orphan))
+}
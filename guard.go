@@ -0,0 +1,479 @@
+package fsm
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// WithGuard option adds a guard to the transition: the transition is only taken if the guard also holds.
+// A guarded transition is not a duplicate of the other transitions for the same event,
+// which are usually given a lower priority to be taken when the guard does not hold.
+func WithGuard(guard func(*Context) bool) TransitionOption {
+	return func(t *transition) {
+		t.guarded = true
+		condition := t.condition
+		t.condition = func(c *Context) bool {
+			return condition(c) && guard(c)
+		}
+	}
+}
+
+// ParseGuard compiles a guard written as an expression over the event data, the instance payload and the event key,
+// so that guards can be carried by definitions, without compiled code. For example:
+//
+//	event.amount > 100 && payload.vip
+//
+// The roots are event, the fired value, unwrapped from Event, payload, the payload of the instance, and key, the event key.
+// Fields of structs are found by name, ignoring the case, or by json tag, and fields of maps by key.
+// A field of nil is nil. The expressions support the literals true, false, nil, numbers and quoted strings,
+// the operators ||, &&, !, ==, !=, <, <=, >, >=, +, -, *, / and %, and parentheses.
+// All numbers are compared as float64. The guard does not hold if the evaluation fails,
+// like when comparing a string with a number, which is logged at debug level.
+func ParseGuard(expr string) (func(*Context) bool, error) {
+	p := &guardParser{src: expr}
+	if err := p.tokenize(); err != nil {
+		return nil, fmt.Errorf("invalid guard %q: %w", expr, err)
+	}
+	eval, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid guard %q: %w", expr, err)
+	}
+	return func(c *Context) bool {
+		v, err := eval(c)
+		if err == nil {
+			b, ok := v.(bool)
+			if ok {
+				return b
+			}
+			err = fmt.Errorf("%v is not a boolean", v)
+		}
+		c.machine.debug("fsm: guard failed", "guard", expr, "error", err)
+		return false
+	}, nil
+}
+
+// guardEval evaluates a node of a guard expression
+type guardEval func(*Context) (interface{}, error)
+
+type guardToken struct {
+	kind  byte // 'i' identifier, 'n' number, 's' string, 'o' operator
+	text  string
+	value interface{}
+	pos   int
+}
+
+type guardParser struct {
+	src    string
+	tokens []guardToken
+	next   int
+}
+
+var guardOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "+", "-", "*", "/", "%", "(", ")", "."}
+
+func (p *guardParser) tokenize() error {
+	src := p.src
+	for i := 0; i < len(src); {
+		r := rune(src[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '_' || unicode.IsLetter(r):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			p.tokens = append(p.tokens, guardToken{kind: 'i', text: src[i:j], pos: i})
+			i = j
+		case unicode.IsDigit(r):
+			j := i + 1
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			f, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return fmt.Errorf("invalid number %q at %d", src[i:j], i)
+			}
+			p.tokens = append(p.tokens, guardToken{kind: 'n', text: src[i:j], value: f, pos: i})
+			i = j
+		case r == '"' || r == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != src[i] {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return fmt.Errorf("unterminated string at %d", i)
+			}
+			s := src[i+1 : j]
+			if r == '"' {
+				var err error
+				if s, err = strconv.Unquote(src[i : j+1]); err != nil {
+					return fmt.Errorf("invalid string at %d", i)
+				}
+			}
+			p.tokens = append(p.tokens, guardToken{kind: 's', text: src[i : j+1], value: s, pos: i})
+			i = j + 1
+		default:
+			op := ""
+			for _, o := range guardOperators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("unexpected character %q at %d", r, i)
+			}
+			p.tokens = append(p.tokens, guardToken{kind: 'o', text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return nil
+}
+
+func (p *guardParser) parse() (guardEval, error) {
+	eval, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.next < len(p.tokens) {
+		return nil, p.unexpected()
+	}
+	return eval, nil
+}
+
+func (p *guardParser) unexpected() error {
+	if p.next >= len(p.tokens) {
+		return fmt.Errorf("unexpected end")
+	}
+	t := p.tokens[p.next]
+	return fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+// accept consumes the next token if it is one of the operators
+func (p *guardParser) accept(ops ...string) (string, bool) {
+	if p.next >= len(p.tokens) || p.tokens[p.next].kind != 'o' {
+		return "", false
+	}
+	for _, o := range ops {
+		if p.tokens[p.next].text == o {
+			p.next++
+			return o, true
+		}
+	}
+	return "", false
+}
+
+// guardPrecedence lists the binary operators from the lowest to the highest precedence
+var guardPrecedence = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *guardParser) binary(level int) (guardEval, error) {
+	if level == len(guardPrecedence) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(guardPrecedence[level]...)
+		if !ok {
+			return left, nil
+		}
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = guardOperation(op, left, right)
+	}
+}
+
+func (p *guardParser) unary() (guardEval, error) {
+	if op, ok := p.accept("!", "-"); ok {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(c *Context) (interface{}, error) {
+			v, err := operand(c)
+			if err != nil {
+				return nil, err
+			}
+			if op == "!" {
+				b, ok := v.(bool)
+				if !ok {
+					return nil, fmt.Errorf("%v is not a boolean", v)
+				}
+				return !b, nil
+			}
+			f, ok := guardNumber(v)
+			if !ok {
+				return nil, fmt.Errorf("%v is not a number", v)
+			}
+			return -f, nil
+		}, nil
+	}
+	return p.primary()
+}
+
+func (p *guardParser) primary() (guardEval, error) {
+	if p.next >= len(p.tokens) {
+		return nil, p.unexpected()
+	}
+	t := p.tokens[p.next]
+	switch {
+	case t.kind == 'n' || t.kind == 's':
+		p.next++
+		v := t.value
+		return func(*Context) (interface{}, error) { return v, nil }, nil
+	case t.kind == 'o' && t.text == "(":
+		p.next++
+		eval, err := p.binary(0)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, p.unexpected()
+		}
+		return eval, nil
+	case t.kind == 'i':
+		p.next++
+		return p.path(t)
+	}
+	return nil, p.unexpected()
+}
+
+// path parses a literal keyword or a root followed by field selectors
+func (p *guardParser) path(root guardToken) (guardEval, error) {
+	var eval guardEval
+	switch root.text {
+	case "true", "false":
+		v := root.text == "true"
+		return func(*Context) (interface{}, error) { return v, nil }, nil
+	case "nil", "null":
+		return func(*Context) (interface{}, error) { return nil, nil }, nil
+	case "event":
+		eval = func(c *Context) (interface{}, error) { return c.payload(), nil }
+	case "payload":
+		eval = func(c *Context) (interface{}, error) { return c.Payload(), nil }
+	case "key":
+		eval = func(c *Context) (interface{}, error) { return c.Key(), nil }
+	default:
+		return nil, fmt.Errorf("unknown identifier %q at %d, expected event, payload or key", root.text, root.pos)
+	}
+	for {
+		if _, ok := p.accept("."); !ok {
+			return eval, nil
+		}
+		if p.next >= len(p.tokens) || p.tokens[p.next].kind != 'i' {
+			return nil, p.unexpected()
+		}
+		name := p.tokens[p.next].text
+		p.next++
+		parent := eval
+		eval = func(c *Context) (interface{}, error) {
+			v, err := parent(c)
+			if err != nil {
+				return nil, err
+			}
+			return guardField(v, name)
+		}
+	}
+}
+
+// guardField returns the field of a struct, by name or json tag, or the entry of a map
+func guardField(v interface{}, name string) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			break
+		}
+		e := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+		if !e.IsValid() {
+			return nil, nil
+		}
+		return e.Interface(), nil
+	case reflect.Struct:
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			f := rt.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			tag := strings.Split(f.Tag.Get("json"), ",")[0]
+			if strings.EqualFold(f.Name, name) || tag == name {
+				return rv.Field(i).Interface(), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%T has no field %s", v, name)
+}
+
+// guardNumber converts any number to float64
+func guardNumber(v interface{}) (float64, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// guardString converts any string, including named string types, to string
+func guardString(v interface{}) (string, bool) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.String {
+		return rv.String(), true
+	}
+	return "", false
+}
+
+func guardOperation(op string, left, right guardEval) guardEval {
+	switch op {
+	case "&&", "||":
+		return func(c *Context) (interface{}, error) {
+			l, err := guardBool(left, c)
+			if err != nil {
+				return nil, err
+			}
+			// short circuit
+			if l == (op == "||") {
+				return l, nil
+			}
+			return guardBool(right, c)
+		}
+	}
+	return func(c *Context) (interface{}, error) {
+		l, err := left(c)
+		if err != nil {
+			return nil, err
+		}
+		r, err := right(c)
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "==":
+			return guardEqual(l, r), nil
+		case "!=":
+			return !guardEqual(l, r), nil
+		}
+		if lf, ok := guardNumber(l); ok {
+			if rf, ok := guardNumber(r); ok {
+				return guardArithmetic(op, lf, rf)
+			}
+		}
+		if ls, ok := guardString(l); ok {
+			if rs, ok := guardString(r); ok {
+				switch op {
+				case "<":
+					return ls < rs, nil
+				case "<=":
+					return ls <= rs, nil
+				case ">":
+					return ls > rs, nil
+				case ">=":
+					return ls >= rs, nil
+				case "+":
+					return ls + rs, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("invalid operation %v %s %v", l, op, r)
+	}
+}
+
+func guardArithmetic(op string, l, r float64) (interface{}, error) {
+	switch op {
+	case "<":
+		return l < r, nil
+	case "<=":
+		return l <= r, nil
+	case ">":
+		return l > r, nil
+	case ">=":
+		return l >= r, nil
+	case "+":
+		return l + r, nil
+	case "-":
+		return l - r, nil
+	case "*":
+		return l * r, nil
+	}
+	if r == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+	if op == "%" {
+		return math.Mod(l, r), nil
+	}
+	return l / r, nil
+}
+
+func guardBool(eval guardEval, c *Context) (bool, error) {
+	v, err := eval(c)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("%v is not a boolean", v)
+	}
+	return b, nil
+}
+
+func guardEqual(l, r interface{}) bool {
+	if lf, ok := guardNumber(l); ok {
+		rf, ok := guardNumber(r)
+		return ok && lf == rf
+	}
+	if ls, ok := guardString(l); ok {
+		rs, ok := guardString(r)
+		return ok && ls == rs
+	}
+	if l == nil || r == nil {
+		return guardIsNil(l) && guardIsNil(r)
+	}
+	lv, rv := reflect.ValueOf(l), reflect.ValueOf(r)
+	if lv.Type() != rv.Type() || !lv.Type().Comparable() {
+		return false
+	}
+	return l == r
+}
+
+func guardIsNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func, reflect.Chan:
+		return rv.IsNil()
+	}
+	return false
+}
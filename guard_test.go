@@ -0,0 +1,135 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+type purchase struct {
+	Amount   int    `json:"amount"`
+	Currency string `json:"currency"`
+	Buyer    *buyer
+}
+
+func (purchase) Kind() interface{} {
+	return "buy"
+}
+
+type buyer struct {
+	VIP bool `json:"vip"`
+}
+
+func TestParseGuard(t *testing.T) {
+	sm := fsm.New()
+	b := sm.AddState("B")
+
+	eval := func(expr string, event interface{}, payload interface{}) bool {
+		guard, err := fsm.ParseGuard(expr)
+		require.NoError(t, err, expr)
+		var result bool
+		st := sm.AddState("T" + expr)
+		st.AddConditionalTransition("check", b, func(c *fsm.Context) bool {
+			result = guard(c)
+			return true
+		})
+		smi := sm.FromState(st)
+		smi.SetPayload(payload)
+		require.NoError(t, smi.Fire(event))
+		return result
+	}
+
+	vip := map[string]interface{}{"vip": true, "tier": "gold"}
+	p := purchase{Amount: 150, Currency: "EUR", Buyer: &buyer{VIP: true}}
+	require.True(t, eval("event.amount > 100 && payload.vip", p, vip))
+	require.False(t, eval("event.amount > 200 || !payload.vip", p, vip))
+	require.True(t, eval("event.Amount * 2 - 100 == 200", p, nil))
+	require.True(t, eval("event.amount % 7 == 3 && event.amount / 3 == 50", p, nil))
+	require.True(t, eval(`event.currency == "EUR" && payload.tier != 'silver'`, p, vip))
+	require.True(t, eval("event.buyer.vip", p, nil))
+	require.True(t, eval("key == 'buy' && (1 + 2) * 3 == 9 && -1 < 0", p, nil))
+	require.True(t, eval("payload.missing == nil && payload == null", p, nil))
+	require.True(t, eval("event > 10", 42, nil))
+	require.True(t, eval("event.amount % 0.5 == 0 && 7.5 % 2 == 1.5", p, nil))
+	require.True(t, eval(`event.currency + "/" + event.currency >= "EUR/EUR"`, p, nil))
+
+	// evaluation errors do not hold
+	require.False(t, eval("event.currency > 10", p, nil))
+	require.False(t, eval("event.unknown", p, nil))
+	require.False(t, eval("event.amount", p, nil))
+	require.False(t, eval("event.amount / 0 > 1", p, nil))
+	require.False(t, eval("event.amount % 0 == 0", p, nil))
+	require.False(t, eval("payload.vip && 1", p, vip))
+
+	// short circuit
+	require.False(t, eval("false && event.unknown", p, nil))
+	require.True(t, eval("true || event.unknown", p, nil))
+}
+
+func TestParseGuardErrors(t *testing.T) {
+	for expr, msg := range map[string]string{
+		"event.amount >":   `invalid guard "event.amount >": unexpected end`,
+		"amount > 1":       `invalid guard "amount > 1": unknown identifier "amount" at 0, expected event, payload or key`,
+		"(event":           `invalid guard "(event": unexpected end`,
+		"event.amount # 1": `invalid guard "event.amount # 1": unexpected character '#' at 13`,
+		`event == "open`:   `invalid guard "event == \"open": unterminated string at 9`,
+		"1.2.3 > event":    `invalid guard "1.2.3 > event": invalid number "1.2.3" at 0`,
+		"event event":      `invalid guard "event event": unexpected "event" at 6`,
+	} {
+		_, err := fsm.ParseGuard(expr)
+		require.EqualError(t, err, msg)
+	}
+}
+
+func TestWithGuard(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	big := sm.AddState("BIG")
+	small := sm.AddState("SMALL")
+	isBig, err := fsm.ParseGuard("event.amount >= 100")
+	require.NoError(t, err)
+	a.AddTransition("buy", big, fsm.WithGuard(isBig), fsm.WithPriority(1))
+	a.AddTransition("buy", small)
+
+	smi := sm.FromState(a)
+	require.NoError(t, smi.Fire(purchase{Amount: 10}))
+	require.Equal(t, small, smi.State())
+	smi = sm.FromState(a)
+	require.NoError(t, smi.Fire(purchase{Amount: 100}))
+	require.Equal(t, big, smi.State())
+}
+
+const guardDefinitionJSON = `{"states":[` +
+	`{"name":"NEW","transitions":[{"to":"REVIEW","event":"buy","guard":"event.amount > 100 && !payload.vip","priority":1},{"to":"PAID","event":"buy"}]},` +
+	`{"name":"REVIEW","transitions":[{"to":"PAID","guard":"payload.vip"},{"to":"PAID","name":"approved","guard":"key == 'approve'"}]},` +
+	`{"name":"PAID"}]}`
+
+func TestGuardDefinition(t *testing.T) {
+	sm, err := fsm.LoadDefinition([]byte(guardDefinitionJSON), fsm.HandlerRegistry{})
+	require.NoError(t, err)
+
+	smi, err := sm.FromStateName("NEW")
+	require.NoError(t, err)
+	smi.SetPayload(map[string]bool{"vip": false})
+	require.NoError(t, smi.Fire(purchase{Amount: 500}))
+	require.Equal(t, "REVIEW", smi.State().Name())
+	require.Error(t, smi.Fire("reject"))
+	require.NoError(t, smi.Fire("approve"))
+	require.Equal(t, "PAID", smi.State().Name())
+
+	smi, err = sm.FromStateName("NEW")
+	require.NoError(t, err)
+	smi.SetPayload(map[string]bool{"vip": true})
+	require.NoError(t, smi.Fire(purchase{Amount: 500}))
+	require.Equal(t, "PAID", smi.State().Name())
+
+	data, err := sm.MarshalDefinition()
+	require.NoError(t, err)
+	require.JSONEq(t, guardDefinitionJSON, string(data))
+	require.Contains(t, sm.Dot(nil), `NEW -> REVIEW [label = "buy [event.amount > 100 && !payload.vip]"];`)
+	require.Contains(t, sm.Dot(nil), `REVIEW -> PAID [label = "[payload.vip]"];`)
+
+	_, err = fsm.LoadDefinition([]byte(`{"states":[{"name":"A","transitions":[{"to":"A","guard":"event >"}]}]}`), fsm.HandlerRegistry{})
+	require.EqualError(t, err, `1:39:states[0].transitions[0]: invalid guard "event >": unexpected end on state A`)
+}
@@ -0,0 +1,90 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type ErrHandlerTimeout struct {
+	handler string
+	state   string
+	timeout time.Duration
+}
+
+func (e *ErrHandlerTimeout) Error() string {
+	return fmt.Sprintf("%s handler of state %s timed out after %s", e.handler, e.state, e.timeout)
+}
+
+// Handler returns the kind of the handler that timed out, like OnEnter
+func (e *ErrHandlerTimeout) Handler() string {
+	return e.handler
+}
+
+// State returns the name of the state of the handler
+func (e *ErrHandlerTimeout) State() string {
+	return e.state
+}
+
+// Unwrap makes the error match context.DeadlineExceeded
+func (e *ErrHandlerTimeout) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// WithHandlerTimeout option limits the duration of each call of a state handler or action.
+// The handler sees the deadline in Context.Context() and, when it is exceeded, the transition is aborted
+// with ErrHandlerTimeout without waiting for the handler to return.
+// Handlers should stop their work once the context is done, since they keep running in the background.
+// A timed out handler can no longer fire events: Context.Fire fails with ErrFireNotAllowed.
+// Timed out handlers are not retried.
+func WithHandlerTimeout(d time.Duration) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.handlerTimeout = d
+	}
+}
+
+// invoke calls the handler, enforcing the handler timeout, if any.
+// state is the state of the handler.
+func (s *StateMachine) invoke(kind string, state *State, handler OnHandler, ctx *Context) error {
+	if s.handlerTimeout <= 0 {
+		return handler(ctx)
+	}
+	goCtx, cancel := context.WithTimeout(ctx.Context(), s.handlerTimeout)
+	defer cancel()
+	// the handler runs with a copy, so that a timed out handler does not race with the rest of the Fire
+	hctx := *ctx
+	hctx.context = goCtx
+	hctx.expiry = &handlerExpiry{}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- handler(&hctx)
+	}()
+	select {
+	case err := <-done:
+		hctx.context = ctx.context
+		hctx.expiry = ctx.expiry
+		*ctx = hctx
+		return err
+	case <-goCtx.Done():
+		// waits for a chained Fire in progress, and prevents the next ones
+		hctx.expiry.expire()
+		if goCtx.Err() == context.DeadlineExceeded {
+			return &ErrHandlerTimeout{handler: kind, state: state.name, timeout: s.handlerTimeout}
+		}
+		return goCtx.Err()
+	}
+}
+
+// handlerExpiry tells a handler that outlived its timeout that it can no longer fire events
+type handlerExpiry struct {
+	mu      sync.Mutex
+	expired bool
+}
+
+func (e *handlerExpiry) expire() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.expired = true
+}
@@ -0,0 +1,66 @@
+package fsm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerTimeout(t *testing.T) {
+	sm := fsm.New(fsm.WithHandlerTimeout(20 * time.Millisecond))
+	a := sm.AddState("A")
+	slow := sm.AddState("SLOW", fsm.OnEnter(func(c *fsm.Context) error {
+		<-c.Context().Done()
+		return nil
+	}))
+	fast := sm.AddState("FAST", fsm.OnEnter(func(c *fsm.Context) error {
+		_, ok := c.Context().Deadline()
+		if !ok {
+			return errors.New("missing deadline")
+		}
+		return nil
+	}))
+	a.AddTransition("slow", slow)
+	a.AddTransition("fast", fast)
+
+	smi := sm.FromState(a)
+	err := smi.Fire("slow")
+	var timeout *fsm.ErrHandlerTimeout
+	require.ErrorAs(t, err, &timeout)
+	require.Equal(t, "OnEnter", timeout.Handler())
+	require.Equal(t, "SLOW", timeout.State())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, a, smi.State())
+
+	require.NoError(t, smi.Fire("fast"))
+	require.Equal(t, fast, smi.State())
+}
+
+func TestHandlerTimeoutFire(t *testing.T) {
+	sm := fsm.New(fsm.WithHandlerTimeout(20 * time.Millisecond))
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	c := sm.AddState("C")
+	fired := make(chan error, 1)
+	a.AddTransition("go", b)
+	a.AddTransition("skip", c)
+	b.AddOnEvent(func(ctx *fsm.Context) error {
+		<-ctx.Context().Done()
+		time.Sleep(10 * time.Millisecond)
+		fired <- ctx.Fire("next")
+		return nil
+	})
+	b.AddTransition("next", c)
+
+	smi := sm.FromState(a)
+	var timeout *fsm.ErrHandlerTimeout
+	require.ErrorAs(t, smi.Fire("go"), &timeout)
+	// the instance keeps being used while the timed out handler is still running
+	require.NoError(t, smi.Fire("skip"))
+	require.ErrorIs(t, <-fired, fsm.ErrFireNotAllowed)
+	require.Equal(t, c, smi.State())
+}
@@ -0,0 +1,44 @@
+package fsm
+
+import "time"
+
+// TransitionRecord describes a transition that happened on an instance
+type TransitionRecord struct {
+	From *State
+	To   *State
+	Key  interface{}
+	// Time is when the transition started
+	Time time.Time
+	// Duration is the time spent executing the handlers, including chained transitions
+	Duration time.Duration
+}
+
+// history keeps the last transitions
+type history = ring[TransitionRecord]
+
+// transitioned updates the instance statistics after a successful transition
+func (m *StateMachineInstance) transitioned(ctx *Context, start time.Time) {
+	m.steps++
+	m.history.add(TransitionRecord{
+		From:     ctx.FromState(),
+		To:       ctx.ToState(),
+		Key:      ctx.Key(),
+		Time:     start,
+		Duration: time.Since(start),
+	})
+}
+
+// EnableHistory keeps the last n transitions of this instance.
+// Calling it again discards the recorded history. A zero n disables it.
+func (m *StateMachineInstance) EnableHistory(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.history = history{size: n}
+}
+
+// History returns the recorded transitions, from the oldest to the newest
+func (m *StateMachineInstance) History() []TransitionRecord {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.history.list()
+}
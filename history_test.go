@@ -0,0 +1,30 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	smi, states, _, err := createFSM()
+	require.NoError(t, err)
+
+	smi.EnableHistory(3)
+	require.Empty(t, smi.History())
+
+	smi.Fire(TICK)
+	smi.Fire(TICK)
+	smi.Fire(LOOP)
+
+	h := smi.History()
+	require.Len(t, h, 3)
+	// chained transitions are recorded as they complete
+	require.Equal(t, states.bounce, h[0].From)
+	require.Equal(t, states.red, h[0].To)
+	require.Equal(t, states.yellow, h[1].From)
+	require.Equal(t, states.bounce, h[1].To)
+	require.Equal(t, states.red, h[2].From)
+	require.Equal(t, LOOP, h[2].Key)
+	require.False(t, h[2].Time.IsZero())
+}
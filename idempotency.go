@@ -0,0 +1,107 @@
+package fsm
+
+import "fmt"
+
+// DefaultIdempotencyWindow is the number of idempotency keys remembered by an instance
+const DefaultIdempotencyWindow = 1024
+
+type ErrDuplicateEvent struct {
+	key string
+}
+
+func (e *ErrDuplicateEvent) Error() string {
+	return fmt.Sprintf("duplicate event with idempotency key %s", e.key)
+}
+
+// IdempotencyKey returns the key of the duplicated event
+func (e *ErrDuplicateEvent) IdempotencyKey() string {
+	return e.key
+}
+
+// idempotentEvent carries the idempotency key of an event
+type idempotentEvent struct {
+	event interface{}
+	key   string
+}
+
+func (e idempotentEvent) Kind() interface{} {
+	return toEventer(e.event).Kind()
+}
+
+// WithIdempotencyKey wraps the event with an idempotency key.
+// An instance remembers the keys of the events it processed, or deferred, and fails firing an event with a known key
+// with ErrDuplicateEvent, without any side effect. Handlers see the original event.
+// The keys are part of the Snapshot.
+func WithIdempotencyKey(event interface{}, key string) Eventer {
+	return idempotentEvent{event: event, key: key}
+}
+
+// IdempotencyWindow option sets the number of idempotency keys remembered by each instance,
+// forgetting the oldest ones. Defaults to DefaultIdempotencyWindow.
+func IdempotencyWindow(n int) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.idempotencyWindow = n
+	}
+}
+
+// seenKeys remembers the last idempotency keys
+type seenKeys struct {
+	order ring[string]
+	set   map[string]struct{}
+}
+
+func (k *seenKeys) contains(key string) bool {
+	_, ok := k.set[key]
+	return ok
+}
+
+func (k *seenKeys) add(key string, size int) {
+	if k.set == nil {
+		k.order = ring[string]{size: size}
+		k.set = map[string]struct{}{}
+	}
+	if k.order.size == 0 {
+		return
+	}
+	if len(k.order.items) == k.order.size {
+		delete(k.set, k.order.items[k.order.next])
+	}
+	k.order.add(key)
+	k.set[key] = struct{}{}
+}
+
+func (k *seenKeys) list() []string {
+	if k.set == nil {
+		return nil
+	}
+	return k.order.list()
+}
+
+// idempotencyWindowSize returns the configured window
+func (s *StateMachine) idempotencyWindowSize() int {
+	if s.idempotencyWindow == 0 {
+		return DefaultIdempotencyWindow
+	}
+	return s.idempotencyWindow
+}
+
+// remember remembers the idempotency key, if not empty.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) remember(key string) {
+	if key != "" {
+		m.seen.add(key, m.idempotencyWindowSize())
+	}
+}
+
+// unwrapIdempotent returns the event inside an idempotent event and its key, failing if the key is known.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) unwrapIdempotent(event interface{}) (interface{}, string, error) {
+	ie, ok := event.(idempotentEvent)
+	if !ok {
+		return event, "", nil
+	}
+	if m.seen.contains(ie.key) {
+		return nil, "", &ErrDuplicateEvent{key: ie.key}
+	}
+	return ie.event, ie.key, nil
+}
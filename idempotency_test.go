@@ -0,0 +1,55 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	var amounts []int
+	sm := fsm.New(fsm.IdempotencyWindow(2))
+	open := sm.AddState("OPEN", fsm.OnEvent(func(c *fsm.Context) error {
+		p, err := fsm.DataAs[payment](c)
+		if err != nil {
+			return err
+		}
+		amounts = append(amounts, p.amount)
+		return nil
+	}))
+	open.AddTransition("pay", open)
+
+	smi := sm.FromState(open)
+	require.NoError(t, smi.Fire(fsm.WithIdempotencyKey(payment{amount: 10}, "msg-1")))
+	err := smi.Fire(fsm.WithIdempotencyKey(payment{amount: 10}, "msg-1"))
+	var dup *fsm.ErrDuplicateEvent
+	require.ErrorAs(t, err, &dup)
+	require.Equal(t, "msg-1", dup.IdempotencyKey())
+	require.Equal(t, []int{10}, amounts)
+
+	// the keys survive a snapshot
+	restored, err := sm.Restore(smi.Snapshot())
+	require.NoError(t, err)
+	require.ErrorAs(t, restored.Fire(fsm.WithIdempotencyKey(payment{amount: 10}, "msg-1")), &dup)
+
+	// the oldest keys are forgotten
+	require.NoError(t, smi.Fire(fsm.WithIdempotencyKey(payment{amount: 20}, "msg-2")))
+	require.NoError(t, smi.Fire(fsm.WithIdempotencyKey(payment{amount: 30}, "msg-3")))
+	require.NoError(t, smi.Fire(fsm.WithIdempotencyKey(payment{amount: 10}, "msg-1")))
+	require.Equal(t, []int{10, 20, 30, 10}, amounts)
+}
+
+func TestIdempotencyKeyOfFailedEvent(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b)
+
+	smi := sm.FromState(b)
+	require.Error(t, smi.Fire(fsm.WithIdempotencyKey(TICK, "msg-1")))
+	smi = sm.FromState(a)
+	// failed events can be redelivered
+	require.NoError(t, smi.Fire(fsm.WithIdempotencyKey(TICK, "msg-1")))
+	require.Equal(t, b, smi.State())
+}
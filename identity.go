@@ -0,0 +1,62 @@
+package fsm
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+func newInstanceID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// ID returns the identifier of the instance, randomly generated on creation unless set with SetID
+func (m *StateMachineInstance) ID() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.id
+}
+
+// SetID sets the identifier of the instance, usually the one of the entity it belongs to
+func (m *StateMachineInstance) SetID(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.monitor.rename(m.id, id)
+	m.id = id
+}
+
+// Payload returns the user defined data of the instance
+func (m *StateMachineInstance) Payload() interface{} {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.payload
+}
+
+// SetPayload sets the user defined data of the instance, available to handlers with Context.Payload.
+// The payload is not part of the Snapshot.
+func (m *StateMachineInstance) SetPayload(payload interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.payload = payload
+}
+
+// InstanceID returns the identifier of the instance the event was fired into,
+// or empty if fired directly into the machine
+func (c *Context) InstanceID() string {
+	if c.instance == nil {
+		return ""
+	}
+	return c.instance.id
+}
+
+// Payload returns the user defined data of the instance the event was fired into,
+// or nil if fired directly into the machine
+func (c *Context) Payload() interface{} {
+	if c.instance == nil {
+		return nil
+	}
+	return c.instance.payload
+}
@@ -0,0 +1,43 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+type order struct {
+	total int
+}
+
+func TestInstanceIdentityAndPayload(t *testing.T) {
+	var seenID string
+	var seen *order
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.OnEnter(func(c *fsm.Context) error {
+		seenID = c.InstanceID()
+		seen = c.Payload().(*order)
+		seen.total += 10
+		return nil
+	}))
+	a.AddTransition(TICK, b)
+
+	smi := sm.FromState(a)
+	require.NotEmpty(t, smi.ID())
+	require.NotEqual(t, smi.ID(), sm.FromState(a).ID())
+
+	smi.SetID("order-1")
+	o := &order{total: 5}
+	smi.SetPayload(o)
+	require.NoError(t, smi.Fire(TICK))
+	require.Equal(t, "order-1", seenID)
+	require.Same(t, o, seen)
+	require.Equal(t, 15, o.total)
+
+	restored, err := sm.Restore(smi.Snapshot())
+	require.NoError(t, err)
+	require.Equal(t, "order-1", restored.ID())
+	require.Nil(t, restored.Payload())
+}
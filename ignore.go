@@ -0,0 +1,47 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+)
+
+// FireOutcome tells what a successful Fire did with the event
+type FireOutcome int
+
+const (
+	// Handled events were handled by a transition
+	Handled FireOutcome = iota
+	// Deferred events were queued by the current state, to be replayed later
+	Deferred
+	// Ignored events had no transition, with IgnoreUnhandledEvents
+	Ignored
+)
+
+func (o FireOutcome) String() string {
+	switch o {
+	case Handled:
+		return "handled"
+	case Deferred:
+		return "deferred"
+	case Ignored:
+		return "ignored"
+	}
+	return "unknown"
+}
+
+// errIgnored tells the instance that the event was ignored
+var errIgnored = errors.New("fsm: event ignored")
+
+// IgnoreUnhandledEvents option makes firing an event without a matching transition, nor fallback, a no-op,
+// instead of failing with ErrTransitionNotFound. The instance stays in its state, without calling any handler,
+// and TryFire reports the event as Ignored. Final states still fail with ErrMachineCompleted.
+func IgnoreUnhandledEvents() func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.ignoreUnhandled = true
+	}
+}
+
+// TryFire is like FireContext, also returning what was done with the event
+func (m *StateMachineInstance) TryFire(ctx context.Context, key interface{}) (FireOutcome, error) {
+	return m.fireOutcome(ctx, nil, key)
+}
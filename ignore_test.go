@@ -0,0 +1,79 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreUnhandledEvents(t *testing.T) {
+	var entered []string
+	sm := fsm.New(fsm.IgnoreUnhandledEvents())
+	idle := sm.AddState("IDLE", fsm.OnEnter(func(c *fsm.Context) error {
+		entered = append(entered, c.ToState().Name())
+		return nil
+	}))
+	busy := sm.AddState("BUSY", fsm.OnExit(func(c *fsm.Context) error {
+		entered = append(entered, "exit "+c.FromState().Name())
+		return nil
+	}))
+	busy.Defer("PAUSE")
+	done := sm.AddState("DONE", fsm.Final())
+	idle.AddTransition("START", busy)
+	busy.AddTransition("FINISH", idle)
+	idle.AddTransition("STOP", done)
+
+	smi := sm.FromState(idle)
+	ctx := context.Background()
+
+	outcome, err := smi.TryFire(ctx, "PING")
+	require.NoError(t, err)
+	require.Equal(t, fsm.Ignored, outcome)
+	require.Equal(t, idle, smi.State())
+	require.Empty(t, smi.History())
+	require.NoError(t, smi.Fire("PING"))
+
+	outcome, err = smi.TryFire(ctx, "START")
+	require.NoError(t, err)
+	require.Equal(t, fsm.Handled, outcome)
+
+	outcome, err = smi.TryFire(ctx, "PAUSE")
+	require.NoError(t, err)
+	require.Equal(t, fsm.Deferred, outcome)
+
+	// the replayed event is ignored too
+	require.NoError(t, smi.Fire("FINISH"))
+	require.Equal(t, idle, smi.State())
+	require.Empty(t, smi.Deferred())
+	require.Equal(t, []string{"exit BUSY", "IDLE"}, entered)
+
+	require.NoError(t, smi.Fire("STOP"))
+
+	_, err = smi.TryFire(ctx, "PING")
+	require.ErrorIs(t, err, fsm.ErrMachineCompleted)
+
+	st, err := sm.Fire(idle, "PING")
+	require.NoError(t, err)
+	require.Equal(t, idle, st)
+}
+
+func TestIgnoreUnhandledEventsInSubMachine(t *testing.T) {
+	sub := fsm.New(fsm.IgnoreUnhandledEvents())
+	step1 := sub.AddState("STEP1")
+	step2 := sub.AddState("STEP2")
+	step1.AddTransition("NEXT", step2)
+
+	sm := fsm.New()
+	running := sm.AddSubMachineState("RUNNING", sub, nil)
+	cancelled := sm.AddState("CANCELLED")
+	running.AddTransition("CANCEL", cancelled)
+
+	smi := sm.FromState(running)
+	// events ignored by the sub-machine are handled by the parent
+	outcome, err := smi.TryFire(context.Background(), "CANCEL")
+	require.NoError(t, err)
+	require.Equal(t, fsm.Handled, outcome)
+	require.Equal(t, cancelled, smi.State())
+}
@@ -0,0 +1,72 @@
+package fsm
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// KeyNormalizer option sets a function that normalizes the event keys,
+// both when registering a transition and when dispatching an event.
+// Keys that the normalizer does not care about should be returned unchanged.
+func KeyNormalizer(normalizer func(interface{}) interface{}) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.keyNormalizer = normalizer
+	}
+}
+
+// KeyComparator option sets the function used to compare the event key with the key of a transition.
+// By default keys are compared with ==, requiring the registered keys to be comparable.
+func KeyComparator(comparator func(a, b interface{}) bool) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.keyComparator = comparator
+	}
+}
+
+// NormalizeStrings option trims and case folds string event keys and state names,
+// both at registration and at dispatch, so that "tick " matches "TICK".
+// State names keep the registered spelling.
+func NormalizeStrings() func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.foldStrings = true
+	}
+}
+
+func foldString(v string) string {
+	return strings.ToLower(strings.TrimSpace(v))
+}
+
+func (s *StateMachine) normalizeKey(key interface{}) interface{} {
+	if s.keyNormalizer != nil {
+		key = s.keyNormalizer(key)
+	}
+	if v, ok := key.(string); ok && s.foldStrings {
+		return foldString(v)
+	}
+	return key
+}
+
+// nameKey returns the key indexing a state name, honouring the NormalizeStrings option
+func (s *StateMachine) nameKey(name string) string {
+	if s.foldStrings {
+		return foldString(name)
+	}
+	return name
+}
+
+func (s *StateMachine) keysEqual(a, b interface{}) bool {
+	if s.keyComparator == nil {
+		return a == b
+	}
+	return s.keyComparator(a, b)
+}
+
+// mustBeComparable panics if the key cannot be compared with == and no comparator was set
+func (s *StateMachine) mustBeComparable(key interface{}) {
+	if s.keyComparator != nil || key == nil {
+		return
+	}
+	if t := reflect.TypeOf(key); !t.Comparable() {
+		panic(fmt.Sprintf("event key of type %s is not comparable. Use the KeyComparator option", t))
+	}
+}
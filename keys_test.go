@@ -0,0 +1,69 @@
+package fsm_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyNormalizer(t *testing.T) {
+	sm := fsm.New(fsm.KeyNormalizer(func(k interface{}) interface{} {
+		switch v := k.(type) {
+		case int:
+			return int64(v)
+		case string:
+			return strings.ToUpper(v)
+		}
+		return k
+	}))
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(1, b)
+	b.AddTransition("back", a)
+
+	smi := sm.FromState(a)
+	require.NoError(t, smi.Fire(int64(1)))
+	require.Equal(t, b, smi.State())
+	require.NoError(t, smi.Fire("BACK"))
+	require.Equal(t, a, smi.State())
+}
+
+func TestKeyComparator(t *testing.T) {
+	sm := fsm.New(fsm.KeyComparator(reflect.DeepEqual))
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition([]string{"x", "y"}, b)
+
+	smi := sm.FromState(a)
+	require.Error(t, smi.Fire([]string{"x"}))
+	require.NoError(t, smi.Fire([]string{"x", "y"}))
+	require.Equal(t, b, smi.State())
+}
+
+func TestUncomparableKey(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	require.Panics(t, func() {
+		a.AddTransition([]string{"x"}, b)
+	})
+}
+
+func TestNormalizeStrings(t *testing.T) {
+	sm := fsm.New(fsm.NormalizeStrings())
+	a := sm.AddState("Idle")
+	b := sm.AddState("Running")
+	a.AddTransition("Start", b)
+
+	smi, err := sm.FromStateName(" IDLE")
+	require.NoError(t, err)
+	require.NoError(t, smi.Fire("start "))
+	require.Equal(t, "Running", smi.State().Name())
+
+	// overrides the existing state
+	c := sm.AddState("running")
+	require.Equal(t, c, sm.StateByName("RUNNING"))
+}
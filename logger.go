@@ -0,0 +1,22 @@
+package fsm
+
+// Logger is the logging interface of the machine. It is satisfied by *slog.Logger.
+// The args are alternating keys and values.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// WithLogger option sets the logger of the machine, used to explain why transitions fire or not:
+// matched transitions, conditions that returned false, fallbacks and handler errors.
+func WithLogger(l Logger) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.logger = l
+	}
+}
+
+func (s *StateMachine) debug(msg string, args ...interface{}) {
+	if s.logger != nil {
+		s.logger.Debug(msg, args...)
+	}
+}
@@ -0,0 +1,64 @@
+package fsm_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingLogger struct {
+	lines []string
+}
+
+func (l *recordingLogger) log(level, msg string, args []interface{}) {
+	var b strings.Builder
+	b.WriteString(level + " " + msg)
+	for i := 0; i+1 < len(args); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", args[i], args[i+1])
+	}
+	l.lines = append(l.lines, b.String())
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) {
+	l.log("DEBUG", msg, args)
+}
+
+func (l *recordingLogger) Error(msg string, args ...interface{}) {
+	l.log("ERROR", msg, args)
+}
+
+func TestLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	sm := fsm.New(fsm.WithLogger(logger))
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.OnEnter(func(c *fsm.Context) error {
+		return errors.New("boom")
+	}))
+	dead := sm.AddState("DEAD")
+	a.AddConditionalTransition("never", b, func(c *fsm.Context) bool { return false })
+	a.AddTransition(TICK, b)
+	sm.SetFallbackHandler(func(c *fsm.Context) *fsm.State {
+		if c.Key() == "POISON" {
+			return dead
+		}
+		return nil
+	})
+
+	require.Error(t, sm.FromState(a).Fire(TICK))
+	require.Error(t, sm.FromState(a).Fire(LOOP))
+	require.NoError(t, sm.FromState(a).Fire("POISON"))
+
+	require.Equal(t, []string{
+		"DEBUG fsm: condition false state=A transition=never event=TICK",
+		"DEBUG fsm: transition matched state=A transition=TICK event=TICK to=B",
+		"ERROR fsm: handler failed handler=OnEnter state=B event=TICK error=boom",
+		"DEBUG fsm: condition false state=A transition=never event=LOOP",
+		"DEBUG fsm: no transition state=A event=LOOP",
+		"DEBUG fsm: condition false state=A transition=never event=POISON",
+		"DEBUG fsm: fallback resolved state=A event=POISON to=DEAD",
+	}, logger.lines)
+}
@@ -0,0 +1,232 @@
+package fsm
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrInstanceNotFound is returned when the store has no instance with the ID
+	ErrInstanceNotFound = errors.New("instance not found")
+	// ErrVersionConflict is returned when saving an instance that was changed since it was loaded
+	ErrVersionConflict = errors.New("instance version conflict")
+)
+
+// Store persists the snapshots of instances keyed by ID, with an optimistic version
+type Store interface {
+	// Load returns the snapshot and the version of the instance, or ErrInstanceNotFound
+	Load(ctx context.Context, id string) (Snapshot, int64, error)
+	// Save stores the snapshot with the next version if the stored version is the expected one,
+	// failing with ErrVersionConflict otherwise. An expected version of zero creates the instance.
+	Save(ctx context.Context, id string, snap Snapshot, expected int64) error
+}
+
+// Manager fires events into persisted instances, loading and saving them through the store
+type Manager struct {
+	machine *StateMachine
+	store   Store
+	cache   *instanceCache
+}
+
+// ManagerOption configures a Manager
+type ManagerOption func(*Manager)
+
+// WithCache option keeps, in process, the snapshots and versions of up to size recently used instances.
+// Saves write through to the store, so reads in this process see its own writes without a round-trip,
+// and a version conflict evicts the entry, so that the next access reloads it.
+func WithCache(size int) ManagerOption {
+	return func(m *Manager) {
+		m.cache = newInstanceCache(size)
+	}
+}
+
+// NewManager creates a manager for the instances of the machine
+func NewManager(sm *StateMachine, store Store, opts ...ManagerOption) *Manager {
+	m := &Manager{
+		machine: sm,
+		store:   store,
+	}
+	for _, o := range opts {
+		o(m)
+	}
+	return m
+}
+
+// Create persists a new instance positioned in the state
+func (m *Manager) Create(ctx context.Context, id string, state *State) error {
+	smi := m.machine.FromState(state)
+	smi.SetID(id)
+	if err := m.save(ctx, id, smi.Snapshot(), 0); err != nil {
+		return err
+	}
+	return smi.syncTimers(ctx)
+}
+
+// State returns the current state of the instance
+func (m *Manager) State(ctx context.Context, id string) (*State, error) {
+	smi, _, err := m.load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return smi.State(), nil
+}
+
+// Fire fires the event into the instance and saves it, returning the reached state.
+// If the instance was changed concurrently, it fails with ErrVersionConflict and the event should be retried.
+// With WithTransaction, loading, firing and saving run in the same transaction.
+func (m *Manager) Fire(ctx context.Context, id string, event interface{}) (*State, error) {
+	var st *State
+	err := m.machine.transact(ctx, func(ctx context.Context) error {
+		smi, version, err := m.load(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := smi.FireContext(ctx, event); err != nil {
+			return err
+		}
+		if err := m.save(ctx, id, smi.Snapshot(), version); err != nil {
+			return err
+		}
+		st = smi.State()
+		return nil
+	})
+	if err != nil {
+		// the saved snapshot may have been rolled back
+		m.cache.remove(id)
+		return nil, err
+	}
+	return st, nil
+}
+
+func (m *Manager) load(ctx context.Context, id string) (*StateMachineInstance, int64, error) {
+	snap, version, ok := m.cache.get(id)
+	if !ok {
+		var err error
+		snap, version, err = m.store.Load(ctx, id)
+		if err != nil {
+			return nil, 0, err
+		}
+		m.cache.put(id, snap, version)
+	}
+	smi, err := m.machine.Restore(snap)
+	if err != nil {
+		return nil, 0, err
+	}
+	smi.SetID(id)
+	return smi, version, nil
+}
+
+func (m *Manager) save(ctx context.Context, id string, snap Snapshot, expected int64) error {
+	if err := m.store.Save(ctx, id, snap, expected); err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			m.cache.remove(id)
+		}
+		return err
+	}
+	m.cache.put(id, snap, expected+1)
+	return nil
+}
+
+type cacheEntry struct {
+	id      string
+	snap    Snapshot
+	version int64
+}
+
+// instanceCache is a LRU cache of snapshots. A nil cache caches nothing.
+type instanceCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+func newInstanceCache(size int) *instanceCache {
+	return &instanceCache{
+		size:  size,
+		order: list.New(),
+		items: map[string]*list.Element{},
+	}
+}
+
+func (c *instanceCache) get(id string) (Snapshot, int64, bool) {
+	if c == nil {
+		return Snapshot{}, 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[id]
+	if !ok {
+		return Snapshot{}, 0, false
+	}
+	c.order.MoveToFront(el)
+	e := el.Value.(*cacheEntry)
+	return e.snap, e.version, true
+}
+
+func (c *instanceCache) put(id string, snap Snapshot, version int64) {
+	if c == nil || c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[id]; ok {
+		e := el.Value.(*cacheEntry)
+		// never go back to an older version, written by a slower concurrent load
+		if version >= e.version {
+			e.snap, e.version = snap, version
+		}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[id] = c.order.PushFront(&cacheEntry{id: id, snap: snap, version: version})
+	if c.order.Len() > c.size {
+		last := c.order.Back()
+		c.order.Remove(last)
+		delete(c.items, last.Value.(*cacheEntry).id)
+	}
+}
+
+func (c *instanceCache) remove(id string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[id]; ok {
+		c.order.Remove(el)
+		delete(c.items, id)
+	}
+}
+
+// MemoryStore is an in memory Store, for tests and single process usage
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]cacheEntry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]cacheEntry{}}
+}
+
+func (s *MemoryStore) Load(_ context.Context, id string) (Snapshot, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.records[id]
+	if !ok {
+		return Snapshot{}, 0, ErrInstanceNotFound
+	}
+	return r.snap, r.version, nil
+}
+
+func (s *MemoryStore) Save(_ context.Context, id string, snap Snapshot, expected int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.records[id].version != expected {
+		return ErrVersionConflict
+	}
+	s.records[id] = cacheEntry{id: id, snap: snap, version: expected + 1}
+	return nil
+}
@@ -0,0 +1,103 @@
+package fsm_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+// countingStore counts the loads of the wrapped store
+type countingStore struct {
+	*fsm.MemoryStore
+	loads int32
+}
+
+func (s *countingStore) Load(ctx context.Context, id string) (fsm.Snapshot, int64, error) {
+	atomic.AddInt32(&s.loads, 1)
+	return s.MemoryStore.Load(ctx, id)
+}
+
+func managerFSM() *fsm.StateMachine {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b)
+	b.AddTransition(TICK, a)
+	return sm
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+	sm := managerFSM()
+	m := fsm.NewManager(sm, fsm.NewMemoryStore())
+
+	_, err := m.State(ctx, "1")
+	require.ErrorIs(t, err, fsm.ErrInstanceNotFound)
+
+	require.NoError(t, m.Create(ctx, "1", sm.StateByName("A")))
+	require.ErrorIs(t, m.Create(ctx, "1", sm.StateByName("A")), fsm.ErrVersionConflict)
+
+	st, err := m.Fire(ctx, "1", TICK)
+	require.NoError(t, err)
+	require.Equal(t, "B", st.Name())
+	st, err = m.State(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, "B", st.Name())
+}
+
+func TestManagerCache(t *testing.T) {
+	ctx := context.Background()
+	sm := managerFSM()
+	store := &countingStore{MemoryStore: fsm.NewMemoryStore()}
+	m := fsm.NewManager(sm, store, fsm.WithCache(10))
+
+	require.NoError(t, m.Create(ctx, "1", sm.StateByName("A")))
+	for i := 0; i < 3; i++ {
+		_, err := m.Fire(ctx, "1", TICK)
+		require.NoError(t, err)
+	}
+	st, err := m.State(ctx, "1")
+	require.NoError(t, err)
+	// reads its own writes
+	require.Equal(t, "B", st.Name())
+	require.Equal(t, int32(0), atomic.LoadInt32(&store.loads))
+}
+
+func TestManagerCacheConflict(t *testing.T) {
+	ctx := context.Background()
+	sm := managerFSM()
+	store := &countingStore{MemoryStore: fsm.NewMemoryStore()}
+	cached := fsm.NewManager(sm, store, fsm.WithCache(10))
+	other := fsm.NewManager(sm, store)
+
+	require.NoError(t, cached.Create(ctx, "1", sm.StateByName("A")))
+	// another process changes the instance
+	_, err := other.Fire(ctx, "1", TICK)
+	require.NoError(t, err)
+
+	// the stale cache entry is detected by the optimistic lock and evicted
+	_, err = cached.Fire(ctx, "1", TICK)
+	require.ErrorIs(t, err, fsm.ErrVersionConflict)
+	st, err := cached.Fire(ctx, "1", TICK)
+	require.NoError(t, err)
+	require.Equal(t, "A", st.Name())
+}
+
+func TestManagerCacheEviction(t *testing.T) {
+	ctx := context.Background()
+	sm := managerFSM()
+	store := &countingStore{MemoryStore: fsm.NewMemoryStore()}
+	m := fsm.NewManager(sm, store, fsm.WithCache(1))
+
+	require.NoError(t, m.Create(ctx, "1", sm.StateByName("A")))
+	require.NoError(t, m.Create(ctx, "2", sm.StateByName("A")))
+	_, err := m.State(ctx, "2")
+	require.NoError(t, err)
+	require.Equal(t, int32(0), atomic.LoadInt32(&store.loads))
+	_, err = m.State(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, int32(1), atomic.LoadInt32(&store.loads))
+}
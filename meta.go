@@ -0,0 +1,69 @@
+package fsm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WithMeta option attaches a metadata entry to a state, like an owner or an SLA
+func WithMeta(key, value string) func(*State) {
+	return func(s *State) {
+		s.meta = setMeta(s.meta, key, value)
+	}
+}
+
+// Meta returns a copy of the metadata of the state
+func (s *State) Meta() map[string]string {
+	return copyMeta(s.meta)
+}
+
+// SetTransitionMeta attaches a metadata entry to the transitions with the given name
+func (s *State) SetTransitionMeta(name, key, value string) *State {
+	s.machine.mustBeMutable()
+	for _, t := range s.transitions {
+		if t.name == name {
+			t.meta = setMeta(t.meta, key, value)
+		}
+	}
+	return s
+}
+
+// TransitionMeta returns a copy of the metadata of the first transition with the given name
+func (s *State) TransitionMeta(name string) map[string]string {
+	for _, t := range s.transitions {
+		if t.name == name {
+			return copyMeta(t.meta)
+		}
+	}
+	return nil
+}
+
+func setMeta(meta map[string]string, key, value string) map[string]string {
+	if meta == nil {
+		meta = map[string]string{}
+	}
+	meta[key] = value
+	return meta
+}
+
+func copyMeta(meta map[string]string) map[string]string {
+	if meta == nil {
+		return nil
+	}
+	c := make(map[string]string, len(meta))
+	for k, v := range meta {
+		c[k] = v
+	}
+	return c
+}
+
+// formatMeta renders the metadata as sorted key=value lines
+func formatMeta(meta map[string]string) string {
+	lines := make([]string, 0, len(meta))
+	for k, v := range meta {
+		lines = append(lines, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
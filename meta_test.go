@@ -0,0 +1,45 @@
+package fsm_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeta(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A", fsm.WithMeta("owner", "team-a"), fsm.WithMeta("sla", "30s"))
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b).SetTransitionMeta(TICK, "channel", "email")
+
+	require.Equal(t, map[string]string{"owner": "team-a", "sla": "30s"}, a.Meta())
+	require.Nil(t, b.Meta())
+	require.Equal(t, map[string]string{"channel": "email"}, a.TransitionMeta(TICK))
+
+	// copies
+	a.Meta()["owner"] = "someone"
+	require.Equal(t, "team-a", a.Meta()["owner"])
+}
+
+func ExampleWithMeta() {
+	sm := fsm.New()
+	a := sm.AddState("A", fsm.WithMeta("owner", "team-a"), fsm.WithMeta("sla", "30s"))
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b).SetTransitionMeta(TICK, "channel", "email")
+
+	fmt.Println(sm.FromState(b).Dot())
+	// Output:
+	// digraph finite_state_machine {
+	// 	rankdir=LR;
+	// 	node [shape = circle];
+	// 	# nodes
+	// 	A [style=filled, shape=doublecircle, tooltip="owner=team-a\nsla=30s"];
+	// 	B [style=filled, fillcolor=gold, shape=doublecircle];
+	// 	# transitions
+	// 	A -> B [label = "TICK", tooltip = "channel=email"];
+	// 	# title
+	// 	labelloc="t";
+	// }
+}
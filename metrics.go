@@ -0,0 +1,50 @@
+package fsm
+
+import (
+	"fmt"
+	"time"
+)
+
+// Metrics receives the measurements of the instances of a machine.
+// Implementations must be safe for concurrent use, and fast, since they are called while firing.
+type Metrics interface {
+	// Transitioned is called for every transition of an instance, including self transitions
+	Transitioned(from, to, event string)
+	// Handled is called after every handler, with its kind, like OnEnter, its duration and its error
+	Handled(kind, state string, d time.Duration, err error)
+	// Failed is called when firing an event into an instance fails
+	Failed(state, event string, err error)
+	// Occupancy is called when the number of instances in a state changes by delta
+	Occupancy(state string, delta int)
+}
+
+// WithMetrics option sets the metrics of the machine instances.
+// Instances are counted in the occupancy when created, and discounted when returned to an InstancePool.
+func WithMetrics(m Metrics) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.metrics = m
+	}
+}
+
+// measureStep reports the outcome of firing an event into an instance
+func (m *StateMachineInstance) measureStep(from, to *State, event interface{}, err error) {
+	if m.metrics == nil {
+		return
+	}
+	key := fmt.Sprintf("%+v", toEventer(event).Kind())
+	if err != nil {
+		m.metrics.Failed(from.name, key, err)
+		return
+	}
+	m.metrics.Transitioned(from.name, to.name, key)
+	m.moveOccupancy(from, to)
+}
+
+// moveOccupancy reports an instance moving between the states
+func (m *StateMachineInstance) moveOccupancy(from, to *State) {
+	if m.metrics == nil || from == to {
+		return
+	}
+	m.metrics.Occupancy(from.name, -1)
+	m.metrics.Occupancy(to.name, 1)
+}
@@ -0,0 +1,174 @@
+package fsm
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FailureRecord is a failed Fire
+type FailureRecord struct {
+	Time  time.Time `json:"time"`
+	State string    `json:"state"`
+	Event string    `json:"event"`
+	Error string    `json:"error"`
+}
+
+// MonitorStats is the runtime state of a machine
+type MonitorStats struct {
+	Name        string `json:"name"`
+	Fingerprint string `json:"fingerprint"`
+	// Instances are the number of instances by state
+	Instances map[string]int `json:"instances"`
+	// Deferred is the number of deferred events waiting in the instances
+	Deferred int             `json:"deferred"`
+	Failures []FailureRecord `json:"failures"`
+}
+
+// Monitor collects the runtime state of the instances of a machine, for operators without a metrics stack.
+// Instances are tracked by ID: they are counted when created, or when first restored in the process,
+// and moved on every transition.
+// Instances returned to an InstancePool are discounted, while other discarded instances are not.
+type Monitor struct {
+	name    string
+	machine *StateMachine
+	mu      sync.Mutex
+	counts  map[string]int
+	// states are the states of the tracked instances, by ID
+	states   map[string]string
+	deferred int
+	failures ring[FailureRecord]
+}
+
+// NewMonitor creates a monitor, keeping the last failures
+func NewMonitor(name string, failures int) *Monitor {
+	return &Monitor{
+		name:     name,
+		counts:   map[string]int{},
+		states:   map[string]string{},
+		failures: ring[FailureRecord]{size: failures},
+	}
+}
+
+// WithMonitor option sets the monitor of the machine instances
+func WithMonitor(mon *Monitor) func(*StateMachine) {
+	return func(s *StateMachine) {
+		mon.machine = s
+		s.monitor = mon
+	}
+}
+
+// Stats returns the current runtime state
+func (m *Monitor) Stats() MonitorStats {
+	m.mu.Lock()
+	stats := MonitorStats{
+		Name:      m.name,
+		Instances: make(map[string]int, len(m.counts)),
+		Deferred:  m.deferred,
+		Failures:  m.failures.list(),
+	}
+	for k, v := range m.counts {
+		if v != 0 {
+			stats.Instances[k] = v
+		}
+	}
+	m.mu.Unlock()
+	if m.machine != nil {
+		stats.Fingerprint = m.machine.Fingerprint()
+	}
+	return stats
+}
+
+// Publish publishes the stats with expvar, under the name "fsm.<name>".
+// Like expvar.Publish, it panics if the name is already in use.
+func (m *Monitor) Publish() {
+	expvar.Publish("fsm."+m.name, expvar.Func(func() interface{} {
+		return m.Stats()
+	}))
+}
+
+// DebugHandler serves the stats of the monitors as JSON, usually at /debug/fsm
+func DebugHandler(monitors ...*Monitor) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := make([]MonitorStats, 0, len(monitors))
+		for _, m := range monitors {
+			stats = append(stats, m.Stats())
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(stats)
+	})
+}
+
+// track counts the instance with the ID in the state, moving it if it was already counted
+func (m *Monitor) track(id string, state *State) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if prev, ok := m.states[id]; ok {
+		if prev == state.name {
+			return
+		}
+		m.counts[prev]--
+	}
+	m.counts[state.name]++
+	m.states[id] = state.name
+}
+
+// untrack discounts the instance with the ID
+func (m *Monitor) untrack(id string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if prev, ok := m.states[id]; ok {
+		m.counts[prev]--
+		delete(m.states, id)
+	}
+}
+
+// rename tracks the instance under a new ID, replacing any instance tracked with it
+func (m *Monitor) rename(old, id string) {
+	if m == nil || old == id {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.states[old]
+	if !ok {
+		return
+	}
+	delete(m.states, old)
+	if prev, ok := m.states[id]; ok {
+		m.counts[prev]--
+	}
+	m.states[id] = state
+}
+
+func (m *Monitor) deferredChanged(delta int) {
+	if m == nil || delta == 0 {
+		return
+	}
+	m.mu.Lock()
+	m.deferred += delta
+	m.mu.Unlock()
+}
+
+func (m *Monitor) failed(state *State, event interface{}, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.failures.add(FailureRecord{
+		Time:  time.Now(),
+		State: state.name,
+		Event: fmt.Sprintf("%+v", toEventer(event).Kind()),
+		Error: err.Error(),
+	})
+	m.mu.Unlock()
+}
@@ -0,0 +1,112 @@
+package fsm_test
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitor(t *testing.T) {
+	mon := fsm.NewMonitor("orders", 2)
+	sm := fsm.New(fsm.WithMonitor(mon))
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b).Defer(LOOP)
+	b.AddTransition(TICK, a)
+
+	first := sm.FromState(a)
+	second := sm.FromState(a)
+	require.NoError(t, first.Fire(TICK))
+	require.NoError(t, second.Fire(LOOP))
+	require.Error(t, first.Fire("UNKNOWN"))
+
+	stats := mon.Stats()
+	require.Equal(t, "orders", stats.Name)
+	require.Equal(t, sm.Fingerprint(), stats.Fingerprint)
+	require.Equal(t, map[string]int{"A": 1, "B": 1}, stats.Instances)
+	require.Equal(t, 1, stats.Deferred)
+	require.Len(t, stats.Failures, 1)
+	require.Equal(t, "B", stats.Failures[0].State)
+	require.Equal(t, "UNKNOWN", stats.Failures[0].Event)
+
+	pool := fsm.NewInstancePool(sm)
+	pooled := pool.Get(b)
+	require.Equal(t, 2, mon.Stats().Instances["B"])
+	pool.Put(pooled)
+	require.Equal(t, 1, mon.Stats().Instances["B"])
+}
+
+func TestMonitorPublish(t *testing.T) {
+	// expvar names can only be published once per process
+	name := fmt.Sprintf("published-%d", time.Now().UnixNano())
+	mon := fsm.NewMonitor(name, 10)
+	sm := fsm.New(fsm.WithMonitor(mon))
+	a := sm.AddState("A")
+	sm.FromState(a)
+
+	mon.Publish()
+	stats := fsm.MonitorStats{}
+	require.NoError(t, json.Unmarshal([]byte(expvar.Get("fsm."+name).String()), &stats))
+	require.Equal(t, 1, stats.Instances["A"])
+
+	rec := httptest.NewRecorder()
+	fsm.DebugHandler(mon).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/fsm", nil))
+	var list []fsm.MonitorStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &list))
+	require.Len(t, list, 1)
+	require.Equal(t, name, list[0].Name)
+}
+
+func TestMonitorRestoredInstances(t *testing.T) {
+	mon := fsm.NewMonitor("restored", 10)
+	sm := fsm.New(fsm.WithMonitor(mon))
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition("go", b)
+
+	ctx := context.Background()
+	m := fsm.NewManager(sm, fsm.NewMemoryStore())
+	require.NoError(t, m.Create(ctx, "1", a))
+	for i := 0; i < 3; i++ {
+		_, err := m.State(ctx, "1")
+		require.NoError(t, err)
+	}
+	_, err := m.Fire(ctx, "1", "go")
+	require.NoError(t, err)
+	_, err = m.State(ctx, "1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"B": 1}, mon.Stats().Instances)
+
+	smi, err := sm.Restore(fsm.Snapshot{ID: "1", State: "B"})
+	require.NoError(t, err)
+	require.Equal(t, b, smi.State())
+	require.Equal(t, map[string]int{"B": 1}, mon.Stats().Instances)
+}
+
+func TestMonitorRestartedProcess(t *testing.T) {
+	// a fresh monitor, as after a restart, never counted the instance
+	mon := fsm.NewMonitor("restarted", 10)
+	sm := fsm.New(fsm.WithMonitor(mon))
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition("go", b)
+
+	smi, err := sm.Restore(fsm.Snapshot{ID: "1", State: "A"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"A": 1}, mon.Stats().Instances)
+	require.NoError(t, smi.Fire("go"))
+	require.Equal(t, map[string]int{"B": 1}, mon.Stats().Instances)
+
+	// restoring it again does not count it twice
+	_, err = sm.Restore(fsm.Snapshot{ID: "1", State: "B"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"B": 1}, mon.Stats().Instances)
+}
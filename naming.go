@@ -0,0 +1,50 @@
+package fsm
+
+import "fmt"
+
+type ErrDuplicateState struct {
+	name string
+}
+
+func (e *ErrDuplicateState) Error() string {
+	return fmt.Sprintf("state %s is already defined", e.name)
+}
+
+// State returns the name of the duplicated state
+func (e *ErrDuplicateState) State() string {
+	return e.name
+}
+
+// NamingPolicy option maps the names given to AddState, for example to prefix the states of an included machine
+// or to scope them by tenant. States are looked up, with StateByName, by the mapped name.
+// Definitions are loaded with the names before mapping, so a definition exported from a machine with a policy
+// should be loaded without it.
+func NamingPolicy(policy func(name string) string) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.namingPolicy = policy
+	}
+}
+
+// Prefix is a naming policy that prefixes the state names
+func Prefix(prefix string) func(string) string {
+	return func(name string) string {
+		return prefix + name
+	}
+}
+
+// RejectDuplicateStates option makes AddState panic with ErrDuplicateState
+// when a state with the same name already exists, instead of replacing it.
+// Definitions with duplicated states are always rejected.
+func RejectDuplicateStates() func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.rejectDuplicates = true
+	}
+}
+
+// stateName applies the naming policy
+func (s *StateMachine) stateName(name string) string {
+	if s.namingPolicy == nil {
+		return name
+	}
+	return s.namingPolicy(name)
+}
@@ -0,0 +1,51 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamingPolicy(t *testing.T) {
+	sm := fsm.New(fsm.NamingPolicy(fsm.Prefix("payment.")))
+	a := sm.AddState("A")
+	require.Equal(t, "payment.A", a.Name())
+	require.Same(t, a, sm.StateByName("payment.A"))
+	require.Nil(t, sm.StateByName("A"))
+}
+
+func TestNamingPolicyFromDefinition(t *testing.T) {
+	data := []byte(`{"states":[{"name":"A","transitions":[{"event":"TICK","to":"B"}]},{"name":"B","parent":"A"}]}`)
+	sm, err := fsm.LoadDefinition(data, fsm.HandlerRegistry{}, fsm.NamingPolicy(fsm.Prefix("tenant1/")))
+	require.NoError(t, err)
+
+	a := sm.StateByName("tenant1/A")
+	require.NotNil(t, a)
+	require.Same(t, a, sm.StateByName("tenant1/B").Parent())
+	next, err := sm.Fire(a, TICK)
+	require.NoError(t, err)
+	require.Equal(t, "tenant1/B", next.Name())
+}
+
+func TestRejectDuplicateStates(t *testing.T) {
+	sm := fsm.New()
+	first := sm.AddState("A")
+	require.NotSame(t, first, sm.AddState("A"))
+
+	sm = fsm.New(fsm.RejectDuplicateStates())
+	sm.AddState("A")
+	require.PanicsWithError(t, "state A is already defined", func() {
+		sm.AddState("A")
+	})
+}
+
+func TestDuplicateStatesInDefinition(t *testing.T) {
+	data := []byte(`{"states":[{"name":"A"},{"name":"A"}]}`)
+	_, err := fsm.LoadDefinition(data, fsm.HandlerRegistry{})
+	var dup *fsm.ErrDuplicateState
+	require.True(t, errors.As(err, &dup))
+	require.Equal(t, "A", dup.State())
+	require.EqualError(t, err, "1:33:states[1].name: state A is already defined")
+}
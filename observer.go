@@ -0,0 +1,106 @@
+package fsm
+
+// Observer bundles callbacks for the whole lifecycle of the transitions of a machine.
+// Returning an error from BeforeTransition vetoes the transition,
+// and from Exit or Enter aborts it, like the state handlers.
+type Observer interface {
+	// BeforeTransition is called before any handler of the transition
+	BeforeTransition(*Context) error
+	// AfterTransition is called with the transition listeners
+	AfterTransition(*Context) error
+	// Exit is called after the OnExit handler, when leaving a state
+	Exit(*Context) error
+	// Enter is called after the OnEnter handler, when entering a state
+	Enter(*Context) error
+}
+
+// BaseObserver is a no-op Observer to be embedded, so that only the callbacks of interest need to be implemented
+type BaseObserver struct{}
+
+func (BaseObserver) BeforeTransition(*Context) error { return nil }
+func (BaseObserver) AfterTransition(*Context) error  { return nil }
+func (BaseObserver) Exit(*Context) error             { return nil }
+func (BaseObserver) Enter(*Context) error            { return nil }
+
+// AddObserver registers an observer for all the transitions of the machine
+func (s *StateMachine) AddObserver(o Observer) {
+	s.mustBeMutable()
+	s.observers = append(s.observers, o)
+}
+
+// AddBeforeTransition adds a listener called BEFORE a transition happens, before any handler.
+// Returning an error vetoes the transition.
+func (s *StateMachine) AddBeforeTransition(listener OnHandler) {
+	s.mustBeMutable()
+	s.beforeListeners = append(s.beforeListeners, listener)
+}
+
+// AddObserver registers an observer for the transitions of this instance, called after the observers of the machine
+func (m *StateMachineInstance) AddObserver(o Observer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observers = append(m.observers, o)
+}
+
+// RemoveObserver unregisters an observer added with AddObserver to this instance
+func (m *StateMachineInstance) RemoveObserver(o Observer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	observers := make([]Observer, 0, len(m.observers))
+	for _, v := range m.observers {
+		if v != o {
+			observers = append(observers, v)
+		}
+	}
+	m.observers = observers
+}
+
+// AddBeforeTransition adds a listener called before the transitions of this instance,
+// after the ones of the machine. Returning an error vetoes the transition.
+func (m *StateMachineInstance) AddBeforeTransition(listener OnHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.beforeListeners = append(m.beforeListeners, listener)
+}
+
+// AddOnTransition adds a listener called after the transitions of this instance,
+// after the ones of the machine.
+func (m *StateMachineInstance) AddOnTransition(listener OnHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onTransitionListeners = append(m.onTransitionListeners, listener)
+}
+
+func (s *StateMachine) fireBeforeTransition(ctx *Context) error {
+	for _, v := range s.beforeListeners {
+		if err := v(ctx); err != nil {
+			return err
+		}
+	}
+	if ctx.instance != nil {
+		for _, v := range ctx.instance.beforeListeners {
+			if err := v(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return s.notifyObservers(Observer.BeforeTransition, ctx)
+}
+
+// observersFor returns the observers of the machine followed by the ones of the instance, if any
+func (s *StateMachine) observersFor(ctx *Context) []Observer {
+	if ctx.instance == nil || len(ctx.instance.observers) == 0 {
+		return s.observers
+	}
+	return append(s.observers[:len(s.observers):len(s.observers)], ctx.instance.observers...)
+}
+
+// notifyObservers calls the callback on every observer, stopping at the first error
+func (s *StateMachine) notifyObservers(callback func(Observer, *Context) error, ctx *Context) error {
+	for _, o := range s.observersFor(ctx) {
+		if err := callback(o, ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package fsm_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingObserver struct {
+	fsm.BaseObserver
+	calls []string
+}
+
+func (o *recordingObserver) BeforeTransition(c *fsm.Context) error {
+	o.calls = append(o.calls, fmt.Sprintf("before %s->%s", c.FromState(), c.ToState()))
+	return nil
+}
+
+func (o *recordingObserver) Exit(c *fsm.Context) error {
+	o.calls = append(o.calls, "exit "+c.FromState().Name())
+	return nil
+}
+
+func (o *recordingObserver) Enter(c *fsm.Context) error {
+	o.calls = append(o.calls, "enter "+c.ToState().Name())
+	return nil
+}
+
+func (o *recordingObserver) AfterTransition(c *fsm.Context) error {
+	o.calls = append(o.calls, fmt.Sprintf("after %s->%s", c.FromState(), c.ToState()))
+	return nil
+}
+
+func TestObserver(t *testing.T) {
+	smi, _, tracker, err := createFSM()
+	require.NoError(t, err)
+
+	o := &recordingObserver{}
+	smi.AddObserver(o)
+	require.NoError(t, smi.Fire(TICK))
+	require.NoError(t, smi.Fire(LOOP))
+	require.Equal(t, []string{
+		"before GREEN->YELLOW",
+		"exit GREEN",
+		"enter YELLOW",
+		"after GREEN->YELLOW",
+		"before YELLOW->EXIT",
+		"exit YELLOW",
+		"enter EXIT",
+		"after YELLOW->EXIT",
+	}, o.calls)
+	require.Len(t, tracker.Events(), 6)
+}
+
+func TestRemoveObserver(t *testing.T) {
+	smi, _, _, err := createFSM()
+	require.NoError(t, err)
+
+	o := &recordingObserver{}
+	kept := &recordingObserver{}
+	smi.AddObserver(o)
+	smi.AddObserver(kept)
+	require.NoError(t, smi.Fire(TICK))
+	smi.RemoveObserver(o)
+	require.NoError(t, smi.Fire(LOOP))
+	require.Len(t, o.calls, 4)
+	require.Len(t, kept.calls, 8)
+}
+
+func TestBeforeTransitionVeto(t *testing.T) {
+	smi, _, tracker, err := createFSM()
+	require.NoError(t, err)
+
+	denied := errors.New("denied")
+	smi.AddBeforeTransition(func(c *fsm.Context) error {
+		return denied
+	})
+
+	err = smi.Fire(TICK)
+	require.True(t, errors.Is(err, denied))
+	require.Equal(t, stateGreen, smi.State().Name())
+	require.Empty(t, tracker.Events())
+}
+
+func TestOnTransitionError(t *testing.T) {
+	smi, _, _, err := createFSM()
+	require.NoError(t, err)
+
+	failed := errors.New("audit failed")
+	calls := 0
+	smi.AddOnTransition(func(c *fsm.Context) error {
+		calls++
+		return failed
+	})
+	smi.AddOnTransition(func(c *fsm.Context) error {
+		calls++
+		return nil
+	})
+
+	err = smi.Fire(TICK)
+	require.True(t, errors.Is(err, failed))
+	require.Equal(t, 2, calls)
+	require.Equal(t, stateGreen, smi.State().Name())
+}
+
+func TestIgnoreListenerErrors(t *testing.T) {
+	sm := fsm.New(fsm.IgnoreListenerErrors())
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b)
+	sm.AddOnTransition(func(c *fsm.Context) error {
+		return errors.New("audit failed")
+	})
+
+	smi := sm.FromState(a)
+	require.NoError(t, smi.Fire(TICK))
+	require.Equal(t, b, smi.State())
+}
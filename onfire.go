@@ -0,0 +1,9 @@
+package fsm
+
+// OnFire option adds a listener to a transition, called when the transition is taken, before the machine listeners,
+// so that side effects of a single edge don't need to be filtered in the machine listeners.
+func OnFire(listener OnHandler) TransitionOption {
+	return func(t *transition) {
+		t.listeners = append(t.listeners, listener)
+	}
+}
@@ -0,0 +1,43 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnFire(t *testing.T) {
+	var calls []string
+	sm := fsm.New()
+	booked := sm.AddState("BOOKED")
+	cancelled := sm.AddState("CANCELLED")
+	completed := sm.AddState("COMPLETED")
+	booked.AddTransition("cancel", cancelled, fsm.OnFire(func(c *fsm.Context) error {
+		calls = append(calls, "email "+c.FromState().Name()+"->"+c.ToState().Name())
+		return nil
+	}))
+	booked.AddTransition("complete", completed)
+	sm.AddOnTransition(func(c *fsm.Context) error {
+		calls = append(calls, "global "+c.ToState().Name())
+		return nil
+	})
+
+	require.NoError(t, sm.FromState(booked).Fire("complete"))
+	require.NoError(t, sm.FromState(booked).Fire("cancel"))
+	require.Equal(t, []string{"global COMPLETED", "email BOOKED->CANCELLED", "global CANCELLED"}, calls)
+}
+
+func TestOnFireError(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b, fsm.OnFire(func(c *fsm.Context) error {
+		return errors.New("boom")
+	}))
+
+	smi := sm.FromState(a)
+	require.Error(t, smi.Fire(TICK))
+	require.Equal(t, a, smi.State())
+}
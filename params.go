@@ -0,0 +1,98 @@
+package fsm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// ParamData is the data the parameter templates are rendered with
+type ParamData struct {
+	// Event is the fired value, unwrapped from Event
+	Event interface{}
+	// Payload is the payload of the instance
+	Payload interface{}
+	Key     interface{}
+	// ID is the identifier of the instance
+	ID   string
+	From string
+	To   string
+}
+
+// handlerParams are the parameters of the handlers bound from a definition
+type handlerParams struct {
+	src       map[string]string
+	templates map[string]*template.Template
+}
+
+// parseParams parses the parameters as text/template templates
+func parseParams(params map[string]string) (*handlerParams, error) {
+	if len(params) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	p := &handlerParams{
+		src:       copyMeta(params),
+		templates: make(map[string]*template.Template, len(params)),
+	}
+	for _, name := range names {
+		t, err := template.New(name).Option("missingkey=zero").Parse(params[name])
+		if err != nil {
+			return nil, fmt.Errorf("invalid template of parameter %s: %w", name, err)
+		}
+		p.templates[name] = t
+	}
+	return p, nil
+}
+
+// bind makes the parameters available to the handler, through Context.Param
+func (p *handlerParams) bind(fn OnHandler) OnHandler {
+	if p == nil || fn == nil {
+		return fn
+	}
+	return func(c *Context) error {
+		prev := c.params
+		c.params = p
+		defer func() {
+			c.params = prev
+		}()
+		return fn(c)
+	}
+}
+
+// Param renders the template of the named parameter of the running action or state handler,
+// as declared in the params of its transition or state definition, with the ParamData of the event.
+// It returns an empty string if there is no such parameter, for example:
+//
+//	{"event": "CANCEL", "action": "sendEmail", "params": {"template": "cancelled", "subject": "Order {{.Event.ID}} cancelled"}}
+func (c *Context) Param(name string) (string, error) {
+	if c.params == nil {
+		return "", nil
+	}
+	t, ok := c.params.templates[name]
+	if !ok {
+		return "", nil
+	}
+	data := ParamData{
+		Event:   c.payload(),
+		Payload: c.Payload(),
+		Key:     c.Key(),
+		ID:      c.InstanceID(),
+	}
+	if c.from != nil {
+		data.From = c.from.name
+	}
+	if c.to != nil {
+		data.To = c.to.name
+	}
+	var buf strings.Builder
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("rendering parameter %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,67 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+type cancellation struct {
+	ID string
+}
+
+func (cancellation) Kind() interface{} {
+	return "CANCEL"
+}
+
+const paramsDefinitionJSON = `{"states":[` +
+	`{"name":"OPEN","transitions":[{"to":"CANCELLED","event":"CANCEL"},{"event":"REMIND","action":"sendEmail","params":{"template":"reminder"}}]},` +
+	`{"name":"CANCELLED","onEnter":"sendEmail","params":{"template":"cancelled","subject":"Order {{.Event.ID}} {{.To}}"}}]}`
+
+func TestDefinitionParams(t *testing.T) {
+	var sent []string
+	registry := fsm.HandlerRegistry{
+		Handlers: map[string]fsm.OnHandler{
+			"sendEmail": func(c *fsm.Context) error {
+				tmpl, err := c.Param("template")
+				if err != nil {
+					return err
+				}
+				subject, err := c.Param("subject")
+				if err != nil {
+					return err
+				}
+				sent = append(sent, tmpl+": "+subject)
+				return nil
+			},
+		},
+	}
+	sm, err := fsm.LoadDefinition([]byte(paramsDefinitionJSON), registry)
+	require.NoError(t, err)
+
+	smi, err := sm.FromStateName("OPEN")
+	require.NoError(t, err)
+	require.NoError(t, smi.Fire("REMIND"))
+	require.NoError(t, smi.Fire(cancellation{ID: "42"}))
+	require.Equal(t, []string{"reminder: ", "cancelled: Order 42 CANCELLED"}, sent)
+
+	data, err := sm.MarshalDefinition()
+	require.NoError(t, err)
+	require.JSONEq(t, paramsDefinitionJSON, string(data))
+}
+
+func TestDefinitionParamsErrors(t *testing.T) {
+	registry := fsm.HandlerRegistry{
+		Handlers: map[string]fsm.OnHandler{
+			"sendEmail": func(*fsm.Context) error { return nil },
+		},
+	}
+	_, err := fsm.LoadDefinition([]byte(`{"states":[{"name":"A","onEnter":"sendEmail","params":{"subject":"{{.Event"}}]}`), registry)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "states[0].params: invalid template of parameter subject")
+
+	_, err = fsm.LoadDefinition([]byte(`{"states":[{"name":"A","transitions":[{"to":"A","event":"E","params":{"template":"x"}}]}]}`), registry)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "states[0].transitions[0]: parameters without an action on state A")
+}
@@ -0,0 +1,59 @@
+package fsm
+
+// CanFire checks if the event would be accepted on the current state, without executing any handler
+func (m *StateMachineInstance) CanFire(key interface{}) bool {
+	_, err := m.PeekTransition(key)
+	return err == nil
+}
+
+// PeekTransition returns the state the instance would move to if the event was fired, without executing any handler.
+// Conditions and fallback resolvers are evaluated, so they should be free of side effects.
+// It returns the current state for internal transitions, deferred events and events handled by a running sub-machine,
+// and the same errors as Fire when no transition matches.
+// Quotas are not checked, so the state may still be refused when the event is fired.
+func (m *StateMachineInstance) PeekTransition(key interface{}) (*State, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, _, err := m.unwrapIdempotent(key)
+	if err != nil {
+		return nil, err
+	}
+	if m.currentState.defers(key) {
+		return m.currentState, nil
+	}
+	if m.sub != nil {
+		if _, err := m.sub.PeekTransition(key); err == nil {
+			return m.currentState, nil
+		}
+	}
+	return m.StateMachine.peek(m.currentState, &Context{
+		machine:  m.StateMachine,
+		instance: m,
+		event:    toEventer(key),
+	})
+}
+
+// peek resolves the target of the event, like fire, without calling any handler
+func (s *StateMachine) peek(state *State, ctx *Context) (*State, error) {
+	if state.final {
+		return nil, ErrMachineCompleted
+	}
+	t, err := s.matchHierarchy(state, ctx)
+	if err != nil {
+		return nil, err
+	}
+	var nextState *State
+	if t != nil {
+		if t.action != nil {
+			return state, nil
+		}
+		nextState = t.state
+	} else {
+		nextState = s.resolveFallback(ctx)
+	}
+	if nextState == nil {
+		return nil, &ErrTransitionNotFound{state: state.name, key: ctx.Key()}
+	}
+	return s.resolveChoice(s.resolveTarget(nextState, ctx), ctx)
+}
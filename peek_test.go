@@ -0,0 +1,49 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeekTransition(t *testing.T) {
+	calls := 0
+	count := func(c *fsm.Context) error {
+		calls++
+		return nil
+	}
+	sm := fsm.New()
+	draft := sm.AddState("DRAFT", fsm.OnExit(count))
+	review := sm.AddState("REVIEW", fsm.OnEnter(count))
+	published := sm.AddState("PUBLISHED", fsm.OnEnter(count), fsm.Final())
+	draft.AddTransition("submit", review)
+	draft.AddInternalTransition("edit", count)
+	review.AddConditionalTransition("approved", published, func(c *fsm.Context) bool {
+		return c.Key() == "approve"
+	})
+
+	smi := sm.FromState(draft)
+	next, err := smi.PeekTransition("submit")
+	require.NoError(t, err)
+	require.Equal(t, review, next)
+	next, err = smi.PeekTransition("edit")
+	require.NoError(t, err)
+	require.Equal(t, draft, next)
+	require.True(t, smi.CanFire("submit"))
+	require.False(t, smi.CanFire("approve"))
+	_, err = smi.PeekTransition("approve")
+	require.ErrorIs(t, err, fsm.ErrUnknownTransition)
+	require.Equal(t, draft, smi.State())
+	require.Zero(t, calls)
+
+	smi = sm.FromState(review)
+	next, err = smi.PeekTransition("approve")
+	require.NoError(t, err)
+	require.Equal(t, published, next)
+
+	smi = sm.FromState(published)
+	_, err = smi.PeekTransition("submit")
+	require.ErrorIs(t, err, fsm.ErrMachineCompleted)
+	require.Zero(t, calls)
+}
@@ -0,0 +1,46 @@
+package fsm
+
+import "time"
+
+// periodicEvent is an event fired regularly while a state is the current one
+type periodicEvent struct {
+	interval time.Duration
+	event    interface{}
+}
+
+// Every fires the event into started instances every interval while the state is their current state,
+// like for polling. The schedule starts when the state is entered and stops when it is left,
+// and is not restarted by internal or self-transitions, so the event is usually handled by an internal transition.
+// Errors firing the event are passed to the onError of Start, and do not stop the schedule.
+func (s *State) Every(interval time.Duration, event interface{}) *State {
+	s.machine.mustBeMutable()
+	if interval <= 0 {
+		panic("fsm: the interval of a periodic event must be positive")
+	}
+	s.periodic = append(s.periodic, periodicEvent{interval: interval, event: event})
+	return s
+}
+
+// schedulePeriodic fires the event every interval, while the timers are not rescheduled.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) schedulePeriodic(gen uint64, p periodicEvent) {
+	sc := &m.scheduler
+	onError := sc.onError
+	var t *time.Timer
+	t = time.AfterFunc(p.interval, func() {
+		if !m.chaos.dropTimer(m.rnd) {
+			err := m.fireWhen(nil, func() bool {
+				return sc.gen == gen
+			}, p.event)
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if sc.gen == gen {
+			t.Reset(p.interval)
+		}
+	})
+	sc.timers = append(sc.timers, t)
+}
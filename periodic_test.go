@@ -0,0 +1,83 @@
+package fsm_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvery(t *testing.T) {
+	var polls int32
+	sm := fsm.New()
+	waiting := sm.AddState("WAITING").Every(5*time.Millisecond, "POLL")
+	done := sm.AddState("DONE")
+	waiting.AddInternalTransition("POLL", func(c *fsm.Context) error {
+		if atomic.AddInt32(&polls, 1) == 3 {
+			return c.Fire("READY")
+		}
+		return nil
+	})
+	waiting.AddTransition("READY", done)
+
+	smi := sm.FromState(waiting)
+	time.Sleep(20 * time.Millisecond)
+	require.Zero(t, atomic.LoadInt32(&polls), "only started instances are polled")
+
+	smi.Start(nil)
+	defer smi.Stop()
+	require.Eventually(t, func() bool {
+		return smi.State() == done
+	}, time.Second, time.Millisecond)
+
+	// leaving the state stops the schedule
+	time.Sleep(20 * time.Millisecond)
+	require.EqualValues(t, 3, atomic.LoadInt32(&polls))
+}
+
+func TestEveryStop(t *testing.T) {
+	var polls int32
+	sm := fsm.New()
+	waiting := sm.AddState("WAITING").Every(5*time.Millisecond, "POLL")
+	waiting.AddInternalTransition("POLL", func(c *fsm.Context) error {
+		atomic.AddInt32(&polls, 1)
+		return nil
+	})
+
+	smi := sm.FromState(waiting)
+	smi.Start(nil)
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&polls) >= 2
+	}, time.Second, time.Millisecond)
+	smi.Stop()
+	time.Sleep(10 * time.Millisecond)
+	n := atomic.LoadInt32(&polls)
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, n, atomic.LoadInt32(&polls))
+}
+
+func TestEveryErrors(t *testing.T) {
+	sm := fsm.New()
+	waiting := sm.AddState("WAITING").Every(5*time.Millisecond, "POLL")
+
+	errs := make(chan error, 10)
+	smi := sm.FromState(waiting)
+	smi.Start(func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	defer smi.Stop()
+	// unhandled events are reported, without stopping the schedule
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			require.ErrorIs(t, err, fsm.ErrUnknownTransition)
+		case <-time.After(time.Second):
+			t.Fatal("no error reported")
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package fsm
+
+import "fmt"
+
+// PermittedEvent describes an event accepted by a state, so that it can be offered to end users
+type PermittedEvent struct {
+	Key interface{}
+	// Transition is the name of the transition handling the event
+	Transition string
+	// To is the target state of the transition
+	To *State
+	// Labels are the display labels by language tag
+	Labels map[string]string
+	// Schema describes the required payload, like a JSON schema
+	Schema string
+	// Roles are the roles required to fire the event
+	Roles []string
+}
+
+// Label returns the display label for the language, falling back to the default language ("")
+// and then to the event key.
+func (p PermittedEvent) Label(lang string) string {
+	if l, ok := p.Labels[lang]; ok {
+		return l
+	}
+	if l, ok := p.Labels[""]; ok {
+		return l
+	}
+	return fmt.Sprintf("%+v", p.Key)
+}
+
+// WithLabel option sets the display label of the event of a transition, for a language tag, like "en" or "pt-PT".
+// The empty language is the default label.
+func WithLabel(lang, label string) TransitionOption {
+	return func(t *transition) {
+		t.labels = setMeta(t.labels, lang, label)
+	}
+}
+
+// WithPayloadSchema option sets the description of the payload required by the event of a transition
+func WithPayloadSchema(schema string) TransitionOption {
+	return func(t *transition) {
+		t.schema = schema
+	}
+}
+
+// WithRoles option sets the roles required to fire the event of a transition
+func WithRoles(roles ...string) TransitionOption {
+	return func(t *transition) {
+		t.roles = append([]string(nil), roles...)
+	}
+}
+
+// PermittedEvents returns the events with a key transition on this state, in transition order,
+// followed by the ones inherited from its ancestors and from the global transitions.
+// Conditional, fallback and timeout transitions are not included.
+func (s *State) PermittedEvents() []PermittedEvent {
+	var events []PermittedEvent
+	var keys []interface{}
+	add := func(transitions []*transition) {
+		for _, t := range transitions {
+			if t.key == nil {
+				continue
+			}
+			key := s.machine.normalizeKey(t.key)
+			if containsKey(s.machine, keys, key) {
+				// overridden
+				continue
+			}
+			keys = append(keys, key)
+			events = append(events, t.permittedEvent())
+		}
+	}
+	for st := s; st != nil; st = st.parent {
+		add(st.transitions)
+	}
+	if !s.final {
+		add(s.machine.globalTransitions())
+	}
+	return events
+}
+
+func containsKey(sm *StateMachine, keys []interface{}, key interface{}) bool {
+	for _, k := range keys {
+		if sm.keysEqual(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *transition) permittedEvent() PermittedEvent {
+	return PermittedEvent{
+		Key:        t.key,
+		Transition: t.name,
+		To:         t.state,
+		Labels:     copyMeta(t.labels),
+		Schema:     t.schema,
+		Roles:      append([]string(nil), t.roles...),
+	}
+}
+
+// PermittedEvents returns the events permitted on the current state.
+// When the state runs a sub-machine, the events permitted by the sub-machine come first,
+// followed by the ones of the state that they do not shadow.
+func (m *StateMachineInstance) PermittedEvents() []PermittedEvent {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	events := m.currentState.PermittedEvents()
+	if m.sub == nil {
+		return events
+	}
+	inner := m.sub.PermittedEvents()
+	keys := make([]interface{}, len(inner))
+	for i, e := range inner {
+		keys[i] = m.sub.normalizeKey(e.Key)
+	}
+	for _, e := range events {
+		if !containsKey(m.sub.StateMachine, keys, m.sub.normalizeKey(e.Key)) {
+			inner = append(inner, e)
+		}
+	}
+	return inner
+}
@@ -0,0 +1,54 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPermittedEvents(t *testing.T) {
+	sm := fsm.New()
+	booked := sm.AddState("BOOKED")
+	cancelled := sm.AddState("CANCELLED")
+	completed := sm.AddState("COMPLETED")
+	booked.AddTransition("cancel", cancelled,
+		fsm.WithLabel("", "Cancel"),
+		fsm.WithLabel("pt", "Cancelar"),
+		fsm.WithRoles("customer", "admin"),
+	)
+	booked.AddTransition("complete", completed,
+		fsm.WithPayloadSchema(`{"type":"object","properties":{"fare":{"type":"integer"}}}`),
+	)
+	booked.AddFallbackTransition(booked)
+
+	events := sm.FromState(booked).PermittedEvents()
+	require.Len(t, events, 2)
+	require.Equal(t, "cancel", events[0].Key)
+	require.Equal(t, "Cancelar", events[0].Label("pt"))
+	require.Equal(t, "Cancel", events[0].Label("en"))
+	require.Equal(t, []string{"customer", "admin"}, events[0].Roles)
+	require.Equal(t, "complete", events[1].Label("en"))
+	require.Contains(t, events[1].Schema, "fare")
+	require.Empty(t, cancelled.PermittedEvents())
+}
+
+func TestPermittedEventsOfSubMachine(t *testing.T) {
+	sm := fsm.New()
+	cart := sm.AddState("CART")
+	payment := sm.AddSubMachineState("PAYMENT", paymentMachine(), map[string]interface{}{"PAID": "PAYMENT_DONE"})
+	shipping := sm.AddState("SHIPPING")
+	cart.AddTransition(SUBMIT, payment)
+	payment.AddTransition("PAYMENT_DONE", shipping)
+	payment.AddTransition(CANCEL, cart)
+
+	smi := sm.FromState(cart)
+	require.NoError(t, smi.Fire(SUBMIT))
+	var keys []interface{}
+	for _, e := range smi.PermittedEvents() {
+		keys = append(keys, e.Key)
+	}
+	// CANCEL is handled by the sub-machine
+	require.Equal(t, []interface{}{APPROVE, CANCEL, "PAYMENT_DONE"}, keys)
+	require.Equal(t, "FAILED", smi.PermittedEvents()[1].To.Name())
+}
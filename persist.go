@@ -0,0 +1,69 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+)
+
+// AutoPersist saves the instance, keyed by its ID, after every Fire with a successful transition,
+// expecting the stored version to be the given one. Saving errors, like ErrVersionConflict, are returned by the Fire,
+// and the instance is restored as it was before the Fire. After a conflict, the instance should then be reloaded.
+func (m *StateMachineInstance) AutoPersist(store Store, version int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = store
+	m.version = version
+}
+
+// Version returns the stored version of an instance with AutoPersist
+func (m *StateMachineInstance) Version() int64 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.version
+}
+
+// persist saves the instance, if AutoPersist is set.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) persist(goCtx context.Context) error {
+	if m.store == nil {
+		return nil
+	}
+	if goCtx == nil {
+		goCtx = context.Background()
+	}
+	if err := m.store.Save(goCtx, m.id, m.snapshot(), m.version); err != nil {
+		return fmt.Errorf("unable to persist instance %s: %w", m.id, err)
+	}
+	m.version++
+	return nil
+}
+
+// Create creates an instance in the state, with the ID, saves it in the store and persists it after every transition
+func (s *StateMachine) Create(ctx context.Context, store Store, id string, state *State) (*StateMachineInstance, error) {
+	m := s.FromState(state)
+	m.SetID(id)
+	m.store = store
+	if err := m.persist(ctx); err != nil {
+		return nil, err
+	}
+	if err := m.syncTimers(ctx); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Load restores the instance with the ID from the store and persists it after every transition
+func (s *StateMachine) Load(ctx context.Context, store Store, id string) (*StateMachineInstance, error) {
+	snap, version, err := store.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.Restore(snap)
+	if err != nil {
+		return nil, err
+	}
+	m.SetID(id)
+	m.store = store
+	m.version = version
+	return m, nil
+}
@@ -0,0 +1,69 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoPersist(t *testing.T) {
+	ctx := context.Background()
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b)
+	b.AddTransition(TICK, a)
+
+	store := fsm.NewMemoryStore()
+	smi, err := sm.Create(ctx, store, "order-1", a)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, smi.Version())
+
+	require.NoError(t, smi.Fire(TICK))
+	snap, version, err := store.Load(ctx, "order-1")
+	require.NoError(t, err)
+	require.Equal(t, "B", snap.State)
+	require.EqualValues(t, 2, version)
+
+	// a concurrent writer
+	other, err := sm.Load(ctx, store, "order-1")
+	require.NoError(t, err)
+	require.Equal(t, b, other.State())
+	require.NoError(t, other.Fire(TICK))
+
+	err = smi.Fire(TICK)
+	require.ErrorIs(t, err, fsm.ErrVersionConflict)
+	// the instance is restored as it was before the Fire
+	require.Equal(t, b, smi.State())
+	require.EqualValues(t, 2, smi.Version())
+
+	// unknown events do not persist
+	require.Error(t, other.Fire(LOOP))
+	_, version, err = store.Load(ctx, "order-1")
+	require.NoError(t, err)
+	require.EqualValues(t, 3, version)
+}
+
+func TestFailedSaveRestoresInstance(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	c := sm.AddState("C")
+	a.AddTransition(TICK, b)
+	b.AddTransition(TICK, c)
+
+	smi, err := sm.Create(context.Background(), fsm.NewMemoryStore(), "order-1", a)
+	require.NoError(t, err)
+	smi.AutoPersist(failingStore{fsm.NewMemoryStore()}, smi.Version())
+
+	require.Error(t, smi.Fire(TICK))
+	require.Equal(t, a, smi.State())
+	require.Empty(t, smi.History())
+
+	n, err := smi.FireAll(TICK, TICK)
+	require.Error(t, err)
+	require.Zero(t, n)
+	require.Equal(t, a, smi.State())
+}
@@ -0,0 +1,92 @@
+package fsm
+
+import (
+	"sync"
+	"time"
+)
+
+// InstancePool recycles the instances of a machine, for workloads creating
+// many short-lived instances, like per-request protocol machines.
+// It is safe for concurrent use.
+type InstancePool struct {
+	machine *StateMachine
+	pool    sync.Pool
+}
+
+// NewInstancePool creates a pool of instances of the machine
+func NewInstancePool(machine *StateMachine) *InstancePool {
+	p := &InstancePool{
+		machine: machine,
+	}
+	p.pool.New = func() interface{} {
+		return &StateMachineInstance{}
+	}
+	return p
+}
+
+// Get returns an instance positioned in the state, as if created with FromState.
+func (p *InstancePool) Get(state *State) *StateMachineInstance {
+	m := p.pool.Get().(*StateMachineInstance)
+	m.reset(p.machine, state)
+	p.machine.monitor.track(m.id, state)
+	if p.machine.metrics != nil {
+		p.machine.metrics.Occupancy(state.name, 1)
+	}
+	return m
+}
+
+// Put returns the instance to the pool, stopping any pending timeout.
+// The instance must not be used afterwards.
+func (p *InstancePool) Put(m *StateMachineInstance) {
+	m.Stop()
+	m.mu.Lock()
+	m.monitor.untrack(m.id)
+	m.monitor.deferredChanged(-len(m.deferred))
+	if m.metrics != nil {
+		m.metrics.Occupancy(m.currentState.name, -1)
+	}
+	m.mu.Unlock()
+	p.pool.Put(m)
+}
+
+// reset reinitializes the instance, reusing its memory
+func (m *StateMachineInstance) reset(machine *StateMachine, state *State) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.StateMachine = machine
+	m.id = newInstanceID()
+	m.payload = nil
+	m.values = nil
+	m.throttles = nil
+	m.Purge(nil)
+	m.currentState = state
+	m.createdAt = time.Now()
+	m.stats = dwellStats{}
+	m.steps = 0
+	m.scheduler = scheduler{}
+	m.history = history{}
+	m.deferred = m.deferred[:0]
+	m.lastActive = nil
+	m.fallbackResolvers = nil
+	m.deadlines = nil
+	m.onTransitionListeners = nil
+	m.beforeListeners = nil
+	m.observers = nil
+	m.sagaTrail = nil
+	m.store = nil
+	m.seen = seenKeys{}
+	m.version = 0
+	m.timerOps = nil
+	m.slot = nil
+	if m.slots == nil {
+		m.slots = &slotJournal{}
+	}
+	m.slots.ops = m.slots.ops[:0]
+	if m.logs == nil {
+		m.logs = &logJournal{}
+	}
+	m.logs.entries = nil
+	m.stopAsync()
+	m.startSub()
+	m.enterDeadline(nil)
+}
@@ -0,0 +1,48 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstancePool(t *testing.T) {
+	smi, states, _, err := createFSM()
+	require.NoError(t, err)
+	pool := fsm.NewInstancePool(smi.StateMachine)
+
+	m := pool.Get(states.green)
+	require.NoError(t, m.Fire(TICK))
+	require.Equal(t, states.yellow, m.State())
+	require.Equal(t, 1, m.Steps())
+	pool.Put(m)
+
+	m = pool.Get(states.red)
+	require.Equal(t, states.red, m.State())
+	require.Equal(t, 0, m.Steps())
+	require.NoError(t, m.Fire(TICK))
+	require.Equal(t, states.green, m.State())
+}
+
+var sinkInstance *fsm.StateMachineInstance
+
+func BenchmarkFromState(b *testing.B) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sinkInstance = sm.FromState(a)
+	}
+}
+
+func BenchmarkInstancePool(b *testing.B) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	pool := fsm.NewInstancePool(sm)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		sinkInstance = pool.Get(a)
+		pool.Put(sinkInstance)
+	}
+}
@@ -0,0 +1,134 @@
+package fsm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// DefinitionError is an error building a machine from a definition, pointing at the offending element
+type DefinitionError struct {
+	// File is the definition file, if loaded from a file
+	File string
+	// Line and Column are the 1-based position of the element in the source, if loaded from a source
+	Line   int
+	Column int
+	// Path is the path of the element in the definition, like states[1].transitions[0]
+	Path string
+	Err  error
+}
+
+func (e *DefinitionError) Error() string {
+	var b bytes.Buffer
+	if e.File != "" {
+		b.WriteString(e.File + ":")
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "%d:%d:", e.Line, e.Column)
+	}
+	if e.Path != "" {
+		b.WriteString(e.Path + ":")
+	}
+	if b.Len() > 0 {
+		b.WriteString(" ")
+	}
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *DefinitionError) Unwrap() error {
+	return e.Err
+}
+
+func atPath(path string, err error) error {
+	return &DefinitionError{Path: path, Err: err}
+}
+
+// locate sets the source position of the element of a DefinitionError
+func locate(file string, data []byte, err error) error {
+	var defErr *DefinitionError
+	if !errors.As(err, &defErr) {
+		return err
+	}
+	defErr.File = file
+	positions := map[string]int64{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if walkJSON(dec, data, "", positions) == nil {
+		if off, ok := positions[defErr.Path]; ok {
+			defErr.Line, defErr.Column = lineColumn(data, off)
+		}
+	}
+	return defErr
+}
+
+// syntaxError converts a JSON decoding error into a DefinitionError with its position
+func syntaxError(file string, data []byte, err error) error {
+	defErr := &DefinitionError{File: file, Err: err}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		defErr.Line, defErr.Column = lineColumn(data, syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		defErr.Path = typeErr.Field
+		defErr.Line, defErr.Column = lineColumn(data, typeErr.Offset)
+	}
+	return defErr
+}
+
+// walkJSON records the offset of every value, by path
+func walkJSON(dec *json.Decoder, data []byte, path string, positions map[string]int64) error {
+	positions[path] = valueStart(data, dec.InputOffset())
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			child := key.(string)
+			if path != "" {
+				child = path + "." + child
+			}
+			if err := walkJSON(dec, data, child, positions); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := walkJSON(dec, data, fmt.Sprintf("%s[%d]", path, i), positions); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+	}
+	return err
+}
+
+// valueStart skips the separators preceding a value
+func valueStart(data []byte, off int64) int64 {
+	for off < int64(len(data)) {
+		switch data[off] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			off++
+		default:
+			return off
+		}
+	}
+	return off
+}
+
+func lineColumn(data []byte, off int64) (int, int) {
+	if off > int64(len(data)) {
+		off = int64(len(data))
+	}
+	before := data[:off]
+	line := bytes.Count(before, []byte("\n")) + 1
+	return line, int(off) - bytes.LastIndexByte(before, '\n')
+}
@@ -0,0 +1,59 @@
+package fsm_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+const badDefinition = `{
+  "states": [
+    {"name": "A", "transitions": [{"to": "B", "event": "tick"}]},
+    {
+      "name": "B",
+      "transitions": [
+        {"to": "A", "event": "tick"},
+        {"to": "MISSING", "event": "loop"}
+      ]
+    }
+  ]
+}`
+
+func TestDefinitionErrorPosition(t *testing.T) {
+	_, err := fsm.LoadDefinition([]byte(badDefinition), fsm.HandlerRegistry{})
+	var defErr *fsm.DefinitionError
+	require.True(t, errors.As(err, &defErr))
+	require.Equal(t, "states[1].transitions[1]", defErr.Path)
+	require.Equal(t, 8, defErr.Line)
+	require.Equal(t, 9, defErr.Column)
+	require.ErrorIs(t, err, fsm.ErrUnknownState)
+	require.Equal(t, "8:9:states[1].transitions[1]: unable to find state: MISSING", err.Error())
+}
+
+func TestDefinitionErrorFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "order.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"states": [{"name": "A", "onEnter": "missing"}]}`), 0o644))
+
+	_, err := fsm.LoadDefinitionFile(file, fsm.HandlerRegistry{})
+	var defErr *fsm.DefinitionError
+	require.True(t, errors.As(err, &defErr))
+	require.Equal(t, file, defErr.File)
+	require.Equal(t, "states[0].onEnter", defErr.Path)
+	require.Equal(t, 1, defErr.Line)
+	require.Equal(t, 38, defErr.Column)
+}
+
+func TestDefinitionSyntaxErrorPosition(t *testing.T) {
+	_, err := fsm.LoadDefinition([]byte("{\n  \"states\": [\n    {\"name\": 1}\n  ]\n}"), fsm.HandlerRegistry{})
+	var defErr *fsm.DefinitionError
+	require.True(t, errors.As(err, &defErr))
+	require.Equal(t, 3, defErr.Line)
+
+	_, err = fsm.LoadDefinition([]byte("{\n  \"states\": [\n    {\"name\" \"A\"}\n  ]\n}"), fsm.HandlerRegistry{})
+	require.True(t, errors.As(err, &defErr))
+	require.Equal(t, 3, defErr.Line)
+}
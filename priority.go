@@ -0,0 +1,107 @@
+package fsm
+
+import "fmt"
+
+type ErrAmbiguousTransition struct {
+	state       string
+	key         interface{}
+	transitions []string
+}
+
+func (e *ErrAmbiguousTransition) Error() string {
+	return fmt.Sprintf("ambiguous transitions on state '%s' for %+v: %v", e.state, e.key, e.transitions)
+}
+
+func (e *ErrAmbiguousTransition) State() string {
+	return e.state
+}
+
+func (e *ErrAmbiguousTransition) Key() interface{} {
+	return e.key
+}
+
+// Transitions returns the names of the matching transitions
+func (e *ErrAmbiguousTransition) Transitions() []string {
+	return e.transitions
+}
+
+// TransitionOption configures a transition
+type TransitionOption func(*transition)
+
+// WithPriority option sets the priority of a transition.
+// When the conditions of several transitions match, the one with the highest priority wins.
+// Transitions with the same priority are evaluated in the order they were added. The default priority is zero.
+func WithPriority(priority int) TransitionOption {
+	return func(t *transition) {
+		t.priority = priority
+	}
+}
+
+// StrictMatching option makes Fire fail with ErrAmbiguousTransition when more than one transition,
+// with the winning priority, matches the event. Fallback transitions are not considered ambiguous.
+func StrictMatching() func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.strictMatching = true
+	}
+}
+
+// addTransition applies the options and inserts the transition, keeping the transitions ordered by priority
+func (s *State) addTransition(t *transition, opts []TransitionOption) *State {
+	s.machine.mustBeMutable()
+	for _, o := range opts {
+		o(t)
+	}
+	s.checkOwned(t)
+	if s.machine.rejectDuplicateTransitions {
+		if err := s.checkDuplicate(t); err != nil {
+			panic(err)
+		}
+	}
+	idx := len(s.transitions)
+	for k, v := range s.transitions {
+		if v.priority < t.priority {
+			idx = k
+			break
+		}
+	}
+	s.transitions = append(s.transitions, nil)
+	copy(s.transitions[idx+1:], s.transitions[idx:])
+	s.transitions[idx] = t
+	return s
+}
+
+// match returns the winning transition for the event, or nil if none matches
+func (s *StateMachine) match(state *State, ctx *Context) (*transition, error) {
+	var matched *transition
+	for _, t := range state.transitions {
+		if matched != nil && (t.priority < matched.priority || t.fallback) {
+			break
+		}
+		ctx.candidate = t
+		ok := s.evalGuard(state, t, ctx)
+		ctx.candidate = nil
+		if !ok {
+			// key mismatches are not worth logging
+			if t.key == nil && s.logger != nil {
+				s.debug("fsm: condition false", "state", state.name, "transition", t.name, "event", ctx.Key())
+			}
+			continue
+		}
+		if matched == nil {
+			if s.logger != nil {
+				s.debug("fsm: transition matched", "state", state.name, "transition", t.name, "event", ctx.Key(), "to", t.state.name)
+			}
+			matched = t
+			if !s.strictMatching || t.fallback {
+				break
+			}
+			continue
+		}
+		return nil, &ErrAmbiguousTransition{
+			state:       state.name,
+			key:         ctx.Key(),
+			transitions: []string{matched.name, t.name},
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,58 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func always(*fsm.Context) bool {
+	return true
+}
+
+func TestPriority(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	low := sm.AddState("LOW")
+	high := sm.AddState("HIGH")
+	a.AddConditionalTransition("low", low, always)
+	a.AddConditionalTransition("high", high, always, fsm.WithPriority(10))
+
+	smi := sm.FromState(a)
+	require.NoError(t, smi.Fire(TICK))
+	require.Equal(t, high, smi.State())
+}
+
+func TestStrictMatching(t *testing.T) {
+	sm := fsm.New(fsm.StrictMatching())
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	c := sm.AddState("C")
+	a.AddConditionalTransition("b", b, always)
+	a.AddConditionalTransition("c", c, always)
+
+	err := sm.FromState(a).Fire(TICK)
+	var ambiguous *fsm.ErrAmbiguousTransition
+	require.ErrorAs(t, err, &ambiguous)
+	require.Equal(t, []string{"b", "c"}, ambiguous.Transitions())
+
+	// priority disambiguates
+	d := sm.AddState("D")
+	a.AddConditionalTransition("d", d, always, fsm.WithPriority(1))
+	smi := sm.FromState(a)
+	require.NoError(t, smi.Fire(TICK))
+	require.Equal(t, d, smi.State())
+}
+
+func TestStrictMatchingIgnoresFallback(t *testing.T) {
+	sm := fsm.New(fsm.StrictMatching())
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b)
+	a.AddFallbackTransition(a)
+
+	m := sm.FromState(a)
+	require.NoError(t, m.Fire(TICK))
+	require.Equal(t, b, m.State())
+}
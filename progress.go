@@ -0,0 +1,30 @@
+package fsm
+
+import "time"
+
+// StepBudget option sets the expected maximum number of transitions for an instance.
+// When an instance goes over the budget, the handler is called once, after the Fire that crossed it.
+// This is useful to flag runaway loops caused by misconfigured chained events.
+func StepBudget(budget int, handler func(*StateMachineInstance)) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.stepBudget = budget
+		s.onStepBudgetExceeded = handler
+	}
+}
+
+// budgetCrossed reports if the step budget was crossed since the before step count
+func (m *StateMachineInstance) budgetCrossed(before int) bool {
+	return m.onStepBudgetExceeded != nil && before <= m.stepBudget && m.steps > m.stepBudget
+}
+
+// Steps returns the number of transitions, including chained ones, executed by this instance
+func (m *StateMachineInstance) Steps() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.steps
+}
+
+// Age returns the time elapsed since this instance was created
+func (m *StateMachineInstance) Age() time.Duration {
+	return time.Since(m.createdAt)
+}
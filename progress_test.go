@@ -0,0 +1,36 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSteps(t *testing.T) {
+	smi, _, _, err := createFSM()
+	require.NoError(t, err)
+
+	smi.Fire(TICK)
+	require.Equal(t, 1, smi.Steps())
+
+	// YELLOW -> BOUNCE -> RED
+	smi.Fire(TICK)
+	require.Equal(t, 3, smi.Steps())
+	require.True(t, smi.Age() > 0)
+}
+
+func TestStepBudget(t *testing.T) {
+	var exceeded []int
+	sm := fsm.New(fsm.StepBudget(2, func(smi *fsm.StateMachineInstance) {
+		exceeded = append(exceeded, smi.Steps())
+	}))
+	loop := sm.AddState("LOOP")
+	loop.AddTransition(LOOP, loop)
+
+	smi := sm.FromState(loop)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, smi.Fire(LOOP))
+	}
+	require.Equal(t, []int{3}, exceeded)
+}
@@ -0,0 +1,98 @@
+package fsm
+
+import "sync"
+
+// PendingEvent is an event posted to an instance, waiting to be fired
+type PendingEvent struct {
+	Key      interface{}
+	Priority int
+}
+
+// eventQueue holds the posted events of an instance, by descending priority and then in arrival order
+type eventQueue struct {
+	mu       sync.Mutex
+	events   []PendingEvent
+	draining bool
+}
+
+// Post queues the event to be fired in the background, with the default priority 0, and returns immediately.
+// Posted events are fired one at a time, in arrival order, after the events posted with a higher priority.
+// Errors firing them are passed to the onError of Start, if any.
+func (m *StateMachineInstance) Post(key interface{}) {
+	m.PostPriority(key, 0)
+}
+
+// PostPriority is like Post, firing the event before the pending events with a lower priority,
+// so that an urgent event, like an ABORT, does not wait for the queued ones.
+// The event being fired is not interrupted.
+func (m *StateMachineInstance) PostPriority(key interface{}, priority int) {
+	q := &m.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i := len(q.events)
+	for i > 0 && q.events[i-1].Priority < priority {
+		i--
+	}
+	q.events = append(q.events, PendingEvent{})
+	copy(q.events[i+1:], q.events[i:])
+	q.events[i] = PendingEvent{Key: key, Priority: priority}
+	if !q.draining {
+		q.draining = true
+		go m.drain()
+	}
+}
+
+// Pending returns the posted events not yet fired, in the order they will be fired
+func (m *StateMachineInstance) Pending() []PendingEvent {
+	q := &m.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]PendingEvent(nil), q.events...)
+}
+
+// Purge removes the posted events not yet fired that match, or all of them if match is nil,
+// returning how many were removed.
+func (m *StateMachineInstance) Purge(match func(PendingEvent) bool) int {
+	q := &m.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kept := q.events[:0]
+	for _, e := range q.events {
+		if match == nil || match(e) {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	purged := len(q.events) - len(kept)
+	for i := len(kept); i < len(q.events); i++ {
+		q.events[i] = PendingEvent{}
+	}
+	q.events = kept
+	return purged
+}
+
+// drain fires the posted events until there are none left
+func (m *StateMachineInstance) drain() {
+	q := &m.queue
+	for {
+		q.mu.Lock()
+		if len(q.events) == 0 {
+			q.draining = false
+			q.mu.Unlock()
+			return
+		}
+		e := q.events[0]
+		q.events[0] = PendingEvent{}
+		q.events = q.events[1:]
+		q.mu.Unlock()
+
+		if err := m.Fire(e.Key); err != nil {
+			m.mu.RLock()
+			onError := m.scheduler.onError
+			m.mu.RUnlock()
+			if onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
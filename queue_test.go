@@ -0,0 +1,65 @@
+package fsm_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostPriority(t *testing.T) {
+	sm := fsm.New()
+	idle := sm.AddState("IDLE")
+	aborted := sm.AddState("ABORTED")
+	release := make(chan struct{})
+	var mu sync.Mutex
+	var fired []string
+	record := func(c *fsm.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		fired = append(fired, c.Key().(string))
+		return nil
+	}
+	idle.AddInternalTransition("slow", func(c *fsm.Context) error {
+		<-release
+		return record(c)
+	})
+	idle.AddInternalTransition("work", record)
+	idle.AddInternalTransition("noise", record)
+	idle.AddTransition("ABORT", aborted)
+	aborted.AddInternalTransition("work", record)
+	aborted.AddOnEnter(record)
+
+	smi := sm.FromState(idle)
+	smi.Post("slow")
+	require.Eventually(t, func() bool {
+		return len(smi.Pending()) == 0
+	}, time.Second, time.Millisecond)
+
+	smi.Post("work")
+	smi.Post("noise")
+	smi.Post("work")
+	smi.PostPriority("ABORT", 10)
+	require.Equal(t, []fsm.PendingEvent{
+		{Key: "ABORT", Priority: 10},
+		{Key: "work"},
+		{Key: "noise"},
+		{Key: "work"},
+	}, smi.Pending())
+
+	require.Equal(t, 1, smi.Purge(func(e fsm.PendingEvent) bool {
+		return e.Key == "noise"
+	}))
+	close(release)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(fired) == 4
+	}, time.Second, time.Millisecond)
+	require.Equal(t, []string{"slow", "ABORT", "work", "work"}, fired)
+	require.Equal(t, aborted, smi.State())
+
+	require.Equal(t, 0, smi.Purge(nil))
+}
@@ -0,0 +1,353 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type ErrQuotaExceeded struct {
+	state string
+}
+
+func (e *ErrQuotaExceeded) Error() string {
+	return fmt.Sprintf("state %s is full", e.state)
+}
+
+func (e *ErrQuotaExceeded) State() string {
+	return e.state
+}
+
+// Quota limits how many instances may occupy a state at the same time.
+// Implementations can be backed by a shared store, to limit instances across processes.
+type Quota interface {
+	// TryAcquire reserves a slot of the state, returning false if the state is full
+	TryAcquire(ctx context.Context, state string) (bool, error)
+	// Release frees a slot of the state
+	Release(ctx context.Context, state string) error
+}
+
+// WithQuota option sets the quota checked before entering a state.
+// Only transitions between different states take and free slots, and an instance only frees the slot it took:
+// instances created with FromState hold no slot of their initial state, restored instances hold the slot
+// recorded in their snapshot, and instances that are discarded must release their slot directly in the quota.
+// Slots left during a Fire are released once it succeeds, and slots taken by a failed Fire are released.
+func WithQuota(q Quota) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.quota = q
+	}
+}
+
+// QuotaPolicy decides what happens when the target state of a transition is full
+type QuotaPolicy struct {
+	overflow *State
+	poll     time.Duration
+}
+
+// RejectWhenFull fails the transition with ErrQuotaExceeded. This is the default.
+func RejectWhenFull() QuotaPolicy {
+	return QuotaPolicy{}
+}
+
+// OverflowTo routes the transition to the overflow state.
+// If the overflow states lead back to a full state, the transition fails with ErrQuotaExceeded.
+func OverflowTo(state *State) QuotaPolicy {
+	return QuotaPolicy{overflow: state}
+}
+
+// QueueWhenFull holds the transition, polling the quota, until a slot is free or the fire context is done.
+// The instance stays locked while waiting, so use FireContext to bound the wait.
+func QueueWhenFull(poll time.Duration) QuotaPolicy {
+	return QuotaPolicy{poll: poll}
+}
+
+// OnQuotaFull sets the policy applied when entering this state and it is full
+func (s *State) OnQuotaFull(policy QuotaPolicy) *State {
+	s.machine.mustBeMutable()
+	s.quotaPolicy = policy
+	return s
+}
+
+// admit takes a slot of the next state, returning the state that was admitted.
+// It must be released with rollbackSlots if the transition fails.
+func (s *StateMachine) admit(current, next *State, ctx *Context) (*State, error) {
+	if s.quota == nil {
+		return next, nil
+	}
+	goCtx := ctx.Context()
+	// overflowed are the full states already tried, to stop at overflow cycles
+	var overflowed []*State
+	for {
+		if current == next {
+			return next, nil
+		}
+		ok, err := s.takeSlot(next, ctx)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return next, nil
+		}
+		policy := next.quotaPolicy
+		switch {
+		case policy.overflow != nil && policy.overflow != next:
+			overflowed = append(overflowed, next)
+			for _, st := range overflowed {
+				if st == policy.overflow {
+					return nil, &ErrQuotaExceeded{state: next.name}
+				}
+			}
+			next = policy.overflow
+		case policy.poll > 0:
+			select {
+			case <-goCtx.Done():
+				return nil, goCtx.Err()
+			case <-time.After(policy.poll):
+			}
+		default:
+			return nil, &ErrQuotaExceeded{state: next.name}
+		}
+	}
+}
+
+// takeSlot takes a slot of the state, reusing the one of the instance if it still holds it
+func (s *StateMachine) takeSlot(st *State, ctx *Context) (bool, error) {
+	m := ctx.instance
+	if m != nil && m.slots.holds(m, st) {
+		m.slots.add(m, st, slotReclaimed)
+		return true, nil
+	}
+	ok, err := s.quota.TryAcquire(ctx.Context(), st.name)
+	if ok && m != nil {
+		m.slots.add(m, st, slotAcquired)
+	}
+	return ok, err
+}
+
+// release frees the slot of the left state, if different from the other one.
+// The slot of an instance is only freed if it holds it, once the fire is committed.
+func (s *StateMachine) release(left, other *State, ctx *Context) error {
+	if s.quota == nil || left == other {
+		return nil
+	}
+	m := ctx.instance
+	if m == nil {
+		return s.quota.Release(ctx.Context(), left.name)
+	}
+	if m.slots.held(m, left) > 0 {
+		m.slots.add(m, left, slotLeft)
+	}
+	return nil
+}
+
+// slotMark returns the position to roll the quota slots back to, if the transition fails
+func (s *StateMachine) slotMark(ctx *Context) int {
+	if s.quota == nil || ctx.instance == nil {
+		return 0
+	}
+	return len(ctx.instance.slots.ops)
+}
+
+// rollbackSlots releases the slots taken since the mark, when the transition to the admitted state fails
+func (s *StateMachine) rollbackSlots(mark int, state, admitted *State, ctx *Context) {
+	if s.quota == nil {
+		return
+	}
+	if ctx.instance == nil {
+		if admitted != state {
+			_ = s.quota.Release(ctx.Context(), admitted.name)
+		}
+		return
+	}
+	ctx.instance.slots.rollback(ctx.Context(), mark)
+}
+
+// commitSlots commits the quota changes of the fire.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) commitSlots(goCtx context.Context) error {
+	if goCtx == nil {
+		goCtx = context.Background()
+	}
+	return m.slots.commit(goCtx)
+}
+
+// leaveSlot records that the instance left its current state without a transition, like when a saga is aborted.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) leaveSlot() {
+	if m.quota != nil && m.slots.held(m, m.currentState) > 0 {
+		m.slots.add(m, m.currentState, slotLeft)
+	}
+}
+
+type slotOpKind int
+
+const (
+	// slotAcquired is a slot taken from the quota, released if the fire fails
+	slotAcquired slotOpKind = iota
+	// slotReclaimed is a slot still held by the instance, entered again
+	slotReclaimed
+	// slotLeft is a slot of a left state, released when the fire is committed
+	slotLeft
+)
+
+// slotOp is a change of the quota slots held by an instance
+type slotOp struct {
+	instance *StateMachineInstance
+	state    *State
+	kind     slotOpKind
+}
+
+// slotJournal records the quota changes of the fires of an instance and its sub-machines, until committed
+type slotJournal struct {
+	ops []slotOp
+}
+
+func (j *slotJournal) add(m *StateMachineInstance, st *State, kind slotOpKind) {
+	j.ops = append(j.ops, slotOp{instance: m, state: st, kind: kind})
+}
+
+// held returns how many times the instance holds the slot of the state, counting the uncommitted changes
+func (j *slotJournal) held(m *StateMachineInstance, st *State) int {
+	n := 0
+	if m.slot == st {
+		n++
+	}
+	for _, op := range j.ops {
+		if op.instance != m || op.state != st {
+			continue
+		}
+		if op.kind == slotLeft {
+			n--
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// holds checks if the instance holds the slot of the state or left it in the uncommitted changes
+func (j *slotJournal) holds(m *StateMachineInstance, st *State) bool {
+	if j.held(m, st) > 0 {
+		return true
+	}
+	pending := 0
+	for _, op := range j.ops {
+		if op.instance != m || op.state != st {
+			continue
+		}
+		switch op.kind {
+		case slotLeft:
+			pending++
+		case slotReclaimed:
+			pending--
+		}
+	}
+	return pending > 0
+}
+
+// rollback releases the slots acquired since the mark and forgets the changes after it
+func (j *slotJournal) rollback(ctx context.Context, mark int) {
+	for i := len(j.ops) - 1; i >= mark; i-- {
+		op := j.ops[i]
+		if op.kind == slotAcquired {
+			_ = op.instance.quota.Release(ctx, op.state.name)
+		}
+	}
+	j.ops = j.ops[:mark]
+}
+
+// commit releases the slots left and not reclaimed, and records the slot each instance holds
+func (j *slotJournal) commit(ctx context.Context) error {
+	if len(j.ops) == 0 {
+		return nil
+	}
+	type slotKey struct {
+		instance *StateMachineInstance
+		state    *State
+	}
+	pending := map[slotKey]int{}
+	for _, op := range j.ops {
+		k := slotKey{op.instance, op.state}
+		switch op.kind {
+		case slotLeft:
+			pending[k]++
+		case slotReclaimed:
+			pending[k]--
+		}
+	}
+	slots := map[*StateMachineInstance]*State{}
+	for _, op := range j.ops {
+		m := op.instance
+		if _, ok := slots[m]; ok {
+			continue
+		}
+		slots[m] = nil
+		if j.held(m, m.currentState) > 0 {
+			slots[m] = m.currentState
+		}
+	}
+	var err error
+	for _, op := range j.ops {
+		k := slotKey{op.instance, op.state}
+		if op.kind != slotLeft || pending[k] <= 0 {
+			continue
+		}
+		pending[k]--
+		if rerr := op.instance.quota.Release(ctx, op.state.name); rerr != nil && err == nil {
+			err = rerr
+		}
+	}
+	for m, st := range slots {
+		m.slot = st
+	}
+	j.ops = j.ops[:0]
+	return err
+}
+
+// MemoryQuota is an in memory Quota, for instances of a single process
+type MemoryQuota struct {
+	mu     sync.Mutex
+	limits map[string]int
+	counts map[string]int
+}
+
+// NewMemoryQuota creates a quota with the maximum number of instances per state name.
+// States without a limit are not restricted.
+func NewMemoryQuota(limits map[string]int) *MemoryQuota {
+	l := make(map[string]int, len(limits))
+	for k, v := range limits {
+		l[k] = v
+	}
+	return &MemoryQuota{
+		limits: l,
+		counts: map[string]int{},
+	}
+}
+
+func (q *MemoryQuota) TryAcquire(_ context.Context, state string) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	limit, ok := q.limits[state]
+	if ok && q.counts[state] >= limit {
+		return false, nil
+	}
+	q.counts[state]++
+	return true, nil
+}
+
+func (q *MemoryQuota) Release(_ context.Context, state string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.counts[state] > 0 {
+		q.counts[state]--
+	}
+	return nil
+}
+
+// Count returns the number of instances occupying the state
+func (q *MemoryQuota) Count(state string) int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.counts[state]
+}
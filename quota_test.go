@@ -0,0 +1,164 @@
+package fsm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func quotaFSM(quota fsm.Quota) (*fsm.StateMachine, *fsm.State, *fsm.State, *fsm.State) {
+	sm := fsm.New(fsm.WithQuota(quota))
+	pending := sm.AddState("PENDING")
+	picking := sm.AddState("PICKING")
+	shipped := sm.AddState("SHIPPED")
+	pending.AddTransition(TICK, picking)
+	picking.AddTransition(TICK, shipped)
+	return sm, pending, picking, shipped
+}
+
+func TestQuotaReject(t *testing.T) {
+	quota := fsm.NewMemoryQuota(map[string]int{"PICKING": 1})
+	sm, pending, picking, _ := quotaFSM(quota)
+
+	first := sm.FromState(pending)
+	require.NoError(t, first.Fire(TICK))
+	require.Equal(t, picking, first.State())
+	require.Equal(t, 1, quota.Count("PICKING"))
+
+	second := sm.FromState(pending)
+	err := second.Fire(TICK)
+	var full *fsm.ErrQuotaExceeded
+	require.True(t, errors.As(err, &full))
+	require.Equal(t, "PICKING", full.State())
+	require.Equal(t, pending, second.State())
+
+	// leaving PICKING frees the slot
+	require.NoError(t, first.Fire(TICK))
+	require.Equal(t, 0, quota.Count("PICKING"))
+	require.NoError(t, second.Fire(TICK))
+	require.Equal(t, picking, second.State())
+}
+
+func TestQuotaOverflow(t *testing.T) {
+	quota := fsm.NewMemoryQuota(map[string]int{"PICKING": 1})
+	sm, pending, picking, _ := quotaFSM(quota)
+	backlog := sm.AddState("BACKLOG")
+	picking.OnQuotaFull(fsm.OverflowTo(backlog))
+
+	require.NoError(t, sm.FromState(pending).Fire(TICK))
+	smi := sm.FromState(pending)
+	require.NoError(t, smi.Fire(TICK))
+	require.Equal(t, backlog, smi.State())
+	require.Equal(t, 1, quota.Count("BACKLOG"))
+}
+
+func TestQuotaOverflowCycle(t *testing.T) {
+	quota := fsm.NewMemoryQuota(map[string]int{"PICKING": 1, "BACKLOG": 1})
+	sm, pending, picking, _ := quotaFSM(quota)
+	backlog := sm.AddState("BACKLOG")
+	picking.OnQuotaFull(fsm.OverflowTo(backlog))
+	backlog.OnQuotaFull(fsm.OverflowTo(picking))
+
+	require.NoError(t, sm.FromState(pending).Fire(TICK))
+	require.NoError(t, sm.FromState(pending).Fire(TICK))
+	smi := sm.FromState(pending)
+	var full *fsm.ErrQuotaExceeded
+	require.ErrorAs(t, smi.Fire(TICK), &full)
+	require.Equal(t, "BACKLOG", full.State())
+	require.Equal(t, pending, smi.State())
+}
+
+func TestQuotaQueue(t *testing.T) {
+	quota := fsm.NewMemoryQuota(map[string]int{"PICKING": 1})
+	sm, pending, picking, _ := quotaFSM(quota)
+	picking.OnQuotaFull(fsm.QueueWhenFull(time.Millisecond))
+
+	first := sm.FromState(pending)
+	require.NoError(t, first.Fire(TICK))
+
+	// gives up when the context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	second := sm.FromState(pending)
+	require.ErrorIs(t, second.FireContext(ctx, TICK), context.DeadlineExceeded)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = first.Fire(TICK)
+	}()
+	require.NoError(t, second.FireContext(context.Background(), TICK))
+	require.Equal(t, picking, second.State())
+}
+
+func TestQuotaReleasedOnFailure(t *testing.T) {
+	quota := fsm.NewMemoryQuota(map[string]int{"PICKING": 1})
+	sm := fsm.New(fsm.WithQuota(quota))
+	pending := sm.AddState("PENDING")
+	picking := sm.AddState("PICKING", fsm.OnEnter(func(c *fsm.Context) error {
+		return errors.New("boom")
+	}))
+	pending.AddTransition(TICK, picking)
+
+	require.Error(t, sm.FromState(pending).Fire(TICK))
+	require.Equal(t, 0, quota.Count("PICKING"))
+}
+
+func TestQuotaInitialStateHoldsNoSlot(t *testing.T) {
+	quota := fsm.NewMemoryQuota(map[string]int{"PICKING": 1})
+	sm, pending, picking, _ := quotaFSM(quota)
+
+	// created in PICKING, without taking a slot
+	first := sm.FromState(picking)
+	second := sm.FromState(pending)
+	require.NoError(t, second.Fire(TICK))
+	require.Equal(t, 1, quota.Count("PICKING"))
+
+	// leaving PICKING does not free the slot of the second instance
+	require.NoError(t, first.Fire(TICK))
+	require.Equal(t, 1, quota.Count("PICKING"))
+	var full *fsm.ErrQuotaExceeded
+	require.ErrorAs(t, sm.FromState(pending).Fire(TICK), &full)
+}
+
+func TestQuotaChainedFireFailure(t *testing.T) {
+	quota := fsm.NewMemoryQuota(map[string]int{"B": 1, "C": 1})
+	sm := fsm.New(fsm.WithQuota(quota))
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.OnEvent(func(c *fsm.Context) error {
+		if err := c.Fire("next"); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}))
+	c := sm.AddState("C")
+	a.AddTransition("go", b)
+	b.AddTransition("next", c)
+
+	smi := sm.FromState(a)
+	require.Error(t, smi.Fire("go"))
+	require.Equal(t, a, smi.State())
+	require.Equal(t, 0, quota.Count("B"))
+	require.Equal(t, 0, quota.Count("C"))
+}
+
+func TestQuotaRestoredSlot(t *testing.T) {
+	quota := fsm.NewMemoryQuota(map[string]int{"PICKING": 1})
+	sm, pending, _, shipped := quotaFSM(quota)
+
+	ctx := context.Background()
+	m := fsm.NewManager(sm, fsm.NewMemoryStore())
+	require.NoError(t, m.Create(ctx, "1", pending))
+	_, err := m.Fire(ctx, "1", TICK)
+	require.NoError(t, err)
+	require.Equal(t, 1, quota.Count("PICKING"))
+
+	// the restored instance still holds the slot, and frees it when leaving
+	st, err := m.Fire(ctx, "1", TICK)
+	require.NoError(t, err)
+	require.Equal(t, shipped, st)
+	require.Equal(t, 0, quota.Count("PICKING"))
+}
@@ -0,0 +1,48 @@
+package fsm
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// RandSeed option sets the seed of the random source used by probabilistic features,
+// making simulations and tests reproducible. By default the seed is time based.
+func RandSeed(seed int64) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.seed = seed
+		s.rnd = rand.New(&lockedSource{src: rand.NewSource(seed)})
+	}
+}
+
+// Seed returns the seed of the random source, to be recorded in reports
+func (s *StateMachine) Seed() int64 {
+	return s.seed
+}
+
+// Rand returns the random source of the machine. It is safe for concurrent use.
+func (s *StateMachine) Rand() *rand.Rand {
+	return s.rnd
+}
+
+// Rand returns the random source of the machine, for guards and handlers that need randomness
+func (c *Context) Rand() *rand.Rand {
+	return c.machine.rnd
+}
+
+// lockedSource makes a rand.Source safe for concurrent use
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (l *lockedSource) Int63() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.src.Int63()
+}
+
+func (l *lockedSource) Seed(seed int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.src.Seed(seed)
+}
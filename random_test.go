@@ -0,0 +1,33 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRandSeed(t *testing.T) {
+	walk := func(seed int64) []string {
+		sm := fsm.New(fsm.RandSeed(seed))
+		a := sm.AddState("A")
+		b := sm.AddState("B")
+		a.AddConditionalTransition("a/b", b, func(c *fsm.Context) bool {
+			return c.Rand().Intn(2) == 0
+		})
+		a.AddFallbackTransition(a)
+		b.AddFallbackTransition(a)
+
+		smi := sm.FromState(a)
+		var path []string
+		for i := 0; i < 20; i++ {
+			require.NoError(t, smi.Fire(TICK))
+			path = append(path, smi.State().Name())
+		}
+		require.Equal(t, seed, sm.Seed())
+		return path
+	}
+
+	require.Equal(t, walk(7), walk(7))
+	require.NotEqual(t, walk(7), walk(8))
+}
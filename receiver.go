@@ -0,0 +1,56 @@
+package fsm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnexpectedPayload is returned when the payload of the instance is not of the type expected by a handler
+var ErrUnexpectedPayload = errors.New("unexpected instance payload")
+
+// PayloadAs returns the payload of the instance the event was fired into as T
+func PayloadAs[T any](c *Context) (T, error) {
+	v, ok := c.Payload().(T)
+	if !ok {
+		var zero T
+		return zero, fmt.Errorf("%w: expected %T, got %T", ErrUnexpectedPayload, zero, c.Payload())
+	}
+	return v, nil
+}
+
+// HandlerT adapts a handler receiving the payload of the instance, usually the aggregate owning it,
+// so that a single frozen definition can serve all the aggregates, setting each one as the payload of its instance.
+// The handler fails with ErrUnexpectedPayload if the payload is not a T.
+func HandlerT[T any](fn func(*Context, T) error) OnHandler {
+	return func(c *Context) error {
+		v, err := PayloadAs[T](c)
+		if err != nil {
+			return err
+		}
+		return fn(c, v)
+	}
+}
+
+// ConditionT adapts a condition receiving the payload of the instance.
+// The condition is false if the payload is not a T.
+func ConditionT[T any](fn func(*Context, T) bool) func(*Context) bool {
+	return func(c *Context) bool {
+		v, ok := c.Payload().(T)
+		return ok && fn(c, v)
+	}
+}
+
+// OnEnterT option adds a handler, receiving the payload of the instance, called when entering the state.
+func OnEnterT[T any](fn func(*Context, T) error) func(*State) {
+	return OnEnter(HandlerT(fn))
+}
+
+// OnExitT option adds a handler, receiving the payload of the instance, called when exiting the state.
+func OnExitT[T any](fn func(*Context, T) error) func(*State) {
+	return OnExit(HandlerT(fn))
+}
+
+// OnEventT option adds a handler, receiving the payload of the instance, called when an event occurs in the state.
+func OnEventT[T any](fn func(*Context, T) error) func(*State) {
+	return OnEvent(HandlerT(fn))
+}
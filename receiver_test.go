@@ -0,0 +1,84 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+type ride struct {
+	smi    *fsm.StateMachineInstance
+	bookID string
+	fare   int
+}
+
+// rideMachine is shared by all the rides, which are bound to their instances as payload
+var rideMachine = func() *fsm.StateMachine {
+	sm := fsm.New()
+	created := sm.AddState("created")
+	booked := sm.AddState("booked", fsm.OnEnterT(func(c *fsm.Context, r *ride) error {
+		b, err := fsm.DataAs[book](c)
+		if err != nil {
+			return err
+		}
+		r.bookID = b.id
+		return nil
+	}))
+	paid := sm.AddState("paid", fsm.OnEnterT(func(c *fsm.Context, r *ride) error {
+		p, err := fsm.DataAs[pay](c)
+		if err != nil {
+			return err
+		}
+		r.fare = p.amount
+		return nil
+	}))
+	comped := sm.AddState("comped")
+	created.AddTransition("book", booked)
+	booked.AddConditionalTransition("comp", comped, fsm.ConditionT(func(c *fsm.Context, r *ride) bool {
+		return r.bookID == "vip" && c.Key() == "pay"
+	}), fsm.WithPriority(1))
+	booked.AddTransition("pay", paid)
+	return sm.Freeze()
+}()
+
+func newRide() *ride {
+	r := &ride{}
+	r.smi = rideMachine.FromState(rideMachine.StateByName("created"))
+	r.smi.SetPayload(r)
+	return r
+}
+
+func TestHandlerT(t *testing.T) {
+	r1, r2 := newRide(), newRide()
+	require.NoError(t, r1.smi.Fire(book{id: "abc"}))
+	require.NoError(t, r2.smi.Fire(book{id: "xyz"}))
+	require.NoError(t, r1.smi.Fire(pay{amount: 10}))
+	require.Equal(t, "abc", r1.bookID)
+	require.Equal(t, 10, r1.fare)
+	require.Equal(t, "xyz", r2.bookID)
+	require.Equal(t, 0, r2.fare)
+	require.Equal(t, "paid", r1.smi.State().Name())
+	require.Equal(t, "booked", r2.smi.State().Name())
+}
+
+func TestHandlerTUnexpectedPayload(t *testing.T) {
+	smi := rideMachine.FromState(rideMachine.StateByName("created"))
+	smi.SetPayload("not a ride")
+	err := smi.Fire(book{id: "abc"})
+	require.ErrorIs(t, err, fsm.ErrUnexpectedPayload)
+	require.Contains(t, err.Error(), "expected *fsm_test.ride, got string")
+}
+
+func TestConditionT(t *testing.T) {
+	r := newRide()
+	require.NoError(t, r.smi.Fire(book{id: "vip"}))
+	require.NoError(t, r.smi.Fire(pay{amount: 10}))
+	require.Equal(t, "comped", r.smi.State().Name())
+
+	// without payload the condition does not match
+	r = newRide()
+	require.NoError(t, r.smi.Fire(book{id: "vip"}))
+	r.smi.SetPayload(nil)
+	require.ErrorIs(t, r.smi.Fire(pay{amount: 10}), fsm.ErrUnexpectedPayload)
+}
@@ -0,0 +1,29 @@
+package fsm
+
+// ReenterOnSelf option makes self-transitions, from a state to itself, exit and re-enter the state,
+// calling OnExit and OnEnter. By default only OnEvent is called.
+// Internal transitions never leave the state.
+func ReenterOnSelf() func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.reenterOnSelf = true
+	}
+}
+
+// IsSelfTransition checks if the transition is from a state to itself, including internal transitions
+func (c *Context) IsSelfTransition() bool {
+	return c.from != nil && c.from == c.to
+}
+
+// IsReentry checks if the transition is a self-transition that exits and re-enters the state. See ReenterOnSelf.
+func (c *Context) IsReentry() bool {
+	return c.reentry
+}
+
+// TransitionName returns the name of the matched transition,
+// or empty if the target was resolved by a fallback handler
+func (c *Context) TransitionName() string {
+	if c.matched == nil {
+		return ""
+	}
+	return c.matched.name
+}
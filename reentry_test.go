@@ -0,0 +1,49 @@
+package fsm_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func reentryFSM(opts ...func(*fsm.StateMachine)) (*fsm.StateMachine, *fsm.State, *[]string) {
+	var calls []string
+	record := func(kind string) fsm.OnHandler {
+		return func(c *fsm.Context) error {
+			calls = append(calls, fmt.Sprintf("%s %s self=%t reentry=%t transition=%s", kind, c.ToState(), c.IsSelfTransition(), c.IsReentry(), c.TransitionName()))
+			return nil
+		}
+	}
+	sm := fsm.New(opts...)
+	a := sm.AddState("A", fsm.OnExit(record("exit")), fsm.OnEnter(record("enter")), fsm.OnEvent(record("event")))
+	b := sm.AddState("B", fsm.OnEnter(record("enter")))
+	a.AddTransition(LOOP, a)
+	a.AddTransition(TICK, b)
+	return sm, a, &calls
+}
+
+func TestSelfTransition(t *testing.T) {
+	sm, a, calls := reentryFSM()
+	_, err := sm.Fire(a, LOOP)
+	require.NoError(t, err)
+	_, err = sm.Fire(a, TICK)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"event A self=true reentry=false transition=LOOP",
+		"exit B self=false reentry=false transition=TICK",
+		"enter B self=false reentry=false transition=TICK",
+	}, *calls)
+}
+
+func TestReenterOnSelf(t *testing.T) {
+	sm, a, calls := reentryFSM(fsm.ReenterOnSelf())
+	_, err := sm.Fire(a, LOOP)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"exit A self=true reentry=true transition=LOOP",
+		"enter A self=true reentry=true transition=LOOP",
+		"event A self=true reentry=true transition=LOOP",
+	}, *calls)
+}
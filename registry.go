@@ -0,0 +1,105 @@
+package fsm
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// WithName option names the machine. The name identifies the machine definition in a Registry,
+// in snapshots and in the Dot graph.
+func WithName(name string) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.name = name
+	}
+}
+
+// Name returns the name of the machine, empty if not named
+func (s *StateMachine) Name() string {
+	return s.name
+}
+
+// Registry is a catalog of machine definitions, by name.
+// It is safe for concurrent use.
+type Registry struct {
+	mu       sync.RWMutex
+	machines map[string]*StateMachine
+}
+
+// DefaultRegistry is the registry used by the package level Register and Lookup
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{
+		machines: map[string]*StateMachine{},
+	}
+}
+
+// Register adds a named machine to the registry.
+// It fails if the machine has no name or if the name is already in use.
+func (r *Registry) Register(sm *StateMachine) error {
+	if sm.name == "" {
+		return fmt.Errorf("unable to register a machine without a name")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.machines[sm.name]; ok {
+		return fmt.Errorf("machine %s is already registered", sm.name)
+	}
+	r.machines[sm.name] = sm
+	return nil
+}
+
+// MustRegister is like Register but panics on error
+func (r *Registry) MustRegister(sm *StateMachine) *StateMachine {
+	if err := r.Register(sm); err != nil {
+		panic(err)
+	}
+	return sm
+}
+
+// Unregister removes the machine with the name, if any
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.machines, name)
+}
+
+// Lookup returns the machine registered with the name, or nil
+func (r *Registry) Lookup(name string) *StateMachine {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.machines[name]
+}
+
+// Names returns the names of the registered machines, sorted
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.machines))
+	for n := range r.machines {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Restore restores the snapshot with the machine it was taken from
+func (r *Registry) Restore(snap Snapshot) (*StateMachineInstance, error) {
+	sm := r.Lookup(snap.Machine)
+	if sm == nil {
+		return nil, fmt.Errorf("unable to restore snapshot: machine %q is not registered", snap.Machine)
+	}
+	return sm.Restore(snap)
+}
+
+// Register adds a named machine to the DefaultRegistry
+func Register(sm *StateMachine) error {
+	return DefaultRegistry.Register(sm)
+}
+
+// Lookup returns the machine registered with the name in the DefaultRegistry, or nil
+func Lookup(name string) *StateMachine {
+	return DefaultRegistry.Lookup(name)
+}
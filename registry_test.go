@@ -0,0 +1,60 @@
+package fsm_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	light := fsm.New(fsm.WithName("traffic-light"))
+	green := light.AddState("GREEN")
+	yellow := light.AddState("YELLOW")
+	green.AddTransition(TICK, yellow)
+	door := fsm.New(fsm.WithName("door"))
+	door.AddState("CLOSED")
+
+	r := fsm.NewRegistry()
+	require.NoError(t, r.Register(light))
+	require.NoError(t, r.Register(door))
+	require.Error(t, r.Register(fsm.New(fsm.WithName("door"))))
+	require.Error(t, r.Register(fsm.New()))
+
+	require.Equal(t, []string{"door", "traffic-light"}, r.Names())
+	require.Same(t, light, r.Lookup("traffic-light"))
+	require.Nil(t, r.Lookup("unknown"))
+
+	smi := light.FromState(green)
+	require.NoError(t, smi.Fire(TICK))
+	snap := smi.Snapshot()
+	require.Equal(t, "traffic-light", snap.Machine)
+	restored, err := r.Restore(snap)
+	require.NoError(t, err)
+	require.Equal(t, yellow, restored.State())
+
+	r.Unregister("traffic-light")
+	_, err = r.Restore(snap)
+	require.Error(t, err)
+}
+
+func TestNameInDefinition(t *testing.T) {
+	sm := fsm.New(fsm.WithName("door"))
+	sm.AddState("CLOSED")
+	data, err := sm.MarshalDefinition()
+	require.NoError(t, err)
+
+	def := fsm.Definition{}
+	require.NoError(t, json.Unmarshal(data, &def))
+	require.Equal(t, "door", def.Name)
+
+	loaded, err := fsm.LoadDefinition(data, fsm.HandlerRegistry{})
+	require.NoError(t, err)
+	require.Equal(t, "door", loaded.Name())
+
+	renamed, err := fsm.LoadDefinition(data, fsm.HandlerRegistry{}, fsm.WithName("gate"))
+	require.NoError(t, err)
+	require.Equal(t, "gate", renamed.Name())
+	require.Contains(t, renamed.Dot(nil), "label=\"gate\";")
+}
@@ -0,0 +1,84 @@
+package fsm
+
+import (
+	"errors"
+	"time"
+)
+
+// Backoff returns the delay before a retry. attempt starts at 1 for the first retry.
+type Backoff func(attempt int) time.Duration
+
+// ConstantBackoff waits the same delay before every retry
+func ConstantBackoff(d time.Duration) Backoff {
+	return func(int) time.Duration {
+		return d
+	}
+}
+
+// ExponentialBackoff doubles the delay, starting at base, up to max
+func ExponentialBackoff(base, max time.Duration) Backoff {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			return max
+		}
+		return d
+	}
+}
+
+type retryPolicy struct {
+	retries int
+	backoff Backoff
+}
+
+// WithRetry option retries the OnEnter and OnEvent handlers of the state up to retries times, when they fail,
+// waiting the backoff, if not nil, before each retry. The error is surfaced after the last retry
+// or if the context of the Fire is done while waiting.
+// Budget and handler timeout errors are not retried.
+func WithRetry(retries int, backoff Backoff) func(*State) {
+	return func(s *State) {
+		s.retry = &retryPolicy{
+			retries: retries,
+			backoff: backoff,
+		}
+	}
+}
+
+// OnRetry option sets a hook called before every retry of a handler, with the kind of handler, like OnEnter,
+// the retry attempt, starting at 1, and the error of the previous attempt
+func OnRetry(hook func(c *Context, handler string, attempt int, err error)) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.onRetry = hook
+	}
+}
+
+// retrying calls the handler, retrying it according to the policy of the state
+func (s *StateMachine) retrying(kind string, handler OnHandler, ctx *Context, policy *retryPolicy) error {
+	err := s.callOnce(kind, handler, ctx)
+	for attempt := 1; err != nil && attempt <= policy.retries; attempt++ {
+		var budget *ErrBudgetExceeded
+		var timeout *ErrHandlerTimeout
+		if errors.As(err, &budget) || errors.As(err, &timeout) {
+			return err
+		}
+		if s.onRetry != nil {
+			s.onRetry(ctx, kind, attempt, err)
+		}
+		if policy.backoff != nil {
+			if d := policy.backoff(attempt); d > 0 {
+				t := time.NewTimer(d)
+				select {
+				case <-t.C:
+				case <-ctx.Context().Done():
+					t.Stop()
+					return err
+				}
+			}
+		}
+		err = s.callOnce(kind, handler, ctx)
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package fsm_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func flaky(failures int) (fsm.OnHandler, *int) {
+	calls := 0
+	return func(c *fsm.Context) error {
+		calls++
+		if calls <= failures {
+			return fmt.Errorf("transient %d", calls)
+		}
+		return nil
+	}, &calls
+}
+
+func TestRetry(t *testing.T) {
+	var retries []string
+	sm := fsm.New(fsm.OnRetry(func(c *fsm.Context, handler string, attempt int, err error) {
+		retries = append(retries, fmt.Sprintf("%s %d %v", handler, attempt, err))
+	}))
+	handler, calls := flaky(2)
+	idle := sm.AddState("IDLE")
+	paying := sm.AddState("PAYING", fsm.OnEnter(handler), fsm.WithRetry(3, fsm.ConstantBackoff(time.Millisecond)))
+	idle.AddTransition(SUBMIT, paying)
+
+	next, err := sm.Fire(idle, SUBMIT)
+	require.NoError(t, err)
+	require.Equal(t, paying, next)
+	require.Equal(t, 3, *calls)
+	require.Equal(t, []string{"OnEnter 1 transient 1", "OnEnter 2 transient 2"}, retries)
+}
+
+func TestRetryExhausted(t *testing.T) {
+	sm := fsm.New()
+	handler, calls := flaky(10)
+	idle := sm.AddState("IDLE")
+	paying := sm.AddState("PAYING", fsm.OnEvent(handler), fsm.WithRetry(2, nil))
+	idle.AddTransition(SUBMIT, paying)
+
+	_, err := sm.Fire(idle, SUBMIT)
+	var te *fsm.TransitionError
+	require.ErrorAs(t, err, &te)
+	require.Equal(t, "OnEvent", te.Handler)
+	require.EqualError(t, te.Err, "transient 3")
+	require.Equal(t, 3, *calls)
+}
+
+func TestRetryCancelled(t *testing.T) {
+	sm := fsm.New()
+	handler, calls := flaky(10)
+	idle := sm.AddState("IDLE")
+	paying := sm.AddState("PAYING", fsm.OnEnter(handler), fsm.WithRetry(5, fsm.ConstantBackoff(time.Hour)))
+	idle.AddTransition(SUBMIT, paying)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := sm.FromState(idle).FireContext(ctx, SUBMIT)
+	require.Error(t, err)
+	require.Equal(t, 1, *calls)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	b := fsm.ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	require.Equal(t, 10*time.Millisecond, b(1))
+	require.Equal(t, 20*time.Millisecond, b(2))
+	require.Equal(t, 40*time.Millisecond, b(3))
+	require.Equal(t, 50*time.Millisecond, b(4))
+}
@@ -0,0 +1,34 @@
+package fsm
+
+// ring keeps the last size items added
+type ring[T any] struct {
+	items []T
+	// next is the position of the next write once the buffer is full
+	next int
+	size int
+}
+
+func (r *ring[T]) add(item T) {
+	if r.size == 0 {
+		return
+	}
+	if len(r.items) < r.size {
+		r.items = append(r.items, item)
+		return
+	}
+	r.items[r.next] = item
+	r.next = (r.next + 1) % r.size
+}
+
+// list returns the items from the oldest to the newest
+func (r *ring[T]) list() []T {
+	out := make([]T, 0, len(r.items))
+	out = append(out, r.items[r.next:]...)
+	return append(out, r.items[:r.next]...)
+}
+
+// clone returns a copy that does not share the items
+func (r ring[T]) clone() ring[T] {
+	r.items = append([]T(nil), r.items...)
+	return r
+}
@@ -0,0 +1,44 @@
+package fsm
+
+import "errors"
+
+// WithRollback option enables compensation when the OnEnter or OnEvent handler fails
+// after the previous state was exited: the OnEnter handler of the previous state is called again.
+// The instance always stays in the original state.
+func WithRollback() func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.rollback = true
+	}
+}
+
+// RollbackHandler option enables rollback, calling the handler instead of re-entering the previous state.
+// The handler receives the context of the failed transition.
+func RollbackHandler(handler OnHandler) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.rollback = true
+		s.rollbackHandler = handler
+	}
+}
+
+// compensate rolls back a failed transition from -> to, if rollback is enabled.
+// The original error is always returned, with any compensation error attached.
+func (s *StateMachine) compensate(from, to *State, ctx *Context, err error) error {
+	if !s.rollback || from == to {
+		return err
+	}
+
+	var rerr error
+	if s.rollbackHandler != nil {
+		rerr = s.rollbackHandler(ctx)
+	} else if from.onEnter != nil {
+		ctx.setFrom(to)
+		ctx.setTo(from)
+		rerr = from.onEnter(ctx)
+	}
+
+	var te *TransitionError
+	if rerr != nil && errors.As(err, &te) && te.Rollback == nil {
+		te.Rollback = rerr
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollback(t *testing.T) {
+	var calls []string
+	sm := fsm.New(fsm.WithRollback())
+	a := sm.AddState("A",
+		fsm.OnEnter(func(c *fsm.Context) error {
+			calls = append(calls, "enter A from "+c.FromState().Name())
+			return nil
+		}),
+		fsm.OnExit(func(c *fsm.Context) error {
+			calls = append(calls, "exit A")
+			return nil
+		}),
+	)
+	b := sm.AddState("B", fsm.OnEnter(func(c *fsm.Context) error {
+		return errors.New("boom")
+	}))
+	a.AddTransition(TICK, b)
+
+	smi := sm.FromState(a)
+	require.Error(t, smi.Fire(TICK))
+	require.Equal(t, a, smi.State())
+	require.Equal(t, []string{"exit A", "enter A from B"}, calls)
+}
+
+func TestRollbackHandler(t *testing.T) {
+	var rolledBack *fsm.Context
+	sm := fsm.New(fsm.RollbackHandler(func(c *fsm.Context) error {
+		rolledBack = c
+		return errors.New("compensation failed")
+	}))
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.OnEvent(func(c *fsm.Context) error {
+		return errors.New("boom")
+	}))
+	a.AddTransition(TICK, b)
+
+	smi := sm.FromState(a)
+	err := smi.Fire(TICK)
+	var te *fsm.TransitionError
+	require.ErrorAs(t, err, &te)
+	require.EqualError(t, te.Rollback, "compensation failed")
+	require.Equal(t, b, rolledBack.ToState())
+	require.Equal(t, a, smi.State())
+}
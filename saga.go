@@ -0,0 +1,94 @@
+package fsm
+
+import (
+	"context"
+	"fmt"
+)
+
+// SagaError is returned by an instance in saga mode when a transition fails and the visited states are compensated
+type SagaError struct {
+	// Err is the error of the failed transition
+	Err error
+	// Compensated are the states whose compensation was executed, in execution order
+	Compensated []*State
+	// CompensationErr is the first error returned by a compensation, if any
+	CompensationErr error
+}
+
+func (e *SagaError) Error() string {
+	if e.CompensationErr != nil {
+		return fmt.Sprintf("saga aborted, compensation failed: %s: %s", e.CompensationErr, e.Err)
+	}
+	return fmt.Sprintf("saga aborted: %s", e.Err)
+}
+
+func (e *SagaError) Unwrap() error {
+	return e.Err
+}
+
+type sagaStep struct {
+	state *State
+	event Eventer
+}
+
+// Compensate option sets the action that undoes the work done when entering the state, used in saga mode.
+// The context carries the event that entered the state.
+func Compensate(fn OnHandler) func(*State) {
+	return func(s *State) {
+		s.compensation = fn
+	}
+}
+
+// EnableSaga enables the saga mode for instances: the states with a compensation entered by an instance are recorded
+// and, when a handler of a transition fails, their compensations are executed, from the most recent,
+// and the instance moves to the aborted state, if not nil, without calling its handlers.
+// If a compensation fails, the remaining ones are still executed.
+// The recorded states are discarded when a final state is reached, and are not part of the Snapshot.
+func (s *StateMachine) EnableSaga(aborted *State) *StateMachine {
+	s.mustBeMutable()
+	s.sagaMode = true
+	s.sagaAborted = aborted
+	return s
+}
+
+// recordSaga records the state entered by the transition, if it has a compensation
+func (s *StateMachine) recordSaga(ctx *Context) {
+	if !s.sagaMode || ctx.instance == nil || ctx.to.compensation == nil {
+		return
+	}
+	ctx.instance.sagaTrail = append(ctx.instance.sagaTrail, sagaStep{state: ctx.to, event: ctx.eventer()})
+}
+
+// abortSaga executes the compensations of the recorded states, most recent first,
+// and moves the instance to the aborted state.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) abortSaga(goCtx context.Context, err error) error {
+	serr := &SagaError{Err: err}
+	aborted := m.sagaAborted
+	for i := len(m.sagaTrail) - 1; i >= 0; i-- {
+		step := m.sagaTrail[i]
+		ctx := &Context{
+			machine:  m.StateMachine,
+			instance: m,
+			context:  m.withValues(goCtx),
+			event:    step.event,
+			from:     step.state,
+			to:       aborted,
+		}
+		if cerr := step.state.compensation(ctx); cerr != nil && serr.CompensationErr == nil {
+			serr.CompensationErr = cerr
+		}
+		serr.Compensated = append(serr.Compensated, step.state)
+	}
+	m.sagaTrail = nil
+	if aborted != nil && aborted != m.currentState {
+		m.leaveSlot()
+		m.moveOccupancy(m.currentState, aborted)
+		m.monitor.track(m.id, aborted)
+		m.currentState = aborted
+		m.startSub()
+		m.startAsync(nil)
+		m.schedule()
+	}
+	return serr
+}
@@ -0,0 +1,79 @@
+package fsm_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaga(t *testing.T) {
+	var log []string
+	step := func(name string, err error) fsm.OnHandler {
+		return func(c *fsm.Context) error {
+			log = append(log, fmt.Sprintf("%s %v", name, c.Key()))
+			return err
+		}
+	}
+
+	sm := fsm.New()
+	cancelled := sm.AddState("CANCELLED")
+	sm.EnableSaga(cancelled)
+	created := sm.AddState("CREATED")
+	booked := sm.AddState("BOOKED", fsm.OnEnter(step("book", nil)), fsm.Compensate(step("unbook", nil)))
+	charged := sm.AddState("CHARGED", fsm.OnEnter(step("charge", nil)), fsm.Compensate(step("refund", errors.New("refund failed"))))
+	shipped := sm.AddState("SHIPPED", fsm.OnEnter(step("ship", errors.New("no stock"))), fsm.Compensate(step("unship", nil)))
+	created.AddTransition("book", booked)
+	booked.AddTransition("charge", charged)
+	charged.AddTransition("ship", shipped)
+
+	smi := sm.FromState(created)
+	require.NoError(t, smi.Fire("book"))
+	require.NoError(t, smi.Fire("charge"))
+	err := smi.Fire("ship")
+
+	var serr *fsm.SagaError
+	require.ErrorAs(t, err, &serr)
+	require.Equal(t, []*fsm.State{charged, booked}, serr.Compensated)
+	require.EqualError(t, serr.CompensationErr, "refund failed")
+	var te *fsm.TransitionError
+	require.ErrorAs(t, err, &te)
+	require.Equal(t, shipped, te.To)
+
+	require.Equal(t, cancelled, smi.State())
+	require.Equal(t, []string{"book book", "charge charge", "ship ship", "refund charge", "unbook book"}, log)
+}
+
+func TestSagaCompletedDiscardsTrail(t *testing.T) {
+	compensated := 0
+	sm := fsm.New().EnableSaga(nil)
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.Compensate(func(c *fsm.Context) error {
+		compensated++
+		return nil
+	}))
+	done := sm.AddState("DONE", fsm.Final())
+	a.AddTransition(TICK, b)
+	b.AddTransition(TICK, done)
+	b.AddTransition(LOOP, a)
+	a.AddTransition(LOOP, done)
+	failing := sm.AddState("FAILING", fsm.OnEnter(func(c *fsm.Context) error {
+		return errors.New("boom")
+	}))
+	a.AddTransition(CANCEL, failing)
+
+	smi := sm.FromState(a)
+	require.NoError(t, smi.Fire(TICK))
+	require.NoError(t, smi.Fire(TICK))
+	require.Equal(t, done, smi.State())
+
+	smi = sm.FromState(a)
+	require.NoError(t, smi.Fire(TICK))
+	require.NoError(t, smi.Fire(LOOP))
+	require.Error(t, smi.Fire(CANCEL))
+	require.Equal(t, 1, compensated)
+	// without an aborted state the instance stays
+	require.Equal(t, a, smi.State())
+}
@@ -0,0 +1,33 @@
+package fsm
+
+// AddSimpleTransition adds a transition between the named states, for the event key,
+// adding the states that do not exist yet, so that simple machines can be described only with strings.
+//
+//	sm.AddSimpleTransition("GREEN", "TICK", "YELLOW").
+//		AddSimpleTransition("YELLOW", "TICK", "RED")
+func (s *StateMachine) AddSimpleTransition(from, event, to string) *StateMachine {
+	s.stateOrNew(from).AddTransition(event, s.stateOrNew(to))
+	return s
+}
+
+// stateOrNew gets the named state, adding it if it does not exist
+func (s *StateMachine) stateOrNew(name string) *State {
+	if st := s.targetByName(name); st != nil {
+		return st
+	}
+	return s.AddState(name)
+}
+
+// FireString fires the event on the named state and returns the name of the reached state.
+// No instance is involved, so timeouts, history and the other instance features are not available.
+func (s *StateMachine) FireString(state, event string) (string, error) {
+	current := s.StateByName(state)
+	if current == nil {
+		return "", &ErrStateNotFound{state: state}
+	}
+	next, err := s.Fire(current, event)
+	if err != nil {
+		return "", err
+	}
+	return next.name, nil
+}
@@ -0,0 +1,30 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimpleTransitions(t *testing.T) {
+	sm := fsm.New().
+		AddSimpleTransition("GREEN", TICK, "YELLOW").
+		AddSimpleTransition("YELLOW", TICK, "RED").
+		AddSimpleTransition("RED", TICK, "GREEN")
+	require.Len(t, sm.States(), 3)
+
+	next, err := sm.FireString("GREEN", TICK)
+	require.NoError(t, err)
+	require.Equal(t, "YELLOW", next)
+
+	_, err = sm.FireString("BLUE", TICK)
+	require.ErrorIs(t, err, fsm.ErrUnknownState)
+	_, err = sm.FireString("RED", "BLINK")
+	require.ErrorIs(t, err, fsm.ErrUnknownTransition)
+
+	smi, err := sm.FromStateName("RED")
+	require.NoError(t, err)
+	require.NoError(t, smi.Fire(TICK))
+	require.Equal(t, "GREEN", smi.State().Name())
+}
@@ -0,0 +1,158 @@
+// Package simulate runs random walks over the graph of a machine, without executing any handler,
+// to validate workflow designs before production: how often each state is visited,
+// how long the paths are and where the walks get stuck.
+//
+// At each step a walk takes one of the transitions that apply on the current state,
+// with a probability proportional to the weight set in the transition metadata:
+//
+//	review.SetTransitionMeta("approve", simulate.WeightKey, "0.8")
+//
+// Transitions without a weight have weight 1, and a weight of 0 excludes the transition.
+// Conditions are not evaluated and internal transitions are ignored, since they don't change the state.
+package simulate
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/quintans/fsm"
+)
+
+// WeightKey is the transition metadata key with the weight of the transition
+const WeightKey = "weight"
+
+// Config configures a simulation
+type Config struct {
+	// Walks is the number of random walks
+	Walks int
+	// MaxSteps is the maximum number of transitions of a walk. Zero means 1000.
+	MaxSteps int
+	// Seed makes the simulation reproducible. Zero picks a random seed, recorded in the Report.
+	Seed int64
+}
+
+// Report summarizes a simulation
+type Report struct {
+	// Seed is the seed of the run, to reproduce it
+	Seed  int64
+	Walks int
+	// Visits counts the times each state was visited, including the start
+	Visits map[string]int
+	// Completed counts the walks that reached a final state
+	Completed int
+	// DeadEnds counts, by state, the walks stuck in a non final state without transitions
+	DeadEnds map[string]int
+	// Truncated counts the walks stopped after MaxSteps
+	Truncated int
+	// AveragePathLength is the average number of transitions of a walk
+	AveragePathLength float64
+}
+
+// Frequency returns the fraction of the visits that were to the state
+func (r Report) Frequency(state string) float64 {
+	total := 0
+	for _, v := range r.Visits {
+		total += v
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(r.Visits[state]) / float64(total)
+}
+
+// Run runs the random walks starting in the state
+func Run(start *fsm.State, cfg Config) (Report, error) {
+	if cfg.MaxSteps <= 0 {
+		cfg.MaxSteps = 1000
+	}
+	if cfg.Seed == 0 {
+		cfg.Seed = time.Now().UnixNano()
+	}
+	rnd := rand.New(rand.NewSource(cfg.Seed))
+	r := Report{
+		Seed:     cfg.Seed,
+		Walks:    cfg.Walks,
+		Visits:   map[string]int{},
+		DeadEnds: map[string]int{},
+	}
+	edges := map[*fsm.State][]edge{}
+	steps := 0
+	for w := 0; w < cfg.Walks; w++ {
+		st := start.Initial()
+		r.Visits[st.Name()]++
+		for i := 0; ; i++ {
+			if st.IsFinal() {
+				r.Completed++
+				break
+			}
+			if i == cfg.MaxSteps {
+				r.Truncated++
+				break
+			}
+			out, ok := edges[st]
+			if !ok {
+				var err error
+				out, err = outgoing(st)
+				if err != nil {
+					return Report{}, err
+				}
+				edges[st] = out
+			}
+			if len(out) == 0 {
+				r.DeadEnds[st.Name()]++
+				break
+			}
+			st = pick(out, rnd)
+			r.Visits[st.Name()]++
+			steps++
+		}
+	}
+	if cfg.Walks > 0 {
+		r.AveragePathLength = float64(steps) / float64(cfg.Walks)
+	}
+	return r, nil
+}
+
+type edge struct {
+	to     *fsm.State
+	weight float64
+}
+
+// outgoing returns the weighted transitions leaving the state
+func outgoing(st *fsm.State) ([]edge, error) {
+	var edges []edge
+	for _, t := range st.AllTransitions() {
+		if t.Kind == fsm.InternalTransition {
+			continue
+		}
+		w := 1.0
+		if v, ok := t.Meta[WeightKey]; ok {
+			var err error
+			w, err = strconv.ParseFloat(v, 64)
+			if err != nil || w < 0 {
+				return nil, fmt.Errorf("invalid weight %q of transition %s of state %s", v, t.Name, st.Name())
+			}
+		}
+		if w > 0 {
+			edges = append(edges, edge{to: t.To.Initial(), weight: w})
+		}
+	}
+	return edges, nil
+}
+
+func pick(edges []edge, rnd *rand.Rand) *fsm.State {
+	total := 0.0
+	for _, e := range edges {
+		total += e.weight
+	}
+	x := rnd.Float64() * total
+	for _, e := range edges {
+		x -= e.weight
+		if x < 0 {
+			return e.to
+		}
+	}
+	return edges[len(edges)-1].to
+}
@@ -0,0 +1,67 @@
+package simulate_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/quintans/fsm/simulate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	sm := fsm.New()
+	draft := sm.AddState("DRAFT")
+	review := sm.AddState("REVIEW")
+	published := sm.AddState("PUBLISHED", fsm.Final())
+	limbo := sm.AddState("LIMBO")
+	draft.AddTransition("submit", review)
+	review.AddTransition("approve", published)
+	review.AddTransition("reject", draft)
+	review.AddTransition("lose", limbo)
+	review.SetTransitionMeta("approve", simulate.WeightKey, "3")
+	review.SetTransitionMeta("lose", simulate.WeightKey, "0")
+
+	r, err := simulate.Run(draft, simulate.Config{Walks: 1000, Seed: 1})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, r.Seed)
+	require.Equal(t, 1000, r.Completed)
+	require.Zero(t, r.Visits["LIMBO"])
+	require.Empty(t, r.DeadEnds)
+	// approve is taken 3 times out of 4, so each walk takes on average 2 * 4/3 transitions
+	require.InDelta(t, 2.67, r.AveragePathLength, 0.15)
+	require.Equal(t, 1000, r.Visits["PUBLISHED"])
+	require.Equal(t, r.Visits["DRAFT"], r.Visits["REVIEW"])
+	require.Equal(t, r.Frequency("DRAFT"), r.Frequency("REVIEW"))
+
+	review.SetTransitionMeta("lose", simulate.WeightKey, "1")
+	r, err = simulate.Run(draft, simulate.Config{Walks: 1000, Seed: 1})
+	require.NoError(t, err)
+	require.Equal(t, 1000, r.Completed+r.DeadEnds["LIMBO"])
+	require.NotZero(t, r.DeadEnds["LIMBO"])
+
+	r, err = simulate.Run(draft, simulate.Config{Walks: 10, MaxSteps: 1, Seed: 1})
+	require.NoError(t, err)
+	require.Equal(t, 10, r.Truncated)
+
+	review.SetTransitionMeta("lose", simulate.WeightKey, "x")
+	_, err = simulate.Run(draft, simulate.Config{Walks: 1})
+	require.EqualError(t, err, `invalid weight "x" of transition lose of state REVIEW`)
+}
+
+func TestRunSeed(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.Final())
+	c := sm.AddState("C", fsm.Final())
+	a.AddTransition("b", b)
+	a.AddTransition("c", c)
+
+	r, err := simulate.Run(a, simulate.Config{Walks: 100})
+	require.NoError(t, err)
+	require.NotZero(t, r.Seed)
+
+	// the recorded seed reproduces the run
+	again, err := simulate.Run(a, simulate.Config{Walks: 100, Seed: r.Seed})
+	require.NoError(t, err)
+	require.Equal(t, r, again)
+}
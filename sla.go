@@ -0,0 +1,128 @@
+package fsm
+
+import (
+	"sort"
+	"time"
+)
+
+type sla struct {
+	name       string
+	event      interface{}
+	target     *State
+	within     time.Duration
+	escalation interface{}
+}
+
+// AddSLA declares that, once the event is fired, the target state must be reached within the duration.
+// Otherwise the escalation event is fired into the instance, if started.
+// The deadline is tracked per instance, survives snapshots, and is cleared when the target is reached
+// or the escalation is fired. Firing the event again while the deadline is pending does not extend it.
+func (s *StateMachine) AddSLA(name string, event interface{}, target *State, within time.Duration, escalation interface{}) *StateMachine {
+	s.mustBeMutable()
+	key := s.normalizeKey(toEventer(event).Kind())
+	s.mustBeComparable(key)
+	s.slas = append(s.slas, &sla{
+		name:       name,
+		event:      key,
+		target:     target,
+		within:     within,
+		escalation: escalation,
+	})
+	return s
+}
+
+// Deadline is a pending SLA of an instance
+type Deadline struct {
+	Name string
+	At   time.Time
+}
+
+// Deadlines returns the pending SLAs, ordered by deadline
+func (m *StateMachineInstance) Deadlines() []Deadline {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]Deadline, 0, len(m.deadlines))
+	for name, at := range m.deadlines {
+		list = append(list, Deadline{Name: name, At: at})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].At.Before(list[j].At)
+	})
+	return list
+}
+
+// trackSLAs starts the SLAs triggered by the event and clears the ones whose target was reached
+func (m *StateMachineInstance) trackSLAs(event interface{}) {
+	if len(m.slas) == 0 {
+		return
+	}
+	key := m.normalizeKey(toEventer(event).Kind())
+	for _, s := range m.slas {
+		_, pending := m.deadlines[s.name]
+		switch {
+		case pending && m.currentState == s.target:
+			m.clearDeadline(s.name)
+		case !pending && m.currentState != s.target && m.keysEqual(key, s.event):
+			at := time.Now().Add(s.within)
+			m.setDeadline(s.name, at)
+			m.armDeadline(s.name, s.escalation, at)
+		}
+	}
+}
+
+func (m *StateMachineInstance) clearDeadline(name string) {
+	delete(m.deadlines, name)
+	if m.timerStore != nil {
+		m.timerOps = append(m.timerOps, timerOp{name: name})
+	}
+	if t, ok := m.slaTimers[name]; ok {
+		t.Stop()
+		delete(m.slaTimers, name)
+	}
+}
+
+// scheduleSLAs replaces the timers of the SLAs and of the state deadline, arming the pending ones if the instance is started.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) scheduleSLAs() {
+	for name, t := range m.slaTimers {
+		t.Stop()
+		delete(m.slaTimers, name)
+	}
+	for _, s := range m.slas {
+		if at, ok := m.deadlines[s.name]; ok {
+			m.armDeadline(s.name, s.escalation, at)
+		}
+	}
+	if d := m.currentState.deadline; d != nil {
+		if at, ok := m.deadlines[m.currentState.DeadlineName()]; ok {
+			m.armDeadline(m.currentState.DeadlineName(), d.escalation, at)
+		}
+	}
+}
+
+// armDeadline fires the escalation event when the deadline passes, if the instance is started.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) armDeadline(name string, escalation interface{}, at time.Time) {
+	if !m.scheduler.running {
+		return
+	}
+	if m.slaTimers == nil {
+		m.slaTimers = map[string]*time.Timer{}
+	}
+	onError := m.scheduler.onError
+	m.slaTimers[name] = time.AfterFunc(time.Until(at), func() {
+		if m.chaos.dropTimer(m.rnd) {
+			return
+		}
+		err := m.fireWhen(nil, func() bool {
+			if d, ok := m.deadlines[name]; !ok || !d.Equal(at) {
+				return false
+			}
+			m.clearDeadline(name)
+			return true
+		}, escalation)
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package fsm_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	SUBMIT   = "submit"
+	APPROVE  = "approve"
+	ESCALATE = "escalate"
+)
+
+// DRAFT -submit-> REVIEW -tick-> CHECK -approve-> APPROVED, with escalation from anywhere
+func slaFSM(within time.Duration) *fsm.StateMachine {
+	sm := fsm.New()
+	draft := sm.AddState("DRAFT")
+	review := sm.AddState("REVIEW")
+	check := sm.AddState("CHECK")
+	approved := sm.AddState("APPROVED")
+	escalated := sm.AddState("ESCALATED")
+	draft.AddTransition(SUBMIT, review)
+	review.AddTransition(TICK, check)
+	check.AddTransition(APPROVE, approved)
+	sm.AddGlobalTransition(ESCALATE, escalated)
+	sm.AddSLA("approval", SUBMIT, approved, within, ESCALATE)
+	return sm
+}
+
+func TestSLAEscalates(t *testing.T) {
+	sm := slaFSM(20 * time.Millisecond)
+	smi := sm.FromState(sm.StateByName("DRAFT"))
+	smi.Start(nil)
+	defer smi.Stop()
+
+	require.NoError(t, smi.Fire(SUBMIT))
+	require.Len(t, smi.Deadlines(), 1)
+	// moving between states does not reset the SLA
+	require.NoError(t, smi.Fire(TICK))
+
+	require.Eventually(t, func() bool {
+		return smi.State().Name() == "ESCALATED"
+	}, time.Second, time.Millisecond)
+	require.Empty(t, smi.Deadlines())
+}
+
+func TestSLAMet(t *testing.T) {
+	sm := slaFSM(20 * time.Millisecond)
+	smi := sm.FromState(sm.StateByName("DRAFT"))
+	smi.Start(nil)
+	defer smi.Stop()
+
+	require.NoError(t, smi.Fire(SUBMIT))
+	require.NoError(t, smi.Fire(TICK))
+	require.NoError(t, smi.Fire(APPROVE))
+	require.Empty(t, smi.Deadlines())
+
+	time.Sleep(40 * time.Millisecond)
+	require.Equal(t, "APPROVED", smi.State().Name())
+}
+
+func TestSLASnapshot(t *testing.T) {
+	sm := slaFSM(20 * time.Millisecond)
+	smi := sm.FromState(sm.StateByName("DRAFT"))
+	require.NoError(t, smi.Fire(SUBMIT))
+
+	data, err := json.Marshal(smi.Snapshot())
+	require.NoError(t, err)
+	snap := fsm.Snapshot{}
+	require.NoError(t, json.Unmarshal(data, &snap))
+
+	restored, err := sm.Restore(snap)
+	require.NoError(t, err)
+	require.Equal(t, smi.Deadlines()[0].At.UnixNano(), restored.Deadlines()[0].At.UnixNano())
+
+	restored.Start(nil)
+	defer restored.Stop()
+	require.Eventually(t, func() bool {
+		return restored.State().Name() == "ESCALATED"
+	}, time.Second, time.Millisecond)
+}
+
+func TestSLADefinition(t *testing.T) {
+	sm := slaFSM(time.Hour)
+	data, err := sm.MarshalDefinition()
+	require.NoError(t, err)
+	loaded, err := fsm.LoadDefinition(data, fsm.HandlerRegistry{})
+	require.NoError(t, err)
+	require.Equal(t, sm.Fingerprint(), loaded.Fingerprint())
+	require.NotEqual(t, slaFSM(time.Minute).Fingerprint(), sm.Fingerprint())
+}
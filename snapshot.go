@@ -0,0 +1,187 @@
+package fsm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type ErrDefinitionDrift struct {
+	expected string
+	actual   string
+}
+
+func (e *ErrDefinitionDrift) Error() string {
+	return fmt.Sprintf("machine definition changed: snapshot fingerprint %s, current fingerprint %s", e.expected, e.actual)
+}
+
+// Snapshot is the persistable state of an instance
+type Snapshot struct {
+	// Machine is the name of the machine, if named
+	Machine string `json:"machine,omitempty"`
+	// ID is the identifier of the instance
+	ID    string `json:"id,omitempty"`
+	State string `json:"state"`
+	// Fingerprint of the machine definition when the snapshot was taken
+	Fingerprint string `json:"fingerprint"`
+	// Deadlines are the pending SLAs, by name
+	Deadlines map[string]time.Time `json:"deadlines,omitempty"`
+	// Sub is the snapshot of the running sub-machine, if any
+	Sub *Snapshot `json:"sub,omitempty"`
+	// Processed are the idempotency keys remembered by the instance, from the oldest
+	Processed []string `json:"processed,omitempty"`
+	// Slot is set if the instance holds a quota slot of its state
+	Slot bool `json:"slot,omitempty"`
+}
+
+// OnDrift option sets the migration hook called when restoring a snapshot taken with a different machine definition.
+// The hook can return a migrated snapshot or an error.
+// Without a hook, restoring such a snapshot fails with ErrDefinitionDrift.
+func OnDrift(hook func(Snapshot) (Snapshot, error)) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.onDrift = hook
+	}
+}
+
+// Fingerprint returns a stable hash of the machine definition: states and their transitions.
+// The registration order of states does not matter, but the order of the transitions of a state does,
+// since it decides which transition wins.
+func (s *StateMachine) Fingerprint() string {
+	states := make([]string, 0, len(s.states)+1)
+	all := s.states
+	if s.global != nil {
+		all = append(all[:len(all):len(all)], s.global)
+	}
+	for _, st := range all {
+		var b strings.Builder
+		fmt.Fprintf(&b, "state %q", st.name)
+		if st.parent != nil {
+			fmt.Fprintf(&b, " parent %q", st.parent.name)
+		}
+		if st.final {
+			b.WriteString(" final")
+		}
+		if st.sub != nil {
+			fmt.Fprintf(&b, " sub %s", st.sub.fingerprint())
+		}
+		if st.deadline != nil {
+			fmt.Fprintf(&b, " deadline %d escalate %q", st.deadline.within, fmt.Sprintf("%+v", toEventer(st.deadline.escalation).Kind()))
+		}
+		b.WriteString("\n")
+		for _, k := range st.deferred {
+			fmt.Fprintf(&b, "\tdefer %q\n", fmt.Sprintf("%+v", k))
+		}
+		for _, t := range st.transitions {
+			fmt.Fprintf(&b, "\t%q -> %q", t.name, t.state.name)
+			if t.action != nil {
+				b.WriteString(" internal")
+			}
+			if t.timeout > 0 {
+				fmt.Fprintf(&b, " timeout %d", t.timeout)
+			}
+			b.WriteString("\n")
+		}
+		states = append(states, b.String())
+	}
+	for _, sl := range s.slas {
+		states = append(states, fmt.Sprintf("sla %q %q -> %q within %d escalate %q\n", sl.name, fmt.Sprintf("%+v", sl.event), sl.target.name, sl.within, fmt.Sprintf("%+v", toEventer(sl.escalation).Kind())))
+	}
+	sort.Strings(states)
+
+	h := sha256.New()
+	for _, st := range states {
+		h.Write([]byte(st))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Snapshot returns the persistable state of the instance
+func (m *StateMachineInstance) Snapshot() Snapshot {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.snapshot()
+}
+
+// snapshot must be called while holding the lock
+func (m *StateMachineInstance) snapshot() Snapshot {
+	snap := Snapshot{
+		Machine:     m.name,
+		ID:          m.id,
+		State:       m.currentState.name,
+		Fingerprint: m.Fingerprint(),
+	}
+	if len(m.deadlines) > 0 {
+		snap.Deadlines = make(map[string]time.Time, len(m.deadlines))
+		for k, v := range m.deadlines {
+			snap.Deadlines[k] = v
+		}
+	}
+	if m.sub != nil {
+		sub := m.sub.Snapshot()
+		snap.Sub = &sub
+	}
+	snap.Processed = m.seen.list()
+	snap.Slot = m.slots.held(m, m.currentState) > 0
+	return snap
+}
+
+// Restore creates an instance from a snapshot, with its pending SLA deadlines, armed once the instance is started.
+// If the snapshot was taken with a different definition, the OnDrift hook is called to migrate it.
+// The restored instance is not counted again in the occupancy metrics, since it was when created,
+// and is counted by the monitor only if no instance with its ID was counted in this process.
+func (s *StateMachine) Restore(snap Snapshot) (*StateMachineInstance, error) {
+	if fp := s.Fingerprint(); snap.Fingerprint != "" && snap.Fingerprint != fp {
+		if s.onDrift == nil {
+			return nil, &ErrDefinitionDrift{expected: snap.Fingerprint, actual: fp}
+		}
+		var err error
+		snap, err = s.onDrift(snap)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the instance was accounted for in the metrics when created
+	state, err := s.lookupState(snap.State)
+	if err != nil {
+		return nil, err
+	}
+	m := s.newInstance(state)
+	if snap.ID != "" {
+		m.id = snap.ID
+	}
+	s.monitor.track(m.id, state)
+	if snap.Slot {
+		m.slot = state
+	}
+	for _, k := range snap.Processed {
+		m.remember(k)
+	}
+	if state.sub != nil && snap.Sub != nil {
+		sub, err := state.sub.machine.Restore(*snap.Sub)
+		if err != nil {
+			return nil, fmt.Errorf("unable to restore sub-machine of state %s: %w", m.currentState.name, err)
+		}
+		sub.slots = m.slots
+		sub.logs = m.logs
+		m.sub = sub
+	} else {
+		m.startSub()
+	}
+	for _, sl := range s.slas {
+		if at, ok := snap.Deadlines[sl.name]; ok {
+			if m.deadlines == nil {
+				m.deadlines = map[string]time.Time{}
+			}
+			m.deadlines[sl.name] = at
+		}
+	}
+	// the deadline of the state started when it was entered, not when restored
+	if at, ok := snap.Deadlines[m.currentState.DeadlineName()]; ok && m.currentState.deadline != nil {
+		m.deadlines[m.currentState.DeadlineName()] = at
+		m.timerOps = nil
+	}
+	return m, nil
+}
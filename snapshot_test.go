@@ -0,0 +1,52 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFingerprint(t *testing.T) {
+	smi1, _, _, err := createFSM()
+	require.NoError(t, err)
+	smi2, states, _, err := createFSM()
+	require.NoError(t, err)
+
+	require.Equal(t, smi1.Fingerprint(), smi2.Fingerprint())
+
+	states.green.AddTransition(LOOP, states.green)
+	require.NotEqual(t, smi1.Fingerprint(), smi2.Fingerprint())
+}
+
+func TestRestore(t *testing.T) {
+	smi, states, _, err := createFSM()
+	require.NoError(t, err)
+	smi.Fire(TICK)
+
+	snap := smi.Snapshot()
+	restored, err := smi.Restore(snap)
+	require.NoError(t, err)
+	require.Equal(t, stateYellow, restored.State().Name())
+
+	// drift
+	states.green.AddTransition(LOOP, states.green)
+	_, err = smi.Restore(snap)
+	var drift *fsm.ErrDefinitionDrift
+	require.ErrorAs(t, err, &drift)
+}
+
+func TestRestoreWithDriftHook(t *testing.T) {
+	var drifted []fsm.Snapshot
+	sm := fsm.New(fsm.OnDrift(func(snap fsm.Snapshot) (fsm.Snapshot, error) {
+		drifted = append(drifted, snap)
+		snap.State = "NEW"
+		return snap, nil
+	}))
+	sm.AddState("NEW")
+
+	smi, err := sm.Restore(fsm.Snapshot{State: "OLD", Fingerprint: "abc"})
+	require.NoError(t, err)
+	require.Equal(t, "NEW", smi.State().Name())
+	require.Len(t, drifted, 1)
+}
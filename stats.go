@@ -0,0 +1,142 @@
+package fsm
+
+import "time"
+
+// InstanceStats are the statistics of an instance, since it was created
+type InstanceStats struct {
+	// Transitions is the number of transitions, including internal and chained ones
+	Transitions int
+	// States are the statistics of the states the instance has been in, by name
+	States map[string]StateStats
+}
+
+// StateStats are the statistics of a state of an instance
+type StateStats struct {
+	// Entries is the number of times the state was entered by a transition,
+	// not counting the state the instance was created in
+	Entries int
+	// Dwell is the cumulative time spent in the state, including the current stay
+	Dwell time.Duration
+	// LastDwell is the time spent in the state the last time it was left
+	LastDwell time.Duration
+}
+
+// dwellStats tracks the time spent in each state
+type dwellStats struct {
+	// enteredAt is when the current state was entered
+	enteredAt time.Time
+	states    map[*State]*StateStats
+}
+
+// statsEntry is what entered changed, to undo it if the transition fails afterwards
+type statsEntry struct {
+	enteredAt time.Time
+	left      *State
+	next      *State
+	// leftStats and nextStats are the previous statistics, if hadLeft and hadNext
+	leftStats StateStats
+	nextStats StateStats
+	hadLeft   bool
+	hadNext   bool
+}
+
+// entered records that the instance left the state for the next one,
+// before any chained transition, so that the dwell times are credited in order.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) entered(left, next *State, now time.Time) statsEntry {
+	if m.stats.states == nil {
+		m.stats.states = map[*State]*StateStats{}
+	}
+	entry := statsEntry{
+		enteredAt: m.stats.enteredAt,
+		left:      left,
+		next:      next,
+	}
+	entry.leftStats, entry.hadLeft = m.stats.previous(left)
+	entry.nextStats, entry.hadNext = m.stats.previous(next)
+	if left != nil {
+		enteredAt := m.stats.enteredAt
+		if enteredAt.IsZero() {
+			enteredAt = m.createdAt
+		}
+		dwell := now.Sub(enteredAt)
+		st := m.stats.stateStats(left)
+		st.Dwell += dwell
+		st.LastDwell = dwell
+	}
+	m.stats.stateStats(next).Entries++
+	m.stats.enteredAt = now
+	return entry
+}
+
+// undoEntered restores the statistics changed by entered.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) undoEntered(e statsEntry) {
+	m.stats.enteredAt = e.enteredAt
+	m.stats.restore(e.next, e.nextStats, e.hadNext)
+	m.stats.restore(e.left, e.leftStats, e.hadLeft)
+}
+
+// previous returns a copy of the statistics of the state, and if there were any
+func (s *dwellStats) previous(st *State) (StateStats, bool) {
+	ss, ok := s.states[st]
+	if !ok || st == nil {
+		return StateStats{}, false
+	}
+	return *ss, true
+}
+
+func (s *dwellStats) restore(st *State, prev StateStats, had bool) {
+	if st == nil {
+		return
+	}
+	if !had {
+		delete(s.states, st)
+		return
+	}
+	*s.states[st] = prev
+}
+
+func (s dwellStats) clone() dwellStats {
+	c := dwellStats{enteredAt: s.enteredAt}
+	if s.states != nil {
+		c.states = make(map[*State]*StateStats, len(s.states))
+		for st, ss := range s.states {
+			v := *ss
+			c.states[st] = &v
+		}
+	}
+	return c
+}
+
+func (s *dwellStats) stateStats(st *State) *StateStats {
+	ss := s.states[st]
+	if ss == nil {
+		ss = &StateStats{}
+		s.states[st] = ss
+	}
+	return ss
+}
+
+// InstanceStats returns how many times each state was entered and how long the instance stayed in it,
+// to feed operational dashboards without wrapping every handler.
+// It is safe to call while other goroutines fire events.
+func (m *StateMachineInstance) InstanceStats() InstanceStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stats := InstanceStats{
+		Transitions: m.steps,
+		States:      make(map[string]StateStats, len(m.stats.states)+1),
+	}
+	for st, ss := range m.stats.states {
+		stats.States[st.name] = *ss
+	}
+	enteredAt := m.stats.enteredAt
+	if enteredAt.IsZero() {
+		enteredAt = m.createdAt
+	}
+	current := stats.States[m.currentState.name]
+	current.Dwell += time.Since(enteredAt)
+	stats.States[m.currentState.name] = current
+	return stats
+}
@@ -0,0 +1,94 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceStats(t *testing.T) {
+	sm := fsm.New()
+	green := sm.AddState("GREEN")
+	yellow := sm.AddState("YELLOW")
+	red := sm.AddState("RED")
+	green.AddTransition("next", yellow)
+	green.AddInternalTransition("tick", func(*fsm.Context) error { return nil })
+	yellow.AddTransition("next", red)
+	red.AddTransition("next", green)
+
+	smi := sm.FromState(green)
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, smi.Fire("tick"))
+	require.NoError(t, smi.Fire("next"))
+	require.NoError(t, smi.Fire("next"))
+	require.NoError(t, smi.Fire("next"))
+	time.Sleep(10 * time.Millisecond)
+
+	stats := smi.InstanceStats()
+	require.Equal(t, 4, stats.Transitions)
+	require.Len(t, stats.States, 3)
+	require.Equal(t, 1, stats.States["GREEN"].Entries)
+	require.Equal(t, 1, stats.States["YELLOW"].Entries)
+	require.Equal(t, 1, stats.States["RED"].Entries)
+
+	g := stats.States["GREEN"]
+	require.GreaterOrEqual(t, g.LastDwell, 20*time.Millisecond)
+	require.GreaterOrEqual(t, g.Dwell, g.LastDwell+10*time.Millisecond)
+	require.Less(t, stats.States["YELLOW"].Dwell, 20*time.Millisecond)
+	require.Equal(t, stats.States["YELLOW"].Dwell, stats.States["YELLOW"].LastDwell)
+}
+
+func TestInstanceStatsChained(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	r := sm.AddState("R")
+	a.AddTransition("go", b)
+	b.AddTransition("done", r)
+	b.AddOnEvent(func(c *fsm.Context) error {
+		time.Sleep(10 * time.Millisecond)
+		return c.Fire("done")
+	})
+
+	smi := sm.FromState(a)
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(t, smi.Fire("go"))
+
+	stats := smi.InstanceStats()
+	require.Equal(t, 2, stats.Transitions)
+	require.GreaterOrEqual(t, stats.States["A"].LastDwell, 50*time.Millisecond)
+	require.GreaterOrEqual(t, stats.States["B"].LastDwell, 10*time.Millisecond)
+	require.Less(t, stats.States["B"].LastDwell, 50*time.Millisecond)
+	require.Equal(t, 1, stats.States["B"].Entries)
+	require.Equal(t, 1, stats.States["R"].Entries)
+}
+
+func TestInstanceStatsFailed(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	c := sm.AddState("C", fsm.OnEvent(func(*fsm.Context) error {
+		return errors.New("boom")
+	}))
+	a.AddTransition("go", b)
+	b.AddTransition("go", c)
+
+	smi := sm.FromState(a)
+	_, err := smi.FireAllAtomic("go", "go")
+	require.Error(t, err)
+	stats := smi.InstanceStats()
+	require.Equal(t, 0, stats.Transitions)
+	require.Equal(t, map[string]fsm.StateStats{"A": stats.States["A"]}, stats.States)
+	require.Equal(t, 0, stats.States["A"].Entries)
+
+	// a failed transition is not an entry
+	require.NoError(t, smi.Fire("go"))
+	require.Error(t, smi.Fire("go"))
+	stats = smi.InstanceStats()
+	require.Equal(t, 1, stats.Transitions)
+	require.Equal(t, 1, stats.States["B"].Entries)
+	require.NotContains(t, stats.States, "C")
+}
@@ -0,0 +1,52 @@
+package fsm
+
+import "fmt"
+
+type ErrForeignState struct {
+	state string
+}
+
+func (e *ErrForeignState) Error() string {
+	return fmt.Sprintf("state %s is not in the machine", e.state)
+}
+
+// State returns the name of the foreign state
+func (e *ErrForeignState) State() string {
+	return e.state
+}
+
+// Strict option turns misuses that are otherwise silent into failures, to catch bugs during development:
+//   - adding a state with the name of an existing one panics with ErrDuplicateState, like with RejectDuplicateStates
+//   - adding a transition for an event key already handled by the state panics with ErrDuplicateTransition,
+//     like with RejectDuplicateTransitions
+//   - adding a transition from or to a state that is not in the machine, like a replaced state
+//     or a state of another machine, panics with ErrForeignState
+//   - calling Context.Fire outside of OnEvent, or of the action of an internal transition,
+//     fails the transition with ErrFireNotAllowed, even if the handler ignores the error
+func Strict() func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.strict = true
+		s.rejectDuplicates = true
+		s.rejectDuplicateTransitions = true
+	}
+}
+
+// owns returns true if the state, or pseudo-state, belongs to the machine
+func (s *StateMachine) owns(st *State) bool {
+	if st.machine != s {
+		return false
+	}
+	return st == s.global || st.pseudo != nil || s.byName[s.nameKey(st.name)] == st
+}
+
+// checkOwned panics with ErrForeignState, on strict machines, if the transition is from or to a state not in the machine
+func (s *State) checkOwned(t *transition) {
+	if !s.machine.strict {
+		return
+	}
+	for _, st := range []*State{s, t.state} {
+		if st != nil && !s.machine.owns(st) {
+			panic(&ErrForeignState{state: st.name})
+		}
+	}
+}
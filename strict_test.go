@@ -0,0 +1,67 @@
+package fsm_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictRejectsDuplicates(t *testing.T) {
+	sm := fsm.New(fsm.Strict())
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition("go", b)
+
+	require.PanicsWithError(t, "state A is already defined", func() {
+		sm.AddState("A")
+	})
+	require.PanicsWithError(t, "state A already has a transition for event go", func() {
+		a.AddTransition("go", a)
+	})
+}
+
+func TestStrictRejectsForeignStates(t *testing.T) {
+	other := fsm.New()
+	foreign := other.AddState("X")
+
+	sm := fsm.New(fsm.Strict())
+	a := sm.AddState("A")
+	require.PanicsWithError(t, "state X is not in the machine", func() {
+		a.AddTransition("go", foreign)
+	})
+	require.PanicsWithError(t, "state X is not in the machine", func() {
+		sm.AddGlobalTransition("reset", foreign)
+	})
+
+	// without strict mode, transitions to foreign states are accepted
+	lenient := fsm.New()
+	lenient.AddState("A").AddTransition("go", foreign)
+}
+
+func TestStrictContextFireMisuse(t *testing.T) {
+	sm := fsm.New(fsm.Strict())
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.OnEnter(func(c *fsm.Context) error {
+		// the error is ignored
+		_ = c.Fire("next")
+		return nil
+	}))
+	a.AddTransition("go", b)
+
+	smi := sm.FromState(a)
+	err := smi.Fire("go")
+	require.True(t, errors.Is(err, fsm.ErrFireNotAllowed), err)
+	require.Equal(t, a, smi.State())
+
+	// without strict mode, the misuse goes unnoticed
+	lenient := fsm.New()
+	a = lenient.AddState("A")
+	b = lenient.AddState("B", fsm.OnEnter(func(c *fsm.Context) error {
+		_ = c.Fire("next")
+		return nil
+	}))
+	a.AddTransition("go", b)
+	require.NoError(t, lenient.FromState(a).Fire("go"))
+}
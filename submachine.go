@@ -0,0 +1,96 @@
+package fsm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// subMachine is the machine implementing a state
+type subMachine struct {
+	machine *StateMachine
+	// exits maps the final states of the sub-machine to the events fired in the parent
+	exits map[string]interface{}
+}
+
+// AddSubMachineState adds a state implemented by the sub machine.
+// Entering the state starts an instance of the sub machine at its first state,
+// and the events fired while in the state are handled by the sub-machine, or by the state when the sub-machine has no transition for them.
+// When the sub-machine reaches a final state, the event mapped to its name in exitMapping, if any, is fired in the parent.
+// Sub-machines are only run by instances.
+func (s *StateMachine) AddSubMachineState(name string, sub *StateMachine, exitMapping map[string]interface{}, opts ...func(*State)) *State {
+	if len(sub.states) == 0 {
+		panic(fmt.Sprintf("fsm: sub-machine of state %s has no states", name))
+	}
+	exits := make(map[string]interface{}, len(exitMapping))
+	for k, v := range exitMapping {
+		exits[k] = v
+	}
+	state := s.AddState(name, opts...)
+	state.sub = &subMachine{
+		machine: sub,
+		exits:   exits,
+	}
+	return state
+}
+
+// SubMachine returns the machine implementing the state, or nil
+func (s *State) SubMachine() *StateMachine {
+	if s.sub == nil {
+		return nil
+	}
+	return s.sub.machine
+}
+
+// SubInstance returns the running instance of the sub-machine of the current state, or nil
+func (m *StateMachineInstance) SubInstance() *StateMachineInstance {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sub
+}
+
+// startSub starts the sub-machine of the current state, if any
+func (m *StateMachineInstance) startSub() {
+	m.sub = nil
+	if m.currentState.sub == nil {
+		return
+	}
+	sub := m.currentState.sub.machine
+	m.sub = sub.FromState(sub.states[0])
+	sub.monitor.rename(m.sub.id, m.id)
+	m.sub.id = m.id
+	m.sub.slots = m.slots
+	m.sub.logs = m.logs
+	m.sub.payload = m.payload
+}
+
+// stepSub fires the event into the running sub-machine, returning false if the sub-machine does not handle it.
+// Reaching a final state of the sub-machine fires the mapped exit event in this instance.
+func (m *StateMachineInstance) stepSub(goCtx context.Context, key interface{}) (bool, error) {
+	err := m.sub.fire(m.withValues(goCtx), key)
+	var notFound *ErrTransitionNotFound
+	if errors.As(err, &notFound) || errors.Is(err, ErrMachineCompleted) || (err == nil && m.sub.outcome == Ignored) {
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	if !m.sub.currentState.final {
+		return true, nil
+	}
+	event, ok := m.currentState.sub.exits[m.sub.currentState.name]
+	if !ok {
+		return true, nil
+	}
+	return true, m.stepSelf(goCtx, event)
+}
+
+func (s *subMachine) fingerprint() string {
+	exits := make([]string, 0, len(s.exits))
+	for k, v := range s.exits {
+		exits = append(exits, fmt.Sprintf("%q=%q", k, fmt.Sprintf("%+v", toEventer(v).Kind())))
+	}
+	sort.Strings(exits)
+	return fmt.Sprintf("%s %v", s.machine.Fingerprint(), exits)
+}
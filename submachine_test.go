@@ -0,0 +1,80 @@
+package fsm_test
+
+import (
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func paymentMachine() *fsm.StateMachine {
+	sub := fsm.New()
+	pending := sub.AddState("PENDING")
+	paid := sub.AddState("PAID", fsm.Final())
+	failed := sub.AddState("FAILED", fsm.Final())
+	pending.AddTransition(APPROVE, paid)
+	pending.AddTransition(CANCEL, failed)
+	return sub
+}
+
+func TestSubMachine(t *testing.T) {
+	var entered []string
+	sm := fsm.New()
+	cart := sm.AddState("CART")
+	payment := sm.AddSubMachineState("PAYMENT", paymentMachine(), map[string]interface{}{
+		"PAID":   "PAYMENT_DONE",
+		"FAILED": "PAYMENT_FAILED",
+	}, fsm.OnEnter(func(c *fsm.Context) error {
+		entered = append(entered, c.ToState().Name())
+		return nil
+	}))
+	shipping := sm.AddState("SHIPPING")
+	cancelled := sm.AddState("CANCELLED")
+	cart.AddTransition(SUBMIT, payment)
+	payment.AddTransition("PAYMENT_DONE", shipping)
+	payment.AddTransition("PAYMENT_FAILED", cart)
+	payment.AddTransition(ESCALATE, cancelled)
+
+	smi := sm.FromState(cart)
+	require.Nil(t, smi.SubInstance())
+	require.NoError(t, smi.Fire(SUBMIT))
+	require.Equal(t, payment, smi.State())
+	require.Equal(t, "PENDING", smi.SubInstance().State().Name())
+
+	// failed payment goes back to the cart
+	require.NoError(t, smi.Fire(CANCEL))
+	require.Equal(t, cart, smi.State())
+	require.Nil(t, smi.SubInstance())
+
+	// the sub-machine restarts
+	require.NoError(t, smi.Fire(SUBMIT))
+	require.Equal(t, "PENDING", smi.SubInstance().State().Name())
+	require.NoError(t, smi.Fire(APPROVE))
+	require.Equal(t, shipping, smi.State())
+	require.Equal(t, []string{"PAYMENT", "PAYMENT"}, entered)
+
+	// events not handled by the sub-machine are handled by the state
+	smi = sm.FromState(payment)
+	require.NoError(t, smi.Fire(ESCALATE))
+	require.Equal(t, cancelled, smi.State())
+}
+
+func TestSubMachineSnapshot(t *testing.T) {
+	sm := fsm.New()
+	payment := sm.AddSubMachineState("PAYMENT", paymentMachine(), map[string]interface{}{"PAID": "PAYMENT_DONE"})
+	shipping := sm.AddState("SHIPPING")
+	payment.AddTransition("PAYMENT_DONE", shipping)
+
+	smi := sm.FromState(payment)
+	snap := smi.Snapshot()
+	require.Equal(t, "PENDING", snap.Sub.State)
+
+	restored, err := sm.Restore(snap)
+	require.NoError(t, err)
+	require.NoError(t, restored.Fire(APPROVE))
+	require.Equal(t, shipping, restored.State())
+
+	_, err = sm.Definition()
+	require.Error(t, err)
+	require.NotEqual(t, sm.Fingerprint(), fsm.New().Fingerprint())
+}
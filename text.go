@@ -0,0 +1,105 @@
+package fsm
+
+import "strings"
+
+// Text renders the machine as a tree of states, with their transitions and sub-states,
+// to inspect it in terminals and logs without Graphviz. For example:
+//
+//	GREEN
+//	|-- TICK -> YELLOW
+//	`-- fallback -> EXIT
+//	ACTIVE
+//	|-- stop -> IDLE
+//	`-- RUNNING
+//	    `-- pause -> PAUSED
+func (m *StateMachine) Text() string {
+	return m.text(nil)
+}
+
+// Text renders the machine as a tree, marking the current state.
+// It is safe to call while other goroutines fire events.
+func (m *StateMachineInstance) Text() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.StateMachine.text(m.currentState)
+}
+
+// textItem is a line of the tree: a transition or a sub-state
+type textItem struct {
+	line  string
+	state *State
+}
+
+func (m *StateMachine) text(currentState *State) string {
+	var buf strings.Builder
+	if m.name != "" {
+		buf.WriteString("# " + m.name + "\n")
+	}
+	for _, st := range m.states {
+		if st.parent == nil {
+			writeTextTree(&buf, st, "", "", currentState)
+		}
+	}
+	if m.global != nil && len(m.global.transitions) > 0 {
+		buf.WriteString("(any state)\n")
+		writeTextItems(&buf, textTransitions(m.global), "", currentState)
+	}
+	return buf.String()
+}
+
+// writeTextTree renders the state, and below it its transitions and sub-states
+func writeTextTree(buf *strings.Builder, st *State, head, prefix string, currentState *State) {
+	buf.WriteString(head + st.name)
+	var marks []string
+	if st == currentState {
+		marks = append(marks, "current")
+	}
+	if st.final {
+		marks = append(marks, "final")
+	}
+	if st.choice {
+		marks = append(marks, "choice")
+	}
+	if len(marks) > 0 {
+		buf.WriteString(" (" + strings.Join(marks, ", ") + ")")
+	}
+	buf.WriteString("\n")
+
+	items := textTransitions(st)
+	for _, c := range st.children {
+		items = append(items, textItem{state: c})
+	}
+	writeTextItems(buf, items, prefix, currentState)
+}
+
+func writeTextItems(buf *strings.Builder, items []textItem, prefix string, currentState *State) {
+	for i, it := range items {
+		connector, next := "|-- ", "|   "
+		if i == len(items)-1 {
+			connector, next = "`-- ", "    "
+		}
+		if it.state != nil {
+			writeTextTree(buf, it.state, prefix+connector, prefix+next, currentState)
+			continue
+		}
+		buf.WriteString(prefix + connector + it.line + "\n")
+	}
+}
+
+// textTransitions renders the transitions declared on the state
+func textTransitions(st *State) []textItem {
+	items := make([]textItem, 0, len(st.transitions))
+	for _, t := range st.transitions {
+		line := t.label()
+		if t.action != nil {
+			line += " (internal)"
+		} else {
+			line += " -> " + t.state.name
+		}
+		if t.deprecated {
+			line += " (deprecated)"
+		}
+		items = append(items, textItem{line: line})
+	}
+	return items
+}
@@ -0,0 +1,86 @@
+package fsm_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func ExampleStateMachineInstance_Text() {
+	smi, _, _, err := createFSM()
+	if err != nil {
+		panic(err)
+	}
+	smi.Fire(TICK)
+
+	fmt.Print(smi.Text())
+	// Output:
+	// GREEN
+	// `-- TICK -> YELLOW
+	// YELLOW (current)
+	// |-- TICK -> BOUNCE
+	// `-- fallback -> EXIT
+	// BOUNCE
+	// `-- CONTINUE -> RED
+	// RED
+	// |-- TICK -> GREEN
+	// `-- LOOP -> RED
+	// EXIT
+}
+
+func ExampleStateMachine_Text() {
+	sm := fsm.New(fsm.WithName("player"))
+	idle := sm.AddState("IDLE")
+	active := sm.AddState("ACTIVE")
+	running := sm.AddState("RUNNING", fsm.ChildOf(active))
+	paused := sm.AddState("PAUSED", fsm.ChildOf(active))
+	done := sm.AddState("DONE", fsm.Final())
+	idle.AddTransition("start", active)
+	running.AddTransition("pause", paused)
+	running.AddInternalTransition("seek", func(c *fsm.Context) error {
+		return nil
+	})
+	paused.AddConditionalTransition("idle", running, func(c *fsm.Context) bool {
+		return true
+	})
+	active.AddTransition("stop", idle)
+	sm.AddGlobalTransition("eject", done)
+
+	fmt.Print(sm.Text())
+	// Output:
+	// # player
+	// IDLE
+	// `-- start -> ACTIVE
+	// ACTIVE
+	// |-- stop -> IDLE
+	// |-- RUNNING
+	// |   |-- pause -> PAUSED
+	// |   `-- seek (internal)
+	// `-- PAUSED
+	//     `-- [idle] -> RUNNING
+	// DONE (final)
+	// (any state)
+	// `-- eject -> DONE
+}
+
+func TestTextChoice(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	c := sm.AddChoice("C", fsm.Case("ok", func(*fsm.Context) bool { return true }, b), fsm.Otherwise(a))
+	a.AddTransition("go", c)
+
+	lines := strings.Split(sm.FromState(b).Text(), "\n")
+	require.Equal(t, []string{
+		"A",
+		"`-- go -> C",
+		"B (current)",
+		"C (choice)",
+		"|-- [ok] -> B",
+		"`-- fallback -> A",
+		"",
+	}, lines)
+}
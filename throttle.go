@@ -0,0 +1,157 @@
+package fsm
+
+import "time"
+
+// throttle limits how often a transition is taken by an instance
+type throttle struct {
+	debounce time.Duration
+	limit    int
+	per      time.Duration
+	coalesce bool
+}
+
+// Debounce option drops the events of the transition arriving within d of the previous one, taken or dropped,
+// so that a burst of events, like button presses, takes the transition only once.
+// Dropped events are reported as Ignored by TryFire. It only applies to events fired into instances.
+func Debounce(d time.Duration) TransitionOption {
+	return func(t *transition) {
+		t.throttleConfig().debounce = d
+	}
+}
+
+// RateLimit option takes the transition at most n times per interval, for each instance,
+// dropping the excess events, like sensor ticks. Dropped events are reported as Ignored by TryFire.
+// It only applies to events fired into instances.
+func RateLimit(n int, per time.Duration) TransitionOption {
+	return func(t *transition) {
+		th := t.throttleConfig()
+		th.limit = n
+		th.per = per
+	}
+}
+
+// Coalesce option, used with Debounce or RateLimit, keeps the last dropped event instead of dropping it,
+// and fires it once the burst is over or the rate allows it.
+// Like timeout transitions, coalesced events are only fired by started instances,
+// and the pending one is discarded when the state changes.
+func Coalesce() TransitionOption {
+	return func(t *transition) {
+		t.throttleConfig().coalesce = true
+	}
+}
+
+func (t *transition) throttleConfig() *throttle {
+	if t.throttle == nil {
+		t.throttle = &throttle{}
+	}
+	return t.throttle
+}
+
+// throttleState is the state of a throttled transition of an instance
+type throttleState struct {
+	// last is the arrival of the last event, for Debounce
+	last time.Time
+	// taken are the times the transition was taken, within the RateLimit interval
+	taken []time.Time
+	// pending is the coalesced event, waiting for the timer
+	pending *pendingEvent
+	timer   *time.Timer
+}
+
+type pendingEvent struct {
+	key interface{}
+}
+
+// throttled returns true if the event of the transition must be dropped, coalescing it if configured.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) throttled(t *transition, ctx *Context) bool {
+	th := t.throttle
+	if th == nil || (th.debounce <= 0 && th.limit <= 0) {
+		return false
+	}
+	if m.throttles == nil {
+		m.throttles = map[*transition]*throttleState{}
+	}
+	st := m.throttles[t]
+	if st == nil {
+		st = &throttleState{}
+		m.throttles[t] = st
+	}
+
+	now := time.Now()
+	var wait time.Duration
+	drop := false
+	if th.debounce > 0 {
+		if !st.last.IsZero() && now.Sub(st.last) < th.debounce {
+			drop = true
+			wait = th.debounce
+		}
+		st.last = now
+	}
+	if !drop && th.limit > 0 {
+		// forget the transitions out of the interval
+		i := 0
+		for i < len(st.taken) && now.Sub(st.taken[i]) >= th.per {
+			i++
+		}
+		st.taken = st.taken[i:]
+		if len(st.taken) >= th.limit {
+			drop = true
+			wait = th.per - now.Sub(st.taken[0])
+		}
+	}
+	if !drop {
+		if th.limit > 0 {
+			st.taken = append(st.taken, now)
+		}
+		return false
+	}
+	if th.coalesce {
+		var key interface{} = ctx.event
+		if ctx.event == nil {
+			key = ctx.raw
+		}
+		m.coalesce(st, key, wait)
+	}
+	return true
+}
+
+// coalesce replaces the pending event of the transition, firing it after the wait.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) coalesce(st *throttleState, key interface{}, wait time.Duration) {
+	p := &pendingEvent{key: key}
+	st.pending = p
+	if st.timer != nil {
+		st.timer.Stop()
+		st.timer = nil
+	}
+	if !m.scheduler.running {
+		return
+	}
+	onError := m.scheduler.onError
+	st.timer = time.AfterFunc(wait, func() {
+		err := m.fireWhen(nil, func() bool {
+			if st.pending != p {
+				return false
+			}
+			st.pending = nil
+			st.timer = nil
+			return true
+		}, p.key)
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
+
+// cancelCoalesced discards the pending coalesced events.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) cancelCoalesced() {
+	for _, st := range m.throttles {
+		if st.timer != nil {
+			st.timer.Stop()
+			st.timer = nil
+		}
+		st.pending = nil
+	}
+}
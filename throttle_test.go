@@ -0,0 +1,94 @@
+package fsm_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+type press struct {
+	N int
+}
+
+func (press) Kind() interface{} {
+	return "press"
+}
+
+func TestDebounce(t *testing.T) {
+	sm := fsm.New()
+	off := sm.AddState("OFF")
+	on := sm.AddState("ON")
+	off.AddTransition("press", on, fsm.Debounce(50*time.Millisecond))
+	on.AddTransition("press", off)
+
+	smi := sm.FromState(off)
+	outcome, err := smi.TryFire(context.Background(), "press")
+	require.NoError(t, err)
+	require.Equal(t, fsm.Handled, outcome)
+	require.NoError(t, smi.Fire("press"))
+	require.Equal(t, off, smi.State())
+
+	// the burst is still going on
+	outcome, err = smi.TryFire(context.Background(), "press")
+	require.NoError(t, err)
+	require.Equal(t, fsm.Ignored, outcome)
+	require.Equal(t, off, smi.State())
+
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, smi.Fire("press"))
+	require.Equal(t, on, smi.State())
+}
+
+func TestRateLimit(t *testing.T) {
+	sm := fsm.New()
+	idle := sm.AddState("IDLE")
+	var ticks int
+	idle.AddInternalTransition("tick", func(*fsm.Context) error {
+		ticks++
+		return nil
+	}, fsm.RateLimit(2, 50*time.Millisecond))
+
+	smi := sm.FromState(idle)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, smi.Fire("tick"))
+	}
+	require.Equal(t, 2, ticks)
+
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, smi.Fire("tick"))
+	require.Equal(t, 3, ticks)
+}
+
+func TestCoalesce(t *testing.T) {
+	sm := fsm.New()
+	idle := sm.AddState("IDLE")
+	var mu sync.Mutex
+	var got []int
+	idle.AddInternalTransition("press", func(c *fsm.Context) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, c.Data().(press).N)
+		return nil
+	}, fsm.Debounce(30*time.Millisecond), fsm.Coalesce())
+
+	smi := sm.FromState(idle)
+	smi.Start(func(err error) {
+		t.Error(err)
+	})
+	defer smi.Stop()
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, smi.Fire(press{N: i}))
+	}
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) == 2
+	}, time.Second, 5*time.Millisecond)
+	mu.Lock()
+	require.Equal(t, []int{1, 4}, got)
+	mu.Unlock()
+}
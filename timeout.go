@@ -0,0 +1,106 @@
+package fsm
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timeout is the event fired by a timeout transition
+type Timeout struct {
+	After time.Duration
+}
+
+func (t Timeout) Kind() interface{} {
+	return t
+}
+
+func (t Timeout) String() string {
+	return fmt.Sprintf("timeout(%s)", t.After)
+}
+
+// AddTimeoutTransition adds a transition that is fired automatically
+// after a started instance has been in this state for the given duration.
+func (s *State) AddTimeoutTransition(after time.Duration, to *State, opts ...TransitionOption) *State {
+	key := Timeout{After: after}
+	return s.addTransition(&transition{
+		name:  key.String(),
+		state: to,
+		condition: func(c *Context) bool {
+			// bypass any key normalization
+			return c.kind() == key
+		},
+		timeout: after,
+	}, opts)
+}
+
+// scheduler holds the timers of the timeout transitions of the current state
+type scheduler struct {
+	running bool
+	// gen is incremented every time the timers are rescheduled, invalidating the pending ones
+	gen     uint64
+	timers  []*time.Timer
+	onError func(error)
+}
+
+// Start starts the scheduling of timeout transitions and periodic events for the current state, and of the deadlines,
+// and the asynchronous handler of the current state, if not running.
+// Errors returned when firing a timeout transition or an async completion are passed to onError, if not nil.
+func (m *StateMachineInstance) Start(onError func(error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scheduler.running = true
+	m.scheduler.onError = onError
+	m.schedule()
+	m.scheduleSLAs()
+	if m.async.cancel == nil {
+		m.startAsync(nil)
+	}
+}
+
+// Stop cancels any pending timeout transition, periodic event, escalation and asynchronous handler.
+func (m *StateMachineInstance) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scheduler.running = false
+	m.schedule()
+	m.scheduleSLAs()
+	m.stopAsync()
+}
+
+// schedule replaces the pending timers with the ones of the timeout transitions and periodic events of the current state.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) schedule() {
+	sc := &m.scheduler
+	for _, t := range sc.timers {
+		t.Stop()
+	}
+	sc.timers = nil
+	sc.gen++
+	m.cancelCoalesced()
+	if !sc.running {
+		return
+	}
+
+	gen := sc.gen
+	onError := sc.onError
+	for _, t := range m.currentState.transitions {
+		if t.timeout <= 0 {
+			continue
+		}
+		key := Timeout{After: t.timeout}
+		sc.timers = append(sc.timers, time.AfterFunc(t.timeout, func() {
+			if m.chaos.dropTimer(m.rnd) {
+				return
+			}
+			err := m.fireWhen(nil, func() bool {
+				return sc.gen == gen
+			}, key)
+			if err != nil && onError != nil {
+				onError(err)
+			}
+		}))
+	}
+	for _, p := range m.currentState.periodic {
+		m.schedulePeriodic(gen, p)
+	}
+}
@@ -0,0 +1,59 @@
+package fsm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutTransition(t *testing.T) {
+	sm := fsm.New()
+	waiting := sm.AddState("WAITING")
+	expired := sm.AddState("EXPIRED")
+	done := sm.AddState("DONE")
+	waiting.AddTimeoutTransition(10*time.Millisecond, expired)
+	waiting.AddTransition(CONTINUE, done)
+
+	smi := sm.FromState(waiting)
+	// not started
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, waiting, smi.State())
+
+	smi.Start(nil)
+	defer smi.Stop()
+	require.Eventually(t, func() bool {
+		return smi.State() == expired
+	}, time.Second, time.Millisecond)
+}
+
+func TestTimeoutTransitionCancelled(t *testing.T) {
+	sm := fsm.New()
+	waiting := sm.AddState("WAITING")
+	expired := sm.AddState("EXPIRED")
+	done := sm.AddState("DONE")
+	waiting.AddTimeoutTransition(20*time.Millisecond, expired)
+	waiting.AddTransition(CONTINUE, done)
+
+	smi := sm.FromState(waiting)
+	smi.Start(nil)
+	require.NoError(t, smi.Fire(CONTINUE))
+	time.Sleep(40 * time.Millisecond)
+	require.Equal(t, done, smi.State())
+
+	smi.Stop()
+}
+
+func TestTimeoutTransitionStopped(t *testing.T) {
+	sm := fsm.New()
+	waiting := sm.AddState("WAITING")
+	expired := sm.AddState("EXPIRED")
+	waiting.AddTimeoutTransition(20*time.Millisecond, expired)
+
+	smi := sm.FromState(waiting)
+	smi.Start(nil)
+	smi.Stop()
+	time.Sleep(40 * time.Millisecond)
+	require.Equal(t, waiting, smi.State())
+}
@@ -0,0 +1,86 @@
+package fsm
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Span is the timing of a guard evaluation or of a handler execution
+type Span struct {
+	// Kind is Guard or the kind of handler: OnExit, OnEnter, OnEvent or Action
+	Kind string
+	// State is the state owning the guard or the handler
+	State string
+	// Transition is the name of the transition of a guard
+	Transition string
+	Start      time.Time
+	Duration   time.Duration
+}
+
+// Tracer captures the timings of guards and handlers into a ring buffer.
+// It is safe for concurrent use and can be shared by several machines.
+type Tracer struct {
+	mu    sync.Mutex
+	spans ring[Span]
+}
+
+// NewTracer creates a tracer keeping the last size spans
+func NewTracer(size int) *Tracer {
+	return &Tracer{
+		spans: ring[Span]{size: size},
+	}
+}
+
+// WithTracer option sets the tracer capturing guard and handler timings.
+// Without a tracer, nothing is timed.
+func WithTracer(t *Tracer) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.tracer = t
+	}
+}
+
+func (t *Tracer) record(span Span) {
+	t.mu.Lock()
+	t.spans.add(span)
+	t.mu.Unlock()
+}
+
+// Spans returns the captured spans, from the oldest to the newest
+func (t *Tracer) Spans() []Span {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.spans.list()
+}
+
+// Dump writes the captured spans, one per line, from the oldest to the newest
+func (t *Tracer) Dump(w io.Writer) error {
+	for _, s := range t.Spans() {
+		name := s.State
+		if s.Transition != "" {
+			name = fmt.Sprintf("%s[%s]", s.State, s.Transition)
+		}
+		if _, err := fmt.Fprintf(w, "%s %-8s %s %s\n", s.Start.Format(time.RFC3339Nano), s.Kind, name, s.Duration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evalGuard evaluates the condition of the transition, timing it if there is a tracer
+func (s *StateMachine) evalGuard(state *State, t *transition, ctx *Context) bool {
+	if s.tracer == nil {
+		return t.condition(ctx)
+	}
+	start := time.Now()
+	ok := t.condition(ctx)
+	s.tracer.record(Span{
+		Kind:       "Guard",
+		State:      state.name,
+		Transition: t.name,
+		Start:      start,
+		Duration:   time.Since(start),
+	})
+	return ok
+}
@@ -0,0 +1,41 @@
+package fsm_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracer(t *testing.T) {
+	tracer := fsm.NewTracer(3)
+	sm := fsm.New(fsm.WithTracer(tracer))
+	a := sm.AddState("A", fsm.OnExit(func(c *fsm.Context) error {
+		return nil
+	}))
+	b := sm.AddState("B",
+		fsm.OnEnter(func(c *fsm.Context) error {
+			return nil
+		}),
+		fsm.OnEvent(func(c *fsm.Context) error {
+			return nil
+		}),
+	)
+	a.AddTransition(LOOP, a)
+	a.AddTransition(TICK, b)
+
+	require.NoError(t, sm.FromState(a).Fire(TICK))
+
+	var kinds []string
+	for _, s := range tracer.Spans() {
+		kinds = append(kinds, s.Kind+" "+s.State+" "+s.Transition)
+	}
+	// two guards and three handlers, only the last three are kept
+	require.Equal(t, []string{"OnExit A ", "OnEnter B ", "OnEvent B "}, kinds)
+
+	var buf bytes.Buffer
+	require.NoError(t, tracer.Dump(&buf))
+	require.Len(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), 3)
+}
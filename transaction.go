@@ -0,0 +1,47 @@
+package fsm
+
+import "context"
+
+// Transactor runs fn in a transaction, committing it if fn succeeds and rolling it back otherwise.
+// tx is the transaction handle, like a *sql.Tx, that handlers get with Context.Tx.
+type Transactor func(ctx context.Context, fn func(tx interface{}) error) error
+
+type txKey struct{}
+
+// WithTransaction option runs every Fire in a transaction started by the transactor,
+// surrounding the handlers of all the chained transitions and the persistence of the instance,
+// so that the transition, the persisted state and the messages written by the handlers, like to an outbox table,
+// are committed as one unit. A Fire that already runs in a transaction, like one from Manager.Fire, joins it.
+// If the transaction fails, the Fire returns the error and the instance is restored as it was before the Fire,
+// like with FireAllAtomic. Other side effects of the handlers are not undone.
+func WithTransaction(transactor Transactor) func(*StateMachine) {
+	return func(s *StateMachine) {
+		s.transactor = transactor
+	}
+}
+
+// TxFromContext returns the transaction handle carried by the context, or nil
+func TxFromContext(ctx context.Context) interface{} {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Value(txKey{})
+}
+
+// Tx returns the handle of the transaction the event is dispatched in, or nil if there is none
+func (c *Context) Tx() interface{} {
+	return TxFromContext(c.context)
+}
+
+// transact runs fn in a new transaction, unless there is no transactor or the context already carries a transaction
+func (s *StateMachine) transact(goCtx context.Context, fn func(context.Context) error) error {
+	if goCtx == nil {
+		goCtx = context.Background()
+	}
+	if s.transactor == nil || TxFromContext(goCtx) != nil {
+		return fn(goCtx)
+	}
+	return s.transactor(goCtx, func(tx interface{}) error {
+		return fn(context.WithValue(goCtx, txKey{}, tx))
+	})
+}
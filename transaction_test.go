@@ -0,0 +1,138 @@
+package fsm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTx records the messages written by the handlers, published only on commit
+type fakeTx struct {
+	outbox []string
+}
+
+type transactions struct {
+	begun     int
+	published []string
+}
+
+func (ts *transactions) run(ctx context.Context, fn func(tx interface{}) error) error {
+	ts.begun++
+	tx := &fakeTx{}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	ts.published = append(ts.published, tx.outbox...)
+	return nil
+}
+
+func TestWithTransaction(t *testing.T) {
+	ts := &transactions{}
+	emit := func(msg string) fsm.OnHandler {
+		return func(c *fsm.Context) error {
+			tx := c.Tx().(*fakeTx)
+			tx.outbox = append(tx.outbox, msg)
+			return nil
+		}
+	}
+	sm := fsm.New(fsm.WithTransaction(ts.run))
+	created := sm.AddState("CREATED", fsm.OnExit(emit("created.exit")))
+	paid := sm.AddState("PAID", fsm.OnEnter(emit("paid.enter")), fsm.OnEvent(func(c *fsm.Context) error {
+		if err := emit("paid.event")(c); err != nil {
+			return err
+		}
+		return c.Fire("ship")
+	}))
+	shipped := sm.AddState("SHIPPED", fsm.OnEnter(func(c *fsm.Context) error {
+		if c.Data().(*fsm.Event).Data == "fail" {
+			return errors.New("boom")
+		}
+		return emit("shipped.enter")(c)
+	}))
+	created.AddTransition("pay", paid)
+	paid.AddTransition("ship", shipped)
+	created.AddTransition("fail", shipped)
+
+	smi := sm.FromState(created)
+	require.NoError(t, smi.Fire("pay"))
+	require.Equal(t, shipped, smi.State())
+	// chained transitions run in the same transaction
+	require.Equal(t, 1, ts.begun)
+	require.Equal(t, []string{"created.exit", "paid.enter", "paid.event", "shipped.enter"}, ts.published)
+
+	// a failed Fire rolls back the messages
+	ts.published = nil
+	smi = sm.FromState(created)
+	require.Error(t, smi.Fire("fail"))
+	require.Equal(t, 2, ts.begun)
+	require.Empty(t, ts.published)
+
+	// a machine without an instance also runs in a transaction
+	_, err := sm.Fire(created, "pay")
+	require.NoError(t, err)
+	require.Equal(t, 3, ts.begun)
+}
+
+// txStore checks that it is called in a transaction
+type txStore struct {
+	*fsm.MemoryStore
+}
+
+func (s txStore) Load(ctx context.Context, id string) (fsm.Snapshot, int64, error) {
+	if fsm.TxFromContext(ctx) == nil {
+		return fsm.Snapshot{}, 0, errors.New("load outside of a transaction")
+	}
+	return s.MemoryStore.Load(ctx, id)
+}
+
+func (s txStore) Save(ctx context.Context, id string, snap fsm.Snapshot, expected int64) error {
+	if expected > 0 && fsm.TxFromContext(ctx) == nil {
+		return errors.New("save outside of a transaction")
+	}
+	return s.MemoryStore.Save(ctx, id, snap, expected)
+}
+
+func TestManagerWithTransaction(t *testing.T) {
+	ts := &transactions{}
+	sm := fsm.New(fsm.WithTransaction(ts.run))
+	a := sm.AddState("A")
+	b := sm.AddState("B", fsm.OnEnter(func(c *fsm.Context) error {
+		require.NotNil(t, c.Tx())
+		return nil
+	}))
+	a.AddTransition("go", b)
+
+	m := fsm.NewManager(sm, txStore{fsm.NewMemoryStore()})
+	ctx := context.Background()
+	require.NoError(t, m.Create(ctx, "i1", a))
+	st, err := m.Fire(ctx, "i1", "go")
+	require.NoError(t, err)
+	require.Equal(t, b, st)
+	// the instance joins the transaction of the manager
+	require.Equal(t, 1, ts.begun)
+}
+
+func TestFailedCommitRestoresInstance(t *testing.T) {
+	commitErr := errors.New("commit failed")
+	sm := fsm.New(fsm.WithTransaction(func(ctx context.Context, fn func(tx interface{}) error) error {
+		if err := fn(&fakeTx{}); err != nil {
+			return err
+		}
+		return commitErr
+	}))
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	a.AddTransition(TICK, b)
+
+	smi := sm.FromState(a)
+	require.ErrorIs(t, smi.Fire(TICK), commitErr)
+	require.Equal(t, a, smi.State())
+	require.Empty(t, smi.History())
+
+	_, err := smi.FireAll(TICK)
+	require.ErrorIs(t, err, commitErr)
+	require.Equal(t, a, smi.State())
+}
@@ -0,0 +1,132 @@
+package fsm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// PopulationSource reports how many instances are in a state, usually from a read model
+type PopulationSource interface {
+	Population(ctx context.Context, state string) (int, error)
+}
+
+// TriggerRule fires the event into the target instance when the population of the state goes above the threshold.
+// The rule fires once per crossing, and is rearmed when the population falls back to the threshold or below.
+type TriggerRule struct {
+	State     string
+	Threshold int
+	Target    *StateMachineInstance
+	Event     interface{}
+}
+
+// Triggers watches the state populations and fires the events of the rules whose thresholds are crossed
+type Triggers struct {
+	source  PopulationSource
+	rules   []TriggerRule
+	mu      sync.Mutex
+	crossed []bool
+}
+
+// NewTriggers creates the triggers for the rules, reading the populations from the source
+func NewTriggers(source PopulationSource, rules ...TriggerRule) *Triggers {
+	return &Triggers{
+		source:  source,
+		rules:   rules,
+		crossed: make([]bool, len(rules)),
+	}
+}
+
+// Evaluate checks all the rules, firing the events of the rules that crossed their threshold.
+// All rules are evaluated, and the first error is returned.
+func (t *Triggers) Evaluate(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var first error
+	for i, r := range t.rules {
+		n, err := t.source.Population(ctx, r.State)
+		if err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		above := n > r.Threshold
+		if !above || t.crossed[i] {
+			t.crossed[i] = above
+			continue
+		}
+		if err := r.Target.FireContext(ctx, r.Event); err != nil {
+			// not marked as crossed, so that it is retried in the next evaluation
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		t.crossed[i] = true
+	}
+	return first
+}
+
+// Run evaluates the rules periodically until the context is done.
+// Evaluation errors are passed to onError, if not nil.
+func (t *Triggers) Run(ctx context.Context, every time.Duration, onError func(error)) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := t.Evaluate(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Census is an in memory PopulationSource, counting the instances of the machines it watches
+type Census struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func NewCensus() *Census {
+	return &Census{counts: map[string]int{}}
+}
+
+// Watch counts the transitions of the machine and of all its instances
+func (c *Census) Watch(sm *StateMachine) {
+	sm.AddOnTransition(func(ctx *Context) error {
+		from, to := ctx.FromState(), ctx.ToState()
+		if from == to {
+			return nil
+		}
+		c.mu.Lock()
+		c.counts[from.name]--
+		c.counts[to.name]++
+		c.mu.Unlock()
+		return nil
+	})
+}
+
+// Add counts an instance created in the state
+func (c *Census) Add(state *State) {
+	c.mu.Lock()
+	c.counts[state.name]++
+	c.mu.Unlock()
+}
+
+// Remove discounts an instance discarded in the state
+func (c *Census) Remove(state *State) {
+	c.mu.Lock()
+	c.counts[state.name]--
+	c.mu.Unlock()
+}
+
+func (c *Census) Population(_ context.Context, state string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[state], nil
+}
@@ -0,0 +1,93 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+func ordersAndController() (*fsm.Census, *fsm.StateMachine, *fsm.StateMachine) {
+	census := fsm.NewCensus()
+	orders := fsm.New()
+	census.Watch(orders)
+	pending := orders.AddState("PENDING")
+	failed := orders.AddState("FAILED")
+	pending.AddTransition("fail", failed)
+	failed.AddTransition("retry", pending)
+
+	controller := fsm.New()
+	running := controller.AddState("RUNNING")
+	halted := controller.AddState("HALTED")
+	running.AddTransition("halt_intake", halted)
+	halted.AddTransition("halt_intake", halted)
+	return census, orders, controller
+}
+
+func TestTriggers(t *testing.T) {
+	census, orders, controller := ordersAndController()
+	var halts int
+	controller.AddOnTransition(func(c *fsm.Context) error {
+		halts++
+		return nil
+	})
+	ctrl := controller.FromState(controller.StateByName("RUNNING"))
+
+	triggers := fsm.NewTriggers(census, fsm.TriggerRule{
+		State:     "FAILED",
+		Threshold: 2,
+		Target:    ctrl,
+		Event:     "halt_intake",
+	})
+
+	pending := orders.StateByName("PENDING")
+	var instances []*fsm.StateMachineInstance
+	for i := 0; i < 4; i++ {
+		census.Add(pending)
+		instances = append(instances, orders.FromState(pending))
+	}
+	ctx := context.Background()
+
+	for _, smi := range instances[:2] {
+		require.NoError(t, smi.Fire("fail"))
+	}
+	require.NoError(t, triggers.Evaluate(ctx))
+	require.Equal(t, "RUNNING", ctrl.State().Name())
+
+	require.NoError(t, instances[2].Fire("fail"))
+	require.NoError(t, triggers.Evaluate(ctx))
+	require.Equal(t, "HALTED", ctrl.State().Name())
+	require.Equal(t, 1, halts)
+
+	// fires once per crossing
+	require.NoError(t, instances[3].Fire("fail"))
+	require.NoError(t, triggers.Evaluate(ctx))
+	require.Equal(t, 1, halts)
+
+	// rearmed after falling back
+	require.NoError(t, instances[3].Fire("retry"))
+	require.NoError(t, instances[2].Fire("retry"))
+	require.NoError(t, triggers.Evaluate(ctx))
+	require.NoError(t, instances[2].Fire("fail"))
+	require.NoError(t, triggers.Evaluate(ctx))
+	require.Equal(t, 2, halts)
+}
+
+func TestTriggersRun(t *testing.T) {
+	census, orders, controller := ordersAndController()
+	ctrl := controller.FromState(controller.StateByName("RUNNING"))
+
+	triggers := fsm.NewTriggers(census, fsm.TriggerRule{State: "FAILED", Target: ctrl, Event: "halt_intake"})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go triggers.Run(ctx, time.Millisecond, nil)
+
+	pending := orders.StateByName("PENDING")
+	census.Add(pending)
+	require.NoError(t, orders.FromState(pending).Fire("fail"))
+	require.Eventually(t, func() bool {
+		return ctrl.State().Name() == "HALTED"
+	}, time.Second, time.Millisecond)
+}
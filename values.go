@@ -0,0 +1,42 @@
+package fsm
+
+import "context"
+
+// WithValue attaches the value to the instance, like a tenant ID, a trace ID or a logger,
+// so that every handler can retrieve it with Context.Context().Value, without carrying it in every event.
+// The values of the context given to FireContext take precedence. Values are not part of the Snapshot.
+func (m *StateMachineInstance) WithValue(key, val interface{}) *StateMachineInstance {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	base := m.values
+	if base == nil {
+		base = context.Background()
+	}
+	m.values = context.WithValue(base, key, val)
+	return m
+}
+
+// withValues returns the context with the values of the instance.
+// Must be called while holding the lock.
+func (m *StateMachineInstance) withValues(goCtx context.Context) context.Context {
+	if m.values == nil {
+		return goCtx
+	}
+	if goCtx == nil {
+		return m.values
+	}
+	return valuesContext{Context: goCtx, values: m.values}
+}
+
+// valuesContext is a context falling back to the values of the instance
+type valuesContext struct {
+	context.Context
+	values context.Context
+}
+
+func (c valuesContext) Value(key interface{}) interface{} {
+	if v := c.Context.Value(key); v != nil {
+		return v
+	}
+	return c.values.Value(key)
+}
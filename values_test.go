@@ -0,0 +1,44 @@
+package fsm_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/quintans/fsm"
+	"github.com/stretchr/testify/require"
+)
+
+type tenantKey struct{}
+
+type traceKey struct{}
+
+func TestWithValue(t *testing.T) {
+	sm := fsm.New()
+	a := sm.AddState("A")
+	b := sm.AddState("B")
+	var got []interface{}
+	record := func(c *fsm.Context) error {
+		got = append(got, c.Context().Value(tenantKey{}), c.Context().Value(traceKey{}))
+		return nil
+	}
+	a.AddTransition("go", b)
+	a.AddOnExit(record)
+	b.AddTransition("back", a)
+	b.AddOnExit(record)
+
+	smi := sm.FromState(a).WithValue(tenantKey{}, "acme").WithValue(traceKey{}, "t1")
+	require.NoError(t, smi.Fire("go"))
+	require.Equal(t, []interface{}{"acme", "t1"}, got)
+
+	// the values of the fired context take precedence
+	got = nil
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), traceKey{}, "t2"))
+	defer cancel()
+	require.NoError(t, smi.FireContext(ctx, "back"))
+	require.Equal(t, []interface{}{"acme", "t2"}, got)
+
+	// other instances do not see them
+	got = nil
+	require.NoError(t, sm.FromState(a).Fire("go"))
+	require.Equal(t, []interface{}{nil, nil}, got)
+}